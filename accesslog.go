@@ -0,0 +1,232 @@
+//
+// accesslog.go
+//
+// 요청마다 한 줄씩 접근 로그를 씁니다. Apache/nginx 의 combined 형식 뒤에 처리 시간(ms)을 붙입니다.
+//
+//   127.0.0.1 - alice [16/Oct/2026:08:18:09 +0000] "GET /item/yellow HTTP/1.1" 200 120 "-" "curl/8.0" 0.412 5f2c9a1e0b7d4c3a
+//
+// 마지막 값은 요청 ID(requestid.go)입니다. 같은 요청의 다른 로그 줄을 찾을 때 씁니다.
+//
+// 설정 파일의 "access_log" :
+//
+//   "access_log": {"file": "/var/log/webserver/access.log", "max_size": 100, "max_age": 24, "max_backups": 7,
+//                  "sample": 100}
+//
+// sample 이 N 이면 성공한 응답(400 미만)은 N개 중 하나만 기록합니다. 에러 응답은 모두 기록합니다.
+// 부하 테스트 중에 로그 쓰기가 디스크를 다 차지하지 않게 합니다. 0이나 1이면 모두 기록합니다.
+//
+// 파일이 max_size MB 보다 커지거나 연 지 max_age 시간이 지나면 access.log.20261016-081809 로
+// 이름을 바꾸고 새 파일을 엽니다. 오래된 파일은 max_backups 개만 남깁니다. (0이면 그 조건은 보지 않습니다.)
+//
+// logrotate 를 쓸 때는 max_size, max_age 를 0으로 두고 postrotate 에서 SIGUSR1 을 보내면
+// 서버를 다시 시작하지 않고 같은 이름의 새 파일을 엽니다.
+//
+//   postrotate
+//       kill -USR1 $(cat /run/webserver.pid)
+//   endscript
+//
+// SIGUSR1 이 없는 Windows 같은 플랫폼에서는 다시 열지 않습니다. (accesslog_unix.go, accesslog_other.go)
+//
+// /healthz 같은 probe(health.go)는 기록하지 않습니다.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 접근 로그 설정
+type AccessLogConfig struct {
+	File       string `json:"file"`        // 비어 있으면 쓰지 않습니다. "-" 이면 표준 출력
+	MaxSize    int    `json:"max_size"`    // MB
+	MaxAge     int    `json:"max_age"`     // 시간
+	MaxBackups int    `json:"max_backups"` // 남길 옛 파일의 수
+	Sample     int    `json:"sample"`      // 성공한 응답은 이 수마다 하나만 기록합니다.
+}
+
+// 크기나 나이가 넘으면 스스로 바꾸고, Reopen 으로 다시 열 수 있는 로그 파일
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// config의 파일을 엽니다. (없으면 만듭니다)
+func OpenRotatingFile(config AccessLogConfig) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:       config.File,
+		maxSize:    int64(config.MaxSize) << 20,
+		maxAge:     time.Duration(config.MaxAge) * time.Hour,
+		maxBackups: config.MaxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// mu를 잡은 상태에서 불러야 합니다.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			log.Printf("access log rotate: %v", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// p를 쓰기 전에 파일을 바꿔야 하는지
+func (f *RotatingFile) due(n int64) bool {
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		return true
+	}
+	return f.maxAge > 0 && time.Since(f.opened) >= f.maxAge
+}
+
+// 지금 파일의 이름을 바꾸고 새 파일을 엽니다. mu를 잡은 상태에서 불러야 합니다.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	backup := f.path + "." + time.Now().Format("20060102-150405")
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// max_backups 개보다 많은 옛 파일은 오래된 것부터 지웁니다.
+func (f *RotatingFile) prune() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil || len(backups) <= f.maxBackups {
+		return
+	}
+	// 이름에 붙은 시간이 정렬 순서와 같습니다.
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-f.maxBackups] {
+		if err := os.Remove(name); err != nil {
+			log.Printf("access log prune: %v", err)
+		}
+	}
+}
+
+// 파일을 닫고 같은 이름으로 다시 엽니다. logrotate 가 파일을 옮긴 뒤에 부릅니다.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.file.Close()
+	return f.open()
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// config대로 접근 로그를 열고 next를 감쌉니다. 파일이 설정되어 있지 않으면 next를 그대로 돌려줍니다.
+// 돌려주는 close는 서버가 끝난 뒤에 부릅니다.
+func AccessLog(config AccessLogConfig, next http.Handler) (handler http.Handler, close func() error, err error) {
+	switch config.File {
+	case "":
+		return next, func() error { return nil }, nil
+	case "-":
+		return AccessLogHandler(os.Stdout, config.Sample, next), func() error { return nil }, nil
+	}
+	f, err := OpenRotatingFile(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	ReopenOnSignal(f)
+	return AccessLogHandler(f, config.Sample, next), f.Close, nil
+}
+
+// 요청마다 w에 한 줄씩 씁니다. sample 이 1보다 크면 성공한 응답은 sample 개 중 하나만 씁니다.
+func AccessLogHandler(w io.Writer, sample int, next http.Handler) http.Handler {
+	var successes uint64 // atomic
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if isProbe(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		start := time.Now()
+		recorder := newResponseRecorder(response)
+		next.ServeHTTP(recorder, request)
+		status := recorder.Status()
+		if sample > 1 && status < 400 && (atomic.AddUint64(&successes, 1)-1)%uint64(sample) != 0 {
+			return
+		}
+		fmt.Fprintln(w, accessLogLine(request, status, recorder.size, start, time.Since(start)))
+	})
+}
+
+// combined 형식의 한 줄과 처리 시간(ms), 요청 ID
+func accessLogLine(request *http.Request, status int, size int64, start time.Time, duration time.Duration) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	user := "-"
+	if name, _, ok := request.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	id := RequestID(request.Context())
+	if id == "" {
+		id = "-"
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %d %s %s %.3f %s",
+		host, user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		logQuote(request.Method+" "+request.RequestURI+" "+request.Proto),
+		status, size, logQuote(request.Referer()), logQuote(request.UserAgent()),
+		float64(duration)/float64(time.Millisecond), id)
+}
+
+// 따옴표로 감쌉니다. 비어 있으면 "-", 줄을 깨뜨릴 수 있는 글자는 이스케이프합니다.
+func logQuote(s string) string {
+	if s == "" {
+		s = "-"
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+//
+// accesslog_other.go
+//
+// SIGUSR1 이 없는 플랫폼에서는 로그 파일을 신호로 다시 열지 않습니다. (accesslog.go)
+// 이름을 바꾸는 것은 max_size, max_age 로 합니다.
+
+package main
+
+// 아무것도 하지 않습니다.
+func ReopenOnSignal(f *RotatingFile) {}
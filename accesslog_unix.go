@@ -0,0 +1,30 @@
+//go:build unix
+
+//
+// accesslog_unix.go
+//
+// logrotate 가 보내는 SIGUSR1 에 로그 파일을 다시 엽니다. (accesslog.go)
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SIGUSR1 을 받을 때마다 f를 다시 엽니다.
+func ReopenOnSignal(f *RotatingFile) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			if err := f.Reopen(); err != nil {
+				log.Printf("access log reopen: %v", err)
+			} else {
+				log.Printf("access log reopened: %s", f.path)
+			}
+		}
+	}()
+}
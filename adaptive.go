@@ -0,0 +1,246 @@
+//
+// adaptive.go
+//
+// 동시에 처리하는 요청 수를 응답 시간을 보고 스스로 정합니다. (gradient 방식의 적응형 동시성 제한)
+// 고정된 수로 막으면 너무 작으면 처리량을 버리고 너무 크면 과부하 때 모든 요청이 느려지는데,
+// 이 제한은 응답 시간이 평소보다 길어지면 줄고, 평소와 같으면 조금씩 늘어납니다.
+//
+//   "concurrency": {"enabled": true, "initial": 20, "min": 5, "max": 1000, "tolerance": 2,
+//                   "window": 1000, "exempt": ["/items/changes"]}
+//
+//   window ms 마다 그동안 끝난 요청들의 평균 응답 시간(latency)을 재고,
+//
+//     baseline  = 지금까지 가장 빨랐던 평균. 천천히(1%씩) latency 쪽으로 따라가서 부하가 바뀌어도 맞춰집니다.
+//     gradient  = baseline × tolerance / latency    (0.5 ~ 1 사이)
+//     새 limit  = limit × gradient + √limit           (√limit 은 늘어날 여유)
+//
+//   를 조금씩(20%) 반영합니다. latency 가 baseline 의 tolerance 배 안이면 limit 은 늘어나고,
+//   넘으면 그 비율만큼 줄어듭니다. 그동안 limit 의 절반도 쓰지 않았으면 늘리지 않습니다.
+//
+// limit 만큼 처리 중일 때 온 요청은 기다리지 않고 503 과 Retry-After: 1 로 거절합니다.
+// 헬스 체크와 exempt 로 시작하는 주소는 세지 않습니다. WebSocket 과 SSE 처럼 오래 열려 있는 요청은
+// 요청 헤더(Upgrade, Accept)만 보고 빼 주면 누구나 제한을 피할 수 있으므로 처음에는 세고,
+// 핸들러가 연결을 가져가거나(Hijack) text/event-stream 으로 응답하기 시작하면 그때 자리를 내놓습니다.
+// 지금의 limit 과 응답 시간은 /debug/vars 의 "concurrency" 로 볼 수 있습니다. (debug.go)
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 적응형 동시성 제한 설정
+type ConcurrencyConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Initial   int      `json:"initial"`   // 처음 limit
+	Min       int      `json:"min"`       // limit 이 이보다 작아지지 않습니다.
+	Max       int      `json:"max"`       // limit 이 이보다 커지지 않습니다.
+	Tolerance float64  `json:"tolerance"` // baseline 의 몇 배까지를 평소의 응답 시간으로 볼지
+	Window    int      `json:"window"`    // limit 을 다시 정하는 간격(ms)
+	Exempt    []string `json:"exempt"`    // 세지 않는 주소들의 앞부분
+}
+
+// /debug/vars 의 "concurrency"
+type ConcurrencyStatus struct {
+	Limit    int     `json:"limit"`
+	InFlight int     `json:"in_flight"`
+	Baseline float64 `json:"baseline_ms"`
+	Latency  float64 `json:"latency_ms"` // 지난 window 의 평균
+	Rejected int64   `json:"rejected"`
+}
+
+// 응답 시간을 보고 동시에 처리할 요청 수를 정합니다.
+type AdaptiveLimiter struct {
+	config ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	rejected int64
+	baseline time.Duration
+	latency  time.Duration
+
+	// 지금 window 의 값들
+	windowStart time.Time
+	total       time.Duration
+	count       int
+	peak        int // 가장 많았던 in-flight
+}
+
+// 서버 전체의 제한. 꺼져 있으면 nil 입니다.
+var concurrencyLimiter *AdaptiveLimiter
+
+// config로 제한을 만듭니다. 꺼져 있으면 nil을 돌려줍니다.
+func NewAdaptiveLimiter(config ConcurrencyConfig) (*AdaptiveLimiter, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if config.Min < 1 || config.Max < config.Min || config.Initial < config.Min || config.Initial > config.Max {
+		return nil, fmt.Errorf("concurrency: need 1 <= min (%d) <= initial (%d) <= max (%d)", config.Min, config.Initial, config.Max)
+	}
+	if config.Tolerance < 1 || config.Window <= 0 {
+		return nil, fmt.Errorf("concurrency: tolerance %v must be >= 1 and window %d positive", config.Tolerance, config.Window)
+	}
+	return &AdaptiveLimiter{config: config, limit: float64(config.Initial), windowStart: time.Now()}, nil
+}
+
+// 요청 하나를 시작할 수 있으면 true. 시작했으면 끝날 때 done을 불러야 합니다.
+func (l *AdaptiveLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		l.rejected++
+		return false
+	}
+	l.inFlight++
+	l.peak = max(l.peak, l.inFlight)
+	return true
+}
+
+// 요청 하나가 took 만에 끝났습니다.
+func (l *AdaptiveLimiter) done(took time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.total += took
+	l.count++
+	if now := time.Now(); now.Sub(l.windowStart) >= time.Duration(l.config.Window)*time.Millisecond {
+		l.adjust()
+		l.windowStart, l.total, l.count, l.peak = now, 0, 0, l.inFlight
+	}
+}
+
+// 요청 하나가 오래 열려 있는 연결이 되었습니다. 자리만 내놓고 응답 시간에는 넣지 않습니다.
+func (l *AdaptiveLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+}
+
+// 지난 window 의 응답 시간으로 limit 을 다시 정합니다. mu를 잡은 상태에서 불러야 합니다.
+func (l *AdaptiveLimiter) adjust() {
+	l.latency = l.total / time.Duration(l.count)
+	if l.baseline == 0 || l.latency < l.baseline {
+		l.baseline = l.latency
+	} else {
+		l.baseline += (l.latency - l.baseline) / 100
+	}
+	gradient := 1.0
+	if l.latency > 0 {
+		gradient = math.Max(0.5, math.Min(1, l.config.Tolerance*float64(l.baseline)/float64(l.latency)))
+	}
+	next := l.limit*gradient + math.Sqrt(l.limit)
+	if float64(l.peak) < l.limit/2 {
+		// 다 쓰지도 않은 limit 을 늘리면 부하가 갑자기 몰릴 때 막지 못합니다.
+		next = math.Min(next, l.limit)
+	}
+	l.limit = l.limit*0.8 + next*0.2
+	l.limit = math.Max(float64(l.config.Min), math.Min(float64(l.config.Max), l.limit))
+}
+
+// 지금의 상태. 꺼져 있으면(nil) 빈 상태입니다.
+func (l *AdaptiveLimiter) Status() ConcurrencyStatus {
+	if l == nil {
+		return ConcurrencyStatus{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ConcurrencyStatus{
+		Limit:    int(l.limit),
+		InFlight: l.inFlight,
+		Baseline: milliseconds(l.baseline),
+		Latency:  milliseconds(l.latency),
+		Rejected: l.rejected,
+	}
+}
+
+// 세지 않는 요청인지. 오래 열려 있는 요청은 응답 시간을 흐리고 자리를 차지합니다.
+func (l *AdaptiveLimiter) exempt(request *http.Request) bool {
+	if isProbe(request) {
+		return true
+	}
+	for _, prefix := range l.config.Exempt {
+		if strings.HasPrefix(request.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 동시에 처리하는 요청 수를 limiter의 limit 으로 제한합니다. limiter가 nil이면 next를 그대로 돌려줍니다.
+func ConcurrencyLimitHandler(limiter *AdaptiveLimiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if limiter.exempt(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		if !limiter.acquire() {
+			response.Header().Set("Retry-After", "1")
+			WriteError(response, request, http.StatusServiceUnavailable, fmt.Errorf("concurrency: limit %d reached", limiter.Status().Limit))
+			return
+		}
+		start := time.Now()
+		writer := &limitedWriter{ResponseWriter: response, limiter: limiter}
+		defer func() {
+			if !writer.released {
+				limiter.done(time.Since(start))
+			}
+		}()
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// 연결을 가져가거나 SSE 로 응답하기 시작하면 limiter의 자리를 내놓는 ResponseWriter
+type limitedWriter struct {
+	http.ResponseWriter
+	limiter  *AdaptiveLimiter
+	released bool
+}
+
+// SSE 응답이면 자리를 내놓습니다.
+func (w *limitedWriter) releaseEventStream() {
+	if !w.released && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.released = true
+		w.limiter.release()
+	}
+}
+
+func (w *limitedWriter) WriteHeader(status int) {
+	if status >= 200 {
+		w.releaseEventStream()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.releaseEventStream()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *limitedWriter) Flush() {
+	w.releaseEventStream()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *limitedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && !w.released {
+		w.released = true
+		w.limiter.release()
+	}
+	return conn, rw, err
+}
+
+func (w *limitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
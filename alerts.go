@@ -0,0 +1,173 @@
+//
+// alerts.go
+//
+// route별 5xx 응답의 비율을 최근 window 초 동안 세고, threshold 를 넘으면 알림을 보냅니다.
+// 알림은 webhook(JSON POST) 이나 명령어로 보낼 수 있고, 보낼지 말지 정한 것은 로그에 남깁니다.
+//
+//   "alerts": {"enabled": true, "window": 60, "threshold": 0.1, "min_requests": 20, "cooldown": 300,
+//              "webhook": "https://hooks.example.com/alert", "command": ["/usr/local/bin/page-oncall"]}
+//
+// 보내는 내용 (명령어는 표준 입력으로 받습니다) :
+//
+//   {"route":"/upload","errors":5,"requests":20,"rate":0.25,"window_seconds":60,"state":"firing","time":"..."}
+//
+// 같은 route의 알림은 cooldown 초에 한 번만 보냅니다. 비율이 threshold 아래로 내려가면
+// "resolved" 를 한 번 보냅니다.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// 5xx 알림 설정
+type AlertConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Window      int      `json:"window"`       // 비율을 세는 기간(초)
+	Threshold   float64  `json:"threshold"`    // 이 비율 이상이면 알림 (0~1)
+	MinRequests int      `json:"min_requests"` // 기간 안의 요청이 이보다 적으면 알리지 않습니다.
+	Cooldown    int      `json:"cooldown"`     // 같은 route의 알림 사이의 최소 간격(초)
+	Webhook     string   `json:"webhook"`
+	Command     []string `json:"command"`
+}
+
+// 알림 하나의 내용
+type Alert struct {
+	Route         string    `json:"route"`
+	Errors        int       `json:"errors"`
+	Requests      int       `json:"requests"`
+	Rate          float64   `json:"rate"`
+	WindowSeconds int       `json:"window_seconds"`
+	State         string    `json:"state"` // "firing" 또는 "resolved"
+	Time          time.Time `json:"time"`
+}
+
+// 1초 동안의 요청 수와 5xx 수
+type errorBucket struct {
+	second   int64
+	requests int
+	errors   int
+}
+
+// route 하나의 최근 window 초. 초마다 칸 하나를 돌려 씁니다.
+type errorWindow struct {
+	buckets []errorBucket
+	firing  bool
+	alerted time.Time // 마지막으로 firing 알림을 보낸 시간
+}
+
+// 최근 window 초 안의 요청 수와 5xx 수
+func (w *errorWindow) totals(now int64) (requests, errors int) {
+	for _, b := range w.buckets {
+		if now-b.second < int64(len(w.buckets)) {
+			requests += b.requests
+			errors += b.errors
+		}
+	}
+	return requests, errors
+}
+
+// route별 5xx 비율을 보고 알림을 보냅니다.
+type ErrorAlerts struct {
+	config AlertConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	routes map[string]*errorWindow
+}
+
+// config로 알림을 만듭니다. 꺼져 있으면 nil
+func NewErrorAlerts(config AlertConfig) *ErrorAlerts {
+	if !config.Enabled {
+		return nil
+	}
+	if config.Window <= 0 {
+		config.Window = 60
+	}
+	return &ErrorAlerts{config: config, client: &http.Client{Timeout: 10 * time.Second}, routes: make(map[string]*errorWindow)}
+}
+
+// 끝난 요청 하나를 기록하고, 필요하면 알림을 보냅니다.
+func (a *ErrorAlerts) Observe(route string, status int) {
+	if a == nil {
+		return
+	}
+	now := time.Now()
+	second := now.Unix()
+
+	a.mu.Lock()
+	w := a.routes[route]
+	if w == nil {
+		w = &errorWindow{buckets: make([]errorBucket, a.config.Window)}
+		a.routes[route] = w
+	}
+	b := &w.buckets[second%int64(len(w.buckets))]
+	if b.second != second {
+		*b = errorBucket{second: second}
+	}
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+	requests, errors := w.totals(second)
+	rate := float64(errors) / float64(requests)
+	alert := Alert{Route: route, Errors: errors, Requests: requests, Rate: rate, WindowSeconds: a.config.Window, Time: now}
+
+	switch {
+	case requests >= a.config.MinRequests && rate >= a.config.Threshold:
+		if !w.firing || now.Sub(w.alerted) >= time.Duration(a.config.Cooldown)*time.Second {
+			w.firing, w.alerted, alert.State = true, now, "firing"
+		}
+	case w.firing && rate < a.config.Threshold:
+		w.firing, alert.State = false, "resolved"
+	}
+	a.mu.Unlock()
+
+	if alert.State != "" {
+		log.Printf("alert %s: route %s 5xx %d/%d (%.1f%%) over %ds", alert.State, route, errors, requests, rate*100, a.config.Window)
+		go a.send(alert)
+	}
+}
+
+// webhook과 명령어로 알림을 보냅니다. 실패는 로그에만 남깁니다.
+func (a *ErrorAlerts) send(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("alert: %v", err)
+		return
+	}
+	if a.config.Webhook != "" {
+		if err := a.post(body); err != nil {
+			log.Printf("alert webhook: %v", err)
+		}
+	}
+	if len(a.config.Command) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, a.config.Command[0], a.config.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("alert command: %v %s", err, bytes.TrimSpace(output))
+		}
+	}
+}
+
+func (a *ErrorAlerts) post(body []byte) error {
+	response, err := a.client.Post(a.config.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
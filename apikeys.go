@@ -0,0 +1,49 @@
+//
+// apikeys.go
+//
+// API 키로 요청한 사람(owner)을 알아냅니다.
+//
+//   $ curl -H 'X-API-Key: s3cret' -F file=@cat.png http://localhost:8080/upload
+//
+// 키와 이름은 설정 파일의 "api_keys": {"s3cret": "alice"} 나 비밀 키 파일(secrets.go)에 적습니다.
+// 한 사람이 키를 여러 개 가질 수 있으므로, 새 키를 넣고 옛 키를 나중에 지우면 키를 바꿀 수 있습니다.
+// 키가 없거나 모르는 키인 요청은 클라이언트 IP 주소로 구분합니다. ("ip:127.0.0.1")
+// 믿는 프록시(trusted_proxies) 뒤에서는 프록시가 알려준 클라이언트의 주소입니다. (clientIP, headers.go)
+// HMAC 서명(requestsign.go)이 맞는 요청은 서명한 클라이언트의 이름입니다.
+
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// API 키 -> 이름. main에서 설정과 비밀 키 파일(secrets.go)로 채웁니다.
+var apiKeys map[string]string
+
+// 요청한 사람의 이름. 올바른 API 키가 있으면 키의 이름, 없으면 "ip:" + 클라이언트 IP
+func RequestOwner(request *http.Request) string {
+	owner, rejected := requestOwner(request)
+	if rejected {
+		audit.Record(request, "apikey.rejected", "", nil)
+	}
+	return owner
+}
+
+// RequestOwner 와 같지만 감사 로그를 남기지 않습니다. rejected는 모르는 키가 왔을 때 true
+// (사용량 집계(usage.go)처럼 모든 요청에서 부를 때 씁니다.)
+func requestOwner(request *http.Request) (owner string, rejected bool) {
+	if client := SignedClient(request); client != "" {
+		return client, false
+	}
+	if key := request.Header.Get("X-API-Key"); key != "" {
+		for k, name := range currentAPIKeys() {
+			// 키를 한 글자씩 맞춰 보는 시간 차 공격을 막습니다.
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				return name, false
+			}
+		}
+		rejected = true
+	}
+	return "ip:" + clientIP(request), rejected
+}
@@ -0,0 +1,103 @@
+//
+// audit.go
+//
+// 보안과 관련된 일(인증 실패, 거부된 API 키, 관리자 작업 등)을 따로 모아 두는 감사 로그입니다.
+// 한 줄에 JSON 하나씩, 파일 끝에만 덧붙입니다. (O_APPEND, 지우거나 고치지 않습니다)
+//
+//   {"time":"2026-10-16T08:20:24Z","event":"auth.failure","ip":"10.0.0.7","user":"ops","method":"GET","path":"/metrics","detail":{"realm":"metrics"}}
+//
+// ip 는 clientIP(headers.go)로 읽으므로 믿는 프록시(trusted_proxies) 뒤에서도 실제 클라이언트의 주소입니다.
+// 설정 파일의 "audit_log" 에 파일 경로를 줍니다. 비어 있으면 기록하지 않습니다.
+//
+// 기록하는 일들
+//
+//   auth.failure         Basic 인증의 이름이나 비밀번호가 틀림 (guard.go)
+//   auth.denied_ip       허용되지 않은 주소에서 온 요청 (guard.go)
+//   apikey.rejected      등록되지 않은 X-API-Key (apikeys.go)
+//   signed_url.rejected  서명이 틀렸거나 만료된 다운로드 주소 (signedurl.go)
+//   admin.access         /debug/ 아래의 관리용 주소를 씀 (debug.go)
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 감사 로그의 한 줄
+type AuditEvent struct {
+	Time      time.Time         `json:"time"`
+	Event     string            `json:"event"`
+	RequestID string            `json:"request_id,omitempty"`
+	IP        string            `json:"ip"`
+	User      string            `json:"user,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Detail    map[string]string `json:"detail,omitempty"`
+}
+
+// 덧붙이기만 하는 감사 로그 파일
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// 서버 전체가 쓰는 감사 로그. nil이면 기록하지 않습니다. main에서 엽니다.
+var audit *AuditLog
+
+// path의 감사 로그를 엽니다. (없으면 만듭니다)
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// request에서 일어난 event를 기록합니다. user는 알 수 없으면 비워 둡니다.
+func (a *AuditLog) Record(request *http.Request, event, user string, detail map[string]string) {
+	if a == nil {
+		return
+	}
+	line, err := json.Marshal(AuditEvent{
+		Time:      time.Now().UTC(),
+		Event:     event,
+		RequestID: RequestID(request.Context()),
+		IP:        clientIP(request),
+		User:      user,
+		Method:    request.Method,
+		Path:      request.URL.Path,
+		Detail:    detail,
+	})
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// next로 들어오는 요청마다 event를 기록합니다. 관리용 주소에 씁니다.
+func AuditHandler(event string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		user, _, _ := request.BasicAuth()
+		audit.Record(request, event, user, nil)
+		next.ServeHTTP(response, request)
+	})
+}
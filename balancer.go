@@ -0,0 +1,219 @@
+//
+// balancer.go
+//
+// 프록시 주소(proxy.go) 하나에 upstream 을 여러 개 두고 요청을 나눠 보냅니다.
+// 주기적으로 health 주소를 불러 보고 응답하지 않는 upstream 은 빼 두었다가 살아나면 다시 넣습니다.
+//
+//   "proxy": [{"path": "/proxy/api/", "upstreams": ["http://10.0.0.1:9000", "http://10.0.0.2:9000"],
+//              "balance": "least_conn",
+//              "health_check": {"path": "/healthz", "interval": 5, "timeout": 2, "unhealthy": 3, "healthy": 2}}]
+//
+//   balance       "round_robin"(기본) 은 돌아가며, "least_conn" 은 지금 처리 중인 요청이 가장 적은 곳으로
+//   health_check  interval 초마다 GET path 를 보내 2xx, 3xx 가 아니거나 timeout 초 안에 답하지 않으면 실패.
+//                 unhealthy 번 연속 실패하면 빼고, 빠진 것은 healthy 번 연속 성공하면 다시 넣습니다.
+//                 path 가 비어 있으면 health check 를 하지 않고 모두 살아 있다고 봅니다.
+//
+// 살아 있는 upstream 이 하나도 없으면 503 Service Unavailable 과 Retry-After 를 돌려줍니다.
+// 빠지고 다시 들어올 때 로그를 남기고, 상태는 /debug/vars 의 "proxy" 로 볼 수 있습니다. (debug.go)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// upstream 들의 health check 설정
+type ProxyHealthCheck struct {
+	Path      string `json:"path"`      // 비어 있으면 health check 를 하지 않습니다.
+	Interval  int    `json:"interval"`  // 초. 0이면 10
+	Timeout   int    `json:"timeout"`   // 초. 0이면 2
+	Unhealthy int    `json:"unhealthy"` // 이만큼 연속 실패하면 뺍니다. 0이면 3
+	Healthy   int    `json:"healthy"`   // 빠진 것이 이만큼 연속 성공하면 다시 넣습니다. 0이면 2
+}
+
+// /debug/vars 의 "proxy" 에 보이는 upstream 하나의 상태
+type UpstreamStatus struct {
+	URL      string `json:"url"`
+	Healthy  bool   `json:"healthy"`
+	Active   int64  `json:"active"`   // 지금 처리 중인 요청 수
+	Requests int64  `json:"requests"` // 지금까지 보낸 요청 수
+}
+
+// upstream 하나
+type upstreamBackend struct {
+	url *url.URL
+
+	healthy  atomic.Bool
+	active   atomic.Int64
+	requests atomic.Int64
+	// health check 고루틴만 바꿉니다.
+	fails, oks int
+}
+
+// 한 프록시 주소의 upstream 들
+type UpstreamPool struct {
+	backends []*upstreamBackend
+	balance  string
+	next     atomic.Uint64 // round_robin 의 다음 차례
+}
+
+// 서버의 프록시 주소별 upstream 들. 시작할 때 RegisterProxies 가 채웁니다.
+var upstreamPools = map[string]*UpstreamPool{}
+
+// upstreams로 pool을 만듭니다. health check 는 StartHealthCheck 로 따로 시작합니다.
+func NewUpstreamPool(upstreams []string, balance string) (*UpstreamPool, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream")
+	}
+	switch balance {
+	case "":
+		balance = "round_robin"
+	case "round_robin", "least_conn":
+	default:
+		return nil, fmt.Errorf("unknown balance %q (round_robin, least_conn)", balance)
+	}
+	pool := &UpstreamPool{balance: balance}
+	for _, upstream := range upstreams {
+		u, err := url.Parse(upstream)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid upstream %q", upstream)
+		}
+		backend := &upstreamBackend{url: u}
+		backend.healthy.Store(true)
+		pool.backends = append(pool.backends, backend)
+	}
+	return pool, nil
+}
+
+// 요청을 보낼 살아 있는 upstream 을 고릅니다. 모두 빠져 있으면 nil 입니다.
+func (p *UpstreamPool) pick() *upstreamBackend {
+	n := len(p.backends)
+	if p.balance == "least_conn" {
+		// 같으면 round_robin 차례에서 가까운 것을 골라 한쪽으로 몰리지 않게 합니다.
+		start := int(p.next.Add(1) % uint64(n))
+		var best *upstreamBackend
+		for i := 0; i < n; i++ {
+			backend := p.backends[(start+i)%n]
+			if backend.healthy.Load() && (best == nil || backend.active.Load() < best.active.Load()) {
+				best = backend
+			}
+		}
+		return best
+	}
+	for i := 0; i < n; i++ {
+		backend := p.backends[int(p.next.Add(1)%uint64(n))]
+		if backend.healthy.Load() {
+			return backend
+		}
+	}
+	return nil
+}
+
+// host가 pool의 upstream 중 하나인지
+func (p *UpstreamPool) hasHost(host string) bool {
+	for _, backend := range p.backends {
+		if backend.url.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// interval 마다 모든 upstream 의 health 주소를 불러 봅니다. path 가 비어 있으면 아무것도 하지 않습니다.
+func (p *UpstreamPool) StartHealthCheck(name string, config ProxyHealthCheck) {
+	if config.Path == "" {
+		return
+	}
+	if config.Interval <= 0 {
+		config.Interval = 10
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2
+	}
+	if config.Unhealthy <= 0 {
+		config.Unhealthy = 3
+	}
+	if config.Healthy <= 0 {
+		config.Healthy = 2
+	}
+	client := &http.Client{
+		Timeout: time.Duration(config.Timeout) * time.Second,
+		// 리다이렉트도 응답한 것이므로 따라가지 않습니다.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	go func() {
+		for range time.Tick(time.Duration(config.Interval) * time.Second) {
+			for _, backend := range p.backends {
+				p.check(name, client, backend, config)
+			}
+		}
+	}()
+}
+
+// backend 하나의 health 주소를 부르고 결과에 따라 빼거나 다시 넣습니다.
+func (p *UpstreamPool) check(name string, client *http.Client, backend *upstreamBackend, config ProxyHealthCheck) {
+	target := *backend.url
+	target.Path = singleJoin(target.Path, config.Path)
+	target.RawQuery = ""
+	err := probeUpstream(client, target.String())
+	if err != nil {
+		backend.oks = 0
+		backend.fails++
+		if backend.fails >= config.Unhealthy && backend.healthy.CompareAndSwap(true, false) {
+			log.Printf("WARN proxy %s: %s is unhealthy, removed (%v)", name, backend.url, err)
+		}
+		return
+	}
+	backend.fails = 0
+	backend.oks++
+	if backend.oks >= config.Healthy && backend.healthy.CompareAndSwap(false, true) {
+		log.Printf("proxy %s: %s is healthy again", name, backend.url)
+	}
+}
+
+// target에 GET 을 보내 2xx, 3xx 로 답하는지 봅니다.
+func probeUpstream(client *http.Client, target string) error {
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", "go-webserver-healthcheck")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// upstream 들의 지금 상태
+func (p *UpstreamPool) Status() []UpstreamStatus {
+	statuses := make([]UpstreamStatus, 0, len(p.backends))
+	for _, backend := range p.backends {
+		statuses = append(statuses, UpstreamStatus{
+			URL:      backend.url.String(),
+			Healthy:  backend.healthy.Load(),
+			Active:   backend.active.Load(),
+			Requests: backend.requests.Load(),
+		})
+	}
+	return statuses
+}
+
+// 프록시 주소별 upstream 상태. /debug/vars 의 "proxy"
+func ProxyStatus() map[string][]UpstreamStatus {
+	statuses := make(map[string][]UpstreamStatus, len(upstreamPools))
+	for path, pool := range upstreamPools {
+		statuses[path] = pool.Status()
+	}
+	return statuses
+}
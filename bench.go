@@ -0,0 +1,404 @@
+//
+// bench.go
+//
+// 외부 도구 없이 핸들러의 성능을 재는 부하 테스트 명령입니다.
+// 여러 클라이언트가 동시에 한 주소로 요청을 보내고, 처리량과 응답 시간의 백분위를 보여줍니다.
+//
+//   $ webserver bench -c 50 -n 10000 /item/green                 이 컴퓨터의 서버 (http://localhost:8080)
+//   $ webserver bench -c 20 -d 30s -H 'Accept: text/csv' https://example.com/items
+//   $ webserver bench -m POST -body '{"name":"green"}' -H 'Content-Type: application/json' /items
+//
+//   requests      10000 (errors 0)
+//   status        200 x 10000
+//   time          1.84s  5434.8 req/s  2.1 MB/s
+//   latency       min 0.3ms  p50 8.7ms  p90 14.2ms  p99 25.9ms  max 41.0ms
+//
+// -d 를 주면 -n 대신 그 시간 동안 보냅니다. 응답 본문은 끝까지 읽고 버립니다.
+// 연결은 다시 쓰므로(keep-alive) 새 연결을 만드는 시간은 거의 들어가지 않습니다.
+//
+// 결과를 남기고 비교하기 (perf/run.sh 가 씁니다.)
+//
+//   $ webserver bench -d 10s -json old/items.json -cpuprofile old/items.cpu.pprof /items
+//   $ webserver benchcmp old new                같은 이름의 .json 끼리 비교합니다.
+//                                               둘 다 gobench.txt (go test -bench -benchmem 의 결과)가 있으면 그것도 비교합니다.
+//
+//   name     req/s (old -> new)          p50 ms            p99 ms
+//   items    5434.8 -> 6120.2 +12.6%     8.70 -> 7.90 -9.2%   25.90 -> 21.00 -18.9%
+//
+// -cpuprofile 은 부하를 주는 동안 서버의 /debug/pprof/profile 을, -heapprofile 은 끝난 뒤의 /debug/pprof/heap 을
+// 받아 씁니다. 서버의 "debug": {"pprof": true} 가 켜져 있어야 합니다. (debug.go)
+//
+//   $ go tool pprof -http=: old/items.cpu.pprof
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -H 를 여러 번 받습니다.
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, ":"); !ok {
+		return fmt.Errorf("header %q must be 'Name: value'", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// 클라이언트 하나가 모은 결과
+type benchResult struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    int
+	bytes     int64
+	lastError error
+}
+
+// webserver bench [-c 동시 수] [-n 요청 수 | -d 시간] [-m 메소드] [-H 헤더] [-body 본문] 주소
+func BenchCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	concurrency := flags.Int("c", 10, "동시에 요청을 보내는 클라이언트 수")
+	total := flags.Int("n", 1000, "보낼 요청 수")
+	duration := flags.Duration("d", 0, "이 시간 동안 보냅니다. 주면 -n 은 쓰지 않습니다. 예) 30s")
+	method := flags.String("m", http.MethodGet, "HTTP 메소드")
+	body := flags.String("body", "", "요청 본문")
+	timeout := flags.Duration("timeout", 10*time.Second, "요청 하나의 제한 시간")
+	jsonOut := flags.String("json", "", "결과를 JSON으로 쓸 파일 (benchcmp 로 비교합니다)")
+	cpuProfile := flags.String("cpuprofile", "", "부하를 주는 동안의 서버 CPU 프로파일을 쓸 파일. -d 가 있어야 합니다.")
+	heapProfile := flags.String("heapprofile", "", "끝난 뒤의 서버 heap 프로파일을 쓸 파일")
+	var headers headerFlags
+	flags.Var(&headers, "H", "요청 헤더. 여러 번 줄 수 있습니다. 예) -H 'Accept: text/csv'")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *concurrency < 1 || (*duration <= 0 && *total < 1) {
+		return errors.New("usage: webserver bench [-c 10] [-n 1000 | -d 30s] [-m GET] [-H 'Name: value'] [-body data] url")
+	}
+	target := flags.Arg(0)
+	if strings.HasPrefix(target, "/") {
+		target = "http://localhost:8080" + target
+	}
+	if _, err := http.NewRequest(*method, target, nil); err != nil {
+		return err
+	}
+	if *cpuProfile != "" && *duration < time.Second {
+		return errors.New("-cpuprofile needs -d of at least 1s")
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *concurrency,
+			DisableCompression:  true,
+		},
+	}
+	var sent atomic.Int64
+	var deadline time.Time
+	if *duration > 0 {
+		deadline = time.Now().Add(*duration)
+	}
+	// 다음 요청을 보내도 되는지
+	next := func() bool {
+		if !deadline.IsZero() {
+			return time.Now().Before(deadline)
+		}
+		return sent.Add(1) <= int64(*total)
+	}
+
+	results := make([]benchResult, *concurrency)
+	var wg sync.WaitGroup
+	profiled := make(chan error, 1)
+	if *cpuProfile != "" {
+		seconds := strconv.Itoa(int(duration.Seconds()))
+		go func() { profiled <- fetchProfile(target, "/debug/pprof/profile?seconds="+seconds, *cpuProfile) }()
+	} else {
+		profiled <- nil
+	}
+	started := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(result *benchResult) {
+			defer wg.Done()
+			result.statuses = make(map[int]int)
+			for next() {
+				request, _ := http.NewRequest(*method, target, strings.NewReader(*body))
+				for _, header := range headers {
+					name, value, _ := strings.Cut(header, ":")
+					request.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+				}
+				start := time.Now()
+				response, err := client.Do(request)
+				if err == nil {
+					var n int64
+					n, err = io.Copy(io.Discard, response.Body)
+					response.Body.Close()
+					result.bytes += n
+					result.statuses[response.StatusCode]++
+				}
+				if err != nil {
+					result.errors++
+					result.lastError = err
+					continue
+				}
+				result.latencies = append(result.latencies, time.Since(start))
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	report := summarizeBench(results, time.Since(started))
+	report.Target = target
+	report.Concurrency = *concurrency
+	report.Print(stdout)
+
+	if err := <-profiled; err != nil {
+		return err
+	}
+	if *heapProfile != "" {
+		if err := fetchProfile(target, "/debug/pprof/heap", *heapProfile); err != nil {
+			return err
+		}
+	}
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*jsonOut, append(data, '\n'), 0o644)
+	}
+	return nil
+}
+
+// target 서버의 pprof 주소 path를 받아 file에 씁니다.
+func fetchProfile(target, path, file string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	profileURL := u.Scheme + "://" + u.Host + path
+	response, err := http.Get(profileURL)
+	if err != nil {
+		return fmt.Errorf("profile %s: %v", profileURL, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("profile %s: %s (is \"debug\": {\"pprof\": true} set?)", profileURL, response.Status)
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, response.Body); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// bench 한 번의 결과. -json 으로 쓰고 benchcmp 가 읽습니다.
+type BenchReport struct {
+	Target      string         `json:"target"`
+	Concurrency int            `json:"concurrency"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	LastError   string         `json:"last_error,omitempty"`
+	Statuses    map[string]int `json:"statuses"`
+	Seconds     float64        `json:"seconds"`
+	RPS         float64        `json:"rps"`
+	MBps        float64        `json:"mb_per_sec"`
+	Min         float64        `json:"min_ms"`
+	P50         float64        `json:"p50_ms"`
+	P90         float64        `json:"p90_ms"`
+	P99         float64        `json:"p99_ms"`
+	Max         float64        `json:"max_ms"`
+}
+
+// 모든 클라이언트의 결과를 합칩니다.
+func summarizeBench(results []benchResult, elapsed time.Duration) BenchReport {
+	var latencies []time.Duration
+	report := BenchReport{Statuses: make(map[string]int), Seconds: elapsed.Seconds()}
+	var bytes int64
+	for _, result := range results {
+		latencies = append(latencies, result.latencies...)
+		for status, n := range result.statuses {
+			report.Statuses[strconv.Itoa(status)] += n
+		}
+		report.Errors += result.errors
+		bytes += result.bytes
+		if result.lastError != nil {
+			report.LastError = result.lastError.Error()
+		}
+	}
+	report.Requests = len(latencies) + report.Errors
+	report.RPS = float64(report.Requests) / report.Seconds
+	report.MBps = float64(bytes) / report.Seconds / 1e6
+	if len(latencies) == 0 {
+		return report
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) float64 {
+		return milliseconds(latencies[int(p*float64(len(latencies)-1))])
+	}
+	report.Min, report.P50, report.P90, report.P99, report.Max = percentile(0), percentile(0.5), percentile(0.9), percentile(0.99), percentile(1)
+	return report
+}
+
+// 결과를 사람이 읽기 좋게 씁니다.
+func (r BenchReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests      %d (errors %d)\n", r.Requests, r.Errors)
+	if r.LastError != "" {
+		fmt.Fprintf(w, "last error    %s\n", r.LastError)
+	}
+	var codes []string
+	for status := range r.Statuses {
+		codes = append(codes, status)
+	}
+	sort.Strings(codes)
+	var parts []string
+	for _, status := range codes {
+		parts = append(parts, fmt.Sprintf("%s x %d", status, r.Statuses[status]))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(w, "status        %s\n", strings.Join(parts, ", "))
+	}
+	fmt.Fprintf(w, "time          %.2fs  %.1f req/s  %.1f MB/s\n", r.Seconds, r.RPS, r.MBps)
+	if r.Requests > r.Errors {
+		fmt.Fprintf(w, "latency       min %.1fms  p50 %.1fms  p90 %.1fms  p99 %.1fms  max %.1fms\n", r.Min, r.P50, r.P90, r.P99, r.Max)
+	}
+}
+
+// webserver benchcmp 옛디렉토리 새디렉토리
+// 두 디렉토리에서 이름이 같은 bench -json 결과끼리 처리량과 응답 시간을 비교합니다.
+func BenchCompareCommand(args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: webserver benchcmp old-dir new-dir")
+	}
+	files, err := filepath.Glob(filepath.Join(args[0], "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no bench results (*.json) in %s", args[0])
+	}
+	read := func(file string) (BenchReport, error) {
+		var report BenchReport
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(data, &report)
+		}
+		return report, err
+	}
+	change := func(before, after float64) string {
+		if before == 0 {
+			return fmt.Sprintf("%.2f -> %.2f", before, after)
+		}
+		return fmt.Sprintf("%.2f -> %.2f %+.1f%%", before, after, (after-before)/before*100)
+	}
+	fmt.Fprintf(stdout, "%-16s %-32s %-28s %s\n", "name", "req/s (old -> new)", "p50 ms", "p99 ms")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		before, err := read(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		after, err := read(filepath.Join(args[1], filepath.Base(file)))
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(stdout, "%-16s (not in %s)\n", name, args[1])
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%-16s %-32s %-28s %s\n", name, change(before.RPS, after.RPS), change(before.P50, after.P50), change(before.P99, after.P99))
+		if after.Errors > before.Errors {
+			fmt.Fprintf(stdout, "%-16s errors %d -> %d\n", "", before.Errors, after.Errors)
+		}
+	}
+	return compareGoBench(args[0], args[1], stdout, change)
+}
+
+// go test -bench -benchmem 의 결과를 남기는 파일 이름 (perf/run.sh, perf_test.go)
+const goBenchFile = "gobench.txt"
+
+// go test 벤치마크 결과 한 줄. 단위 -> 값 ("ns/op", "B/op", "allocs/op")
+type goBenchResult map[string]float64
+
+// go test -bench 의 출력에서 벤치마크 이름 순서와 결과들을 읽습니다.
+// 이름 뒤의 -GOMAXPROCS 는 떼어 냅니다. 같은 이름이 여러 번 있으면 마지막 것을 씁니다.
+func readGoBench(file string) ([]string, map[string]goBenchResult, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	results := make(map[string]goBenchResult)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		result := make(goBenchResult)
+		for i := 2; i+1 < len(fields); i += 2 {
+			if value, err := strconv.ParseFloat(fields[i], 64); err == nil {
+				result[fields[i+1]] = value
+			}
+		}
+		if _, ok := results[name]; !ok {
+			names = append(names, name)
+		}
+		results[name] = result
+	}
+	return names, results, nil
+}
+
+// 두 디렉토리의 gobench.txt 를 같은 이름의 벤치마크끼리 비교합니다. 어느 한 쪽에 없으면 넘어갑니다.
+func compareGoBench(oldDir, newDir string, stdout io.Writer, change func(before, after float64) string) error {
+	names, before, err := readGoBench(filepath.Join(oldDir, goBenchFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, after, err := readGoBench(filepath.Join(newDir, goBenchFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "\n%-28s %-32s %-28s %s\n", "benchmark", "ns/op (old -> new)", "B/op", "allocs/op")
+	for _, name := range names {
+		b, a := before[name], after[name]
+		if a == nil {
+			fmt.Fprintf(stdout, "%-28s (not in %s)\n", name, newDir)
+			continue
+		}
+		fmt.Fprintf(stdout, "%-28s %-32s %-28s %s\n", name, change(b["ns/op"], a["ns/op"]), change(b["B/op"], a["B/op"]), change(b["allocs/op"], a["allocs/op"]))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func BenchmarkWriteJSON(b *testing.B) {
+	items := NewItemStore(nil, defaultItems...).List()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WriteJSON(httptest.NewRecorder(), http.StatusOK, items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderTemplate(b *testing.B) {
+	mustLoadTemplates(b)
+	request := httptest.NewRequest(http.MethodGet, "/item/green", nil)
+	item := Item{Name: "green", What: "item"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder := httptest.NewRecorder()
+		RenderTemplate(recorder, request, "item", item)
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("status %d", recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//
+// buildinfo.go
+//
+// 서버의 버전과 켜진 기능들을 /debug/buildinfo 에서 JSON으로 보여줍니다.
+// 여러 대의 서버가 무엇으로 돌고 있는지 모을 때 씁니다.
+//
+// 버전과 커밋은 빌드할 때 넣습니다. 넣지 않으면 버전은 "dev", 커밋은 go 가 기록한 VCS 정보입니다.
+//
+//   $ go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse HEAD)"
+//
+//   $ curl localhost:8080/debug/buildinfo
+//   {"version":"1.4.0","commit":"798e29b...","go_version":"go1.22.0","uptime_seconds":42,"features":["compression","metrics"]}
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// 빌드할 때 -ldflags "-X ..." 로 바꿉니다.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// /debug/buildinfo 의 응답 본문
+type BuildInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	Modified  bool      `json:"modified,omitempty"` // 커밋되지 않은 변경이 있는 채로 빌드했는지
+	GoVersion string    `json:"go_version"`
+	Started   time.Time `json:"started"`
+	Uptime    int64     `json:"uptime_seconds"`
+	Features  []string  `json:"features"`
+}
+
+// config에서 켜진 기능들의 이름
+func EnabledFeatures(config Config) []string {
+	features := []string{}
+	add := func(on bool, name string) {
+		if on {
+			features = append(features, name)
+		}
+	}
+	add(config.Dev, "dev")
+	add(config.Compression.Enabled, "compression")
+	add(config.SPA.Enabled, "spa")
+	add(config.Minify.HTML || config.Minify.CSS || config.Minify.JS, "minify")
+	add(config.Metrics.Enabled, "metrics")
+	add(config.Debug.Expvar, "expvar")
+	add(config.Debug.Pprof, "pprof")
+	add(config.Tracing.Enabled, "tracing")
+	add(config.Alerts.Enabled, "alerts")
+	add(len(config.APIKeys) > 0, "api_keys")
+	add(len(config.RequestSigning.Clients) > 0, "request_signing")
+	add(config.Upload.SignedDownloads, "signed_downloads")
+	add(len(config.Upload.ScanCommand) > 0, "upload_scan")
+	add(config.Upload.Quota > 0, "upload_quota")
+	return features
+}
+
+// GET /debug/buildinfo
+func BuildInfoHandler(config Config) http.Handler {
+	info := BuildInfo{Version: version, Commit: commit, GoVersion: runtime.Version(), Features: EnabledFeatures(config)}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		info := info
+		info.Started = serverStarted
+		info.Uptime = int64(time.Since(serverStarted) / time.Second)
+		SetContentType(response, "application/json")
+		response.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(response).Encode(info)
+	})
+}
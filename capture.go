@@ -0,0 +1,201 @@
+//
+// capture.go
+//
+// 클라이언트 연동 문제를 찾을 때 켜는 디버그 모드입니다. 요청과 응답의 본문을
+// max_size 바이트까지 헤더와 함께 로그에 남깁니다. 기본으로 꺼져 있습니다.
+//
+//   "body_capture": {"enabled": true, "max_size": 4096, "paths": ["/items", "/upload"],
+//                    "redact_headers": ["X-Session"], "redact_fields": ["password", "token"]}
+//
+// 쿠키, Authorization 같은 헤더(recover.go 의 redactedHeaders)와 redact_headers 의 값은 가립니다.
+// JSON 이나 폼 본문에서는 redact_fields 이름의 값만 가립니다.
+//
+//   capture GET /items 200
+//     > Cookie: [REDACTED]
+//     > {"name":"green","password":"[REDACTED]"}
+//     < [{"name":"foo","what":"item"}]
+//
+// 이미지 같은 바이너리 본문은 크기와 형식만 남깁니다. WebSocket과 SSE는 남기지 않습니다.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 본문 기록 설정
+type BodyCaptureConfig struct {
+	Enabled       bool     `json:"enabled"`
+	MaxSize       int      `json:"max_size"`       // 요청과 응답마다 남기는 최대 바이트
+	Paths         []string `json:"paths"`          // 이 주소로 시작하는 요청만 남깁니다. 비어 있으면 모두
+	RedactHeaders []string `json:"redact_headers"` // 값을 가릴 헤더 (기본으로 가리는 것에 더해서)
+	RedactFields  []string `json:"redact_fields"`  // JSON, 폼에서 값을 가릴 이름
+}
+
+// 최대 limit 바이트까지만 모으는 버퍼
+type captureBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+	total     int64 // 버리지 않았다면 모였을 크기
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// 응답 본문을 captureBuffer에도 쓰는 ResponseWriter
+type captureWriter struct {
+	*responseRecorder
+	body *captureBuffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.responseRecorder.Write(p)
+}
+
+// 요청과 응답의 본문을 로그에 남깁니다. 꺼져 있으면 next를 그대로 돌려줍니다.
+func BodyCaptureHandler(config BodyCaptureConfig, next http.Handler) http.Handler {
+	if !config.Enabled {
+		return next
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 4096
+	}
+	redactor := newRedactor(config)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if !capturePath(config.Paths, request.URL.Path) || isProbe(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		requestBody := &captureBuffer{limit: config.MaxSize}
+		if request.Body != nil {
+			request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(request.Body, requestBody), request.Body}
+		}
+		w := &captureWriter{newResponseRecorder(response), &captureBuffer{limit: config.MaxSize}}
+		next.ServeHTTP(w, request)
+		if w.hijacked || strings.HasPrefix(response.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "capture %s %s %d", request.Method, request.URL.RequestURI(), w.Status())
+		redactor.writeHeaders(&b, "  > ", request.Header)
+		redactor.writeBody(&b, "  > ", request.Header.Get("Content-Type"), requestBody)
+		redactor.writeHeaders(&b, "  < ", response.Header())
+		redactor.writeBody(&b, "  < ", response.Header().Get("Content-Type"), w.body)
+		Logf(request.Context(), "%s", b.String())
+	})
+}
+
+// paths가 비어 있거나 path가 그중 하나로 시작하면 true
+func capturePath(paths []string, path string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, prefix := range paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 헤더와 본문에서 민감한 값을 가립니다.
+type redactor struct {
+	headers map[string]bool // http.CanonicalHeaderKey 형태
+	fields  map[string]bool // 소문자
+	json    *regexp.Regexp  // "field": 값
+}
+
+func newRedactor(config BodyCaptureConfig) *redactor {
+	r := &redactor{headers: make(map[string]bool), fields: make(map[string]bool)}
+	for _, name := range append(append([]string(nil), redactedHeaders...), config.RedactHeaders...) {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	var quoted []string
+	for _, name := range config.RedactFields {
+		r.fields[strings.ToLower(name)] = true
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	if len(quoted) > 0 {
+		// 잘린 JSON에서도 가릴 수 있도록 파싱하지 않고 "이름": 값 모양을 찾습니다.
+		r.json = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return r
+}
+
+func (r *redactor) writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if r.headers[name] {
+			value = redacted
+		}
+		fmt.Fprintf(b, "\n%s%s: %s", prefix, name, value)
+	}
+}
+
+func (r *redactor) writeBody(b *strings.Builder, prefix, contentType string, body *captureBuffer) {
+	if body.Len() == 0 {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var text string
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		text = r.form(body.String())
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		text = body.String()
+		if r.json != nil {
+			text = r.json.ReplaceAllString(text, `${1}"`+redacted+`"`)
+		}
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml"):
+		text = body.String()
+	default:
+		fmt.Fprintf(b, "\n%s[%d bytes %s]", prefix, body.total, contentType)
+		return
+	}
+	if body.truncated {
+		text += " ...(truncated)"
+	}
+	fmt.Fprintf(b, "\n%s%s", prefix, text)
+}
+
+// 폼 본문에서 redact_fields 의 값을 가립니다. 잘린 본문이라 읽을 수 없으면 통째로 가립니다.
+func (r *redactor) form(body string) string {
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return redacted
+	}
+	for name := range values {
+		if r.fields[strings.ToLower(name)] {
+			values[name] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
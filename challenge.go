@@ -0,0 +1,278 @@
+//
+// challenge.go
+//
+// 클라이언트 IP마다 요청 빈도를 제한하고, 제한을 계속 넘는 클라이언트에게는 바로 막기 전에
+// 작업 증명(proof-of-work) 문제를 먼저 풀게 합니다. 사람이 쓰는 브라우저는 잠깐 기다리면 지나가고,
+// 요청을 쏟아붓는 프로그램은 요청마다 계산을 해야 하므로 느려집니다.
+//
+//   "challenge": {"enabled": true, "rate": 20, "burst": 40, "violations": 3, "window": 60,
+//                 "difficulty": 16, "pass_ttl": 3600, "ban_after": 20, "ban_time": 600}
+//
+//   1. 초당 rate 개(한꺼번에 burst 개)를 넘는 요청은 429 Too Many Requests 로 거절합니다.
+//   2. window 초 안에 violations 번 넘으면 문제를 냅니다. 브라우저에게는 문제를 푸는
+//      페이지(templates/challenge.html)를, 다른 클라이언트에게는 429 와 두 헤더를 줍니다.
+//
+//        X-Challenge: AAAAAGkX...                 (서명된 문제. 5분 동안 쓸 수 있습니다.)
+//        X-Challenge-Difficulty: 16
+//
+//      SHA-256(문제 + ":" + 답)의 앞 difficulty 비트가 0인 답을 찾아 보냅니다.
+//
+//        POST /__challenge  {"challenge":"AAAAAGkX...","solution":"48213"}
+//
+//      맞으면 서명된 "challenge_pass" 쿠키를 주고, 그 쿠키가 있는 동안(pass_ttl 초)은 문제를 내지 않습니다.
+//   3. window 초 안에 ban_after 번 넘으면 ban_time 초 동안 403 으로 모두 거절합니다. (0이면 막지 않습니다.)
+//
+// 문제, 통과와 차단은 감사 로그(audit.go)에 challenge.issued, challenge.passed, challenge.banned 로 남깁니다.
+// 클라이언트 IP 는 clientIP(headers.go)로 읽으므로 믿는 프록시(trusted_proxies) 뒤에서는 프록시가 아니라 실제 클라이언트마다 셉니다.
+// 헬스 체크(/healthz, /readyz)는 세지 않습니다. 브라우저의 crypto.subtle 은 https 나 localhost 에서만 쓸 수 있습니다.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 빈도 제한과 문제 설정
+type ChallengeConfig struct {
+	Enabled    bool    `json:"enabled"`
+	Rate       float64 `json:"rate"`       // IP 하나가 초당 보낼 수 있는 요청 수
+	Burst      int     `json:"burst"`      // 한꺼번에 보낼 수 있는 요청 수
+	Violations int     `json:"violations"` // window 안에 이만큼 제한을 넘으면 문제를 냅니다.
+	Window     int     `json:"window"`     // 초
+	Difficulty int     `json:"difficulty"` // 답의 해시 앞에 있어야 하는 0 비트 수
+	PassTTL    int     `json:"pass_ttl"`   // 초. 문제를 푼 뒤 다시 묻지 않는 시간
+	BanAfter   int     `json:"ban_after"`  // window 안에 이만큼 넘으면 막습니다. 0이면 막지 않습니다.
+	BanTime    int     `json:"ban_time"`   // 초
+}
+
+// 문제를 푸는 주소
+const challengePath = "/__challenge"
+
+// 통과 쿠키의 이름, 문제에 서명할 때 쓰는 이름, 문제를 풀 수 있는 시간
+// 이름이 다르므로 문제를 통과 쿠키로 쓸 수 없습니다.
+const (
+	challengeCookie = "challenge_pass"
+	challengeName   = "challenge"
+	challengeTTL    = 5 * time.Minute
+)
+
+var errChallengeFailed = errors.New("challenge: wrong or expired solution")
+
+// 클라이언트 IP 하나의 상태
+type challengeClient struct {
+	limiter    *messageLimiter // 토큰 버킷 (websocket.go)
+	violations int
+	last       time.Time // 마지막으로 제한을 넘은 시각
+	challenged bool      // 문제를 풀어야 요청을 받습니다.
+	banned     time.Time // 이 시각까지 막습니다.
+}
+
+// 서명된 문제와 통과 쿠키에 담는 값. 다른 IP로 옮겨 쓰지 못하게 IP를 넣습니다.
+type challengeTicket struct {
+	IP      string `json:"ip"`
+	Expires int64  `json:"expires"`
+	Random  string `json:"random,omitempty"`
+}
+
+// IP마다 요청을 세고 문제를 냅니다.
+type Challenger struct {
+	config ChallengeConfig
+
+	mu      sync.Mutex
+	clients map[string]*challengeClient
+}
+
+func NewChallenger(config ChallengeConfig) *Challenger {
+	return &Challenger{config: config, clients: make(map[string]*challengeClient)}
+}
+
+// ip의 요청 하나를 셉니다. 막혀 있으면 남은 시간, 문제를 풀어야 하면 challenged가 true
+func (c *Challenger) check(request *http.Request, ip string) (banned time.Duration, limited, challenged bool) {
+	now := time.Now()
+	window := time.Duration(c.config.Window) * time.Second
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client := c.clients[ip]
+	if client == nil {
+		if len(c.clients) > 10000 {
+			c.prune(now, window)
+		}
+		client = &challengeClient{limiter: newMessageLimiter(c.config.Rate, c.config.Burst)}
+		c.clients[ip] = client
+	}
+	if client.banned.After(now) {
+		return client.banned.Sub(now), true, false
+	}
+	if client.limiter.allow() {
+		return 0, false, client.challenged
+	}
+	if now.Sub(client.last) > window {
+		client.violations = 0
+	}
+	client.violations++
+	client.last = now
+	if c.config.BanAfter > 0 && client.violations >= c.config.BanAfter {
+		client.banned = now.Add(time.Duration(c.config.BanTime) * time.Second)
+		client.violations, client.challenged = 0, false
+		audit.Record(request, "challenge.banned", "", map[string]string{"seconds": strconv.Itoa(c.config.BanTime)})
+		return client.banned.Sub(now), true, false
+	}
+	if client.violations >= c.config.Violations && !client.challenged {
+		client.challenged = true
+		audit.Record(request, "challenge.issued", "", map[string]string{"violations": strconv.Itoa(client.violations)})
+	}
+	return 0, true, client.challenged
+}
+
+// ip가 문제를 풀었습니다.
+func (c *Challenger) pass(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client := c.clients[ip]; client != nil {
+		client.challenged = false
+	}
+}
+
+// 오래 조용하고 막혀 있지 않은 IP를 지웁니다. mu를 잡은 상태에서 불러야 합니다.
+func (c *Challenger) prune(now time.Time, window time.Duration) {
+	for ip, client := range c.clients {
+		if client.banned.Before(now) && now.Sub(client.last) > window && !client.challenged {
+			delete(c.clients, ip)
+		}
+	}
+}
+
+// ip에게 낼 새 문제
+func newChallenge(ip string) (string, error) {
+	random := make([]byte, 8)
+	rand.Read(random)
+	return currentCookieCodec().Encode(challengeName, challengeTicket{
+		IP: ip, Expires: time.Now().Add(challengeTTL).Unix(), Random: hex.EncodeToString(random),
+	})
+}
+
+// 해시 앞의 0 비트 수
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// challenge가 ip에게 낸 문제이고 solution이 맞는 답인지
+func (c *Challenger) verify(ip, challenge, solution string) bool {
+	var ticket challengeTicket
+	if err := currentCookieCodec().Decode(challengeName, challenge, &ticket); err != nil {
+		return false
+	}
+	if ticket.IP != ip || time.Now().Unix() > ticket.Expires || len(solution) > 32 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(challenge + ":" + solution))
+	return leadingZeroBits(sum[:]) >= c.config.Difficulty
+}
+
+// 요청에 ip의 통과 쿠키가 있는지
+func hasChallengePass(request *http.Request, ip string) bool {
+	var ticket challengeTicket
+	if err := ReadSecureCookie(request, challengeCookie, &ticket); err != nil {
+		return false
+	}
+	return ticket.IP == ip && time.Now().Unix() <= ticket.Expires
+}
+
+// 문제 페이지(templates/challenge.html)에 넘겨주는 값들
+type ChallengePage struct {
+	Challenge  string
+	Difficulty int
+}
+
+// POST /__challenge 에 대한 응답
+func (c *Challenger) solve(response http.ResponseWriter, request *http.Request, ip string) {
+	if request.Method != http.MethodPost {
+		response.Header().Set("Allow", "POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	var body struct {
+		Challenge string `json:"challenge"`
+		Solution  string `json:"solution"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 4096)).Decode(&body); err != nil {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("challenge parse error %v", err))
+		return
+	}
+	if !c.verify(ip, body.Challenge, body.Solution) {
+		WriteError(response, request, http.StatusForbidden, errChallengeFailed)
+		return
+	}
+	ticket := challengeTicket{IP: ip, Expires: time.Now().Add(time.Duration(c.config.PassTTL) * time.Second).Unix()}
+	if err := SetSecureCookie(response, challengeCookie, ticket); err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	c.pass(ip)
+	audit.Record(request, "challenge.passed", "", nil)
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// IP마다 요청 빈도를 제한하고, 계속 넘는 클라이언트에게는 문제를 냅니다. 꺼져 있으면 next를 그대로 돌려줍니다.
+func ChallengeHandler(config ChallengeConfig, next http.Handler) http.Handler {
+	if !config.Enabled {
+		return next
+	}
+	c := NewChallenger(config)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if isProbe(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		ip := clientIP(request)
+		banned, limited, challenged := c.check(request, ip)
+		if banned > 0 {
+			setRetryAfter(response, banned)
+			WriteError(response, request, http.StatusForbidden, fmt.Errorf("challenge: %s is banned", ip))
+			return
+		}
+		if request.URL.Path == challengePath {
+			c.solve(response, request, ip)
+			return
+		}
+		if challenged && !hasChallengePass(request, ip) {
+			challenge, err := newChallenge(ip)
+			if err != nil {
+				WriteError(response, request, http.StatusInternalServerError, err)
+				return
+			}
+			response.Header().Set("X-Challenge", challenge)
+			response.Header().Set("X-Challenge-Difficulty", strconv.Itoa(config.Difficulty))
+			response.Header().Set("Cache-Control", "no-store")
+			if AcceptsType(request, "text/html") {
+				RenderTemplateStatus(response, request, http.StatusTooManyRequests, "challenge", ChallengePage{challenge, config.Difficulty})
+				return
+			}
+			WriteError(response, request, http.StatusTooManyRequests, nil)
+			return
+		}
+		if limited {
+			response.Header().Set("Retry-After", "1")
+			WriteError(response, request, http.StatusTooManyRequests, nil)
+			return
+		}
+		next.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,78 @@
+//
+// changes.go
+//
+// SSE나 WebSocket을 쓸 수 없는 클라이언트를 위한 long polling 입니다.
+//
+//   GET /items/changes?since=3
+//
+// since 번 이후의 저장소 변경이 있으면 바로 응답하고, 없으면 새 변경이 생길 때까지
+// 최대 timeout 초 동안 응답을 미룹니다. 시간이 다 되면 빈 목록을 보냅니다.
+// 클라이언트는 응답의 "last" 값을 다음 요청의 since로 넘기며 요청을 반복하면 됩니다.
+//
+//   {"changes":[{"seq":4,"item":{"name":"green","what":"item"},"time":"..."}],"last":4}
+//
+// since를 빼면 기다리지 않고 지금의 last만 돌려주므로, 처음 한 번은 그렇게 시작합니다.
+// 변경 기록은 저장소의 change feed(store.go)를 그대로 씁니다.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// long polling 설정
+type LongPollConfig struct {
+	Timeout int `json:"timeout"` // 응답을 최대 몇 초까지 미룰지. 클라이언트가 ?timeout= 으로 줄일 수 있습니다.
+}
+
+// /items/changes 의 응답 본문
+type ChangesResponse struct {
+	Changes []ItemChange `json:"changes"`
+	Last    uint64       `json:"last"`
+}
+
+// GET /items/changes 에 대한 핸들러를 만듭니다.
+func ItemChangesHandler(config LongPollConfig) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			response.Header().Set("Allow", "GET, HEAD")
+			WriteError(response, request, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		query := request.URL.Query()
+
+		var changes []ItemChange
+		var last uint64
+		if query.Get("since") == "" {
+			_, last = store.ChangesSince(^uint64(0))
+		} else {
+			since, err := strconv.ParseUint(query.Get("since"), 10, 64)
+			if err != nil {
+				WriteError(response, request, http.StatusBadRequest, fmt.Errorf("changes since %v", err))
+				return
+			}
+			timeout := config.Timeout
+			if t, err := strconv.Atoi(query.Get("timeout")); err == nil && t >= 0 && t < timeout {
+				timeout = t
+			}
+			// 클라이언트가 연결을 끊거나 서버가 닫히기 시작하면 더 기다리지 않습니다.
+			ctx, cancel := drainContext(request.Context())
+			ctx, cancelTimeout := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+			changes, last = store.WaitChanges(ctx, since)
+			cancelTimeout()
+			cancel()
+		}
+		if changes == nil {
+			changes = []ItemChange{}
+		}
+
+		SetContentType(response, "application/json")
+		response.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(response).Encode(ChangesResponse{Changes: changes, Last: last})
+	})
+}
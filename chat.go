@@ -0,0 +1,197 @@
+//
+// chat.go
+//
+// WebSocket 채팅방 예제입니다. REST 예제(/item/name)의 실시간 버전입니다.
+//
+//   URL: http://localhost:8080/chat       채팅 페이지 (여러 브라우저 창으로 열어 보세요)
+//   URL: ws://localhost:8080/chat/ws       채팅 WebSocket
+//
+// 구조:
+//   - Hub 는 접속한 클라이언트 목록을 가진 고루틴 하나입니다. register/unregister/broadcast
+//     채널로만 목록을 바꾸므로 잠금(mutex)이 필요 없습니다.
+//   - ChatClient 는 연결마다 읽는 고루틴(readLoop)과 쓰는 고루틴(writeLoop)을 가집니다.
+//     보낼 메시지는 send 버퍼 채널에 쌓이고, 버퍼가 가득 찬 느린 클라이언트는
+//     다른 사람을 기다리게 하지 않도록 Hub가 연결을 끊습니다.
+//
+// 메시지는 JSON 입니다.
+//   {"type":"message","name":"guest-1","text":"안녕","time":"2026-10-16T07:00:00Z"}
+//   {"type":"join","name":"guest-2", ...}  {"type":"leave", ...}
+//
+// 저장소에 item이 추가되거나 바뀌면 이벤트 버스(events.go)로 받아 채팅방에 알립니다.
+//   {"type":"item","name":"green","text":"item", ...}
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// 클라이언트 하나의 보낼 메시지 버퍼 크기
+const chatSendBuffer = 32
+
+// 채팅 메시지 하나
+type ChatMessage struct {
+	Type string    `json:"type"` // message, join, leave, item
+	Name string    `json:"name"`
+	Text string    `json:"text,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// 채팅방. 접속한 클라이언트들에게 메시지를 나눠줍니다.
+type Hub struct {
+	register   chan *ChatClient
+	unregister chan *ChatClient
+	broadcast  chan []byte
+	clients    map[*ChatClient]bool
+}
+
+// 새 채팅방을 만듭니다. Run을 고루틴으로 돌려야 동작합니다.
+func NewHub() *Hub {
+	return &Hub{
+		register:   make(chan *ChatClient),
+		unregister: make(chan *ChatClient),
+		broadcast:  make(chan []byte, 64),
+		clients:    make(map[*ChatClient]bool),
+	}
+}
+
+// 클라이언트 목록을 관리하고 메시지를 나눠주는 루프
+func (h *Hub) Run() {
+	items := events.Subscribe(TopicItems)
+	defer items.Close()
+	for {
+		select {
+		case event := <-items.C:
+			change := event.Data.(ItemChange)
+			h.send(ChatMessage{Type: "item", Name: change.Item.Name, Text: change.Item.What, Time: change.Time})
+		case client := <-h.register:
+			h.clients[client] = true
+		case client := <-h.unregister:
+			if h.clients[client] {
+				delete(h.clients, client)
+				close(client.send)
+			}
+		case message := <-h.broadcast:
+			h.deliver(message)
+		}
+	}
+}
+
+// 접속한 모든 클라이언트의 send 버퍼에 넣습니다. Run 안에서만 부릅니다.
+func (h *Hub) deliver(data []byte) {
+	for client := range h.clients {
+		select {
+		case client.send <- data:
+		default:
+			// 버퍼가 가득 찬 느린 클라이언트는 끊습니다.
+			delete(h.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+// Run 안에서 메시지를 바로 나눠줍니다. (Publish는 Run이 받는 채널로 보내므로 Run 안에서 쓰면 멈출 수 있습니다.)
+func (h *Hub) send(message ChatMessage) {
+	if data, err := json.Marshal(message); err == nil {
+		h.deliver(data)
+	}
+}
+
+// 채팅방 전체에 메시지를 보냅니다.
+func (h *Hub) Publish(message ChatMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("chat: %v", err)
+		return
+	}
+	h.broadcast <- data
+}
+
+// 채팅방에 접속한 연결 하나
+type ChatClient struct {
+	hub  *Hub
+	ws   *WebSocketConn
+	send chan []byte
+	name string
+}
+
+// 이름을 정하지 않은 사람에게 붙여주는 번호
+var chatGuestNumber int64
+
+// /chat/ws : 채팅방 WebSocket
+func ChatHandler(hub *Hub, config WebSocketConfig) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		name := chatName(request.URL.Query().Get("name"))
+		ws, err := UpgradeWebSocket(response, request, config)
+		if err != nil {
+			Logf(request.Context(), "chat %s: %v", request.RemoteAddr, err)
+			return
+		}
+		client := &ChatClient{hub: hub, ws: ws, send: make(chan []byte, chatSendBuffer), name: name}
+		defer presence.Join("chat", name)()
+		hub.register <- client
+		hub.Publish(ChatMessage{Type: "join", Name: name, Time: time.Now()})
+
+		go client.writeLoop()
+		client.readLoop(request.Context())
+	})
+}
+
+// 이름을 다듬습니다. 비어 있으면 guest-N 을 붙여줍니다.
+func chatName(name string) string {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > 20 {
+		name = string([]rune(name)[:20])
+	}
+	if name == "" {
+		name = fmt.Sprintf("guest-%d", atomic.AddInt64(&chatGuestNumber, 1))
+	}
+	return name
+}
+
+// 클라이언트가 보낸 글을 읽어 채팅방에 보냅니다. 연결이 끊기면 채팅방에서 나갑니다. ctx는 연결한 요청의 것입니다.
+func (c *ChatClient) readLoop(ctx context.Context) {
+	defer func() {
+		c.hub.unregister <- c
+		c.hub.Publish(ChatMessage{Type: "leave", Name: c.name, Time: time.Now()})
+		c.ws.Close()
+	}()
+	for {
+		opcode, text, err := c.ws.ReadMessage()
+		if err != nil {
+			logReadError(ctx, "chat", c.ws, err)
+			return
+		}
+		if opcode != OpText {
+			c.ws.WriteClose(CloseUnsupportedData, "text messages only")
+			return
+		}
+		if msg := strings.TrimSpace(string(text)); msg != "" {
+			c.hub.Publish(ChatMessage{Type: "message", Name: c.name, Text: msg, Time: time.Now()})
+		}
+	}
+}
+
+// send 버퍼의 메시지를 클라이언트에게 씁니다.
+// Hub가 send를 닫으면 (느린 클라이언트이거나 나간 경우) 연결을 닫습니다.
+func (c *ChatClient) writeLoop() {
+	for message := range c.send {
+		if err := c.ws.WriteMessage(OpText, message); err != nil {
+			break
+		}
+	}
+	c.ws.Close()
+}
+
+// /chat : 채팅 페이지
+func ChatPageHandler(response http.ResponseWriter, request *http.Request) {
+	RenderTemplate(response, request, "chat", nil)
+}
@@ -0,0 +1,68 @@
+//
+// client.go
+//
+// 이 웹서버와 통신하는 Go 프로그램을 위한 작은 클라이언트입니다.
+// /item/name 을 gob으로 받아서 Item 으로 디코딩합니다.
+//
+// 사용 예:
+//
+//   item, err := client.FetchItem(http.DefaultClient, "http://localhost:8080", "yellow")
+//   fmt.Println(item.Name, item.What)   // yellow item
+//
+// gob은 필드 이름으로 맞춰 디코딩하므로 서버의 Item 과 필드 이름만 같으면 됩니다.
+
+package client
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gob 응답의 MIME type. 서버의 GobContentType 과 같아야 합니다.
+const GobContentType = "application/x-gob"
+
+// 서버가 돌려주는 item 하나
+type Item struct {
+	Name string
+	What string
+}
+
+// baseURL 서버에서 name 이라는 item을 gob으로 받아옵니다.
+func FetchItem(httpClient *http.Client, baseURL, name string) (Item, error) {
+	var item Item
+	request, err := http.NewRequest("GET", strings.TrimRight(baseURL, "/")+"/item/"+url.PathEscape(name), nil)
+	if err != nil {
+		return item, err
+	}
+	request.Header.Set("Accept", GobContentType)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return item, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return item, fmt.Errorf("item %q: %s %s", name, response.Status, body)
+	}
+	if err := DecodeItem(response, &item); err != nil {
+		return item, fmt.Errorf("item %q: %v", name, err)
+	}
+	return item, nil
+}
+
+// gob 응답 본문을 into 로 디코딩합니다.
+// 서버가 gob이 아닌 형식(예: JSON)으로 답했다면 에러를 돌려줍니다.
+func DecodeItem(response *http.Response, into interface{}) error {
+	mediatype, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediatype != GobContentType {
+		return fmt.Errorf("expected %s response, got %q", GobContentType, mediatype)
+	}
+	return gob.NewDecoder(response.Body).Decode(into)
+}
@@ -0,0 +1,89 @@
+//
+// coalesce.go
+//
+// 같은 것을 원하는 요청이 한꺼번에 몰릴 때(thundering herd) 한 요청만 저장소를 읽거나 템플릿을 실행하고,
+// 함께 기다린 요청들은 그 결과를 나눠 받습니다. (golang.org/x/sync/singleflight 와 같은 방식)
+//
+//   body, err, shared := flights.Do("items.json", func() ([]byte, error) {
+//       return encodeJSON(store.List())
+//   })
+//
+// 결과를 저장해 두는 캐시가 아닙니다. 먼저 온 요청의 일이 끝나면 다음 요청은 다시 만듭니다.
+// 나눠 받는 결과는 여러 요청이 함께 읽으므로 고치면 안 되고, 풀(bufpool.go)의 버퍼이면 안 됩니다.
+//
+// 지금 쓰는 곳
+//
+//   GET /items   (JSON)           저장소 목록과 JSON 인코딩 (item.go)
+//   GET /home                     템플릿 실행. 언어, 사용자, 주소가 같은 요청끼리 (templates.go)
+//
+// 나눠 받은 요청 수는 /debug/vars 의 "coalesced" 로 볼 수 있습니다. (debug.go)
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// 진행 중인 일 하나
+type flightCall struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// 같은 key의 일을 한 번만 합니다.
+type FlightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+
+	shared atomic.Int64 // 다른 요청의 결과를 받은 수
+}
+
+// 서버 전체가 쓰는 그룹
+var flights = &FlightGroup{}
+
+// key의 일이 진행 중이면 끝나기를 기다려 그 결과를, 아니면 fn을 불러 그 결과를 돌려줍니다.
+// shared는 다른 요청이 만든 결과를 받았는지입니다.
+func (g *FlightGroup) Do(key string, fn func() ([]byte, error)) (value []byte, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		g.shared.Add(1)
+		return call.value, call.err, true
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		// fn이 panic 하면 기다리던 요청들은 에러를 받고, panic 은 이 요청의 RecoverHandler 가 받습니다.
+		if p := recover(); p != nil {
+			call.err = fmt.Errorf("coalesced %s: panic: %v", key, p)
+			g.finish(key, call)
+			panic(p)
+		}
+		g.finish(key, call)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
+
+// call을 끝내고 기다리는 요청들을 깨웁니다.
+func (g *FlightGroup) finish(key string, call *flightCall) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	call.wg.Done()
+}
+
+// 다른 요청의 결과를 나눠 받은 수
+func (g *FlightGroup) Shared() int64 {
+	return g.shared.Load()
+}
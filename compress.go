@@ -0,0 +1,238 @@
+//
+// compress.go
+//
+// 응답을 gzip으로 압축하는 미들웨어입니다.
+//
+// 압축 여부는 응답의 Content-Type 마다 설정 파일의 규칙으로 정합니다. (config.go)
+// 규칙은 위에서부터 보고 처음 맞는 것 하나만 씁니다.
+//   - skip 이 true 이면 압축하지 않습니다. (예: 이미 압축된 image/*)
+//   - min_size 보다 작은 응답은 압축하지 않습니다. 작은 응답은 압축해도 이득이 없습니다.
+//   - 어떤 규칙에도 맞지 않는 Content-Type은 압축하지 않습니다.
+//   - Range 요청과 206 Partial Content 응답은 압축하지 않습니다.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 압축 설정
+type CompressionConfig struct {
+	Enabled bool              `json:"enabled"`
+	Rules   []CompressionRule `json:"rules"`
+}
+
+// Content-Type 하나(또는 "text/*" 같은 묶음)에 대한 압축 규칙
+type CompressionRule struct {
+	Type    string `json:"type"`
+	Skip    bool   `json:"skip"`
+	MinSize int    `json:"min_size"`
+}
+
+// 설정 파일의 rules는 기본 규칙에 섞이지 않고 통째로 바뀝니다.
+// (encoding/json은 기존 슬라이스 원소 위에 덮어쓰므로 skip 같은 값이 남아버립니다.)
+func (c *CompressionConfig) UnmarshalJSON(data []byte) error {
+	type plain CompressionConfig
+	p := plain(*c)
+	p.Rules = nil
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	if p.Rules == nil {
+		p.Rules = c.Rules
+	}
+	*c = CompressionConfig(p)
+	return nil
+}
+
+// contentType에 맞는 규칙을 찾습니다. 맞는 규칙이 없으면 ok가 false입니다.
+func (c CompressionConfig) ruleFor(contentType string) (rule CompressionRule, ok bool) {
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return rule, false
+	}
+	for _, rule := range c.Rules {
+		if rule.Type == mediatype {
+			return rule, true
+		}
+		if prefix, wildcard := strings.CutSuffix(rule.Type, "/*"); wildcard && strings.HasPrefix(mediatype, prefix+"/") {
+			return rule, true
+		}
+	}
+	return rule, false
+}
+
+// next의 응답을 설정에 따라 gzip으로 압축합니다.
+func CompressHandler(config CompressionConfig, next http.Handler) http.Handler {
+	if !config.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Add("Vary", "Accept-Encoding")
+		// Range 요청은 압축하지 않습니다. 이어받기(If-Range)나 동영상 탐색은
+		// 원본 바이트 기준의 206 응답과 강한 ETag가 있어야 동작합니다.
+		if request.Method == "HEAD" || request.Header.Get("Range") != "" || !acceptsGzip(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: response, config: config, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, request)
+	})
+}
+
+// Accept-Encoding에 gzip이 있는지 확인합니다.
+func acceptsGzip(request *http.Request) bool {
+	return acceptsEncoding(request, "gzip")
+}
+
+// Accept-Encoding에 name(gzip, br)이 있는지 확인합니다.
+func acceptsEncoding(request *http.Request, name string) bool {
+	for _, part := range strings.Split(request.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) == name {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// 압축할지 결정할 수 있을 때까지 응답을 붙잡아 두는 ResponseWriter
+//
+// 규칙의 min_size 만큼 본문이 모이면 압축을 시작하고,
+// 그 전에 응답이 끝나면 모아둔 본문을 압축하지 않고 그대로 보냅니다.
+type compressWriter struct {
+	http.ResponseWriter
+	config      CompressionConfig
+	status      int
+	wroteHeader bool // 핸들러가 WriteHeader를 불렀는지
+	decided     bool // 압축 여부를 정했는지
+	minSize     int
+	buffer      []byte
+	gz          *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	// 1xx 정보 응답은 그대로 보냅니다.
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+	cw.decide()
+}
+
+// Content-Type과 상태 코드를 보고 압축할지 정합니다.
+func (cw *compressWriter) decide() {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	header := cw.Header()
+	rule, ok := cw.config.ruleFor(header.Get("Content-Type"))
+	if !ok || rule.Skip || header.Get("Content-Encoding") != "" ||
+		cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified ||
+		cw.status == http.StatusPartialContent {
+		cw.passthrough()
+		return
+	}
+	cw.minSize = rule.MinSize
+}
+
+// 압축하지 않기로 했을 때 헤더를 그대로 보냅니다.
+func (cw *compressWriter) passthrough() {
+	cw.minSize = -1
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case cw.gz != nil:
+		return cw.gz.Write(p)
+	case cw.minSize < 0:
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buffer = append(cw.buffer, p...)
+	if len(cw.buffer) >= cw.minSize {
+		if err := cw.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// 압축을 시작하고 모아둔 본문을 압축하여 씁니다.
+func (cw *compressWriter) startGzip() error {
+	header := cw.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	// 압축한 본문은 바이트 범위로 잘라 받을 수 없습니다.
+	header.Del("Accept-Ranges")
+	// 압축한 본문은 원본과 바이트가 다르므로 강한 ETag를 약한 ETag로 바꿉니다.
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", "W/"+etag)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.gz = getGzipWriter(cw.ResponseWriter)
+	_, err := cw.gz.Write(cw.buffer)
+	cw.buffer = nil
+	return err
+}
+
+// 응답을 마무리합니다. min_size에 못 미친 본문은 압축하지 않고 보냅니다.
+func (cw *compressWriter) Close() {
+	switch {
+	case cw.gz != nil:
+		cw.gz.Close()
+		putGzipWriter(cw.gz)
+		cw.gz = nil
+	case !cw.wroteHeader:
+		// 핸들러가 아무것도 쓰지 않은 경우
+		cw.ResponseWriter.WriteHeader(cw.status)
+	case cw.minSize >= 0:
+		cw.passthrough()
+		cw.ResponseWriter.Write(cw.buffer)
+	}
+}
+
+// 스트리밍 응답을 위해 지금까지 쓴 내용을 클라이언트로 보냅니다.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gz == nil && cw.minSize >= 0 {
+		// 아직 압축 여부를 정하지 못했지만 지금 보내야 하므로 압축을 시작합니다.
+		if err := cw.startGzip(); err != nil {
+			return
+		}
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// 웹소켓처럼 연결을 가져가는 핸들러를 위해 Hijack을 넘겨줍니다.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// http.ResponseController가 원래의 ResponseWriter를 찾을 수 있게 합니다.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,303 @@
+//
+// config.go
+//
+// 서버 설정 파일입니다. JSON 형식이며 -config 플래그로 경로를 지정합니다.
+// 파일을 주지 않으면 DefaultConfig 의 값을 그대로 씁니다.
+//
+//   $ go run . -config server.json
+//
+// 설정 파일 예 (적지 않은 항목은 기본값을 따릅니다) :
+//
+//   {
+//     "port": 8080,
+//     "site_url": "https://example.com",
+//     "dev_dir": ".",
+//     "static": {"dir": "", "max_age": 3600, "listing": false, "precompress": true},
+//     "spa": {"enabled": false, "index": "index.html"},
+//     "favicon": "",
+//     "robots": {"mode": "allow"},
+//     "security_txt": {"contact": ["mailto:security@example.com"], "expires": "2027-10-16T00:00:00Z", "policy": "",
+//                      "encryption": "", "acknowledgments": "", "preferred_languages": "ko, en", "hiring": ""},
+//     "docs": {"dir": ""},
+//     "pages": {"dir": ""},
+//     "early_hints": {"enabled": true},
+//     "minify": {"html": false, "css": false, "js": false},
+//     "meta": {"default": {"description": "..."}, "routes": {"/home": {"title": "..."}}},
+//     "websocket": {"allowed_origins": [], "max_message_size": 65536, "ping_interval": 30, "pong_timeout": 10,
+//                   "message_rate": 10, "message_burst": 20},
+//     "long_poll": {"timeout": 30},
+//     "shutdown_timeout": 10,
+//     "proxy": [{"path": "/proxy/grafana/", "upstream": "http://localhost:3000", "strip_prefix": true, "timeout": 30,
+//                "headers": {"X-WEBAUTH-USER": "demo"}},
+//               {"path": "/proxy/api/", "upstreams": ["http://localhost:9001", "http://localhost:9002"], "balance": "least_conn",
+//                "health_check": {"path": "/healthz", "interval": 5, "timeout": 2, "unhealthy": 3, "healthy": 2}}],
+//     "grpc": {"enabled": false, "allowed_ips": ["127.0.0.1", "10.0.0.0/8"]},
+//     "workers": {"size": 0, "queue": 100},
+//     "container": {"enabled": true, "memory_ratio": 0.9},
+//     "concurrency": {"enabled": false, "initial": 20, "min": 5, "max": 1000, "tolerance": 2, "window": 1000,
+//                     "exempt": ["/items/changes", "/upload"]},
+//     "memory_shed": {"limit": 0, "high": 0.9, "low": 0.8, "interval": 250, "essential": ["/metrics", "/debug/"]},
+//     "upload": {"dir": "uploads", "max_file_size": 10485760, "max_total_size": 52428800,
+//                "allowed_extensions": [".png", ".txt"], "allowed_types": ["image/*", "text/plain"],
+//                "thumbnail_sizes": [64, 256], "scan_command": ["clamdscan", "--no-summary", "-"],
+//                "signed_downloads": false, "signing_key": "...", "share_ttl": 86400, "quota": 104857600,
+//                "resumable_ttl": 86400},
+//     "cookies": {"http_only": true, "secure": false, "same_site": "lax", "path": "/", "domain": "", "max_age": 0,
+//                 "consent": false, "keys": [{"hash": "...", "block": "..."}]},
+//     "login": {"user_file": "users.json", "session_ttl": 86400, "idle_timeout": 3600, "totp_issuer": "go-webserver",
+//               "lockout": {"max_failures": 5, "ip_max_failures": 20, "window": 900, "base_delay": 1, "max_delay": 900}},
+//     "challenge": {"enabled": false, "rate": 20, "burst": 40, "violations": 3, "window": 60, "difficulty": 16,
+//                   "pass_ttl": 3600, "ban_after": 20, "ban_time": 600},
+//     "https_only": {"routes": [{"path": "/login"}, {"path": "/admin/"}, {"path": "/items", "methods": ["POST"]}], "port": 443},
+//     "headers": {"trusted_proxies": [], "strip": []},
+//     "csp": {"enabled": false, "report_only": false, "script_src": [], "report_uri": ""},
+//     "access": [{"path": "/admin/", "roles": ["admin"]}, {"path": "/items", "methods": ["POST"], "roles": ["items:write"]}],
+//     "api_keys": {"s3cret": "alice"},
+//     "secrets": {"file": "/run/secrets/webserver.json", "env": "WEBSERVER_SECRETS"},
+//     "request_signing": {"clients": {"build-bot": "..."}, "window": 300, "max_body": 1048576, "required": []},
+//     "metrics": {"enabled": true, "allowed_ips": ["127.0.0.1", "::1"], "username": "", "password": ""},
+//     "alerts": {"enabled": false, "window": 60, "threshold": 0.1, "min_requests": 20, "cooldown": 300,
+//                "webhook": "", "command": []},
+//     "log": {"output": "stderr", "file": "", "syslog_address": "", "tag": "webserver"},
+//     "crash_dir": "crashes",
+//     "audit_log": "/var/log/webserver/audit.log",
+//     "slow_request_threshold": 1000,
+//     "body_capture": {"enabled": false, "max_size": 4096, "paths": [], "redact_headers": [],
+//                      "redact_fields": ["password", "token", "secret", "api_key"]},
+//     "access_log": {"file": "", "max_size": 100, "max_age": 0, "max_backups": 7, "sample": 1},
+//     "statsd": {"enabled": false, "address": "127.0.0.1:8125", "prefix": "webserver.", "tags": [],
+//                "dogstatsd": false, "flush_interval": 10},
+//     "tracing": {"enabled": false, "endpoint": "http://localhost:4318/v1/traces", "service_name": "go-webserver",
+//                 "sample_ratio": 1, "headers": {}},
+//     "debug": {"expvar": true, "pprof": false, "allowed_ips": ["127.0.0.1", "::1"], "username": "", "password": ""},
+//     "admin": {"allowed_ips": ["127.0.0.1", "::1"], "username": "", "password": "", "users": false},
+//     "compression": {
+//       "enabled": true,
+//       "rules": [
+//         {"type": "image/*", "skip": true},
+//         {"type": "application/json", "min_size": 1024},
+//         {"type": "text/*", "min_size": 1024}
+//       ]
+//     }
+//   }
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 서버 전체 설정
+type Config struct {
+	Port        int               `json:"port"`
+	SiteURL     string            `json:"site_url"` // sitemap.xml 의 주소 앞부분. 예) "https://example.com"
+	Dev         bool              `json:"dev"`      // 개발 모드. -dev 플래그로도 켤 수 있습니다.
+	DevDir      string            `json:"dev_dir"`  // 개발용. 실행 파일에 들어간 HTML 대신 이 디렉토리의 파일을 읽습니다.
+	Compression CompressionConfig `json:"compression"`
+	Static      StaticConfig      `json:"static"`
+	SPA         SPAConfig         `json:"spa"`
+	Favicon     string            `json:"favicon"` // 비어 있으면 실행 파일 안의 static/favicon.ico
+	Robots      RobotsConfig      `json:"robots"`
+	SecurityTxt SecurityTxtConfig `json:"security_txt"` // /.well-known/security.txt. securitytxt.go
+	Docs        DocsConfig        `json:"docs"`
+	Pages       PagesConfig       `json:"pages"`
+	Minify      MinifyConfig      `json:"minify"`
+	EarlyHints  EarlyHintsConfig  `json:"early_hints"`
+	Meta        MetaConfig        `json:"meta"`
+	WebSocket   WebSocketConfig   `json:"websocket"`
+	LongPoll    LongPollConfig    `json:"long_poll"`
+	Upload      UploadConfig      `json:"upload"`
+	Cookies     CookieConfig      `json:"cookies"`
+	Login       LoginConfig       `json:"login"`
+	Access      []AccessRule      `json:"access"`     // 주소마다 필요한 역할. rbac.go
+	CSP         CSPConfig         `json:"csp"`        // Content-Security-Policy. csp.go
+	Headers     HeaderConfig      `json:"headers"`    // 요청 헤더 정리. headers.go
+	Challenge   ChallengeConfig   `json:"challenge"`  // IP마다 빈도 제한과 작업 증명. challenge.go
+	HTTPSOnly   HTTPSOnlyConfig   `json:"https_only"` // https 로만 받는 주소. https.go
+	APIKeys     map[string]string `json:"api_keys"`   // API 키 -> 이름. apikeys.go
+	Metrics     MetricsConfig     `json:"metrics"`
+	Debug       DebugConfig       `json:"debug"`
+	Admin       GuardConfig       `json:"admin"` // /admin/ 주소의 접근 제한. usage.go, users.go
+	Tracing     TracingConfig     `json:"tracing"`
+	StatsD      StatsDConfig      `json:"statsd"`
+	Alerts      AlertConfig       `json:"alerts"`
+	AccessLog   AccessLogConfig   `json:"access_log"`
+	BodyCapture BodyCaptureConfig `json:"body_capture"`
+	Log         LogConfig         `json:"log"`
+
+	RequestSigning RequestSigningConfig `json:"request_signing"` // HMAC 서명 요청. requestsign.go
+	Secrets        SecretsConfig        `json:"secrets"`         // 키를 읽어 올 파일과 환경 변수. secrets.go
+
+	Proxy       []ProxyRoute      `json:"proxy"`       // 다른 서버로 넘기는 주소들. proxy.go
+	GRPC        GRPCConfig        `json:"grpc"`        // 같은 포트의 gRPC ItemService. grpc.go
+	Workers     WorkerPoolConfig  `json:"workers"`     // 썸네일, 내보내기 같은 무거운 일을 하는 워커 풀. workpool.go
+	Container   ContainerConfig   `json:"container"`   // cgroup 한도에 맞춘 GOMAXPROCS 와 GC 목표. container.go
+	MemoryShed  MemoryShedConfig  `json:"memory_shed"` // 메모리가 한도에 가까울 때 요청 거절. memshed.go
+	Concurrency ConcurrencyConfig `json:"concurrency"` // 응답 시간을 보고 정하는 동시 요청 수. adaptive.go
+
+	ShutdownTimeout int    `json:"shutdown_timeout"` // 끌 때 연결이 끝나기를 기다리는 시간(초). shutdown.go
+	CrashDir        string `json:"crash_dir"`        // panic 보고서를 쓰는 디렉토리. recover.go
+	AuditLog        string `json:"audit_log"`        // 감사 로그 파일. 비어 있으면 끔. audit.go
+
+	SlowRequestThreshold int `json:"slow_request_threshold"` // 이보다 오래 걸린 요청을 로그에 남깁니다(ms). 0이면 끔. slowlog.go
+}
+
+// 기본 설정. 설정 파일은 이 값 위에 덮어씁니다.
+func DefaultConfig() Config {
+	return Config{
+		Port: 8080,
+		WebSocket: WebSocketConfig{
+			MaxMessageSize: 64 * 1024,
+			PingInterval:   30,
+			PongTimeout:    10,
+			MessageRate:    10,
+			MessageBurst:   20,
+		},
+		LongPoll: LongPollConfig{
+			Timeout: 30,
+		},
+		ShutdownTimeout: 10,
+		Cookies: CookieConfig{
+			HttpOnly: true,
+			SameSite: "lax",
+			Path:     "/",
+		},
+		CrashDir: "crashes",
+
+		SlowRequestThreshold: 1000,
+		Metrics: MetricsConfig{
+			Enabled: true,
+			// 기본은 서버 자신에서만 볼 수 있습니다.
+			GuardConfig: GuardConfig{AllowedIPs: []string{"127.0.0.1", "::1"}},
+		},
+		Debug: DebugConfig{
+			Expvar:      true,
+			GuardConfig: GuardConfig{AllowedIPs: []string{"127.0.0.1", "::1"}},
+		},
+		Login: LoginConfig{
+			SessionTTL:  24 * 60 * 60,
+			IdleTimeout: 60 * 60,
+			TOTPIssuer:  "go-webserver",
+			Lockout: LockoutConfig{
+				MaxFailures:   5,
+				IPMaxFailures: 20,
+				Window:        15 * 60,
+				BaseDelay:     1,
+				MaxDelay:      15 * 60,
+			},
+		},
+		Admin: GuardConfig{AllowedIPs: []string{"127.0.0.1", "::1"}},
+		RequestSigning: RequestSigningConfig{
+			Window:  5 * 60,
+			MaxBody: 1 << 20,
+		},
+		Challenge: ChallengeConfig{
+			Rate:       20,
+			Burst:      40,
+			Violations: 3,
+			Window:     60,
+			Difficulty: 16,
+			PassTTL:    60 * 60,
+			BanAfter:   20,
+			BanTime:    10 * 60,
+		},
+		Secrets: SecretsConfig{
+			Env: "WEBSERVER_SECRETS",
+		},
+		EarlyHints: EarlyHintsConfig{
+			Enabled: true,
+		},
+		Workers: WorkerPoolConfig{
+			Queue: 100,
+		},
+		Container: ContainerConfig{
+			Enabled:     true,
+			MemoryRatio: 0.9,
+		},
+		Concurrency: ConcurrencyConfig{
+			Initial:   20,
+			Min:       5,
+			Max:       1000,
+			Tolerance: 2,
+			Window:    1000,
+			Exempt:    []string{"/items/changes", "/upload"},
+		},
+		MemoryShed: MemoryShedConfig{
+			High:      0.9,
+			Low:       0.8,
+			Interval:  250,
+			Essential: []string{"/metrics", "/debug/"},
+		},
+		BodyCapture: BodyCaptureConfig{
+			MaxSize:      4096,
+			RedactFields: []string{"password", "token", "secret", "api_key"},
+		},
+		AccessLog: AccessLogConfig{
+			MaxSize:    100,
+			MaxBackups: 7,
+		},
+		Alerts: AlertConfig{
+			Window:      60,
+			Threshold:   0.1,
+			MinRequests: 20,
+			Cooldown:    5 * 60,
+		},
+		StatsD: StatsDConfig{
+			Address:       "127.0.0.1:8125",
+			Prefix:        "webserver.",
+			FlushInterval: 10,
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "http://localhost:4318/v1/traces",
+			ServiceName: "go-webserver",
+			SampleRatio: 1,
+		},
+		Upload: UploadConfig{
+			Dir:               "uploads",
+			MaxFileSize:       10 << 20,
+			MaxTotalSize:      50 << 20,
+			AllowedExtensions: []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".txt", ".pdf"},
+			AllowedTypes:      []string{"image/*", "text/plain", "application/pdf"},
+			ThumbnailSizes:    []int{64, 256},
+			ShareTTL:          24 * 60 * 60,
+			ResumableTTL:      24 * 60 * 60,
+		},
+		Static: StaticConfig{
+			MaxAge:      3600,
+			Precompress: true,
+		},
+		SPA: SPAConfig{
+			Index:       "index.html",
+			APIPrefixes: []string{"/api/", "/item/", "/items", "/generic/"},
+		},
+		Compression: CompressionConfig{
+			Enabled: true,
+			Rules: []CompressionRule{
+				// 이미지는 이미 압축되어 있으므로 다시 압축하지 않습니다.
+				{Type: "image/*", Skip: true},
+				{Type: "application/json", MinSize: 1024},
+				{Type: "application/problem+json", MinSize: 1024},
+				{Type: "text/html", MinSize: 1024},
+				{Type: "text/*", MinSize: 1024},
+			},
+		},
+	}
+}
+
+// path의 설정 파일을 읽습니다. path가 비어 있으면 기본 설정을 돌려줍니다.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("config %s: %v", path, err)
+	}
+	return config, nil
+}
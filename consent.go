@@ -0,0 +1,84 @@
+//
+// consent.go
+//
+// 쿠키 동의 모드입니다. 설정 파일의 "cookies": {"consent": true} 이면 꼭 필요하지 않은 쿠키
+// (SetMyCookie 의 테스트 쿠키 같은 것)는 클라이언트가 /consent 로 동의한 뒤에만 내려줍니다.
+//
+//   $ curl -X POST -d accept=yes http://localhost:8080/consent     동의
+//   $ curl -X POST -d accept=no  http://localhost:8080/consent     거절 (이미 받은 쿠키도 지웁니다)
+//   $ curl http://localhost:8080/consent
+//     {"required":true,"accepted":false}
+//
+// 동의했는지는 서명된 "consent" 쿠키(securecookie.go)에 남깁니다. 이 쿠키와 언어를 고른 "lang"
+// 쿠키는 사용자가 직접 요청한 것이므로 꼭 필요한 쿠키로 보고 동의 없이도 씁니다.
+//
+// consent 가 false 이면 (기본값) 모든 쿠키를 지금처럼 내려줍니다.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// 동의가 있어야 내려주는 쿠키들의 이름. 동의를 거두면 이 쿠키들을 지웁니다.
+var optionalCookies = []string{"testcookiename"}
+
+// 동의 상태를 담는 쿠키
+const consentCookie = "consent"
+
+// 동의 쿠키의 값
+type Consent struct {
+	Accepted bool `json:"accepted"`
+}
+
+// 꼭 필요하지 않은 쿠키를 내려줘도 되는지. 동의 모드가 꺼져 있으면 항상 true
+func HasConsent(request *http.Request) bool {
+	if !cookieConfig.Consent {
+		return true
+	}
+	var consent Consent
+	return ReadSecureCookie(request, consentCookie, &consent) == nil && consent.Accepted
+}
+
+// 동의가 있을 때만 cookie를 응답에 붙입니다.
+func SetOptionalCookie(response http.ResponseWriter, request *http.Request, cookie *http.Cookie) {
+	if HasConsent(request) {
+		http.SetCookie(response, cookie)
+	}
+}
+
+// GET /consent 는 지금 상태를, POST /consent 는 accept=yes|no 로 동의하거나 거절합니다.
+func ConsentHandler(response http.ResponseWriter, request *http.Request) {
+	accepted := HasConsent(request)
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		switch request.FormValue("accept") {
+		case "yes":
+			accepted = true
+		case "no":
+			accepted = false
+			for _, name := range optionalCookies {
+				expired := NewCookie(name, "")
+				expired.MaxAge = -1
+				http.SetCookie(response, expired)
+			}
+		default:
+			WriteError(response, request, http.StatusBadRequest, fmt.Errorf("accept must be yes or no"))
+			return
+		}
+		if err := SetSecureCookie(response, consentCookie, Consent{Accepted: accepted}); err != nil {
+			WriteError(response, request, http.StatusInternalServerError, err)
+			return
+		}
+	default:
+		response.Header().Set("Allow", "GET, HEAD, POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(map[string]bool{"required": cookieConfig.Consent, "accepted": accepted})
+}
@@ -0,0 +1,156 @@
+//
+// container.go
+//
+// 컨테이너(cgroup)에서 돌 때 CPU와 메모리 제한을 읽어서 Go 런타임을 맞춥니다.
+//
+//   "container": {"enabled": true, "memory_ratio": 0.9}
+//
+//   container: cpu limit 1.50 -> GOMAXPROCS 2 (host 16 CPUs)
+//   container: memory limit 512.0 MiB -> GOMEMLIMIT 460.8 MiB
+//
+//   CPU      cgroup 의 CPU 한도(cpu.max, v1 은 cpu.cfs_quota_us)를 올림한 만큼만 GOMAXPROCS 를 씁니다.
+//            호스트의 CPU 수만큼 스레드를 돌리면 한도에 걸려 자꾸 멈춥니다(throttling).
+//   메모리   cgroup 의 메모리 한도(memory.max, v1 은 memory.limit_in_bytes)의 memory_ratio 배를
+//            GC 의 목표(debug.SetMemoryLimit)로 정합니다. heap 이 한도에 가까워지면 GC 가 더 자주 돌아서
+//            OOM killer 에게 죽기 전에 메모리를 돌려받습니다. 나머지는 스택, 버퍼 같은 heap 밖의 몫입니다.
+//
+// GOMAXPROCS, GOMEMLIMIT 환경 변수를 직접 준 경우에는 그 값을 그대로 둡니다.
+// 정해진 값은 시작할 때 로그에 남기고, /debug/runtime 의 gomaxprocs, memory_limit 로도 볼 수 있습니다.
+
+package main
+
+import (
+	"bufio"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// 컨테이너 설정
+type ContainerConfig struct {
+	Enabled     bool    `json:"enabled"`
+	MemoryRatio float64 `json:"memory_ratio"` // 메모리 한도 중 GC 목표로 쓸 비율
+}
+
+// cgroup 파일 시스템이 붙는 곳
+const cgroupRoot = "/sys/fs/cgroup"
+
+// 이 프로세스의 cgroup 경로들. v2 는 "" 키에, v1 은 컨트롤러 이름("cpu", "memory")마다 있습니다.
+func cgroupPaths() map[string]string {
+	paths := make(map[string]string)
+	file, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return paths
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 0::/user.slice  또는  4:cpu,cpuacct:/docker/abc
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// dir 아래 path 의 name 파일을 읽습니다. 컨테이너 안에서는 자기 cgroup 이 루트로 보이기도 하므로 dir 의 것도 봅니다.
+func readCgroupFile(dir, path, name string) (string, bool) {
+	for _, p := range []string{filepath.Join(dir, path, name), filepath.Join(dir, name)} {
+		if data, err := os.ReadFile(p); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// cgroup 의 CPU 한도(CPU 수). 없으면 0
+func cgroupCPULimit(paths map[string]string) float64 {
+	if path, ok := paths[""]; ok {
+		// v2: "max 100000" 또는 "150000 100000"
+		if value, ok := readCgroupFile(cgroupRoot, path, "cpu.max"); ok {
+			quota, period, _ := strings.Cut(value, " ")
+			q, err1 := strconv.ParseFloat(quota, 64)
+			p, err2 := strconv.ParseFloat(period, 64)
+			if err1 == nil && err2 == nil && q > 0 && p > 0 {
+				return q / p
+			}
+			return 0
+		}
+	}
+	for _, controller := range []string{"cpu,cpuacct", "cpu"} {
+		dir := filepath.Join(cgroupRoot, controller)
+		quota, ok1 := readCgroupFile(dir, paths["cpu"], "cpu.cfs_quota_us")
+		period, ok2 := readCgroupFile(dir, paths["cpu"], "cpu.cfs_period_us")
+		if !ok1 || !ok2 {
+			continue
+		}
+		q, err1 := strconv.ParseFloat(quota, 64)
+		p, err2 := strconv.ParseFloat(period, 64)
+		if err1 == nil && err2 == nil && q > 0 && p > 0 {
+			return q / p
+		}
+		return 0
+	}
+	return 0
+}
+
+// cgroup 의 메모리 한도(바이트). 없으면 0
+func cgroupMemoryLimit(paths map[string]string) int64 {
+	value, ok := "", false
+	if path, v2 := paths[""]; v2 {
+		value, ok = readCgroupFile(cgroupRoot, path, "memory.max")
+	}
+	if !ok {
+		value, ok = readCgroupFile(filepath.Join(cgroupRoot, "memory"), paths["memory"], "memory.limit_in_bytes")
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	// v2 는 한도가 없으면 "max", v1 은 아주 큰 수입니다.
+	if !ok || err != nil || limit <= 0 || limit >= 1<<62 {
+		return 0
+	}
+	return limit
+}
+
+// 바이트를 MiB 로
+func mebibytes(n int64) string {
+	return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MiB"
+}
+
+// cgroup 의 한도에 맞춰 GOMAXPROCS 와 GC 목표를 정하고 로그에 남깁니다. main 에서 시작할 때 부릅니다.
+func TuneForContainer(config ContainerConfig) {
+	if !config.Enabled {
+		return
+	}
+	paths := cgroupPaths()
+	if cpus := cgroupCPULimit(paths); cpus > 0 {
+		procs := max(1, int(math.Ceil(cpus)))
+		if os.Getenv("GOMAXPROCS") == "" && procs < runtime.GOMAXPROCS(0) {
+			runtime.GOMAXPROCS(procs)
+		}
+		log.Printf("container: cpu limit %.2f -> GOMAXPROCS %d (host %d CPUs)", cpus, runtime.GOMAXPROCS(0), runtime.NumCPU())
+	}
+	if memory := cgroupMemoryLimit(paths); memory > 0 {
+		if os.Getenv("GOMEMLIMIT") == "" && config.MemoryRatio > 0 {
+			debug.SetMemoryLimit(int64(float64(memory) * min(config.MemoryRatio, 1)))
+		}
+		log.Printf("container: memory limit %s -> GOMEMLIMIT %s", mebibytes(memory), mebibytes(currentMemoryLimit()))
+	}
+}
+
+// 지금의 GC 메모리 목표. 정하지 않았으면 0
+func currentMemoryLimit() int64 {
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return 0
+	}
+	return limit
+}
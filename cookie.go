@@ -0,0 +1,83 @@
+//
+// cookie.go
+//
+// 서버가 내려주는 쿠키의 속성을 설정 파일에서 정합니다. SetMyCookie 의 테스트 쿠키와
+// 앞으로 붙을 세션 쿠키가 모두 NewCookie 로 만들어 같은 속성을 씁니다.
+//
+//   "cookies": {"http_only": true, "secure": true, "same_site": "lax", "path": "/",
+//               "domain": "", "max_age": 86400}
+//
+//   Set-Cookie: testcookiename=testcookievalue; Path=/; Max-Age=86400; HttpOnly; Secure; SameSite=Lax
+//
+// same_site 는 "lax", "strict", "none" 중 하나이고 비어 있으면 붙이지 않습니다.
+// "none" 은 브라우저가 Secure 없이는 받지 않으므로 secure 를 함께 켜야 합니다.
+// max_age 가 0이면 브라우저를 닫을 때 사라지는 쿠키입니다.
+// 서명된 쿠키(securecookie.go)는 만든 지 max_age 초가 지나면 서버도 받지 않습니다.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 쿠키 속성 설정
+type CookieConfig struct {
+	HttpOnly bool        `json:"http_only"` // JavaScript 에서 읽지 못하게 합니다.
+	Secure   bool        `json:"secure"`    // HTTPS 로만 보냅니다.
+	SameSite string      `json:"same_site"` // "lax", "strict", "none"
+	Path     string      `json:"path"`
+	Domain   string      `json:"domain"`  // 비어 있으면 요청한 호스트만
+	MaxAge   int         `json:"max_age"` // 초. 0이면 세션 쿠키
+	Consent  bool        `json:"consent"` // 꼭 필요하지 않은 쿠키는 동의한 뒤에만 씁니다. consent.go
+	Keys     []CookieKey `json:"keys"`    // 서명, 암호화 키. 첫 번째로 만듭니다. securecookie.go
+}
+
+// 서버 전체가 쓰는 쿠키 설정. main에서 설정 파일로 채웁니다.
+var cookieConfig = DefaultConfig().Cookies
+
+// 설정이 올바른지 확인합니다.
+func (c CookieConfig) Validate() error {
+	sameSite, err := parseSameSite(c.SameSite)
+	if err != nil {
+		return err
+	}
+	if sameSite == http.SameSiteNoneMode && !c.Secure {
+		return fmt.Errorf("same_site \"none\" needs secure")
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max_age %d: must not be negative", c.MaxAge)
+	}
+	return nil
+}
+
+func parseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("same_site %q: want lax, strict or none", s)
+}
+
+// cookieConfig 의 속성을 붙인 쿠키를 만듭니다.
+func NewCookie(name, value string) *http.Cookie {
+	// Validate 로 이미 확인했으므로 에러는 없습니다.
+	sameSite, _ := parseSameSite(cookieConfig.SameSite)
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cookieConfig.Path,
+		Domain:   cookieConfig.Domain,
+		MaxAge:   cookieConfig.MaxAge,
+		HttpOnly: cookieConfig.HttpOnly,
+		Secure:   cookieConfig.Secure,
+		SameSite: sameSite,
+	}
+}
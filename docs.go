@@ -0,0 +1,110 @@
+//
+// docs.go
+//
+// /docs/ 아래에서 Markdown 문서를 사이트 레이아웃에 넣어 보여줍니다.
+//
+//   URL: http://localhost:8080/docs/        =>  docs/index.md
+//   URL: http://localhost:8080/docs/rest    =>  docs/rest.md
+//
+// 기본은 실행 파일 안에 들어간 docs/ 디렉토리이고, 설정 파일의
+// "docs": {"dir": "./docs"} 로 디스크의 디렉토리를 대신 쓸 수 있습니다.
+// 문서의 첫 번째 "# 제목" 이 페이지 제목이 됩니다.
+
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// 문서 설정
+type DocsConfig struct {
+	Dir string `json:"dir"` // 비어 있으면 실행 파일 안의 docs/ 를 씁니다.
+}
+
+// 문서 페이지에 넘겨주는 값들
+type DocPage struct {
+	Title string
+	Body  template.HTML
+}
+
+// Markdown 문서를 보여주는 핸들러
+type DocsHandler struct {
+	fsys   fs.FS
+	prefix string
+}
+
+// config에 따라 문서 핸들러를 만듭니다.
+func NewDocsHandler(prefix string, config DocsConfig) (*DocsHandler, error) {
+	if config.Dir != "" {
+		return &DocsHandler{fsys: os.DirFS(config.Dir), prefix: prefix}, nil
+	}
+	sub, err := fs.Sub(assets, "docs")
+	if err != nil {
+		return nil, err
+	}
+	return &DocsHandler{fsys: sub, prefix: prefix}, nil
+}
+
+func (h *DocsHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	name := strings.TrimPrefix(path.Clean(request.URL.Path), strings.TrimSuffix(h.prefix, "/"))
+	name = strings.Trim(name, "/")
+	if name == "" {
+		name = "index"
+	}
+	if !fs.ValidPath(name) {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+
+	source, err := fs.ReadFile(h.fsys, name+".md")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			WriteError(response, request, http.StatusNotFound, nil)
+		} else {
+			WriteError(response, request, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	RenderTemplate(response, request, "doc", DocPage{
+		Title: markdownTitle(string(source), name),
+		Body:  RenderMarkdown(string(source)),
+	})
+}
+
+// 모든 문서의 주소와 수정 시간. sitemap.xml 이 씁니다.
+func (h *DocsHandler) Routes() []SiteRoute {
+	var routes []SiteRoute
+	fs.WalkDir(h.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(name) != ".md" {
+			return err
+		}
+		route := strings.TrimSuffix(name, ".md")
+		if route == "index" {
+			route = ""
+		}
+		var mtime time.Time
+		if info, err := entry.Info(); err == nil {
+			mtime = info.ModTime()
+		}
+		routes = append(routes, SiteRoute{Path: h.prefix + route, ModTime: mtime})
+		return nil
+	})
+	return routes
+}
+
+// 문서의 첫 번째 "# 제목" 을 찾습니다. 없으면 fallback을 씁니다.
+func markdownTitle(source, fallback string) string {
+	for _, line := range strings.Split(source, "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+			return strings.TrimSpace(title)
+		}
+	}
+	return fallback
+}
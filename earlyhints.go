@@ -0,0 +1,79 @@
+//
+// earlyhints.go
+//
+// 페이지를 만드는 동안 브라우저가 먼저 스크립트와 스타일시트를 받기 시작하도록
+// 최종 응답 전에 103 Early Hints 로 미리 받을 파일을 알려줍니다. (RFC 8297)
+//
+//   "early_hints": {"enabled": true}
+//
+//   HTTP/1.1 103 Early Hints
+//   Link: </static/css/site.3f2a91c0.css>; rel=preload; as=style
+//   Link: </static/js/home.9add26d1.js>; rel=preload; as=script
+//   Link: <http://ajax.googleapis.com>; rel=preconnect
+//
+//   HTTP/1.1 200 OK
+//   Link: ...                  (같은 Link 헤더가 최종 응답에도 붙습니다.)
+//
+// 핸들러가 페이지를 만들기 전에 SendEarlyHints 로 보냅니다. 주소는 지문이 붙은 asset 주소(static.go)이므로
+// 템플릿의 {{asset}} 이 쓰는 주소와 같습니다.
+//
+//   SendEarlyHints(response, request, Preload("js/home.js", "script"), Preconnect("https://cdn.example.com"))
+//
+// HEAD 요청과 1xx 응답을 모르는 HTTP/1.0 클라이언트에게는 보내지 않습니다.
+// 미들웨어의 ResponseWriter 들(recorder.go, compress.go)은 1xx 를 최종 상태로 세지 않습니다.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 103 Early Hints 설정
+type EarlyHintsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Link 헤더 하나
+type EarlyHint struct {
+	URL string
+	Rel string // preload, preconnect, modulepreload
+	As  string // script, style, font, image. preload 에만 씁니다.
+}
+
+func (h EarlyHint) String() string {
+	link := fmt.Sprintf("<%s>; rel=%s", h.URL, h.Rel)
+	if h.As != "" {
+		link += "; as=" + h.As
+	}
+	if h.As == "font" {
+		// 글꼴은 crossorigin 없이 미리 받으면 브라우저가 다시 받습니다.
+		link += "; crossorigin"
+	}
+	return link
+}
+
+// 정적 파일 name을 미리 받게 합니다. as는 script, style 같은 파일의 종류입니다.
+func Preload(name, as string) EarlyHint {
+	return EarlyHint{URL: AssetURL(name), Rel: "preload", As: as}
+}
+
+// 다른 서버(CDN)에 미리 연결하게 합니다.
+func Preconnect(origin string) EarlyHint {
+	return EarlyHint{URL: origin, Rel: "preconnect"}
+}
+
+// 103 Early Hints 를 보낼지. main에서 설정으로 정합니다.
+var earlyHintsEnabled = DefaultConfig().EarlyHints.Enabled
+
+// hints를 Link 헤더로 달고 103 Early Hints 로 먼저 보냅니다. 헤더는 최종 응답에도 남습니다.
+// 보낼 수 없는 요청이면 Link 헤더만 답니다.
+func SendEarlyHints(response http.ResponseWriter, request *http.Request, hints ...EarlyHint) {
+	for _, hint := range hints {
+		response.Header().Add("Link", hint.String())
+	}
+	if !earlyHintsEnabled || len(hints) == 0 || request.Method == http.MethodHead || !request.ProtoAtLeast(1, 1) {
+		return
+	}
+	response.WriteHeader(http.StatusEarlyHints)
+}
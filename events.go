@@ -0,0 +1,248 @@
+//
+// events.go
+//
+// 서버 안의 이벤트 버스(pub/sub)입니다.
+//
+// 저장소 같은 곳이 topic에 이벤트를 Publish 하면, 그 topic을 Subscribe 한 모든 구독자가
+// 자기 채널(Subscription.C)로 받습니다. SSE, WebSocket 같은 실시간 기능이
+// 저장소를 각자 조회하지 않고 이벤트만 기다리면 됩니다.
+//
+//   sub := events.Subscribe(TopicItems)
+//   defer sub.Close()
+//   for event := range sub.C { ... }
+//
+// Publish는 기다리지 않습니다. 구독자의 버퍼가 가득 차 있으면 그 구독자에게는
+// 이벤트를 버리고 Dropped 수를 늘립니다. 느린 구독자 하나가 저장소를 멈추게 하지 않습니다.
+//
+// 이벤트 버스를 쓰는 곳:
+//   - /items/events : item 변경을 SSE로 보냅니다.
+//   - /items/ws      : item 변경을 WebSocket으로 보냅니다. (JSON 또는 MessagePack)
+//   - /chat/ws       : 채팅방에 item 변경을 알립니다. (chat.go)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// item 저장소의 변경. Data는 ItemChange 입니다.
+const TopicItems = "items"
+
+// 구독자 하나의 이벤트 버퍼 크기
+const eventBuffer = 64
+
+// 이벤트 하나
+type Event struct {
+	Topic string
+	Data  interface{}
+	Time  time.Time
+}
+
+// topic별 구독자 목록을 가진 이벤트 버스
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[string]map[*Subscription]bool
+}
+
+// 구독 하나. C로 이벤트를 받고, 다 쓰면 Close 합니다.
+type Subscription struct {
+	C       <-chan Event
+	Dropped int64 // 버퍼가 가득 차서 버린 이벤트 수
+
+	bus   *EventBus
+	topic string
+	c     chan Event
+	once  sync.Once
+}
+
+// 서버 전체가 함께 쓰는 이벤트 버스
+var events = NewEventBus()
+
+// 새 이벤트 버스를 만듭니다.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[string]map[*Subscription]bool)}
+}
+
+// topic을 구독합니다.
+func (b *EventBus) Subscribe(topic string) *Subscription {
+	c := make(chan Event, eventBuffer)
+	sub := &Subscription{C: c, bus: b, topic: topic, c: c}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*Subscription]bool)
+	}
+	b.subs[topic][sub] = true
+	return sub
+}
+
+// topic의 모든 구독자에게 data를 보냅니다.
+func (b *EventBus) Publish(topic string, data interface{}) {
+	event := Event{Topic: topic, Data: data, Time: time.Now()}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs[topic] {
+		select {
+		case sub.c <- event:
+		default:
+			atomic.AddInt64(&sub.Dropped, 1)
+		}
+	}
+}
+
+// 구독을 끝냅니다. C가 닫힙니다. 여러 번 불러도 됩니다.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs[s.topic], s)
+		s.bus.mu.Unlock()
+		close(s.c)
+	})
+}
+
+// /items/events : item 변경을 SSE로 보냅니다.
+//
+//	event: item
+//	id: 4
+//	data: {"seq":4,"item":{"name":"green","what":"item"},"time":"..."}
+//
+// 브라우저의 EventSource는 다시 연결할 때 Last-Event-ID 헤더를 보내므로,
+// 끊긴 동안의 변경은 저장소의 change feed에서 찾아 먼저 보냅니다.
+func ItemEventsHandler(response http.ResponseWriter, request *http.Request) {
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("item events: streaming not supported"))
+		return
+	}
+	// 빠지는 변경이 없도록 지난 변경을 찾기 전에 먼저 구독합니다.
+	sub := events.Subscribe(TopicItems)
+	defer sub.Close()
+	defer presence.Join("items.events", "")()
+
+	var last uint64
+	var missed []ItemChange
+	if id, err := strconv.ParseUint(request.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		missed, _ = store.ChangesSince(id)
+		last = id
+	}
+
+	SetContentType(response, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	fmt.Fprint(response, ": connected\n\n")
+
+	send := func(change ItemChange) {
+		if change.Seq <= last {
+			return // 지난 변경으로 이미 보냄
+		}
+		last = change.Seq
+		data, _ := json.Marshal(change)
+		fmt.Fprintf(response, "event: item\nid: %d\ndata: %s\n\n", change.Seq, data)
+	}
+	for _, change := range missed {
+		send(change)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveReloadHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-draining:
+			fmt.Fprint(response, "event: shutdown\ndata: shutdown\n\n")
+			flusher.Flush()
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			send(event.Data.(ItemChange))
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(response, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// /items/ws 가 지원하는 하위 프로토콜
+const (
+	ItemsProtocolJSON    = "items.json"    // 텍스트 프레임에 JSON (기본)
+	ItemsProtocolMsgPack = "items.msgpack" // 바이너리 프레임에 MessagePack
+)
+
+// MessagePack으로 보낼 때의 모양. JSON과 같은 키를 씁니다.
+func (c ItemChange) msgpackValue() map[string]interface{} {
+	return map[string]interface{}{
+		"seq":  c.Seq,
+		"item": map[string]interface{}{"name": c.Item.Name, "what": c.Item.What},
+		"time": c.Time,
+	}
+}
+
+// /items/ws : item 변경을 WebSocket으로 보냅니다.
+//
+// Sec-WebSocket-Protocol: items.msgpack 으로 연결하면 바이너리 프레임에 MessagePack으로,
+// 그 밖에는 텍스트 프레임에 /items/events 와 같은 JSON으로 보냅니다.
+//
+//	var ws = new WebSocket("ws://localhost:8080/items/ws", ["items.msgpack"]);
+//	ws.binaryType = "arraybuffer";
+func ItemSocketHandler(config WebSocketConfig) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ws, err := UpgradeWebSocket(response, request, config, ItemsProtocolMsgPack, ItemsProtocolJSON)
+		if err != nil {
+			Logf(request.Context(), "items ws %s: %v", request.RemoteAddr, err)
+			return
+		}
+		defer ws.Close()
+		sub := events.Subscribe(TopicItems)
+		defer sub.Close()
+		defer presence.Join("items.ws", "")()
+
+		// 클라이언트는 보내는 것이 없지만, ping/close 프레임을 처리하려면 읽어야 합니다.
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					logReadError(request.Context(), "items ws", ws, err)
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-gone:
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				change := event.Data.(ItemChange)
+				opcode, data := OpText, []byte(nil)
+				if ws.Subprotocol == ItemsProtocolMsgPack {
+					opcode = OpBinary
+					data, err = AppendMsgPack(nil, change.msgpackValue())
+				} else {
+					data, err = json.Marshal(change)
+				}
+				if err != nil {
+					Logf(request.Context(), "items ws: %v", err)
+					continue
+				}
+				if err := ws.WriteMessage(opcode, data); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
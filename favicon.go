@@ -0,0 +1,61 @@
+//
+// favicon.go
+//
+// 브라우저는 모든 사이트에서 /favicon.ico 를 요청합니다.
+// 이 핸들러가 없으면 방문할 때마다 404 가 로그에 쌓이므로, 실행 파일에 들어간
+// static/favicon.ico (또는 설정 파일의 "favicon" 경로의 파일)를 보내줍니다.
+//
+// 아이콘은 거의 바뀌지 않으므로 일주일 동안 캐시하게 합니다.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+)
+
+// favicon 캐시 시간 (일주일)
+const faviconMaxAge = "604800"
+
+// 메모리에 읽어둔 favicon을 보내주는 핸들러
+type FaviconHandler struct {
+	icon    []byte
+	etag    string
+	modTime time.Time
+}
+
+// path가 비어 있으면 실행 파일 안의 static/favicon.ico 를, 아니면 path 파일을 읽습니다.
+func NewFaviconHandler(path string) (*FaviconHandler, error) {
+	var icon []byte
+	var err error
+	modTime := time.Now()
+	if path == "" {
+		icon, err = fs.ReadFile(assets, "static/favicon.ico")
+	} else {
+		icon, err = os.ReadFile(path)
+		if info, statErr := os.Stat(path); statErr == nil {
+			modTime = info.ModTime()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(icon)
+	return &FaviconHandler{
+		icon:    icon,
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime: modTime,
+	}, nil
+}
+
+func (h *FaviconHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "image/x-icon")
+	response.Header().Set("Cache-Control", "public, max-age="+faviconMaxAge)
+	response.Header().Set("ETag", h.etag)
+	http.ServeContent(response, request, "favicon.ico", h.modTime, bytes.NewReader(h.icon))
+}
@@ -0,0 +1,191 @@
+//
+// files.go
+//
+// /files/ 아래에서 업로드된 파일(filestore.go)을 보여줍니다.
+//
+//   GET /files/{id}/download       파일 내려받기 (Content-Disposition: attachment)
+//   GET /files/{id}/thumb/{size}   썸네일 (thumbnail.go)
+//   POST /files/{id}/share?ttl=600 서명된 내려받기 주소를 새로 만듭니다. (signedurl.go)
+//
+// "signed_downloads" 설정을 켜면 내려받기와 썸네일은 서명된 주소로만 할 수 있습니다.
+// 서명된 주소는 파일을 올린 사람(같은 API 키나 로그인 사용자)이나 admin 역할의 사용자만 만들 수 있습니다.
+//
+// 저장된 파일의 내용은 바뀌지 않으므로 파일 ID를 ETag로 씁니다.
+// If-None-Match, If-Modified-Since, Range 요청은 http.ServeContent 가 처리합니다.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 업로드된 파일을 보여주는 핸들러
+type FilesHandler struct {
+	store  *FileStore
+	config UploadConfig
+	signer *URLSigner // 내려받기 주소의 서명
+}
+
+// /files/ 핸들러를 만듭니다.
+func NewFilesHandler(store *FileStore, config UploadConfig, signer *URLSigner) *FilesHandler {
+	return &FilesHandler{store: store, config: config, signer: signer}
+}
+
+// id 파일을 내려받는 주소. 서명을 쓰는 설정이면 서명된 주소입니다.
+func (h *FilesHandler) DownloadURL(id string) string {
+	path := "/files/" + id + "/download"
+	if !h.config.SignedDownloads {
+		return path
+	}
+	return h.signer.Sign(path, time.Duration(h.config.ShareTTL)*time.Second)
+}
+
+func (h *FilesHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	// "/files/{id}/..." => ["{id}", ...]
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, "/files/"), "/")
+	f, ok := h.store.Get(parts[0])
+	if !ok || len(parts) < 2 {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	allow, ok := "GET, HEAD", request.Method == http.MethodGet || request.Method == http.MethodHead
+	if parts[1] == "share" {
+		allow, ok = "POST", request.Method == http.MethodPost
+	}
+	if !ok {
+		response.Header().Set("Allow", allow)
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	var serve http.HandlerFunc
+	switch {
+	case len(parts) == 2 && parts[1] == "download":
+		serve = func(response http.ResponseWriter, request *http.Request) {
+			h.serveDownload(response, request, f)
+		}
+	case len(parts) == 2 && parts[1] == "share":
+		h.serveShare(response, request, f)
+		return
+	case len(parts) == 3 && parts[1] == "thumb":
+		serve = func(response http.ResponseWriter, request *http.Request) {
+			h.serveThumbnail(response, request, f, parts[2])
+		}
+	default:
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	if h.config.SignedDownloads {
+		h.signer.Require(serve).ServeHTTP(response, request)
+		return
+	}
+	serve(response, request)
+}
+
+// request 가 f 의 서명된 주소를 만들 수 있는지. 올린 사람과 admin 만 됩니다.
+// IP 주소로 구분한 올린 사람("ip:...")은 같은 IP 의 다른 사람일 수 있으므로 인정하지 않습니다.
+func mayShare(request *http.Request, f StoredFile) bool {
+	if f.Owner != "" && !strings.HasPrefix(f.Owner, "ip:") && RequestOwner(request) == f.Owner {
+		return true
+	}
+	user := RequestUser(request)
+	if user == "" {
+		return false
+	}
+	if user == f.Owner {
+		return true
+	}
+	for _, role := range users.Roles(user) {
+		if role == adminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// POST /files/{id}/share?ttl=600 : ttl 초 동안 쓸 수 있는 서명된 주소
+// ttl은 설정의 share_ttl 보다 길 수 없습니다. 이미지이면 썸네일 주소들도 함께 돌려줍니다.
+// 올린 사람이나 admin 이 아니면 403 입니다.
+func (h *FilesHandler) serveShare(response http.ResponseWriter, request *http.Request, f StoredFile) {
+	if !mayShare(request, f) {
+		audit.Record(request, "files.share_denied", RequestUser(request), map[string]string{"file": f.ID})
+		WriteError(response, request, http.StatusForbidden, fmt.Errorf("share %s: not the owner", f.ID))
+		return
+	}
+	ttl := h.config.ShareTTL
+	if t, err := strconv.Atoi(request.URL.Query().Get("ttl")); err == nil && t > 0 && t < ttl {
+		ttl = t
+	}
+	body := map[string]interface{}{
+		"url":     h.signer.Sign("/files/"+f.ID+"/download", time.Duration(ttl)*time.Second),
+		"expires": time.Now().Add(time.Duration(ttl) * time.Second).UTC(),
+	}
+	if isThumbnailable(f) && len(h.config.ThumbnailSizes) > 0 {
+		thumbnails := map[string]string{}
+		for _, size := range h.config.ThumbnailSizes {
+			path := "/files/" + f.ID + "/thumb/" + strconv.Itoa(size)
+			thumbnails[strconv.Itoa(size)] = h.signer.Sign(path, time.Duration(ttl)*time.Second)
+		}
+		body["thumbnails"] = thumbnails
+	}
+	SetContentType(response, "application/json")
+	json.NewEncoder(response).Encode(body)
+}
+
+// GET /files/{id}/download
+func (h *FilesHandler) serveDownload(response http.ResponseWriter, request *http.Request, f StoredFile) {
+	_, span := StartSpan(request.Context(), "FileStore.Open")
+	file, _, err := h.store.Open(f.ID)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		WriteError(response, request, http.StatusNotFound, err)
+		return
+	}
+	defer file.Close()
+
+	header := response.Header()
+	header.Set("Content-Type", f.Type)
+	// 한글 같은 이름은 filename*=utf-8''... 로 인코딩됩니다. (RFC 6266)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	header.Set("ETag", `"`+f.ID+`"`)
+	header.Set("Cache-Control", "private, max-age=3600")
+	// 브라우저가 내용을 보고 HTML 등으로 바꿔 해석하지 않도록
+	header.Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(response, request, "", f.Uploaded, file)
+}
+
+// GET /files/{id}/thumb/{size}
+func (h *FilesHandler) serveThumbnail(response http.ResponseWriter, request *http.Request, f StoredFile, size string) {
+	n, err := strconv.Atoi(size)
+	configured := false
+	for _, s := range h.config.ThumbnailSizes {
+		configured = configured || s == n
+	}
+	if err != nil || !configured || !isThumbnailable(f) {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	thumb, err := os.Open(h.store.thumbPath(f.ID, n))
+	if err != nil {
+		// 아직 만드는 중일 수 있습니다.
+		response.Header().Set("Retry-After", "1")
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	defer thumb.Close()
+	info, err := thumb.Stat()
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	response.Header().Set("Content-Type", "image/png")
+	response.Header().Set("Cache-Control", immutableCacheControl)
+	http.ServeContent(response, request, "", info.ModTime(), thumb)
+}
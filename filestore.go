@@ -0,0 +1,288 @@
+//
+// filestore.go
+//
+// 업로드된 파일을 디스크의 디렉토리에 보관하는 저장소입니다.
+//
+//   uploads/3f2a...9c        파일 내용
+//   uploads/3f2a...9c.json   파일 정보 (StoredFile)
+//
+// 파일 이름은 사용자가 보낸 이름 대신 무작위 ID를 씁니다. 사용자가 보낸 이름은
+// 정보 파일에만 남기므로 "../../etc/passwd" 같은 이름도 디렉토리 밖을 가리킬 수 없습니다.
+// 서버를 다시 시작하면 정보 파일들을 읽어 목록을 되살립니다.
+//
+// Scanner(scanner.go)가 있으면 파일을 목록에 넣기 전에 검사하고,
+// 통과하지 못한 파일은 .quarantine/ 으로 옮깁니다.
+//
+// Quota 가 있으면 파일을 목록에 넣을 때 올린 사람의 크기 합을 다시 확인합니다.
+// 넣는 중인 파일의 크기는 미리 잡아 두므로, 같은 사람의 업로드 여럿이 동시에 끝나도 합이 Quota 를 넘지 않습니다.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 파일이 크기 제한보다 클 때 Save가 돌려주는 에러
+var ErrFileTooLarge = errors.New("file too large")
+
+// 올린 사람의 저장 공간이 모자랄 때의 에러 (upload.go)
+var ErrQuotaExceeded = errors.New("upload quota exceeded")
+
+// 저장된 파일 하나의 정보
+type StoredFile struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"` // 사용자가 보낸 파일 이름
+	Size     int64     `json:"size"`
+	Type     string    `json:"type"` // 내용으로 알아낸 MIME type
+	Uploaded time.Time `json:"uploaded"`
+	Owner    string    `json:"owner,omitempty"` // 올린 사람 (apikeys.go)
+}
+
+// 디렉토리 하나에 파일들을 보관하는 저장소
+type FileStore struct {
+	dir     string
+	mu      sync.RWMutex
+	files   map[string]StoredFile
+	pending map[string]int64 // 올린 사람 -> 목록에 넣는 중인 파일들의 크기 합
+	Scanner Scanner          // nil이면 검사하지 않습니다.
+	Quota   int64            // 한 사람이 쓸 수 있는 바이트. 0이면 제한 없음 (upload.go)
+}
+
+// 업로드된 파일들의 저장소. main에서 NewFileStore로 만듭니다.
+var files *FileStore
+
+// dir에 파일 저장소를 만듭니다. 디렉토리가 없으면 만들고, 있으면 정보 파일들을 읽습니다.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, files: make(map[string]StoredFile), pending: make(map[string]int64)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f StoredFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+		s.files[f.ID] = f
+	}
+	return s, nil
+}
+
+// 파일 하나의 무작위 ID
+func newFileID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ID가 이 저장소가 만든 모양인지. 경로에 쓰기 전에 확인합니다.
+func validFileID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// id 파일의 디스크 경로
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// r의 내용을 새 파일로 저장합니다. limit 바이트보다 크면 ErrFileTooLarge 를 돌려주고 아무것도 남기지 않습니다.
+// 다 쓴 다음에야 목록에 넣으므로, 쓰는 도중의 파일은 다른 요청에게 보이지 않습니다.
+func (s *FileStore) Save(ctx context.Context, owner, name, contentType string, r io.Reader, limit int64) (StoredFile, error) {
+	f := StoredFile{ID: newFileID(), Name: SafeHeaderValue(filepath.Base(name)), Type: contentType, Uploaded: time.Now().UTC(), Owner: owner}
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return f, err
+	}
+	defer os.Remove(tmp.Name()) // Rename에 성공하면 아무것도 지우지 않습니다.
+
+	f.Size, err = io.Copy(tmp, io.LimitReader(r, limit+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return f, err
+	}
+	if f.Size > limit {
+		return f, ErrFileTooLarge
+	}
+	return f, s.add(ctx, tmp.Name(), f)
+}
+
+// 디스크에 이미 다 받아 둔 path 파일을 저장소로 옮깁니다. (이어받기 업로드가 씁니다.)
+// path는 저장소와 같은 파일 시스템에 있어야 합니다.
+func (s *FileStore) Import(ctx context.Context, path, owner, name, contentType string) (StoredFile, error) {
+	f := StoredFile{ID: newFileID(), Name: SafeHeaderValue(filepath.Base(name)), Type: contentType, Uploaded: time.Now().UTC(), Owner: owner}
+	info, err := os.Stat(path)
+	if err != nil {
+		return f, err
+	}
+	f.Size = info.Size()
+	return f, s.add(ctx, path, f)
+}
+
+// 정보 파일을 쓰고 path를 f.ID 로 옮긴 다음 목록에 넣습니다.
+// 검사에 통과하지 못하면 격리하고 에러를 돌려줍니다.
+func (s *FileStore) add(ctx context.Context, path string, f StoredFile) (err error) {
+	if err := s.reserve(f.Owner, f.Size); err != nil {
+		return err
+	}
+	defer func() {
+		s.mu.Lock()
+		s.release(f.Owner, f.Size)
+		if err == nil {
+			s.files[f.ID] = f
+		}
+		s.mu.Unlock()
+	}()
+
+	if err := s.scan(ctx, path, f); err != nil {
+		if qerr := s.quarantine(ctx, path, f); qerr != nil {
+			Logf(ctx, "quarantine %s: %v", f.ID, qerr)
+		}
+		return err
+	}
+
+	info, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path(f.ID)+".json", info, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(path, s.path(f.ID)); err != nil {
+		os.Remove(s.path(f.ID) + ".json")
+		return err
+	}
+	return nil
+}
+
+// owner 의 공간에서 size 바이트를 잡아 둡니다. 저장된 파일과 잡아 둔 크기의 합이 Quota 를 넘으면 ErrQuotaExceeded
+func (s *FileStore) reserve(owner string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Quota > 0 {
+		used := s.pending[owner]
+		for _, f := range s.files {
+			if f.Owner == owner {
+				used += f.Size
+			}
+		}
+		if used+size > s.Quota {
+			return ErrQuotaExceeded
+		}
+	}
+	s.pending[owner] += size
+	return nil
+}
+
+// reserve 로 잡아 둔 크기를 놓습니다. s.mu 를 잡고 부릅니다.
+func (s *FileStore) release(owner string, size int64) {
+	if s.pending[owner] -= size; s.pending[owner] <= 0 {
+		delete(s.pending, owner)
+	}
+}
+
+// Scanner로 path 파일을 검사합니다.
+func (s *FileStore) scan(ctx context.Context, path string, f StoredFile) error {
+	if s.Scanner == nil {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return s.Scanner.Scan(ctx, f, file)
+}
+
+// path 파일을 정보와 함께 .quarantine/ 으로 옮깁니다. 목록에는 넣지 않습니다.
+func (s *FileStore) quarantine(ctx context.Context, path string, f StoredFile) error {
+	dir := filepath.Join(s.dir, ".quarantine")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, f.ID+".json"), info, 0o600); err != nil {
+		return err
+	}
+	Logf(ctx, "upload %s (%s) quarantined", f.ID, f.Name)
+	return os.Rename(path, filepath.Join(dir, f.ID))
+}
+
+// id의 파일 정보를 찾습니다.
+func (s *FileStore) Get(id string) (StoredFile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.files[id]
+	return f, ok
+}
+
+// 저장된 파일의 개수
+func (s *FileStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.files)
+}
+
+// owner가 올린 파일들의 크기 합과 개수
+func (s *FileStore) Usage(owner string) (bytes int64, count int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range s.files {
+		if f.Owner == owner {
+			bytes += f.Size
+			count++
+		}
+	}
+	return bytes, count
+}
+
+// id의 파일을 엽니다.
+func (s *FileStore) Open(id string) (*os.File, StoredFile, error) {
+	f, ok := s.Get(id)
+	if !ok || !validFileID(id) {
+		return nil, f, os.ErrNotExist
+	}
+	file, err := os.Open(s.path(id))
+	return file, f, err
+}
+
+// id의 파일과 정보를 지웁니다.
+func (s *FileStore) Delete(id string) error {
+	if !validFileID(id) {
+		return os.ErrNotExist
+	}
+	s.mu.Lock()
+	delete(s.files, id)
+	s.mu.Unlock()
+	os.Remove(s.path(id) + ".json")
+	thumbs, _ := filepath.Glob(s.path(id) + ".thumb-*.png")
+	for _, thumb := range thumbs {
+		os.Remove(thumb)
+	}
+	return os.Remove(s.path(id))
+}
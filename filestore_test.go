@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 같은 사람의 업로드가 동시에 끝나도 크기 합이 Quota 를 넘지 않습니다.
+func TestFileStoreQuotaConcurrent(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Quota = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Save(context.Background(), "ip:192.0.2.1", "a.txt", "text/plain", strings.NewReader(strings.Repeat("x", 30)), 1000)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	saved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, ErrQuotaExceeded):
+			t.Errorf("Save: %v", err)
+		}
+	}
+	if used, count := store.Usage("ip:192.0.2.1"); saved != 3 || count != 3 || used != 90 {
+		t.Errorf("saved %d files, usage %d bytes in %d files; want 3 files, 90 bytes", saved, used, count)
+	}
+	if _, err := store.Save(context.Background(), "ip:192.0.2.2", "b.txt", "text/plain", strings.NewReader("x"), 1000); err != nil {
+		t.Errorf("another owner: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+//
+// form.go
+//
+// /form 은 HTML 폼을 POST로 받아 서버에서 검사하는 예제입니다.
+// 홈 페이지에도 같은 폼이 있습니다. (templates/partials/form.html)
+//
+//   GET  /form   빈 폼
+//   POST /form   값이 올바르면 받은 값을 보여주고, 아니면 입력한 값과 에러를 담아 폼을 다시 보여줍니다.
+//
+//   $ curl -d 'name=imdhson&email=me@example.com&message=hi' http://localhost:8080/form
+//
+// 브라우저의 required 같은 검사는 건너뛸 수 있으므로 서버에서 다시 검사합니다.
+// 받은 값은 html/template이 이스케이프하므로 그대로 다시 보여줘도 안전합니다.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// 폼 필드의 최대 글자 수
+const (
+	formMaxName    = 40
+	formMaxMessage = 1000
+)
+
+// 폼 템플릿(templates/form.html, partials/form.html)에 넘겨주는 값들
+type FormPage struct {
+	Name    string
+	Email   string
+	Message string
+	Errors  map[string]string // 필드 이름 -> 에러 메시지의 uiCatalog 키
+	Success bool
+}
+
+// 필드들을 검사해 Errors를 채웁니다. 에러가 없으면 true를 돌려줍니다.
+func (f *FormPage) Validate() bool {
+	f.Errors = make(map[string]string)
+	switch {
+	case f.Name == "":
+		f.Errors["name"] = "form.required"
+	case utf8.RuneCountInString(f.Name) > formMaxName:
+		f.Errors["name"] = "form.too_long"
+	}
+	if f.Email == "" {
+		f.Errors["email"] = "form.required"
+	} else if addr, err := mail.ParseAddress(f.Email); err != nil || addr.Address != f.Email {
+		f.Errors["email"] = "form.email_invalid"
+	}
+	switch {
+	case f.Message == "":
+		f.Errors["message"] = "form.required"
+	case utf8.RuneCountInString(f.Message) > formMaxMessage:
+		f.Errors["message"] = "form.too_long"
+	}
+	return len(f.Errors) == 0
+}
+
+// /form 에 대한 응답
+func FormHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		RenderTemplate(response, request, "form", FormPage{})
+	case http.MethodPost:
+		request.Body = http.MaxBytesReader(response, request.Body, 64*1024)
+		if err := request.ParseForm(); err != nil {
+			WriteError(response, request, http.StatusBadRequest, fmt.Errorf("form parse error %v", err))
+			return
+		}
+		form := FormPage{
+			Name:    strings.TrimSpace(request.PostForm.Get("name")),
+			Email:   strings.TrimSpace(request.PostForm.Get("email")),
+			Message: strings.TrimSpace(request.PostForm.Get("message")),
+		}
+		if !form.Validate() {
+			RenderTemplateStatus(response, request, http.StatusUnprocessableEntity, "form", form)
+			return
+		}
+		form.Success = true
+		RenderTemplate(response, request, "form", form)
+	default:
+		response.Header().Set("Allow", "GET, HEAD, POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+	}
+}
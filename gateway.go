@@ -0,0 +1,698 @@
+//
+// gateway.go
+//
+// items.proto 의 google.api.http 옵션대로 gRPC ItemService(grpc.go)를 JSON REST 로도 받습니다. (grpc-gateway 와 같은 방식)
+// 서버가 시작할 때 실행 파일 안의 items.proto 를 읽어 주소와 메시지 모양을 알아내므로
+// 코드를 따로 만들지 않아도 proto 파일 하나로 gRPC 와 REST 가 늘 같습니다.
+//
+//   rpc GetItem(GetItemRequest) returns (Item) { option (google.api.http) = { get: "/v1/items/{name}" }; }
+//
+//   $ curl localhost:8080/v1/items/yellow
+//   {"name":"yellow","what":"item"}
+//   $ curl -d '{"name":"green"}' localhost:8080/v1/items
+//   {"name":"green","what":"item"}
+//   $ curl 'localhost:8080/v1/items:watch?since=3'
+//   {"result":{"item":{"name":"green","what":"item"},"seq":"4","time":"2026-10-16T09:00:00.000000001Z"}}
+//
+// 요청 메시지는 body 옵션("*" 이면 본문 전체), 주소의 {필드}, 나머지는 ?필드=값 순서로 채웁니다.
+// JSON 은 proto3 JSON 매핑을 따릅니다. 필드 이름은 lowerCamelCase, 64비트 정수는 문자열,
+// google.protobuf.Timestamp 는 RFC 3339 문자열입니다. 응답에는 값이 없는 필드도 기본값으로 넣습니다.
+// gRPC 상태 코드는 HTTP 상태 코드로 바꿔 이 서버의 에러 형식(messages.go)으로 돌려줍니다.
+//
+// gRPC 를 켰을 때("grpc": {"enabled": true})만 등록하고, gRPC 와 같은 allowed_ips, username/password, users 로 막습니다.
+// Basic 인증은 Authorization 헤더로 보냅니다.
+//
+//   $ curl -u svc:... localhost:8080/v1/items
+//
+// proto 에 있는 메서드를 grpc.go 가 구현하지 않았거나 그 반대이면 서버가 시작하지 않습니다.
+// 지원하는 것은 이 서버가 쓰는 만큼입니다: 중첩되지 않은 message, repeated, string, bool,
+// (u)int32, (u)int64, Timestamp, 서버 스트리밍. enum, oneof, map, 클라이언트 스트리밍은 없습니다.
+
+package main
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ItemService 의 정의
+//
+//go:embed items.proto
+var itemsProto string
+
+// proto 파일에서 읽은 서비스 하나와 메시지들
+type protoSchema struct {
+	Package  string
+	Service  string
+	Methods  []protoMethod
+	Messages map[string]*protoMessage
+}
+
+// rpc 하나와 그 REST 주소
+type protoMethod struct {
+	Name, Input, Output string
+	Stream              bool   // 서버 스트리밍
+	HTTPMethod          string // 비어 있으면 REST 로는 받지 않습니다.
+	HTTPPath            string
+	Body                string // "*" 이면 본문 전체, 필드 이름이면 그 필드
+}
+
+// message 하나
+type protoMessage struct {
+	Name   string
+	Fields []protoFieldDesc
+}
+
+// message 의 필드 하나
+type protoFieldDesc struct {
+	Name     string
+	JSONName string
+	Type     string
+	Number   int
+	Repeated bool
+}
+
+// google.protobuf.Timestamp 의 이름
+const protoTimestamp = "google.protobuf.Timestamp"
+
+// 지원하는 스칼라 타입
+var protoScalarTypes = map[string]bool{
+	"string": true, "bool": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+}
+
+// 이름으로 필드를 찾습니다. proto 이름과 JSON 이름 모두 받습니다.
+func (m *protoMessage) field(name string) *protoFieldDesc {
+	for i := range m.Fields {
+		if m.Fields[i].Name == name || m.Fields[i].JSONName == name {
+			return &m.Fields[i]
+		}
+	}
+	return nil
+}
+
+// snake_case 를 lowerCamelCase 로 바꿉니다.
+func protoJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// proto 파일의 토큰들. 주석은 빼고, 문자열은 따옴표를 붙인 채로 둡니다.
+var protoTokenPattern = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/|"(?:[^"\\]|\\.)*"|[A-Za-z0-9_.]+|\S`)
+
+// proto 파일을 읽습니다. 이 서버가 쓰는 만큼의 문법만 압니다.
+func parseProtoSchema(src string) (*protoSchema, error) {
+	var tokens []string
+	for _, token := range protoTokenPattern.FindAllString(src, -1) {
+		if !strings.HasPrefix(token, "//") && !strings.HasPrefix(token, "/*") {
+			tokens = append(tokens, token)
+		}
+	}
+	p := &protoParser{tokens: tokens}
+	schema := &protoSchema{Messages: map[string]*protoMessage{}}
+	for !p.done() {
+		switch token := p.next(); token {
+		case "syntax", "import", "option":
+			p.skipStatement()
+		case "package":
+			schema.Package = p.next()
+			p.expect(";")
+		case "message":
+			message := p.parseMessage()
+			schema.Messages[message.Name] = message
+		case "service":
+			if schema.Service != "" {
+				p.fail("only one service is supported")
+			}
+			schema.Service = p.next()
+			schema.Methods = p.parseService()
+		default:
+			p.fail("unexpected %q", token)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return schema, schema.check()
+}
+
+// 토큰을 하나씩 읽습니다. 처음 만난 에러만 기억하고, 그 뒤로는 빈 토큰을 돌려줍니다.
+type protoParser struct {
+	tokens []string
+	pos    int
+	err    error
+}
+
+func (p *protoParser) done() bool {
+	return p.err != nil || p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("proto: "+format, args...)
+	}
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	if p.done() {
+		p.fail("unexpected end of file")
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *protoParser) expect(token string) {
+	if got := p.next(); got != token && p.err == nil {
+		p.fail("expected %q, got %q", token, got)
+	}
+}
+
+// ; 까지 건너뜁니다.
+func (p *protoParser) skipStatement() {
+	for !p.done() && p.next() != ";" {
+	}
+}
+
+// 따옴표를 뗀 문자열
+func (p *protoParser) str() string {
+	token := p.next()
+	s, err := strconv.Unquote(token)
+	if err != nil {
+		p.fail("expected string, got %q", token)
+	}
+	return s
+}
+
+// message 이름 { [repeated] 타입 이름 = 번호; ... }
+func (p *protoParser) parseMessage() *protoMessage {
+	message := &protoMessage{Name: p.next()}
+	p.expect("{")
+	for !p.done() && p.peek() != "}" {
+		var field protoFieldDesc
+		if field.Type = p.next(); field.Type == "repeated" {
+			field.Repeated = true
+			field.Type = p.next()
+		}
+		switch field.Type {
+		case "message", "enum", "oneof", "map", "reserved", "option":
+			p.fail("%s in message %s is not supported", field.Type, message.Name)
+		}
+		field.Name = p.next()
+		field.JSONName = protoJSONName(field.Name)
+		p.expect("=")
+		number, err := strconv.Atoi(p.next())
+		if err != nil || number <= 0 {
+			p.fail("bad field number for %s.%s", message.Name, field.Name)
+		}
+		field.Number = number
+		if p.peek() == "[" {
+			for !p.done() && p.next() != "]" {
+			}
+		}
+		p.expect(";")
+		message.Fields = append(message.Fields, field)
+	}
+	p.expect("}")
+	return message
+}
+
+// service 이름 { rpc ... } 의 rpc 들
+func (p *protoParser) parseService() []protoMethod {
+	var methods []protoMethod
+	p.expect("{")
+	for !p.done() && p.peek() != "}" {
+		if token := p.next(); token == "option" {
+			p.skipStatement()
+			continue
+		} else if token != "rpc" {
+			p.fail("unexpected %q in service", token)
+			break
+		}
+		method := protoMethod{Name: p.next()}
+		p.expect("(")
+		if method.Input = p.next(); method.Input == "stream" {
+			p.fail("client streaming %s is not supported", method.Name)
+		}
+		p.expect(")")
+		p.expect("returns")
+		p.expect("(")
+		if method.Output = p.next(); method.Output == "stream" {
+			method.Stream = true
+			method.Output = p.next()
+		}
+		p.expect(")")
+		if p.peek() == "{" {
+			p.next()
+			for !p.done() && p.peek() != "}" {
+				p.parseMethodOption(&method)
+			}
+			p.expect("}")
+		} else {
+			p.expect(";")
+		}
+		methods = append(methods, method)
+	}
+	p.expect("}")
+	return methods
+}
+
+// option (google.api.http) = { get: "/v1/items/{name}" body: "*" };
+// 다른 옵션은 건너뜁니다.
+func (p *protoParser) parseMethodOption(method *protoMethod) {
+	p.expect("option")
+	if p.peek() != "(" {
+		p.skipStatement()
+		return
+	}
+	p.next()
+	name := p.next()
+	p.expect(")")
+	if name != "google.api.http" {
+		p.skipStatement()
+		return
+	}
+	p.expect("=")
+	p.expect("{")
+	for !p.done() && p.peek() != "}" {
+		key := p.next()
+		p.expect(":")
+		value := p.str()
+		switch key {
+		case "get", "put", "post", "delete", "patch":
+			method.HTTPMethod, method.HTTPPath = strings.ToUpper(key), value
+		case "body":
+			method.Body = value
+		default:
+			p.fail("google.api.http %s of %s is not supported", key, method.Name)
+		}
+		if p.peek() == "," {
+			p.next()
+		}
+	}
+	p.expect("}")
+	p.expect(";")
+}
+
+// 메서드와 필드가 가리키는 타입이 모두 있는지
+func (s *protoSchema) check() error {
+	known := func(name string) bool {
+		_, ok := s.Messages[name]
+		return ok || name == protoTimestamp
+	}
+	for _, message := range s.Messages {
+		for _, field := range message.Fields {
+			if !protoScalarTypes[field.Type] && !known(field.Type) {
+				return fmt.Errorf("proto: %s.%s has unsupported type %s", message.Name, field.Name, field.Type)
+			}
+		}
+	}
+	for _, method := range s.Methods {
+		if !known(method.Input) || !known(method.Output) {
+			return fmt.Errorf("proto: %s uses an unknown message", method.Name)
+		}
+		if method.Body != "" && method.Body != "*" && s.Messages[method.Input].field(method.Body) == nil {
+			return fmt.Errorf("proto: %s body %q is not a field of %s", method.Name, method.Body, method.Input)
+		}
+	}
+	return nil
+}
+
+// JSON 값(json.Decoder 의 UseNumber 로 읽은 것)들을 message 로 인코딩합니다.
+// 주소나 ?필드=값 에서 온 값은 문자열이므로 숫자와 bool 도 문자열로 받습니다.
+func (s *protoSchema) encodeJSON(message *protoMessage, values map[string]interface{}) ([]byte, error) {
+	for name := range values {
+		if message.field(name) == nil {
+			return nil, fmt.Errorf("unknown field %q in %s", name, message.Name)
+		}
+	}
+	var b []byte
+	for _, field := range message.Fields {
+		value, ok := values[field.JSONName]
+		if !ok {
+			value = values[field.Name]
+		}
+		if value == nil {
+			continue
+		}
+		if !field.Repeated {
+			var err error
+			if b, err = s.appendJSONValue(b, field, value, false); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list", field.JSONName)
+		}
+		for _, element := range list {
+			var err error
+			if b, err = s.appendJSONValue(b, field, element, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// 필드 값 하나를 붙입니다. keep 이면 기본값이어도 붙입니다. (repeated 의 원소)
+func (s *protoSchema) appendJSONValue(b []byte, field protoFieldDesc, value interface{}, keep bool) ([]byte, error) {
+	text, isText := value.(string)
+	if number, ok := value.(json.Number); ok {
+		text, isText = number.String(), field.Type != "string"
+	}
+	switch field.Type {
+	case "string":
+		if !isText {
+			return nil, fmt.Errorf("%s must be a string", field.JSONName)
+		}
+		if keep || text != "" {
+			b = appendProtoMessage(b, field.Number, []byte(text))
+		}
+		return b, nil
+	case "bool", "int32", "int64", "uint32", "uint64":
+		var n uint64
+		var err error
+		bits, _ := strconv.Atoi(field.Type[len(field.Type)-2:])
+		switch v, isBool := value.(bool); {
+		case isBool && field.Type == "bool":
+			if v {
+				n = 1
+			}
+		case !isText:
+			err = errors.New("wrong type")
+		case field.Type == "bool":
+			if v, err = strconv.ParseBool(text); v {
+				n = 1
+			}
+		case strings.HasPrefix(field.Type, "uint"):
+			n, err = strconv.ParseUint(text, 10, bits)
+		default:
+			var v int64
+			v, err = strconv.ParseInt(text, 10, bits)
+			n = uint64(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s must be %s", field.JSONName, field.Type)
+		}
+		if keep || n != 0 {
+			b = binary.AppendUvarint(appendProtoTag(b, field.Number, protoVarint), n)
+		}
+		return b, nil
+	case protoTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if !isText || err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 time", field.JSONName)
+		}
+		return appendProtoTimestamp(b, field.Number, t), nil
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", field.JSONName)
+	}
+	msg, err := s.encodeJSON(s.Messages[field.Type], values)
+	if err != nil {
+		return nil, err
+	}
+	return appendProtoMessage(b, field.Number, msg), nil
+}
+
+// 인코딩한 message 를 JSON 으로 바꿀 값으로 읽습니다. 없는 필드는 기본값으로 채웁니다.
+func (s *protoSchema) decodeProto(message *protoMessage, b []byte) (map[string]interface{}, error) {
+	fields, err := parseProto(b)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(message.Fields))
+	for _, field := range message.Fields {
+		if field.Repeated {
+			values[field.JSONName] = []interface{}{}
+		} else {
+			values[field.JSONName] = protoDefault(field.Type)
+		}
+	}
+	for _, wire := range fields {
+		var field *protoFieldDesc
+		for i := range message.Fields {
+			if message.Fields[i].Number == wire.Number {
+				field = &message.Fields[i]
+			}
+		}
+		if field == nil {
+			// 모르는 필드는 proto 처럼 건너뜁니다.
+			continue
+		}
+		value, err := s.decodeProtoValue(*field, wire)
+		if err != nil {
+			return nil, err
+		}
+		if field.Repeated {
+			values[field.JSONName] = append(values[field.JSONName].([]interface{}), value)
+		} else {
+			values[field.JSONName] = value
+		}
+	}
+	return values, nil
+}
+
+// 타입의 기본값. message 는 null 입니다.
+func protoDefault(typ string) interface{} {
+	switch typ {
+	case "string":
+		return ""
+	case "bool":
+		return false
+	case "int32", "uint32":
+		return 0
+	case "int64", "uint64":
+		return "0"
+	}
+	return nil
+}
+
+// 필드 값 하나를 JSON 으로 바꿀 값으로 읽습니다.
+func (s *protoSchema) decodeProtoValue(field protoFieldDesc, wire protoField) (interface{}, error) {
+	if (wire.Type == protoBytes) != (!protoScalarTypes[field.Type] || field.Type == "string") {
+		return nil, fmt.Errorf("protobuf: wrong wire type for %s", field.Name)
+	}
+	switch field.Type {
+	case "string":
+		return string(wire.Bytes), nil
+	case "bool":
+		return wire.Value != 0, nil
+	case "int32":
+		return int32(wire.Value), nil
+	case "uint32":
+		return uint32(wire.Value), nil
+	case "int64":
+		return strconv.FormatInt(int64(wire.Value), 10), nil
+	case "uint64":
+		return strconv.FormatUint(wire.Value, 10), nil
+	case protoTimestamp:
+		fields, err := parseProto(wire.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		var seconds, nanos int64
+		for _, f := range fields {
+			switch f.Number {
+			case 1:
+				seconds = int64(f.Value)
+			case 2:
+				nanos = int64(int32(f.Value))
+			}
+		}
+		return time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano), nil
+	}
+	return s.decodeProto(s.Messages[field.Type], wire.Bytes)
+}
+
+// gRPC 상태 코드에 맞는 HTTP 상태 코드
+func grpcHTTPStatus(code int) int {
+	switch code {
+	case grpcOK:
+		return http.StatusOK
+	case grpcInvalidArgument:
+		return http.StatusBadRequest
+	case grpcDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case grpcNotFound:
+		return http.StatusNotFound
+	case grpcPermissionDenied:
+		return http.StatusForbidden
+	case grpcResourceExhausted:
+		return http.StatusTooManyRequests
+	case grpcUnimplemented:
+		return http.StatusNotImplemented
+	case grpcUnavailable:
+		return http.StatusServiceUnavailable
+	case grpcUnauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// {name} 같은 주소의 필드 이름들
+var gatewayPathField = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// gRPC 가 켜져 있으면 items.proto 의 REST 주소들을 config 의 접근 제한(guard.go)과 함께 mux 에 등록합니다.
+// proto 의 메서드와 methods(grpc.go 의 구현)가 하나라도 다르면 에러를 돌려줍니다.
+func RegisterGateway(mux *http.ServeMux, config GRPCConfig, methods map[string]grpcMethod) error {
+	schema, err := parseProtoSchema(itemsProto)
+	if err != nil {
+		return err
+	}
+	prefix := "/" + schema.Package + "." + schema.Service + "/"
+	declared := map[string]bool{}
+	for _, method := range schema.Methods {
+		declared[prefix+method.Name] = true
+		if methods[prefix+method.Name] == nil {
+			return fmt.Errorf("%s%s is in items.proto but not implemented", prefix, method.Name)
+		}
+	}
+	for path := range methods {
+		if !declared[path] {
+			return fmt.Errorf("%s is implemented but not in items.proto", path)
+		}
+	}
+	if !config.Enabled {
+		return nil
+	}
+	for _, method := range schema.Methods {
+		if method.HTTPMethod == "" {
+			continue
+		}
+		handler, err := GuardHandler(config.GuardConfig, "grpc", gatewayHandler(schema, method, methods[prefix+method.Name]))
+		if err != nil {
+			return fmt.Errorf("grpc: %v", err)
+		}
+		mux.Handle(method.HTTPMethod+" "+method.HTTPPath, handler)
+	}
+	return nil
+}
+
+// REST 요청을 요청 메시지로 바꿔 call 을 부르고, 응답 메시지를 JSON 으로 돌려줍니다.
+func gatewayHandler(schema *protoSchema, method protoMethod, call grpcMethod) http.Handler {
+	input, output := schema.Messages[method.Input], schema.Messages[method.Output]
+	pathFields := gatewayPathField.FindAllStringSubmatch(method.HTTPPath, -1)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		values := map[string]interface{}{}
+		if method.Body != "" {
+			decoder := json.NewDecoder(http.MaxBytesReader(response, request.Body, grpcMaxMessageSize))
+			decoder.UseNumber()
+			var body interface{}
+			if err := decoder.Decode(&body); err != nil {
+				WriteError(response, request, http.StatusBadRequest, fmt.Errorf("%s decode error %v", method.Name, err))
+				return
+			}
+			if method.Body != "*" {
+				values[method.Body] = body
+			} else if object, ok := body.(map[string]interface{}); ok {
+				values = object
+			} else {
+				WriteError(response, request, http.StatusBadRequest, fmt.Errorf("%s body must be an object", method.Name))
+				return
+			}
+		}
+		for _, match := range pathFields {
+			values[match[1]] = request.PathValue(match[1])
+		}
+		for name, list := range request.URL.Query() {
+			field := input.field(name)
+			if field == nil || values[field.Name] != nil || values[field.JSONName] != nil {
+				continue
+			}
+			if field.Repeated {
+				elements := make([]interface{}, len(list))
+				for i, s := range list {
+					elements[i] = s
+				}
+				values[name] = elements
+			} else {
+				values[name] = list[0]
+			}
+		}
+		in, err := schema.encodeJSON(input, values)
+		if err != nil {
+			WriteError(response, request, http.StatusBadRequest, fmt.Errorf("%s: %v", method.Name, err))
+			return
+		}
+
+		if !method.Stream {
+			var out []byte
+			err := call(withAccessRequest(request), in, func(b []byte) error { out = b; return nil })
+			var result map[string]interface{}
+			if err == nil {
+				result, err = schema.decodeProto(output, out)
+			}
+			if err != nil {
+				writeGatewayError(response, request, method, err)
+				return
+			}
+			WriteJSON(response, http.StatusOK, result)
+			return
+		}
+
+		// 서버 스트리밍은 한 줄에 {"result": 메시지} 하나씩 보냅니다.
+		SetContentType(response, "application/json")
+		response.Header().Set("Cache-Control", "no-store")
+		response.Header().Set("X-Accel-Buffering", "no")
+		controller := http.NewResponseController(response)
+		encoder := json.NewEncoder(response)
+		started := false
+		err = call(withAccessRequest(request), in, func(b []byte) error {
+			result, err := schema.decodeProto(output, b)
+			if err != nil {
+				return err
+			}
+			started = true
+			if err := encoder.Encode(map[string]interface{}{"result": result}); err != nil {
+				return err
+			}
+			return controller.Flush()
+		})
+		if err == nil || request.Context().Err() != nil {
+			return
+		}
+		if !started {
+			writeGatewayError(response, request, method, err)
+			return
+		}
+		// 이미 200 을 보냈으므로 마지막 줄에 에러를 넣습니다.
+		code, message := grpcStatus(err)
+		encoder.Encode(map[string]interface{}{"error": map[string]interface{}{"code": code, "message": message}})
+	})
+}
+
+// call 의 에러를 HTTP 상태 코드로 바꿔 응답합니다.
+func writeGatewayError(response http.ResponseWriter, request *http.Request, method protoMethod, err error) {
+	code, _ := grpcStatus(err)
+	WriteError(response, request, grpcHTTPStatus(code), fmt.Errorf("%s: %v", method.Name, err))
+}
@@ -0,0 +1,4 @@
+module github.com/imdhson/forked-golang-webserver
+
+// go 줄은 GODEBUG 기본값도 정합니다. 1.22 보다 낮추면 "GET /v1/items/{name}" 같은 ServeMux 패턴이 동작하지 않습니다.
+go 1.24
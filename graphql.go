@@ -0,0 +1,331 @@
+//
+// graphql.go
+//
+// /graphql 은 item 저장소(store.go)를 GraphQL 로 보여줍니다. 실행기는 graphqlexec.go 에 있습니다.
+//
+//   type Item       { name: String!  what: String! }
+//   type ItemChange { seq: Int!  item: Item!  time: String! }
+//   type Query        { item(name: String!): Item   items: [Item!]! }
+//   type Mutation     { putItem(name: String!, what: String): Item! }
+//   type Subscription { itemChanged(since: Int): ItemChange! }
+//
+//   $ curl -d '{"query": "{ items { name } }"}' localhost:8080/graphql
+//   {"data":{"items":[{"name":"foo"},{"name":"purple"},{"name":"yellow"}]}}
+//   $ curl 'localhost:8080/graphql?query=\{item(name:"yellow")\{what\}\}'
+//
+// query 는 GET 과 POST 로, mutation 은 POST 로만 받습니다. GraphQL 에러도 200 으로 보내고,
+// 본문을 읽을 수 없거나 문법이 틀리면 400 입니다.
+//
+// subscription 은 같은 주소의 WebSocket 으로 graphql-transport-ws 프로토콜을 씁니다.
+// (https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md) 변경은 이벤트 버스(events.go)에서 받고,
+// since 를 주면 그 뒤의 지난 변경부터 보냅니다. /items/events 와 같은 순서 번호입니다.
+//
+// 개발 모드(-dev)에서는 브라우저로 GET /graphql 을 열면 GraphiQL 페이지를 보여줍니다.
+// GraphiQL 은 vendor-assets.sh 로 static/vendor/ 에 내려받아 둔 것을 씁니다. 다른 출처에서 받지 않으므로 CSP(csp.go)를 켜도 됩니다.
+// 저장소에는 들어 있지 않으므로 -dev 로 띄우기 전에 한 번 내려받아 둡니다. 파일이 없으면 서버가 시작하지 않습니다.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// graphql-transport-ws 하위 프로토콜
+const GraphQLProtocolWS = "graphql-transport-ws"
+
+// GraphiQL 페이지(templates/graphiql.html)가 쓰는 static/ 의 파일들
+var graphiQLAssets = []string{
+	"vendor/graphiql@3.7.1/graphiql.min.css",
+	"vendor/react@18.3.1/react.production.min.js",
+	"vendor/react-dom@18.3.1/react-dom.production.min.js",
+	"vendor/graphql-ws@5.16.0/graphql-ws.min.js",
+	"vendor/graphiql@3.7.1/graphiql.min.js",
+}
+
+// /graphql 의 스키마
+var itemsGraphQLSchema = &gqlSchema{
+	Query:        "Query",
+	Mutation:     "Mutation",
+	Subscription: "Subscription",
+	Types: map[string]*gqlType{
+		"Item": {Name: "Item", Kind: "OBJECT", Description: "저장소의 item 하나", Fields: []*gqlField{
+			{Name: "name", Type: "String!", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(Item).Name, nil
+			}},
+			{Name: "what", Type: "String!", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(Item).What, nil
+			}},
+		}},
+		"ItemChange": {Name: "ItemChange", Kind: "OBJECT", Description: "저장소의 변경 하나", Fields: []*gqlField{
+			{Name: "seq", Type: "Int!", Description: "/items/changes 와 같은 순서 번호", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(ItemChange).Seq, nil
+			}},
+			{Name: "item", Type: "Item!", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(ItemChange).Item, nil
+			}},
+			{Name: "time", Type: "String!", Description: "RFC 3339", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(ItemChange).Time.UTC().Format(time.RFC3339Nano), nil
+			}},
+		}},
+		"Query": {Name: "Query", Kind: "OBJECT", Fields: []*gqlField{
+			{Name: "item", Type: "Item", Description: "이름으로 item 하나를 찾습니다. 없으면 null",
+				Args: []gqlArg{{Name: "name", Type: "String!"}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					if item, ok := store.Get(args["name"].(string)); ok {
+						return item, nil
+					}
+					return nil, nil
+				}},
+			{Name: "items", Type: "[Item!]!", Description: "모든 item 을 이름 순서로",
+				Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+					return store.List(), nil
+				}},
+		}},
+		"Mutation": {Name: "Mutation", Kind: "OBJECT", Fields: []*gqlField{
+			{Name: "putItem", Type: "Item!", Description: "item 을 추가하거나 바꿉니다. POST /items 와 같습니다.",
+				Args: []gqlArg{{Name: "name", Type: "String!"}, {Name: "what", Type: "String"}},
+				Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					item := Item{Name: args["name"].(string)}
+					item.What, _ = args["what"].(string)
+					return SaveItem(ctx, item)
+				}},
+		}},
+		"Subscription": {Name: "Subscription", Kind: "OBJECT", Fields: []*gqlField{
+			{Name: "itemChanged", Type: "ItemChange!", Description: "item 이 바뀔 때마다 보냅니다. since 를 주면 그 뒤의 지난 변경부터",
+				Args:      []gqlArg{{Name: "since", Type: "Int"}},
+				Subscribe: subscribeItemChanges},
+		}},
+	},
+}
+
+// 이벤트 버스의 item 변경을 보냅니다. since 가 있으면 그 뒤의 지난 변경을 먼저 보냅니다.
+func subscribeItemChanges(ctx context.Context, args map[string]interface{}) (<-chan interface{}, error) {
+	// 빠지는 변경이 없도록 지난 변경을 찾기 전에 먼저 구독합니다. (events.go 의 ItemEventsHandler 와 같습니다.)
+	sub := events.Subscribe(TopicItems)
+	var last uint64
+	var missed []ItemChange
+	if since, ok := args["since"].(int); ok && since >= 0 {
+		last = uint64(since)
+		missed, _ = store.ChangesSince(last)
+	}
+	changes := make(chan interface{})
+	go func() {
+		defer close(changes)
+		defer sub.Close()
+		send := func(change ItemChange) bool {
+			if change.Seq <= last {
+				return true
+			}
+			last = change.Seq
+			select {
+			case changes <- change:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, change := range missed {
+			if !send(change) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.C:
+				if !ok || !send(event.Data.(ItemChange)) {
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// /graphql 핸들러. devMode 이면 브라우저에 GraphiQL 을 보여줍니다.
+func GraphQLHandler(config WebSocketConfig, devMode bool) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if headerContains(request.Header, "Upgrade", "websocket") {
+			serveGraphQLSocket(response, request, config)
+			return
+		}
+		var gql gqlRequest
+		switch request.Method {
+		case http.MethodGet:
+			query := request.URL.Query()
+			if devMode && query.Get("query") == "" && AcceptsType(request, "text/html") {
+				RenderTemplate(response, request, "graphiql", nil)
+				return
+			}
+			gql.Query = query.Get("query")
+			gql.OperationName = query.Get("operationName")
+			if variables := query.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &gql.Variables); err != nil {
+					WriteJSON(response, http.StatusBadRequest, gqlErrorResponse("variables: %v", err))
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 64*1024)).Decode(&gql); err != nil {
+				WriteJSON(response, http.StatusBadRequest, gqlErrorResponse("request decode error: %v", err))
+				return
+			}
+		default:
+			response.Header().Set("Allow", "GET, POST")
+			WriteError(response, request, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		if request.Method == http.MethodGet {
+			// GET 은 안전해야 하므로 mutation 을 받지 않습니다.
+			if _, operation, failed := gqlPrepare(itemsGraphQLSchema, gql); failed == nil && operation.kind != "query" {
+				response.Header().Set("Allow", "POST")
+				WriteJSON(response, http.StatusMethodNotAllowed, gqlErrorResponse("%s must use POST", operation.kind))
+				return
+			}
+		}
+		result := gqlExecute(withAccessRequest(request), itemsGraphQLSchema, gql)
+		status := http.StatusOK
+		if result.Data == nil {
+			status = http.StatusBadRequest
+		}
+		response.Header().Set("Cache-Control", "no-store")
+		WriteJSON(response, status, result)
+	})
+}
+
+// graphql-transport-ws 의 메시지
+type graphQLSocketMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// WebSocket 하나로 여러 subscription 을 받습니다.
+func serveGraphQLSocket(response http.ResponseWriter, request *http.Request, config WebSocketConfig) {
+	ws, err := UpgradeWebSocket(response, request, config, GraphQLProtocolWS)
+	if err != nil {
+		Logf(request.Context(), "graphql ws %s: %v", request.RemoteAddr, err)
+		return
+	}
+	defer ws.Close()
+	if ws.Subprotocol != GraphQLProtocolWS {
+		ws.WriteClose(4406, "Subprotocol not acceptable")
+		return
+	}
+	defer presence.Join("graphql.ws", "")()
+
+	// 서버가 닫히거나 연결이 끊기면 모든 subscription 이 끝납니다.
+	ctx, cancel := drainContext(withAccessRequest(request))
+	defer cancel()
+	send := func(message graphQLSocketMessage) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return ws.WriteMessage(OpText, data)
+	}
+
+	var mu sync.Mutex
+	operations := map[string]context.CancelFunc{}
+	defer func() {
+		mu.Lock()
+		for _, stop := range operations {
+			stop()
+		}
+		mu.Unlock()
+	}()
+	var acknowledged atomic.Bool
+	initTimeout := time.AfterFunc(10*time.Second, func() {
+		if !acknowledged.Load() {
+			ws.WriteClose(4408, "Connection initialisation timeout")
+		}
+	})
+	defer initTimeout.Stop()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			logReadError(request.Context(), "graphql ws", ws, err)
+			return
+		}
+		var message graphQLSocketMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			ws.WriteClose(4400, "Invalid message")
+			return
+		}
+		switch message.Type {
+		case "connection_init":
+			if acknowledged.Swap(true) {
+				ws.WriteClose(4429, "Too many initialisation requests")
+				return
+			}
+			send(graphQLSocketMessage{Type: "connection_ack"})
+		case "ping":
+			send(graphQLSocketMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !acknowledged.Load() {
+				ws.WriteClose(4401, "Unauthorized")
+				return
+			}
+			var gql gqlRequest
+			if err := json.Unmarshal(message.Payload, &gql); err != nil || message.ID == "" {
+				ws.WriteClose(4400, "Invalid message")
+				return
+			}
+			mu.Lock()
+			_, exists := operations[message.ID]
+			opCtx, stop := context.WithCancel(ctx)
+			if !exists {
+				operations[message.ID] = stop
+			}
+			mu.Unlock()
+			if exists {
+				stop()
+				ws.WriteClose(4409, fmt.Sprintf("Subscriber for %s already exists", message.ID))
+				return
+			}
+			go func(id string) {
+				defer func() {
+					mu.Lock()
+					delete(operations, id)
+					mu.Unlock()
+					stop()
+				}()
+				results, failed := gqlSubscribe(opCtx, itemsGraphQLSchema, gql)
+				if failed != nil {
+					payload, _ := json.Marshal(failed.Errors)
+					send(graphQLSocketMessage{ID: id, Type: "error", Payload: payload})
+					return
+				}
+				for result := range results {
+					payload, _ := json.Marshal(result)
+					if send(graphQLSocketMessage{ID: id, Type: "next", Payload: payload}) != nil {
+						return
+					}
+				}
+				// 클라이언트가 complete 로 끝냈거나 연결이 끊긴 것이면 알리지 않습니다.
+				if opCtx.Err() == nil {
+					send(graphQLSocketMessage{ID: id, Type: "complete"})
+				}
+			}(message.ID)
+		case "complete":
+			mu.Lock()
+			if stop, ok := operations[message.ID]; ok {
+				stop()
+			}
+			mu.Unlock()
+		default:
+			ws.WriteClose(4400, "Invalid message")
+			return
+		}
+	}
+}
@@ -0,0 +1,1391 @@
+//
+// graphqlexec.go
+//
+// 외부 라이브러리 없이 구현한 작은 GraphQL 실행기입니다. (https://spec.graphql.org/October2021/)
+// /graphql(graphql.go)의 item 스키마를 실행합니다.
+//
+// 지원하는 것: query, mutation, subscription, 변수와 기본값, 별칭, fragment 와 inline fragment,
+// @skip / @include, __typename, GraphiQL 이 쓰는 introspection(__schema, __type).
+// 스키마는 object 와 scalar 타입만 가질 수 있습니다. (interface, union, enum, input object 는 없습니다.)
+//
+// 실행하기 전에 없는 필드와 인자, 하위 선택을 확인하고(validate), 인자 값의 타입은 실행하면서 확인합니다.
+// 에러가 난 필드는 null 이 되고, null 이 될 수 없는 필드(String! 같은)면 null 을 받을 수 있는 부모까지 올라갑니다.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 스키마. 타입 이름으로 찾습니다.
+type gqlSchema struct {
+	Types        map[string]*gqlType
+	Query        string
+	Mutation     string // 비어 있으면 mutation 을 받지 않습니다.
+	Subscription string // 비어 있으면 subscription 을 받지 않습니다.
+}
+
+// object 또는 scalar 타입 하나
+type gqlType struct {
+	Name        string
+	Kind        string // "OBJECT" 또는 "SCALAR"
+	Description string
+	Fields      []*gqlField
+}
+
+// object 타입의 필드 하나. Type 은 "[Item!]!" 처럼 GraphQL 문법으로 씁니다.
+type gqlField struct {
+	Name        string
+	Type        string
+	Description string
+	Args        []gqlArg
+	// nil 이면 source 가 map[string]interface{} 일 때 같은 이름의 값을 씁니다.
+	Resolve func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+	// Subscription 타입의 필드. 채널로 받은 값마다 결과를 하나씩 보냅니다. ctx 가 끝나면 채널을 닫아야 합니다.
+	Subscribe func(ctx context.Context, args map[string]interface{}) (<-chan interface{}, error)
+}
+
+// 필드의 인자 하나. Default 는 "false" 처럼 GraphQL 문법의 값입니다.
+type gqlArg struct {
+	Name        string
+	Type        string
+	Description string
+	Default     string
+}
+
+// 기본 scalar 타입들
+var gqlScalars = []*gqlType{
+	{Name: "String", Kind: "SCALAR"},
+	{Name: "Int", Kind: "SCALAR"},
+	{Name: "Float", Kind: "SCALAR"},
+	{Name: "Boolean", Kind: "SCALAR"},
+	{Name: "ID", Kind: "SCALAR"},
+}
+
+// typ 의 필드를 이름으로 찾습니다.
+func (s *gqlSchema) field(typ *gqlType, name string) *gqlField {
+	for _, field := range typ.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// ---- 요청과 응답 ----
+
+// POST /graphql 의 본문
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// 에러 하나
+type gqlError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// 실행 결과. 실행을 시작하기 전의 에러(문법 같은)면 data 가 없습니다.
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []gqlError      `json:"errors,omitempty"`
+}
+
+// 요청 에러 하나만 있는 응답
+func gqlErrorResponse(format string, args ...interface{}) *gqlResponse {
+	return &gqlResponse{Errors: []gqlError{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+// 필드 순서를 지키는 결과 object
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *gqlObject) set(key string, value interface{}) {
+	if o.values == nil {
+		o.values = map[string]interface{}{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// ---- 어휘 분석 ----
+
+// 토큰 하나. kind 는 'n'(이름), 'i'(정수), 'f'(실수), 's'(문자열), 'p'(구두점), 0(끝)
+type gqlToken struct {
+	kind  byte
+	value string
+}
+
+// query 를 토큰들로 나눕니다. 쉼표와 주석은 버립니다.
+func gqlLex(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{'p', "..."})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+			tokens = append(tokens, gqlToken{'p', string(c)})
+			i++
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || 'a' <= src[j] && src[j] <= 'z' || 'A' <= src[j] && src[j] <= 'Z' || '0' <= src[j] && src[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, gqlToken{'n', src[i:j]})
+			i = j
+		case c == '-' || '0' <= c && c <= '9':
+			j, kind := i+1, byte('i')
+			for j < len(src) && ('0' <= src[j] && src[j] <= '9' || strings.IndexByte(".eE+-", src[j]) >= 0) {
+				if strings.IndexByte(".eE", src[j]) >= 0 {
+					kind = 'f'
+				}
+				j++
+			}
+			tokens = append(tokens, gqlToken{kind, src[i:j]})
+			i = j
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			for end >= 0 && src[i+3+end-1] == '\\' {
+				next := strings.Index(src[i+3+end+3:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 3 + next
+			}
+			if end < 0 {
+				return nil, errors.New("Syntax Error: unterminated block string")
+			}
+			value := strings.ReplaceAll(src[i+3:i+3+end], `\"""`, `"""`)
+			tokens = append(tokens, gqlToken{'s', strings.TrimSpace(value)})
+			i += 3 + end + 3
+		case c == '"':
+			value, n, err := gqlUnquote(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, gqlToken{'s', value})
+			i += n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, fmt.Errorf("Syntax Error: unexpected character %q", r)
+		}
+	}
+	return append(tokens, gqlToken{}), nil
+}
+
+// "..." 문자열을 읽고 읽은 바이트 수를 돌려줍니다.
+func gqlUnquote(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, nil
+		case c == '\n' || c == '\r':
+			return "", 0, errors.New("Syntax Error: unterminated string")
+		case c != '\\':
+			b.WriteByte(c)
+			i++
+		case i+1 >= len(src):
+			return "", 0, errors.New("Syntax Error: unterminated string")
+		default:
+			escape := src[i+1]
+			i += 2
+			if j := strings.IndexByte(`"\/bfnrt`, escape); j >= 0 {
+				b.WriteByte("\"\\/\b\f\n\r\t"[j])
+				continue
+			}
+			if escape != 'u' || i+4 > len(src) {
+				return "", 0, fmt.Errorf("Syntax Error: invalid escape \\%c", escape)
+			}
+			code, err := strconv.ParseUint(src[i:i+4], 16, 16)
+			if err != nil {
+				return "", 0, errors.New("Syntax Error: invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			i += 4
+		}
+	}
+	return "", 0, errors.New("Syntax Error: unterminated string")
+}
+
+// ---- 구문 분석 ----
+
+// query 문서
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// query, mutation, subscription 하나
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDef
+	selections []*gqlSelection
+}
+
+// $name: Type = default
+type gqlVariableDef struct {
+	name       string
+	typ        string
+	def        interface{}
+	hasDefault bool
+}
+
+// fragment Name on Type { ... }
+type gqlFragment struct {
+	on         string
+	selections []*gqlSelection
+}
+
+// 필드, ...Fragment, ... on Type { } 중 하나
+type gqlSelection struct {
+	alias, name string
+	args        map[string]interface{}
+	directives  []gqlDirective
+	selections  []*gqlSelection
+	spread      string // ...Fragment 의 이름
+	inline      bool   // ... on Type { }
+	on          string // inline fragment 의 타입. 비어 있으면 모든 타입
+}
+
+// @name(args)
+type gqlDirective struct {
+	name string
+	args map[string]interface{}
+}
+
+// 값 안의 $변수
+type gqlVariable string
+
+// 값 안의 enum 이름
+type gqlEnum string
+
+// 토큰을 하나씩 읽습니다.
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+func (p *gqlParser) peek() gqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	token := p.tokens[p.pos]
+	if token.kind != 0 {
+		p.pos++
+	}
+	return token
+}
+
+// 다음 토큰이 구두점 punct 이면 읽고 true 를 돌려줍니다.
+func (p *gqlParser) accept(punct string) bool {
+	if token := p.peek(); token.kind == 'p' && token.value == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(punct string) error {
+	if !p.accept(punct) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	if token := p.peek(); token.kind == 'n' {
+		p.pos++
+		return token.value, nil
+	}
+	return "", p.unexpected()
+}
+
+func (p *gqlParser) unexpected() error {
+	if token := p.peek(); token.kind != 0 {
+		return fmt.Errorf("Syntax Error: unexpected %q", token.value)
+	}
+	return errors.New("Syntax Error: unexpected end of document")
+}
+
+// query 문서를 읽습니다.
+func gqlParse(src string) (*gqlDocument, error) {
+	tokens, err := gqlLex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.peek().kind != 0 {
+		token := p.peek()
+		switch {
+		case token.kind == 'p' && token.value == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: selections})
+		case token.kind == 'n' && (token.value == "query" || token.value == "mutation" || token.value == "subscription"):
+			operation, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, operation)
+		case token.kind == 'n' && token.value == "fragment":
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if on, err := p.name(); err != nil || on != "on" {
+				return nil, errors.New("Syntax Error: expected \"on\"")
+			}
+			fragment := &gqlFragment{}
+			if fragment.on, err = p.name(); err != nil {
+				return nil, err
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			if fragment.selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("no operation in document")
+	}
+	return doc, nil
+}
+
+// query Name($v: Type = default) @directive { ... }
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	operation := &gqlOperation{kind: p.next().value}
+	if p.peek().kind == 'n' {
+		operation.name = p.next().value
+	}
+	if p.accept("(") {
+		for !p.accept(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			var def gqlVariableDef
+			var err error
+			if def.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if def.typ, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			if p.accept("=") {
+				def.hasDefault = true
+				if def.def, err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.directives(); err != nil {
+				return nil, err
+			}
+			operation.variables = append(operation.variables, def)
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	var err error
+	operation.selections, err = p.selectionSet()
+	return operation, err
+}
+
+// Name, [Type], Type! 을 "[Item!]!" 같은 문자열로 읽습니다.
+func (p *gqlParser) typeRef() (string, error) {
+	var typ string
+	if p.accept("[") {
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.accept("!") {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+// { selection ... }
+func (p *gqlParser) selectionSet() ([]*gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*gqlSelection
+	for !p.accept("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, errors.New("Syntax Error: empty selection set")
+	}
+	return selections, nil
+}
+
+func (p *gqlParser) selection() (*gqlSelection, error) {
+	selection := &gqlSelection{}
+	var err error
+	if p.accept("...") {
+		if token := p.peek(); token.kind == 'n' && token.value != "on" {
+			selection.spread = p.next().value
+			selection.directives, err = p.directives()
+			return selection, err
+		}
+		selection.inline = true
+		if token := p.peek(); token.kind == 'n' && token.value == "on" {
+			p.next()
+			if selection.on, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if selection.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		selection.selections, err = p.selectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.accept(":") {
+		selection.alias = selection.name
+		if selection.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if selection.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if selection.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind == 'p' && token.value == "{" {
+		selection.selections, err = p.selectionSet()
+	}
+	return selection, err
+}
+
+// (name: value ...). 없으면 nil
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	if !p.accept("(") {
+		return nil, nil
+	}
+	args := map[string]interface{}{}
+	for !p.accept(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// @name(args) ...
+func (p *gqlParser) directives() ([]gqlDirective, error) {
+	var directives []gqlDirective
+	for p.accept("@") {
+		var directive gqlDirective
+		var err error
+		if directive.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if directive.args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive)
+	}
+	return directives, nil
+}
+
+// 값 하나. 정수는 int64, 실수는 float64 입니다.
+func (p *gqlParser) value() (interface{}, error) {
+	token := p.next()
+	switch token.kind {
+	case 'i':
+		n, err := strconv.ParseInt(token.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Syntax Error: invalid number %s", token.value)
+		}
+		return n, nil
+	case 'f':
+		f, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Syntax Error: invalid number %s", token.value)
+		}
+		return f, nil
+	case 's':
+		return token.value, nil
+	case 'n':
+		switch token.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return gqlEnum(token.value), nil
+	case 'p':
+		switch token.value {
+		case "$":
+			name, err := p.name()
+			return gqlVariable(name), err
+		case "[":
+			list := []interface{}{}
+			for !p.accept("]") {
+				value, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			return list, nil
+		case "{":
+			object := map[string]interface{}{}
+			for !p.accept("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			return object, nil
+		}
+	}
+	p.pos--
+	return nil, p.unexpected()
+}
+
+// ---- 실행 ----
+
+// 요청 하나를 실행하는 동안의 상태
+type gqlExecutor struct {
+	schema    *gqlSchema
+	doc       *gqlDocument
+	variables map[string]interface{}
+	errors    []gqlError
+}
+
+// 실행할 operation 을 고르고 변수를 준비합니다.
+func gqlPrepare(schema *gqlSchema, request gqlRequest) (*gqlExecutor, *gqlOperation, *gqlResponse) {
+	if request.Query == "" {
+		return nil, nil, gqlErrorResponse("query is empty")
+	}
+	doc, err := gqlParse(request.Query)
+	if err != nil {
+		return nil, nil, gqlErrorResponse("%v", err)
+	}
+	var operation *gqlOperation
+	for _, candidate := range doc.operations {
+		if request.OperationName == "" || candidate.name == request.OperationName {
+			if operation != nil {
+				return nil, nil, gqlErrorResponse("operationName is required when the document has several operations")
+			}
+			operation = candidate
+		}
+	}
+	if operation == nil {
+		return nil, nil, gqlErrorResponse("unknown operation %q", request.OperationName)
+	}
+
+	e := &gqlExecutor{schema: schema, doc: doc, variables: map[string]interface{}{}}
+	if root := e.rootType(operation.kind); root != nil {
+		if err := e.validate(root, operation.selections, map[string]bool{}); err != nil {
+			return nil, nil, gqlErrorResponse("%v", err)
+		}
+	}
+	for _, def := range operation.variables {
+		value, ok := request.Variables[def.name]
+		if !ok && def.hasDefault {
+			value, ok = def.def, true
+		}
+		if !ok && !strings.HasSuffix(def.typ, "!") {
+			continue
+		}
+		coerced, err := e.coerceInput(def.typ, value)
+		if err != nil {
+			return nil, nil, gqlErrorResponse("variable $%s: %v", def.name, err)
+		}
+		e.variables[def.name] = coerced
+	}
+	return e, operation, nil
+}
+
+// query 와 mutation 을 실행합니다. subscription 은 gqlSubscribe 로 실행합니다.
+func gqlExecute(ctx context.Context, schema *gqlSchema, request gqlRequest) *gqlResponse {
+	e, operation, failed := gqlPrepare(schema, request)
+	if failed != nil {
+		return failed
+	}
+	root := e.rootType(operation.kind)
+	switch {
+	case operation.kind == "subscription":
+		return gqlErrorResponse("subscriptions need a WebSocket (graphql-transport-ws)")
+	case root == nil:
+		return gqlErrorResponse("schema has no %s type", operation.kind)
+	}
+	data, ok := e.executeSelections(ctx, root, nil, operation.selections, nil)
+	return e.response(data, ok)
+}
+
+// operation 종류의 루트 타입. 스키마에 없으면 nil
+func (e *gqlExecutor) rootType(kind string) *gqlType {
+	switch kind {
+	case "query":
+		return e.schema.Types[e.schema.Query]
+	case "mutation":
+		return e.schema.Types[e.schema.Mutation]
+	}
+	return e.schema.Types[e.schema.Subscription]
+}
+
+// 실행하기 전에 없는 필드, 인자, fragment 와 잘못된 하위 선택을 찾습니다.
+func (e *gqlExecutor) validate(typ *gqlType, selections []*gqlSelection, visiting map[string]bool) error {
+	for _, selection := range selections {
+		switch {
+		case selection.spread != "":
+			fragment := e.doc.fragments[selection.spread]
+			if fragment == nil {
+				return fmt.Errorf("unknown fragment %q", selection.spread)
+			}
+			if fragment.on != typ.Name {
+				return fmt.Errorf("fragment %q on %q cannot be spread on type %q", selection.spread, fragment.on, typ.Name)
+			}
+			if visiting[selection.spread] {
+				return fmt.Errorf("fragment %q spreads itself", selection.spread)
+			}
+			visiting[selection.spread] = true
+			err := e.validate(typ, fragment.selections, visiting)
+			delete(visiting, selection.spread)
+			if err != nil {
+				return err
+			}
+			continue
+		case selection.inline:
+			if selection.on != "" && selection.on != typ.Name {
+				return fmt.Errorf("fragment on %q cannot be spread on type %q", selection.on, typ.Name)
+			}
+			if err := e.validate(typ, selection.selections, visiting); err != nil {
+				return err
+			}
+			continue
+		case selection.name == "__typename":
+			continue
+		}
+		field := e.schema.field(typ, selection.name)
+		if field == nil && typ.Name == e.schema.Query {
+			field = e.introspectionField(selection.name)
+		}
+		if field == nil {
+			return fmt.Errorf("Cannot query field %q on type %q", selection.name, typ.Name)
+		}
+		for name := range selection.args {
+			found := false
+			for _, arg := range field.Args {
+				found = found || arg.Name == name
+			}
+			if !found {
+				return fmt.Errorf("unknown argument %q on field \"%s.%s\"", name, typ.Name, field.Name)
+			}
+		}
+		inner := e.schema.lookup(strings.Trim(field.Type, "[]!"))
+		switch {
+		case inner.Kind == "SCALAR" && selection.selections != nil:
+			return fmt.Errorf("field %q of type %q must not have a selection of subfields", selection.name, field.Type)
+		case inner.Kind == "OBJECT" && selection.selections == nil:
+			return fmt.Errorf("field %q of type %q must have a selection of subfields", selection.name, field.Type)
+		case inner.Kind == "OBJECT":
+			if err := e.validate(inner, selection.selections, visiting); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 결과를 응답으로 만듭니다. ok 가 false 이면 data 는 null 입니다.
+func (e *gqlExecutor) response(data *gqlObject, ok bool) *gqlResponse {
+	response := &gqlResponse{Errors: e.errors, Data: json.RawMessage("null")}
+	if ok {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return gqlErrorResponse("%v", err)
+		}
+		response.Data = b
+	}
+	return response
+}
+
+// subscription 을 시작합니다. 이벤트마다 결과를 하나씩 보내고, ctx 가 끝나면 채널을 닫습니다.
+// 시작할 수 없으면 에러 응답을 돌려줍니다. query 와 mutation 은 결과 하나를 보내고 닫습니다.
+func gqlSubscribe(ctx context.Context, schema *gqlSchema, request gqlRequest) (<-chan *gqlResponse, *gqlResponse) {
+	e, operation, failed := gqlPrepare(schema, request)
+	if failed != nil {
+		return nil, failed
+	}
+	results := make(chan *gqlResponse, 1)
+	if operation.kind != "subscription" {
+		results <- gqlExecute(ctx, schema, request)
+		close(results)
+		return results, nil
+	}
+	if schema.Subscription == "" {
+		return nil, gqlErrorResponse("schema has no subscription type")
+	}
+	typ := schema.Types[schema.Subscription]
+	fields := e.collectFields(typ, operation.selections, map[string]bool{})
+	if len(fields) != 1 {
+		return nil, gqlErrorResponse("a subscription must select exactly one field")
+	}
+	key, selections := fields[0].key, fields[0].selections
+	field := schema.field(typ, selections[0].name)
+	if field == nil || field.Subscribe == nil {
+		return nil, gqlErrorResponse("Cannot query field %q on type %q", selections[0].name, typ.Name)
+	}
+	args, err := e.coerceArgs(field, selections[0].args)
+	if err != nil {
+		return nil, gqlErrorResponse("%v", err)
+	}
+	var inner []*gqlSelection
+	for _, selection := range selections {
+		inner = append(inner, selection.selections...)
+	}
+	events, err := field.Subscribe(ctx, args)
+	if err != nil {
+		return nil, gqlErrorResponse("%v", err)
+	}
+	go func() {
+		defer close(results)
+		for event := range events {
+			// 이벤트마다 새 실행기로 실행해야 에러가 섞이지 않습니다.
+			run := &gqlExecutor{schema: schema, doc: e.doc, variables: e.variables}
+			data := &gqlObject{}
+			value, ok := run.completeValue(ctx, field.Type, event, inner, []interface{}{key})
+			data.set(key, value)
+			select {
+			case results <- run.response(data, ok):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// 응답 키 하나와 그 키로 합쳐진 필드 선택들
+type gqlCollected struct {
+	key        string
+	selections []*gqlSelection
+}
+
+// fragment 를 펼치고 @skip/@include 를 적용해서 응답 키별로 필드 선택을 모읍니다.
+func (e *gqlExecutor) collectFields(typ *gqlType, selections []*gqlSelection, visited map[string]bool) []gqlCollected {
+	var fields []gqlCollected
+	add := func(key string, selection *gqlSelection) {
+		for i := range fields {
+			if fields[i].key == key {
+				fields[i].selections = append(fields[i].selections, selection)
+				return
+			}
+		}
+		fields = append(fields, gqlCollected{key, []*gqlSelection{selection}})
+	}
+	for _, selection := range selections {
+		if !e.included(selection.directives) {
+			continue
+		}
+		var inner []*gqlSelection
+		switch {
+		case selection.spread != "":
+			fragment := e.doc.fragments[selection.spread]
+			if visited[selection.spread] || fragment == nil || fragment.on != typ.Name {
+				continue
+			}
+			visited[selection.spread] = true
+			inner = fragment.selections
+		case selection.inline:
+			if selection.on != "" && selection.on != typ.Name {
+				continue
+			}
+			inner = selection.selections
+		default:
+			key := selection.alias
+			if key == "" {
+				key = selection.name
+			}
+			add(key, selection)
+			continue
+		}
+		for _, field := range e.collectFields(typ, inner, visited) {
+			for _, s := range field.selections {
+				add(field.key, s)
+			}
+		}
+	}
+	return fields
+}
+
+// @skip(if: true) 나 @include(if: false) 가 없는지
+func (e *gqlExecutor) included(directives []gqlDirective) bool {
+	for _, directive := range directives {
+		condition, _ := e.resolveValue(directive.args["if"]).(bool)
+		if directive.name == "skip" && condition || directive.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// typ 의 source 에서 selections 를 실행합니다.
+// null 이 될 수 없는 필드가 null 이 되면 ok 가 false 이고, 부모가 대신 null 이 됩니다.
+func (e *gqlExecutor) executeSelections(ctx context.Context, typ *gqlType, source interface{}, selections []*gqlSelection, path []interface{}) (*gqlObject, bool) {
+	result := &gqlObject{}
+	for _, collected := range e.collectFields(typ, selections, map[string]bool{}) {
+		selection := collected.selections[0]
+		fieldPath := append(append([]interface{}(nil), path...), collected.key)
+		if selection.name == "__typename" {
+			result.set(collected.key, typ.Name)
+			continue
+		}
+		field := e.schema.field(typ, selection.name)
+		if field == nil && typ.Name == e.schema.Query {
+			field = e.introspectionField(selection.name)
+		}
+		var inner []*gqlSelection
+		for _, s := range collected.selections {
+			inner = append(inner, s.selections...)
+		}
+
+		value, err := e.resolveField(ctx, field, source, selection.args)
+		if err != nil {
+			e.fail(fieldPath, "%v", err)
+			if strings.HasSuffix(field.Type, "!") {
+				return nil, false
+			}
+			result.set(collected.key, nil)
+			continue
+		}
+		completed, ok := e.completeValue(ctx, field.Type, value, inner, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		result.set(collected.key, completed)
+	}
+	return result, true
+}
+
+// 인자를 준비하고 필드의 값을 구합니다.
+func (e *gqlExecutor) resolveField(ctx context.Context, field *gqlField, source interface{}, astArgs map[string]interface{}) (interface{}, error) {
+	args, err := e.coerceArgs(field, astArgs)
+	if err != nil {
+		return nil, err
+	}
+	if field.Resolve != nil {
+		return field.Resolve(ctx, source, args)
+	}
+	values, _ := source.(map[string]interface{})
+	value := values[field.Name]
+	if lazy, ok := value.(func() interface{}); ok {
+		value = lazy()
+	}
+	return value, nil
+}
+
+// 값을 typeRef 모양의 결과로 만듭니다. null 이 될 수 없는 자리가 null 이 되면 에러를 남기고 ok 가 false 입니다.
+func (e *gqlExecutor) completeValue(ctx context.Context, typeRef string, value interface{}, selections []*gqlSelection, path []interface{}) (interface{}, bool) {
+	if inner, nonNull := strings.CutSuffix(typeRef, "!"); nonNull {
+		completed, ok := e.completeInner(ctx, inner, value, selections, path)
+		if ok && completed == nil {
+			e.fail(path, "Cannot return null for non-nullable field")
+			ok = false
+		}
+		return completed, ok
+	}
+	if completed, ok := e.completeInner(ctx, typeRef, value, selections, path); ok {
+		return completed, true
+	}
+	// null 이 될 수 있는 자리에서 멈춥니다.
+	return nil, true
+}
+
+func (e *gqlExecutor) completeInner(ctx context.Context, typeRef string, value interface{}, selections []*gqlSelection, path []interface{}) (interface{}, bool) {
+	if gqlIsNull(value) {
+		return nil, true
+	}
+	if strings.HasPrefix(typeRef, "[") {
+		list := reflect.ValueOf(value)
+		if list.Kind() != reflect.Slice {
+			e.fail(path, "expected a list")
+			return nil, false
+		}
+		results := make([]interface{}, list.Len())
+		for i := range results {
+			elementPath := append(append([]interface{}(nil), path...), i)
+			completed, ok := e.completeValue(ctx, typeRef[1:len(typeRef)-1], list.Index(i).Interface(), selections, elementPath)
+			if !ok {
+				return nil, false
+			}
+			results[i] = completed
+		}
+		return results, true
+	}
+	typ := e.schema.lookup(typeRef)
+	if typ.Kind == "SCALAR" {
+		serialized, err := gqlSerialize(typeRef, value)
+		if err != nil {
+			e.fail(path, "%v", err)
+			return nil, false
+		}
+		return serialized, true
+	}
+	return e.executeSelections(ctx, typ, value, selections, path)
+}
+
+// nil 이거나 nil 인 포인터, map, slice 인지
+func gqlIsNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// scalar 값을 JSON 으로 보낼 값으로 바꿉니다.
+func gqlSerialize(scalar string, value interface{}) (interface{}, error) {
+	switch scalar {
+	case "String", "ID":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case fmt.Stringer:
+			return v.String(), nil
+		}
+		if scalar == "ID" {
+			return fmt.Sprint(value), nil
+		}
+	case "Boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case "Int", "Float":
+		v := reflect.ValueOf(value)
+		var f float64
+		switch {
+		case v.CanInt():
+			f = float64(v.Int())
+		case v.CanUint():
+			f = float64(v.Uint())
+		case v.CanFloat():
+			f = v.Float()
+		default:
+			return nil, fmt.Errorf("%s cannot represent %v", scalar, value)
+		}
+		if scalar == "Float" {
+			return f, nil
+		}
+		if f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+			return nil, fmt.Errorf("Int cannot represent %v", value)
+		}
+		return int(f), nil
+	}
+	return nil, fmt.Errorf("%s cannot represent %v", scalar, value)
+}
+
+// 에러를 남깁니다.
+func (e *gqlExecutor) fail(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, gqlError{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// $변수를 그 값으로 바꿉니다. list 와 object 안의 것도 바꿉니다.
+func (e *gqlExecutor) resolveValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case gqlVariable:
+		return e.variables[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, element := range v {
+			list[i] = e.resolveValue(element)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = e.resolveValue(element)
+		}
+		return object
+	}
+	return value
+}
+
+// 필드의 인자들을 선언된 타입으로 바꿉니다. 없는 인자는 기본값을 씁니다. (모르는 인자는 validate 가 거릅니다.)
+func (e *gqlExecutor) coerceArgs(field *gqlField, astArgs map[string]interface{}) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, arg := range field.Args {
+		raw, ok := astArgs[arg.Name]
+		if variable, isVariable := raw.(gqlVariable); isVariable {
+			raw, ok = e.variables[string(variable)]
+		}
+		if !ok && arg.Default != "" {
+			tokens, err := gqlLex(arg.Default)
+			if err != nil {
+				return nil, err
+			}
+			if raw, err = (&gqlParser{tokens: tokens}).value(); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+		if !ok {
+			if strings.HasSuffix(arg.Type, "!") {
+				return nil, fmt.Errorf("argument %q of type %s is required", arg.Name, arg.Type)
+			}
+			continue
+		}
+		value, err := e.coerceInput(arg.Type, e.resolveValue(raw))
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", arg.Name, err)
+		}
+		args[arg.Name] = value
+	}
+	return args, nil
+}
+
+// 입력 값을 typeRef 로 바꿉니다. Int 는 int, Float 는 float64, String 과 ID 는 string 이 됩니다.
+// JSON 변수의 숫자는 float64 로 오므로 정수인지 확인합니다.
+func (e *gqlExecutor) coerceInput(typeRef string, value interface{}) (interface{}, error) {
+	if inner, nonNull := strings.CutSuffix(typeRef, "!"); nonNull {
+		if value == nil {
+			return nil, fmt.Errorf("expected non-null %s", typeRef)
+		}
+		return e.coerceInput(inner, value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(typeRef, "[") {
+		elementType := typeRef[1 : len(typeRef)-1]
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+		results := make([]interface{}, len(list))
+		for i, element := range list {
+			var err error
+			if results[i], err = e.coerceInput(elementType, element); err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	}
+	switch v := value.(type) {
+	case string:
+		if typeRef == "String" || typeRef == "ID" {
+			return v, nil
+		}
+	case bool:
+		if typeRef == "Boolean" {
+			return v, nil
+		}
+	case int64:
+		switch typeRef {
+		case "Int":
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case "Float":
+			return float64(v), nil
+		case "ID":
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		switch typeRef {
+		case "Int":
+			if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case "Float":
+			return v, nil
+		case "ID":
+			if v == math.Trunc(v) {
+				return strconv.FormatInt(int64(v), 10), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s cannot represent %v", typeRef, value)
+}
+
+// ---- introspection ----
+
+// introspection 결과의 타입들. 값은 map 이고 필드는 같은 이름의 값을 씁니다.
+var gqlIntrospectionTypes = map[string]*gqlType{}
+
+func init() {
+	object := func(name string, fields ...*gqlField) {
+		gqlIntrospectionTypes[name] = &gqlType{Name: name, Kind: "OBJECT", Fields: fields}
+	}
+	field := func(name, typ string, args ...gqlArg) *gqlField {
+		return &gqlField{Name: name, Type: typ, Args: args}
+	}
+	deprecated := gqlArg{Name: "includeDeprecated", Type: "Boolean", Default: "false"}
+	object("__Schema",
+		field("description", "String"),
+		field("types", "[__Type!]!"),
+		field("queryType", "__Type!"),
+		field("mutationType", "__Type"),
+		field("subscriptionType", "__Type"),
+		field("directives", "[__Directive!]!"))
+	object("__Type",
+		field("kind", "String!"),
+		field("name", "String"),
+		field("description", "String"),
+		field("specifiedByURL", "String"),
+		field("fields", "[__Field!]", deprecated),
+		field("interfaces", "[__Type!]"),
+		field("possibleTypes", "[__Type!]"),
+		field("enumValues", "[__EnumValue!]", deprecated),
+		field("inputFields", "[__InputValue!]", deprecated),
+		field("ofType", "__Type"),
+		field("isOneOf", "Boolean"))
+	object("__Field",
+		field("name", "String!"),
+		field("description", "String"),
+		field("args", "[__InputValue!]!", deprecated),
+		field("type", "__Type!"),
+		field("isDeprecated", "Boolean!"),
+		field("deprecationReason", "String"))
+	object("__InputValue",
+		field("name", "String!"),
+		field("description", "String"),
+		field("type", "__Type!"),
+		field("defaultValue", "String"),
+		field("isDeprecated", "Boolean!"),
+		field("deprecationReason", "String"))
+	object("__EnumValue",
+		field("name", "String!"),
+		field("description", "String"),
+		field("isDeprecated", "Boolean!"),
+		field("deprecationReason", "String"))
+	object("__Directive",
+		field("name", "String!"),
+		field("description", "String"),
+		field("locations", "[String!]!"),
+		field("args", "[__InputValue!]!", deprecated),
+		field("isRepeatable", "Boolean!"))
+}
+
+// query 타입에 숨어 있는 __schema 와 __type 필드
+func (e *gqlExecutor) introspectionField(name string) *gqlField {
+	switch name {
+	case "__schema":
+		return &gqlField{Name: name, Type: "__Schema!", Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+			return e.schema.introspect(), nil
+		}}
+	case "__type":
+		return &gqlField{Name: name, Type: "__Type", Args: []gqlArg{{Name: "name", Type: "String!"}},
+			Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				name := args["name"].(string)
+				if e.schema.lookup(name) == nil {
+					return nil, nil
+				}
+				return e.schema.introspectType(name), nil
+			}}
+	}
+	return nil
+}
+
+// 이름으로 타입을 찾습니다. 기본 scalar 와 introspection 타입도 찾습니다.
+func (s *gqlSchema) lookup(name string) *gqlType {
+	if typ := s.Types[name]; typ != nil {
+		return typ
+	}
+	if typ := gqlIntrospectionTypes[name]; typ != nil {
+		return typ
+	}
+	for _, scalar := range gqlScalars {
+		if scalar.Name == name {
+			return scalar
+		}
+	}
+	return nil
+}
+
+// __schema 의 값
+func (s *gqlSchema) introspect() map[string]interface{} {
+	var types []interface{}
+	for _, scalar := range gqlScalars {
+		types = append(types, s.introspectType(scalar.Name))
+	}
+	names := make([]string, 0, len(s.Types))
+	for name := range s.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		types = append(types, s.introspectType(name))
+	}
+	root := func(name string) interface{} {
+		if name == "" {
+			return nil
+		}
+		return s.introspectType(name)
+	}
+	condition := []interface{}{map[string]interface{}{
+		"name": "if", "type": s.introspectTypeRef("Boolean!"), "isDeprecated": false,
+	}}
+	directive := func(name, description string) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "description": description, "args": condition, "isRepeatable": false,
+			"locations": []interface{}{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		}
+	}
+	return map[string]interface{}{
+		"types":            types,
+		"queryType":        root(s.Query),
+		"mutationType":     root(s.Mutation),
+		"subscriptionType": root(s.Subscription),
+		"directives": []interface{}{
+			directive("skip", "Skips this field when the argument is true."),
+			directive("include", "Includes this field only when the argument is true."),
+		},
+	}
+}
+
+// 이름 있는 타입 하나의 __Type. 필드는 물어볼 때 만듭니다. (타입들이 서로를 가리키므로)
+func (s *gqlSchema) introspectType(name string) map[string]interface{} {
+	typ := s.lookup(name)
+	value := map[string]interface{}{"kind": typ.Kind, "name": typ.Name, "description": gqlDescription(typ.Description)}
+	if typ.Kind != "OBJECT" {
+		return value
+	}
+	value["interfaces"] = []interface{}{}
+	value["fields"] = func() interface{} {
+		fields := make([]interface{}, 0, len(typ.Fields))
+		for _, field := range typ.Fields {
+			args := make([]interface{}, 0, len(field.Args))
+			for _, arg := range field.Args {
+				input := map[string]interface{}{
+					"name": arg.Name, "description": gqlDescription(arg.Description), "type": s.introspectTypeRef(arg.Type), "isDeprecated": false,
+				}
+				if arg.Default != "" {
+					input["defaultValue"] = arg.Default
+				}
+				args = append(args, input)
+			}
+			fields = append(fields, map[string]interface{}{
+				"name": field.Name, "description": gqlDescription(field.Description), "args": args,
+				"type": s.introspectTypeRef(field.Type), "isDeprecated": false,
+			})
+		}
+		return fields
+	}
+	return value
+}
+
+// 설명이 없으면 null
+func gqlDescription(description string) interface{} {
+	if description == "" {
+		return nil
+	}
+	return description
+}
+
+// "[Item!]!" 같은 타입의 __Type
+func (s *gqlSchema) introspectTypeRef(typeRef string) map[string]interface{} {
+	if inner, nonNull := strings.CutSuffix(typeRef, "!"); nonNull {
+		return map[string]interface{}{"kind": "NON_NULL", "ofType": s.introspectTypeRef(inner)}
+	}
+	if strings.HasPrefix(typeRef, "[") {
+		return map[string]interface{}{"kind": "LIST", "ofType": s.introspectTypeRef(typeRef[1 : len(typeRef)-1])}
+	}
+	return s.introspectType(typeRef)
+}
@@ -0,0 +1,353 @@
+//
+// grpc.go
+//
+// HTTP 와 같은 포트에서 gRPC 의 ItemService(items.proto)를 받습니다. /items 와 같은 저장소(store.go)를 씁니다.
+// gRPC 를 좋아하는 내부 클라이언트를 위한 것이고, 외부 라이브러리 없이 gRPC over HTTP/2 를 직접 구현했습니다.
+// (https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md)
+//
+//   "grpc": {"enabled": true, "allowed_ips": ["10.0.0.0/8"], "username": "svc", "password": "..."}
+//
+//   $ grpcurl -plaintext -import-path . -proto items.proto localhost:8080 items.ItemService/ListItems
+//
+// 켜면 서버가 TLS 없는 HTTP/2(h2c)도 받습니다. HTTP/2 요청의 Content-Type 이 application/grpc 로 시작하면
+// gRPC 로, 아니면 여느 HTTP 요청처럼 처리합니다. gRPC 요청은 압축이나 CSP 같은 HTTP 미들웨어를 거치지 않습니다.
+//
+// allowed_ips 와 username/password 는 guard.go 와 같습니다. Basic 인증은 authorization 메타데이터로 보냅니다.
+// grpc-timeout 을 지키고, 메시지 압축(grpc-encoding)과 클라이언트 스트리밍은 지원하지 않습니다.
+// 메트릭(metrics.go)에는 "/items.ItemService/GetItem" 같은 메서드 이름으로 남습니다.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC 설정
+type GRPCConfig struct {
+	Enabled bool `json:"enabled"`
+	GuardConfig
+}
+
+// gRPC 상태 코드 (https://grpc.github.io/grpc/core/md_doc_statuscodes.html)
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// 받는 메시지의 최대 크기
+const grpcMaxMessageSize = 64 * 1024
+
+// 상태 코드가 있는 에러. 다른 에러는 INTERNAL 로 보냅니다.
+type grpcError struct {
+	Code    int
+	Message string
+}
+
+func (e *grpcError) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.Code, e.Message)
+}
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// 메서드 하나. 요청 메시지를 받아 응답 메시지를 send 로 보냅니다. 단항 메서드는 send 를 한 번만 부릅니다.
+type grpcMethod func(ctx context.Context, in []byte, send func([]byte) error) error
+
+// 단항 메서드를 grpcMethod 로 바꿉니다.
+func grpcUnary(handle func(ctx context.Context, in []byte) ([]byte, error)) grpcMethod {
+	return func(ctx context.Context, in []byte, send func([]byte) error) error {
+		out, err := handle(ctx, in)
+		if err != nil {
+			return err
+		}
+		return send(out)
+	}
+}
+
+// ItemService 의 메서드들. 키는 요청의 경로입니다.
+var itemServiceMethods = map[string]grpcMethod{
+	"/items.ItemService/GetItem":    grpcUnary(grpcGetItem),
+	"/items.ItemService/ListItems":  grpcUnary(grpcListItems),
+	"/items.ItemService/PutItem":    grpcUnary(grpcPutItem),
+	"/items.ItemService/WatchItems": grpcWatchItems,
+}
+
+// gRPC 요청은 ItemService 로, 나머지는 next 로 보내는 핸들러를 만듭니다. 꺼져 있으면 next를 그대로 돌려줍니다.
+func GRPCHandler(config GRPCConfig, next http.Handler) (http.Handler, error) {
+	if !config.Enabled {
+		return next, nil
+	}
+	prefixes, err := parseIPPrefixes("grpc allowed_ips", config.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.ProtoMajor != 2 || !strings.HasPrefix(request.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(response, request)
+			return
+		}
+		start := time.Now()
+		recorder := newResponseRecorder(response)
+		err := serveGRPC(recorder, request, config, prefixes)
+		if err != nil {
+			Logf(request.Context(), "grpc %s: %v", request.URL.Path, err)
+		}
+		metrics.Observe(request.URL.Path, recorder.Status(), time.Since(start), recorder.size)
+	}), nil
+}
+
+// 요청 하나를 처리하고 grpc-status 를 trailer 로 보냅니다. 돌려준 에러는 로그에 남깁니다.
+func serveGRPC(response http.ResponseWriter, request *http.Request, config GRPCConfig, prefixes []netip.Prefix) error {
+	header := response.Header()
+	header.Set("Content-Type", "application/grpc")
+	header.Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	err := callGRPC(response, request, config, prefixes)
+	status, message := grpcStatus(err)
+	// 본문을 하나도 쓰지 않았어도 헤더를 먼저 보내야 Grpc-Status 가 trailer 로 갑니다.
+	response.WriteHeader(http.StatusOK)
+	header.Set("Grpc-Status", strconv.Itoa(status))
+	if message != "" {
+		header.Set("Grpc-Message", grpcPercentEncode(message))
+	}
+	return err
+}
+
+// err 의 gRPC 상태 코드와 메시지. nil 이면 OK 입니다.
+func grpcStatus(err error) (int, string) {
+	var statusErr *grpcError
+	switch {
+	case err == nil:
+		return grpcOK, ""
+	case errors.As(err, &statusErr):
+		return statusErr.Code, statusErr.Message
+	case errors.Is(err, context.DeadlineExceeded):
+		return grpcDeadlineExceeded, "deadline exceeded"
+	case errors.Is(err, context.Canceled):
+		return grpcCanceled, "canceled"
+	}
+	return grpcInternal, "internal error"
+}
+
+// 접근 제한을 확인하고 메서드를 부릅니다.
+func callGRPC(response http.ResponseWriter, request *http.Request, config GRPCConfig, prefixes []netip.Prefix) error {
+	if request.Method != http.MethodPost {
+		return grpcErrorf(grpcUnimplemented, "method %s", request.Method)
+	}
+	if len(prefixes) > 0 && !ipAllowed(request, prefixes) {
+		audit.Record(request, "auth.denied_ip", "", map[string]string{"realm": "grpc"})
+		return grpcErrorf(grpcPermissionDenied, "address %s not allowed", request.RemoteAddr)
+	}
+	if config.Username != "" || config.Users {
+		user, pass, ok := request.BasicAuth()
+		if !ok || !basicAuthValid(request, config.GuardConfig, user, pass) {
+			if ok {
+				audit.Record(request, "auth.failure", user, map[string]string{"realm": "grpc"})
+			}
+			return grpcErrorf(grpcUnauthenticated, "authentication required")
+		}
+	}
+	if encoding := request.Header.Get("Grpc-Encoding"); encoding != "" && encoding != "identity" {
+		return grpcErrorf(grpcUnimplemented, "grpc-encoding %s not supported", encoding)
+	}
+	method, ok := itemServiceMethods[request.URL.Path]
+	if !ok {
+		return grpcErrorf(grpcUnimplemented, "unknown method %s", request.URL.Path)
+	}
+
+	ctx := withAccessRequest(request)
+	if timeout := request.Header.Get("Grpc-Timeout"); timeout != "" {
+		d, err := parseGRPCTimeout(timeout)
+		if err != nil {
+			return grpcErrorf(grpcInvalidArgument, "grpc-timeout %q", timeout)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	in, err := readGRPCMessage(request.Body)
+	if err != nil {
+		return err
+	}
+	controller := http.NewResponseController(response)
+	return method(ctx, in, func(out []byte) error {
+		frame := make([]byte, 5, 5+len(out))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(out)))
+		if _, err := response.Write(append(frame, out...)); err != nil {
+			return err
+		}
+		return controller.Flush()
+	})
+}
+
+// 길이가 앞에 붙은 메시지 하나를 읽습니다. (Length-Prefixed-Message)
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "read message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed message not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > grpcMaxMessageSize {
+		return nil, grpcErrorf(grpcResourceExhausted, "message of %d bytes is larger than %d", length, grpcMaxMessageSize)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(body, message); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "read message: %v", err)
+	}
+	return message, nil
+}
+
+// "100m", "5S" 같은 grpc-timeout 값을 읽습니다.
+func parseGRPCTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, errors.New("bad length")
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("bad value")
+	}
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, errors.New("bad unit")
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// grpc-message 에 넣을 수 있게 출력할 수 있는 ASCII 가 아닌 바이트와 %를 %XX 로 바꿉니다.
+func grpcPercentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Item 메시지
+func appendProtoItem(b []byte, item Item) []byte {
+	b = appendProtoString(b, 1, item.Name)
+	return appendProtoString(b, 2, item.What)
+}
+
+// Item 메시지를 읽습니다.
+func parseProtoItem(b []byte) (Item, error) {
+	fields, err := parseProto(b)
+	if err != nil {
+		return Item{}, err
+	}
+	var item Item
+	for _, field := range fields {
+		switch {
+		case field.Number == 1 && field.Type == protoBytes:
+			item.Name = string(field.Bytes)
+		case field.Number == 2 && field.Type == protoBytes:
+			item.What = string(field.Bytes)
+		}
+	}
+	return item, nil
+}
+
+// rpc GetItem(GetItemRequest) returns (Item)
+func grpcGetItem(ctx context.Context, in []byte) ([]byte, error) {
+	// GetItemRequest 의 name 은 Item 의 name 과 같은 번호입니다.
+	request, err := parseProtoItem(in)
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	item, ok := store.Get(request.Name)
+	if !ok {
+		return nil, grpcErrorf(grpcNotFound, "item %q not found", request.Name)
+	}
+	return appendProtoItem(nil, item), nil
+}
+
+// rpc ListItems(ListItemsRequest) returns (ListItemsResponse)
+func grpcListItems(ctx context.Context, in []byte) ([]byte, error) {
+	var out []byte
+	for _, item := range store.List() {
+		out = appendProtoMessage(out, 1, appendProtoItem(nil, item))
+	}
+	return out, nil
+}
+
+// rpc PutItem(Item) returns (Item)
+func grpcPutItem(ctx context.Context, in []byte) ([]byte, error) {
+	item, err := parseProtoItem(in)
+	if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	var accessErr *accessError
+	if item, err = SaveItem(ctx, item); errors.As(err, &accessErr) {
+		if accessErr.status == http.StatusUnauthorized {
+			return nil, grpcErrorf(grpcUnauthenticated, "%v", err)
+		}
+		return nil, grpcErrorf(grpcPermissionDenied, "%v", err)
+	} else if err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	return appendProtoItem(nil, item), nil
+}
+
+// rpc WatchItems(WatchItemsRequest) returns (stream ItemChange)
+// 클라이언트가 끊거나 서버가 꺼질 때까지 보냅니다.
+func grpcWatchItems(ctx context.Context, in []byte, send func([]byte) error) error {
+	fields, err := parseProto(in)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+	var since uint64
+	for _, field := range fields {
+		if field.Number == 1 && field.Type == protoVarint {
+			since = field.Value
+		}
+	}
+	ctx, cancel := drainContext(ctx)
+	defer cancel()
+	for ctx.Err() == nil {
+		var changes []ItemChange
+		changes, since = store.WaitChanges(ctx, since)
+		for _, change := range changes {
+			var out []byte
+			out = appendProtoUint(out, 1, change.Seq)
+			out = appendProtoMessage(out, 2, appendProtoItem(nil, change.Item))
+			out = appendProtoTimestamp(out, 3, change.Time)
+			if err := send(out); err != nil {
+				return err
+			}
+		}
+	}
+	select {
+	case <-draining:
+		// 서버가 꺼지는 것이므로 클라이언트는 다른 서버로 다시 연결하면 됩니다.
+		return grpcErrorf(grpcUnavailable, "server shutting down")
+	default:
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,116 @@
+//
+// guard.go
+//
+// 운영용 엔드포인트(/metrics 등)를 아무나 보지 못하게 막는 접근 제한입니다.
+//
+//   "metrics": {"allowed_ips": ["127.0.0.1", "10.0.0.0/8"], "username": "ops", "password": "..."}
+//
+// allowed_ips 를 주면 그 주소에서 온 요청만, username 을 주면 Basic 인증을 통과한 요청만 받습니다.
+// 둘 다 주면 둘 다 맞아야 합니다. 둘 다 비어 있으면 모두 허용합니다.
+// "users": true 이면 로그인 사용자(users.go)의 이름과 비밀번호로도 Basic 인증을 통과합니다.
+
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// 접근 제한 설정
+type GuardConfig struct {
+	AllowedIPs []string `json:"allowed_ips"` // IP 주소나 CIDR
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	Users      bool     `json:"users"` // 사용자 저장소(users.go)의 이름과 비밀번호로도 Basic 인증을 받습니다.
+}
+
+// 설정에 맞는 요청만 next로 보내는 핸들러를 만듭니다. 설정이 잘못되었으면 에러를 돌려줍니다.
+func GuardHandler(config GuardConfig, realm string, next http.Handler) (http.Handler, error) {
+	prefixes, err := parseIPPrefixes("allowed_ips", config.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if len(prefixes) > 0 && !ipAllowed(request, prefixes) {
+			audit.Record(request, "auth.denied_ip", "", map[string]string{"realm": realm})
+			WriteError(response, request, http.StatusForbidden, fmt.Errorf("%s: address %s not allowed", realm, request.RemoteAddr))
+			return
+		}
+		if config.Username != "" || config.Users {
+			user, pass, ok := request.BasicAuth()
+			if !ok || !basicAuthValid(request, config, user, pass) {
+				// 처음에는 브라우저가 인증 없이 요청하므로 이름과 비밀번호를 보낸 경우만 실패로 기록합니다.
+				if ok {
+					audit.Record(request, "auth.failure", user, map[string]string{"realm": realm})
+				}
+				response.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+				WriteError(response, request, http.StatusUnauthorized, nil)
+				return
+			}
+		}
+		next.ServeHTTP(response, request)
+	}), nil
+}
+
+// Basic 인증의 이름과 비밀번호가 설정의 것이거나, users 이면 사용자 저장소의 것인지
+func basicAuthValid(request *http.Request, config GuardConfig, user, pass string) bool {
+	if config.Username != "" &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(config.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(config.Password)) == 1 {
+		return true
+	}
+	if !config.Users {
+		return false
+	}
+	return basicAuthenticate(request, user, pass)
+}
+
+// IP 주소나 CIDR 목록을 읽습니다. key는 에러 메시지에 쓰는 설정 이름입니다.
+func parseIPPrefixes(key string, list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s %q: %v", key, s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %v", key, s, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// 요청이 prefixes 중 하나의 주소에서 왔는지
+func ipAllowed(request *http.Request, prefixes []netip.Prefix) bool {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	return prefixesContain(prefixes, addr)
+}
+
+// addr 가 prefixes 중 하나에 들어 있는지
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+//
+// headers.go
+//
+// 들어오는 요청 헤더를 정리하고, 나가는 헤더에 사용자 값이 그대로 들어가지 않게 합니다.
+//
+//   "headers": {"trusted_proxies": ["127.0.0.1", "10.0.0.0/8"], "strip": ["X-Debug"]}
+//
+// 들어오는 요청
+//
+//   - 헤더 이름이나 값에 제어 문자(탭 말고)나 UTF-8 이 아닌 바이트가 있으면 400 으로 거절합니다.
+//   - Authorization, Content-Type 처럼 하나만 와야 하는 헤더가 여러 번 오면 400 으로 거절합니다.
+//     미들웨어마다 다른 값을 읽는 일이 없게 합니다.
+//   - X-Forwarded-For, X-Forwarded-Proto, Forwarded, X-Real-IP 같은 프록시 헤더는
+//     trusted_proxies 에서 온 요청만 남기고 지웁니다. 아무나 보낸 값으로 주소나 https 를 속이지 못합니다.
+//     클라이언트의 주소가 필요한 곳(빈도 제한, 로그인 잠금, 감사 로그 ...)은 clientIP 로 읽습니다.
+//   - Proxy (httpoxy), X-HTTP-Method-Override, X-Original-URL, X-Rewrite-URL 과 strip 의 헤더는 항상 지웁니다.
+//
+// 나가는 응답
+//
+//   사용자가 정한 값(파일 이름, 아이템 이름 등)을 헤더에 넣을 때는 SafeHeaderValue 나
+//   mime.FormatMediaType, url.PathEscape 로 바꿔서 넣습니다. 줄바꿈으로 헤더를 끼워 넣지 못합니다.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"unicode/utf8"
+)
+
+// 헤더 정리 설정
+type HeaderConfig struct {
+	TrustedProxies []string `json:"trusted_proxies"` // 프록시 헤더를 믿는 주소. IP 주소나 CIDR
+	Strip          []string `json:"strip"`           // 항상 지우는 헤더
+}
+
+// 프록시가 붙이는 헤더들. 믿는 프록시에서 온 요청만 남깁니다.
+var forwardedHeaders = []string{
+	"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port", "X-Real-IP",
+}
+
+// 받지 않는 헤더들. 경로나 메소드를 바꾸거나(프록시 우회) 환경 변수로 새어 나갑니다(httpoxy).
+var strippedHeaders = []string{
+	"Proxy", "X-HTTP-Method-Override", "X-Method-Override", "X-Original-URL", "X-Rewrite-URL",
+}
+
+// 두 번 이상 오면 거절하는 헤더들
+var singleHeaders = []string{
+	"Authorization", "Content-Type", "Content-Length", "X-API-Key", "X-Request-ID",
+	"X-Client-ID", "X-Timestamp", "X-Nonce", "X-Signature",
+}
+
+// 프록시 헤더를 믿는 주소들. HeaderSanitizeHandler 가 설정에서 읽어 둡니다. (clientIP)
+var trustedProxies []netip.Prefix
+
+// 요청 헤더를 검사하고 정리해서 next로 보냅니다. 설정이 잘못되었으면 에러를 돌려줍니다.
+func HeaderSanitizeHandler(config HeaderConfig, next http.Handler) (http.Handler, error) {
+	proxies, err := parseIPPrefixes("trusted_proxies", config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies = proxies
+	strip := append(append([]string(nil), strippedHeaders...), config.Strip...)
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if err := checkRequestHeaders(request.Header); err != nil {
+			WriteError(response, request, http.StatusBadRequest, err)
+			return
+		}
+		for _, name := range strip {
+			request.Header.Del(name)
+		}
+		if len(proxies) == 0 || !ipAllowed(request, proxies) {
+			for _, name := range forwardedHeaders {
+				request.Header.Del(name)
+			}
+		}
+		next.ServeHTTP(response, request)
+	}), nil
+}
+
+// 요청을 보낸 클라이언트의 IP 주소
+// 믿는 프록시(trusted_proxies)에서 온 요청이면 X-Forwarded-For (없으면 Forwarded 의 for=)를 오른쪽부터 보고
+// 믿는 프록시가 아닌 첫 주소를 씁니다. 그보다 왼쪽의 값은 클라이언트가 마음대로 적을 수 있으므로 보지 않습니다.
+// 읽을 수 없는 값(for=unknown 등)을 만나면 그 값을 붙인 프록시의 주소를 씁니다.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	if len(trustedProxies) == 0 || !ipAllowed(request, trustedProxies) {
+		return host
+	}
+	hops := forwardedHops(request.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			return host
+		}
+		if i == 0 || !prefixesContain(trustedProxies, addr) {
+			return addr.String()
+		}
+		host = addr.String()
+	}
+	return host
+}
+
+// X-Forwarded-For 나 Forwarded 의 for= 에 적힌 주소들. 왼쪽이 클라이언트이고 오른쪽으로 갈수록 가까운 프록시입니다.
+func forwardedHops(header http.Header) []string {
+	var hops []string
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, value := range values {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+		return hops
+	}
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				if key, hop, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(key, "for") {
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// 192.0.2.1, 192.0.2.1:4711, "[2001:db8::1]:4711" 같은 값 하나의 IP 주소
+func parseHop(hop string) (netip.Addr, error) {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"))
+	return addr.Unmap(), err
+}
+
+// 헤더에 잘못된 글자나 겹친 헤더가 있으면 에러를 돌려줍니다.
+func checkRequestHeaders(header http.Header) error {
+	for name, values := range header {
+		if !validHeaderText(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		for _, value := range values {
+			if !validHeaderText(value) {
+				return fmt.Errorf("invalid value in header %s", name)
+			}
+		}
+	}
+	for _, name := range singleHeaders {
+		if len(header.Values(name)) > 1 {
+			return fmt.Errorf("duplicate header %s", name)
+		}
+	}
+	return nil
+}
+
+// 제어 문자(탭 말고)가 없는 UTF-8 인지
+func validHeaderText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, c := range s {
+		if c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// 사용자 값을 응답 헤더에 넣을 수 있게 제어 문자를 지웁니다.
+func SafeHeaderValue(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c < ' ' && c != '\t' || c == 0x7f || c == utf8.RuneError {
+			return -1
+		}
+		return c
+	}, s)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	old := trustedProxies
+	trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	t.Cleanup(func() { trustedProxies = old })
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		ip         string
+	}{
+		{"direct", "192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"untrusted peer", "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.7", "192.0.2.1"},
+		{"trusted proxy", "10.0.0.1:1234", "X-Forwarded-For", "198.51.100.7", "198.51.100.7"},
+		{"spoofed left", "10.0.0.1:1234", "X-Forwarded-For", "203.0.113.9, 198.51.100.7", "198.51.100.7"},
+		{"proxy chain", "10.0.0.1:1234", "X-Forwarded-For", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"only proxies", "10.0.0.1:1234", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"no header", "10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"unknown", "10.0.0.1:1234", "X-Forwarded-For", "garbage", "10.0.0.1"},
+		{"unknown behind proxy", "10.0.0.1:1234", "X-Forwarded-For", "garbage, 10.0.0.2", "10.0.0.2"},
+		{"forwarded", "10.0.0.1:1234", "Forwarded", `for=198.51.100.7;proto=https`, "198.51.100.7"},
+		{"forwarded ipv6", "10.0.0.1:1234", "Forwarded", `for="[2001:db8::1]:4711", for=10.0.0.2`, "2001:db8::1"},
+		{"forwarded unknown", "10.0.0.1:1234", "Forwarded", "for=unknown", "10.0.0.1"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = test.remoteAddr
+		if test.header != "" {
+			request.Header.Set(test.header, test.value)
+		}
+		if got := clientIP(request); got != test.ip {
+			t.Errorf("%s: clientIP = %q, want %q", test.name, got, test.ip)
+		}
+	}
+}
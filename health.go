@@ -0,0 +1,56 @@
+//
+// health.go
+//
+// 로드밸런서와 k8s liveness probe 가 부르는 /healthz 입니다.
+// 프로세스가 요청에 답할 수 있으면 200 과 간단한 프로세스 정보를 돌려줍니다.
+//
+//   $ curl localhost:8080/healthz
+//   {"status":"ok","uptime_seconds":42,"goroutines":9,"pid":1234,"go_version":"go1.22.0"}
+//
+// 저장소, 템플릿 같은 의존하는 것들의 검사는 /readyz 에서 합니다. (ready.go)
+//
+// probe는 몇 초마다 들어오므로 접근 로그(accesslog.go), 요청 수 제한, tracing 에서는 뺍니다. (isProbe)
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+)
+
+// /healthz 의 응답 본문
+type Health struct {
+	Status     string `json:"status"`
+	Uptime     int64  `json:"uptime_seconds"`
+	Goroutines int    `json:"goroutines"`
+	PID        int    `json:"pid"`
+	GoVersion  string `json:"go_version"`
+}
+
+// 로그와 요청 수 제한에서 빼는 probe 주소들
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// request가 health probe 이면 true
+func isProbe(request *http.Request) bool {
+	return probePaths[request.URL.Path]
+}
+
+// GET /healthz
+// 다른 것(저장소, 디스크 등)은 확인하지 않습니다. 그것이 실패해도 재시작으로 고쳐지지 않기 때문입니다.
+func HealthHandler(response http.ResponseWriter, request *http.Request) {
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(Health{
+		Status:     "ok",
+		Uptime:     int64(time.Since(serverStarted) / time.Second),
+		Goroutines: runtime.NumGoroutine(),
+		PID:        os.Getpid(),
+		GoVersion:  runtime.Version(),
+	})
+}
@@ -0,0 +1,96 @@
+//
+// https.go
+//
+// 로그인, 관리자 주소, 아이템 쓰기처럼 비밀번호나 쿠키가 오가는 주소를 https 로만 받습니다.
+//
+//   "https_only": {"routes": [{"path": "/login"}, {"path": "/admin/"},
+//                             {"path": "/items", "methods": ["POST"]}],
+//                  "port": 443}
+//
+// routes 의 형식은 "access" 의 규칙(rbac.go)과 같습니다. ("/" 로 끝나면 그 아래 모두, methods 가 비면 모든 메소드)
+// http 로 온 GET, HEAD 요청은 같은 주소의 https 로 301 리다이렉트하고, 다른 메소드는 403 으로 거절합니다.
+// POST 는 리다이렉트해도 본문이 이미 평문으로 지나갔으므로 클라이언트가 고쳐야 합니다.
+// 리다이렉트할 호스트는 site_url 이 있으면 그것, 없으면 요청의 Host 이고, port 가 443 이 아니면 붙입니다.
+//
+// 이 서버가 직접 TLS 를 받은 요청이거나, 믿는 프록시(headers.go 의 trusted_proxies)가
+// X-Forwarded-Proto: https 나 Forwarded: proto=https 를 붙인 요청을 https 로 봅니다.
+// 다른 곳에서 온 프록시 헤더는 headers.go 가 먼저 지우므로 속일 수 없습니다.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// https 전용 주소 설정
+type HTTPSOnlyConfig struct {
+	Routes []RouteRule `json:"routes"`
+	Port   int         `json:"port"` // 리다이렉트할 https 포트
+}
+
+// request가 https 로 왔는지
+func isHTTPS(request *http.Request) bool {
+	if request.TLS != nil {
+		return true
+	}
+	// gRPC 처럼 headers.go 를 거치지 않는 요청도 있으므로 믿는 프록시에서 온 것인지 여기서도 봅니다.
+	if len(trustedProxies) == 0 || !ipAllowed(request, trustedProxies) {
+		return false
+	}
+	if strings.EqualFold(strings.TrimSpace(strings.Split(request.Header.Get("X-Forwarded-Proto"), ",")[0]), "https") {
+		return true
+	}
+	for _, part := range strings.Split(strings.Split(request.Header.Get("Forwarded"), ",")[0], ";") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.EqualFold(key, "proto") {
+			return strings.EqualFold(strings.Trim(value, `"`), "https")
+		}
+	}
+	return false
+}
+
+// request와 같은 주소의 https 주소
+func httpsURL(request *http.Request, port int) string {
+	host := request.Host
+	if siteURL != "" {
+		if u, err := url.Parse(siteURL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if port != 0 && port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return "https://" + host + request.URL.RequestURI()
+}
+
+// routes 의 주소에 http 로 온 요청을 https 로 보내거나 거절합니다. routes 가 없으면 next를 그대로 돌려줍니다.
+func HTTPSOnlyHandler(config HTTPSOnlyConfig, next http.Handler) http.Handler {
+	if len(config.Routes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if isHTTPS(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		for _, route := range config.Routes {
+			if !route.matches(request) {
+				continue
+			}
+			if request.Method == http.MethodGet || request.Method == http.MethodHead {
+				http.Redirect(response, request, httpsURL(request, config.Port), http.StatusMovedPermanently)
+				return
+			}
+			WriteError(response, request, http.StatusForbidden, fmt.Errorf("%s %s requires https", request.Method, request.URL.Path))
+			return
+		}
+		next.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,205 @@
+//
+// item.go
+//
+// /item/name 이 돌려주는 item 자료형과 gob 인코딩입니다.
+//
+// 내부의 Go 클라이언트는 JSON 대신 gob으로 item을 받을 수 있습니다.
+// Accept 헤더에 application/x-gob 을 넣으면 됩니다.
+//
+//   $ curl -H 'Accept: application/x-gob' http://localhost:8080/item/yellow
+//
+// 받는 쪽에서는 client 패키지의 FetchItem 을 쓰면 됩니다. (client/client.go)
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gob 응답의 MIME type
+const GobContentType = "application/x-gob"
+
+// item 하나. JSON으로는 {"name":"yellow","what":"item"} 입니다.
+type Item struct {
+	Name string `json:"name"`
+	What string `json:"what"`
+}
+
+// item을 HTML 상세 페이지로 응답합니다.
+// html/template이 이름 등을 알아서 이스케이프해줍니다.
+func WriteItemHTML(response http.ResponseWriter, request *http.Request, item Item) {
+	RenderTemplate(response, request, "item", item)
+}
+
+// value를 gob으로 인코딩하여 응답합니다.
+// gob은 바이너리이므로 SetContentType을 쓰지 않고 charset 없이 보냅니다.
+func WriteGob(response http.ResponseWriter, request *http.Request, value interface{}) {
+	response.Header().Set("Content-Type", GobContentType)
+	if err := gob.NewEncoder(response).Encode(value); err != nil {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("gob encode error %v", err))
+	}
+}
+
+// GET /items 에 대한 응답
+//
+// 기본은 JSON 배열입니다. Accept: text/csv 이거나 ?format=csv 이면
+// 엑셀에서 바로 열 수 있는 CSV로 응답합니다.
+// 첫 줄의 헤더는 ?header=absent (또는 Accept: text/csv;header=absent) 로 뺄 수 있습니다.
+//
+// POST /items 에 JSON item을 보내면 저장소에 추가합니다. (PostItem)
+func ItemsHandler(response http.ResponseWriter, request *http.Request) {
+	SetMyCookie(response, request)
+	if request.Method == http.MethodPost {
+		PostItem(response, request)
+		return
+	}
+	response.Header().Add("Vary", "Accept")
+
+	if request.URL.Query().Get("format") == "csv" || AcceptsType(request, "text/csv") {
+		items := listItems(request)
+		// CSV 만들기는 워커 풀에서 합니다. 워커가 모두 바쁘고 큐도 가득 차면 잠시 뒤에 다시 오게 합니다.
+		err := workers.Do(request.Context(), func() { WriteItemsCSV(response, request, items) })
+		if err == ErrPoolFull {
+			response.Header().Set("Retry-After", "1")
+			WriteError(response, request, http.StatusServiceUnavailable, fmt.Errorf("items csv: %v", err))
+		}
+		return
+	}
+	// 동시에 온 요청들은 목록을 한 번만 읽고 인코딩한 결과를 나눠 받습니다. (coalesce.go)
+	body, err, _ := flights.Do("items.json", func() ([]byte, error) {
+		return encodeJSON(listItems(request))
+	})
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSONBytes(response, http.StatusOK, body)
+}
+
+// 저장소의 모든 item
+func listItems(request *http.Request) []Item {
+	_, span := StartSpan(request.Context(), "ItemStore.List")
+	items := store.List()
+	span.SetAttribute("item.count", len(items))
+	span.End()
+	return items
+}
+
+// name이 item 이름으로 쓸 수 있는지. 영문, 숫자와 _ 만 됩니다. (정규식 \w+ 와 같습니다.)
+// 요청마다 부르므로 정규식 대신 바이트를 직접 보고, 메모리를 할당하지 않습니다.
+func isItemName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// /item/name 주소에서 name을 꺼냅니다. name은 path의 일부분이므로 새로 할당하지 않습니다.
+//
+//	itemPathName("/item/green")  ==  "green", true
+//	itemPathName("/item/a/b")    ==  "", false
+func itemPathName(path string) (string, bool) {
+	name, ok := strings.CutPrefix(path, "/item/")
+	if !ok || !isItemName(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// POST /items 에 대한 응답
+//
+//	$ curl -d '{"name":"green","what":"item"}' http://localhost:8080/items
+//
+// 저장한 item을 201 Created 와 함께 돌려줍니다. 변경은 /items/changes 로 알려집니다.
+func PostItem(response http.ResponseWriter, request *http.Request) {
+	var item Item
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 64*1024)).Decode(&item); err != nil {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("item decode error %v", err))
+		return
+	}
+	item, err := SaveItem(withAccessRequest(request), item)
+	var accessErr *accessError
+	if errors.As(err, &accessErr) {
+		WriteError(response, request, accessErr.status, err)
+		return
+	} else if err != nil {
+		WriteError(response, request, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	response.Header().Set("Location", "/item/"+url.PathEscape(item.Name))
+	WriteJSON(response, http.StatusCreated, item)
+}
+
+// item을 검사하고 저장소에 넣습니다. POST /items, gRPC(grpc.go), GraphQL(graphql.go)이 함께 씁니다.
+// 이름이 잘못되었으면 에러를 돌려주고, what 이 비어 있으면 "item" 으로 채웁니다.
+// 어디서 오든 POST /items 의 https 전용 주소와 접근 규칙(items:write)을 따릅니다. ctx 에는 withAccessRequest 로 요청을 넣어 둡니다.
+func SaveItem(ctx context.Context, item Item) (Item, error) {
+	if err := Authorize(ctx, http.MethodPost, "/items"); err != nil {
+		return item, err
+	}
+	if !isItemName(item.Name) {
+		return item, fmt.Errorf("invalid item name %q", item.Name)
+	}
+	if item.What == "" {
+		item.What = "item"
+	}
+	_, span := StartSpan(ctx, "ItemStore.Put")
+	change := store.Put(item)
+	span.SetAttribute("item.seq", int64(change.Seq))
+	span.End()
+	return item, nil
+}
+
+// items를 RFC 4180 CSV로 씁니다.
+// 엑셀이 한글을 UTF-8로 읽도록 맨 앞에 BOM을 붙입니다.
+func WriteItemsCSV(response http.ResponseWriter, request *http.Request, items []Item) {
+	header := request.URL.Query().Get("header")
+	if header == "" {
+		header = acceptParam(request, "text/csv", "header")
+	}
+
+	SetContentType(response, "text/csv")
+	response.Header().Set("Content-Disposition", `attachment; filename="items.csv"`)
+	fmt.Fprint(response, "\uFEFF")
+
+	w := csv.NewWriter(response)
+	w.UseCRLF = true
+	if header != "absent" {
+		w.Write([]string{"name", "what"})
+	}
+	for _, item := range items {
+		w.Write([]string{item.Name, item.What})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		Logf(request.Context(), "items csv write error %v", err)
+	}
+}
+
+// Accept 헤더에서 mimetype 항목의 파라미터 값을 찾습니다.
+// 예) Accept: text/csv;header=absent  =>  acceptParam(request, "text/csv", "header") == "absent"
+func acceptParam(request *http.Request, mimetype, name string) string {
+	for _, part := range strings.Split(request.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mt == mimetype {
+			return params[name]
+		}
+	}
+	return ""
+}
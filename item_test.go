@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestItemPathName(t *testing.T) {
+	tests := []struct {
+		path string
+		name string
+		ok   bool
+	}{
+		{"/item/green", "green", true},
+		{"/item/snake_case_1", "snake_case_1", true},
+		{"/item/", "", false},
+		{"/item/a/b", "", false},
+		{"/item/a-b", "", false},
+		{"/items", "", false},
+		{"/other/green", "", false},
+	}
+	for _, test := range tests {
+		name, ok := itemPathName(test.path)
+		if name != test.name || ok != test.ok {
+			t.Errorf("itemPathName(%q) = %q, %v; want %q, %v", test.path, name, ok, test.name, test.ok)
+		}
+	}
+}
+
+func TestItemPathNameAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		itemPathName("/item/green")
+		itemPathName("/item/a/b")
+	})
+	if allocs != 0 {
+		t.Errorf("itemPathName allocates %v times per call, want 0", allocs)
+	}
+}
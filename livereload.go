@@ -0,0 +1,164 @@
+//
+// livereload.go
+//
+// -dev 모드에서 템플릿이나 정적 파일을 고치면 브라우저가 알아서 새로고침합니다.
+//
+// 모든 HTML 응답의 </body> 앞에 <script src="/__livereload.js"> 를 넣고,
+// 이 스크립트는 /__livereload 에 SSE(Server-Sent Events)로 연결해 기다립니다.
+// 서버는 파일의 수정 시간을 주기적으로 확인하다가 바뀐 것이 있으면
+// 연결된 모든 브라우저에 "reload" 이벤트를 보냅니다.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 파일 변경을 확인하는 간격
+const liveReloadInterval = 500 * time.Millisecond
+
+// 연결이 끊기지 않도록 보내는 빈 이벤트의 간격
+const liveReloadHeartbeat = 15 * time.Second
+
+// 브라우저에서 돌아가는 스크립트. 연결이 끊겼다가 다시 붙으면(서버 재시작) 새로고침합니다.
+const liveReloadScript = `(function () {
+  var connected = false;
+  var source = new EventSource("/__livereload");
+  source.addEventListener("reload", function () { location.reload(); });
+  source.onopen = function () {
+    if (connected) { location.reload(); }
+    connected = true;
+  };
+})();
+`
+
+// SSE로 연결된 브라우저들에게 새로고침을 알려주는 핸들러
+type LiveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// 개발 모드에서만 만들어집니다. nil이면 스크립트를 넣지 않습니다.
+var liveReload *LiveReload
+
+// 새 LiveReload를 만들고 파일 감시를 시작합니다.
+func NewLiveReload() *LiveReload {
+	lr := &LiveReload{clients: make(map[chan struct{}]bool)}
+	go lr.watch()
+	return lr
+}
+
+// 파일들의 수정 시간을 주기적으로 확인하고, 바뀌면 브라우저들에게 알립니다.
+func (lr *LiveReload) watch() {
+	last, _ := liveReloadStamp()
+	for range time.Tick(liveReloadInterval) {
+		stamp, err := liveReloadStamp()
+		if err != nil || stamp == last {
+			continue
+		}
+		last = stamp
+		log.Print("livereload: files changed, reloading browsers")
+		lr.broadcast()
+	}
+}
+
+// 템플릿과 정적 파일의 크기, 수정 시간
+func liveReloadStamp() (string, error) {
+	stamp, err := templateStamp()
+	if err != nil || staticFiles == nil {
+		return stamp, err
+	}
+	err = fs.WalkDir(staticFiles.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return stamp, err
+}
+
+// 연결된 모든 브라우저에 새로고침을 알립니다.
+func (lr *LiveReload) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- struct{}{}:
+		default: // 이미 알림이 하나 기다리고 있음
+		}
+	}
+}
+
+// /__livereload : SSE 연결
+func (lr *LiveReload) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("livereload: streaming not supported"))
+		return
+	}
+	client := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[client] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	SetContentType(response, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	fmt.Fprint(response, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveReloadHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-draining:
+			fmt.Fprint(response, "event: shutdown\ndata: shutdown\n\n")
+			flusher.Flush()
+			return
+		case <-client:
+			fmt.Fprint(response, "event: reload\ndata: reload\n\n")
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(response, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// /__livereload.js : 브라우저 스크립트
+func LiveReloadScriptHandler(response http.ResponseWriter, request *http.Request) {
+	SetContentType(response, "text/javascript")
+	response.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(response, liveReloadScript)
+}
+
+// HTML 페이지의 </body> 앞에 live reload 스크립트를 넣습니다.
+func InjectLiveReload(page []byte) []byte {
+	tag := []byte(`<script nonce="` + cspNoncePlaceholder + `" src="/__livereload.js"></script>`)
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, tag...)
+	}
+	out := make([]byte, 0, len(page)+len(tag))
+	out = append(out, page[:i]...)
+	out = append(out, tag...)
+	return append(out, page[i:]...)
+}
@@ -0,0 +1,167 @@
+//
+// lockout.go
+//
+// 비밀번호를 계속 맞춰 보는 공격을 막습니다. 로그인(login.go)과 사용자 저장소로 하는
+// Basic 인증(guard.go, rbac.go)의 실패를 사용자 이름마다, 클라이언트 IP마다 셉니다.
+//
+//   "login": {"lockout": {"max_failures": 5, "ip_max_failures": 20, "window": 900,
+//                         "base_delay": 1, "max_delay": 900}}
+//
+// 실패가 max_failures 번(IP는 ip_max_failures 번)이 되면 base_delay 초 동안 잠그고,
+// 그 뒤로 실패할 때마다 잠그는 시간을 두 배로 늘립니다. (최대 max_delay 초)
+//
+//   실패 5번 => 1초, 6번 => 2초, 7번 => 4초 ... 15번 => 900초
+//
+// 잠긴 동안에는 비밀번호가 맞아도 429 Too Many Requests 와 Retry-After 로 거절합니다.
+// 로그인에 성공하면 그 사용자의 실패 수를 지웁니다. window 초 동안 실패가 없어도 지웁니다.
+// 잠글 때와 잠긴 동안의 시도는 감사 로그(audit.go)에 auth.lockout, auth.locked 로 남깁니다.
+//
+// 클라이언트 IP 는 clientIP(headers.go)로 읽으므로 믿는 프록시(trusted_proxies) 뒤에서도 클라이언트마다 셉니다.
+// max_failures 가 0이면 사용자 이름으로는, ip_max_failures 가 0이면 IP로는 잠그지 않습니다.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 잠금 설정
+type LockoutConfig struct {
+	MaxFailures   int `json:"max_failures"`    // 사용자 이름마다
+	IPMaxFailures int `json:"ip_max_failures"` // 클라이언트 IP마다
+	Window        int `json:"window"`          // 초. 이만큼 실패가 없으면 실패 수를 지웁니다.
+	BaseDelay     int `json:"base_delay"`      // 초
+	MaxDelay      int `json:"max_delay"`       // 초
+}
+
+// 한 사용자나 IP의 실패 기록
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// 로그인 실패를 세고 잠급니다.
+type LoginLimiter struct {
+	config LockoutConfig
+
+	mu      sync.Mutex
+	entries map[string]*loginFailures // "user:alice", "ip:127.0.0.1"
+}
+
+// 서버 전체가 쓰는 잠금. main에서 설정으로 만듭니다.
+var loginLimiter = NewLoginLimiter(DefaultConfig().Login.Lockout)
+
+func NewLoginLimiter(config LockoutConfig) *LoginLimiter {
+	return &LoginLimiter{config: config, entries: make(map[string]*loginFailures)}
+}
+
+// 요청과 사용자 이름으로 세는 키들과 각각의 최대 실패 수
+func (l *LoginLimiter) keys(request *http.Request, name string) map[string]int {
+	return map[string]int{"user:" + name: l.config.MaxFailures, "ip:" + clientIP(request): l.config.IPMaxFailures}
+}
+
+// 잠겨 있으면 남은 시간, 아니면 0
+func (l *LoginLimiter) Locked(request *http.Request, name string) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for key := range l.keys(request, name) {
+		if entry := l.entries[key]; entry != nil && entry.lockedUntil.After(now) {
+			wait = max(wait, entry.lockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// 실패를 하나 셉니다. 최대 실패 수를 넘으면 잠급니다.
+func (l *LoginLimiter) Fail(request *http.Request, name string) {
+	now := time.Now()
+	window := time.Duration(l.config.Window) * time.Second
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) > 10000 {
+		l.prune(now, window)
+	}
+	for key, limit := range l.keys(request, name) {
+		if limit <= 0 {
+			continue
+		}
+		entry := l.entries[key]
+		if entry == nil || (window > 0 && now.Sub(entry.last) > window) {
+			entry = &loginFailures{}
+			l.entries[key] = entry
+		}
+		entry.count++
+		entry.last = now
+		if entry.count < limit {
+			continue
+		}
+		delay := l.delay(entry.count - limit)
+		entry.lockedUntil = now.Add(delay)
+		audit.Record(request, "auth.lockout", name, map[string]string{
+			"key": key, "failures": strconv.Itoa(entry.count), "seconds": strconv.Itoa(int(delay / time.Second)),
+		})
+	}
+}
+
+// 최대 실패 수를 over 번 넘었을 때 잠그는 시간. base_delay * 2^over, 최대 max_delay
+func (l *LoginLimiter) delay(over int) time.Duration {
+	delay := time.Duration(l.config.BaseDelay) * time.Second
+	limit := time.Duration(l.config.MaxDelay) * time.Second
+	for i := 0; i < over && delay < limit; i++ {
+		delay *= 2
+	}
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// 로그인에 성공하면 그 사용자의 실패 수를 지웁니다. IP의 실패 수는 남깁니다.
+func (l *LoginLimiter) Succeed(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, "user:"+name)
+}
+
+// window 동안 실패가 없고 잠겨 있지 않은 기록을 지웁니다. mu를 잡은 상태에서 불러야 합니다.
+func (l *LoginLimiter) prune(now time.Time, window time.Duration) {
+	for key, entry := range l.entries {
+		if entry.lockedUntil.Before(now) && now.Sub(entry.last) > window {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// 잠금을 확인하며 사용자 저장소로 비밀번호를 확인합니다. 잠겨 있으면 남은 시간을 돌려줍니다.
+// 실패 수는 로그인을 모두 마친 뒤에(2단계 인증까지) 지웁니다.
+func authenticate(request *http.Request, name, password string) (ok bool, locked time.Duration) {
+	if wait := loginLimiter.Locked(request, name); wait > 0 {
+		audit.Record(request, "auth.locked", name, nil)
+		return false, wait
+	}
+	if !users.Verify(name, password) {
+		loginLimiter.Fail(request, name)
+		return false, 0
+	}
+	return true, 0
+}
+
+// Basic 인증으로 사용자를 확인합니다. 2단계 인증을 켠 사용자는 코드를 보낼 수 없으므로 받지 않습니다.
+func basicAuthenticate(request *http.Request, name, password string) bool {
+	if ok, _ := authenticate(request, name, password); !ok || totpEnabled(name) {
+		return false
+	}
+	loginLimiter.Succeed(name)
+	return true
+}
+
+// 잠겨 있다는 응답의 Retry-After (초, 올림)
+func setRetryAfter(response http.ResponseWriter, wait time.Duration) {
+	response.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+}
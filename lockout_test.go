@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func requestFrom(ip string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/login", nil)
+	request.RemoteAddr = ip + ":1234"
+	return request
+}
+
+func TestLoginLimiterThreshold(t *testing.T) {
+	l := NewLoginLimiter(LockoutConfig{MaxFailures: 3, Window: 900, BaseDelay: 10, MaxDelay: 60})
+	request := requestFrom("192.0.2.1")
+	for i := 1; i < 3; i++ {
+		l.Fail(request, "alice")
+		if wait := l.Locked(request, "alice"); wait != 0 {
+			t.Fatalf("locked for %v after %d failures", wait, i)
+		}
+	}
+	l.Fail(request, "alice")
+	if wait := l.Locked(request, "alice"); wait <= 9*time.Second || wait > 10*time.Second {
+		t.Errorf("locked for %v after 3 failures, want 10s", wait)
+	}
+	if wait := l.Locked(request, "bob"); wait != 0 {
+		t.Errorf("other user locked for %v (ip_max_failures is 0)", wait)
+	}
+}
+
+func TestLoginLimiterDelay(t *testing.T) {
+	l := NewLoginLimiter(LockoutConfig{BaseDelay: 1, MaxDelay: 900})
+	for over, want := range []time.Duration{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 900, 900} {
+		if got := l.delay(over); got != want*time.Second {
+			t.Errorf("delay(%d) = %v, want %v", over, got, want*time.Second)
+		}
+	}
+	if got := l.delay(1 << 20); got != 900*time.Second {
+		t.Errorf("delay(1<<20) = %v, want max_delay", got)
+	}
+
+	// 최대 실패 수를 넘은 뒤로는 실패할 때마다 두 배로 늘어납니다.
+	l = NewLoginLimiter(LockoutConfig{MaxFailures: 1, Window: 900, BaseDelay: 10, MaxDelay: 30})
+	request := requestFrom("192.0.2.1")
+	for _, want := range []time.Duration{10, 20, 30, 30} {
+		l.Fail(request, "alice")
+		if wait := l.Locked(request, "alice"); wait <= want*time.Second-time.Second || wait > want*time.Second {
+			t.Errorf("locked for %v, want %v", wait, want*time.Second)
+		}
+	}
+}
+
+func TestLoginLimiterWindow(t *testing.T) {
+	l := NewLoginLimiter(LockoutConfig{MaxFailures: 2, Window: 60, BaseDelay: 1, MaxDelay: 60})
+	request := requestFrom("192.0.2.1")
+	l.Fail(request, "alice")
+	// window 보다 오래전의 실패는 세지 않습니다.
+	l.entries["user:alice"].last = time.Now().Add(-61 * time.Second)
+	l.Fail(request, "alice")
+	if wait := l.Locked(request, "alice"); wait != 0 {
+		t.Errorf("locked for %v by a failure outside the window", wait)
+	}
+	if count := l.entries["user:alice"].count; count != 1 {
+		t.Errorf("count %d, want 1", count)
+	}
+	l.Fail(request, "alice")
+	if wait := l.Locked(request, "alice"); wait == 0 {
+		t.Error("not locked after 2 failures inside the window")
+	}
+}
+
+func TestLoginLimiterSucceed(t *testing.T) {
+	l := NewLoginLimiter(LockoutConfig{MaxFailures: 2, IPMaxFailures: 2, Window: 900, BaseDelay: 10, MaxDelay: 60})
+	request := requestFrom("192.0.2.1")
+	l.Fail(request, "alice")
+	l.Fail(request, "alice")
+	l.Succeed("alice")
+	if _, ok := l.entries["user:alice"]; ok {
+		t.Error("user failures kept after success")
+	}
+	// IP 의 실패 수는 남으므로 같은 IP 에서는 여전히 잠겨 있습니다.
+	if wait := l.Locked(request, "bob"); wait == 0 {
+		t.Error("ip lock cleared by success")
+	}
+	if wait := l.Locked(requestFrom("192.0.2.2"), "alice"); wait != 0 {
+		t.Errorf("alice locked for %v from another ip", wait)
+	}
+}
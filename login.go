@@ -0,0 +1,246 @@
+//
+// login.go
+//
+// 로그인, 로그아웃과 지금 로그인한 사용자입니다. 세션은 session.go 가 관리합니다.
+//
+//   GET  /login     로그인 폼 (templates/login.html)
+//   POST /login     폼이면 ?next= 주소(없으면 /home)로, JSON이면 {"user":"alice"} 로 응답합니다.
+//   POST /logout    세션을 끝냅니다.
+//   POST /2fa/...   2단계 인증 켜기, 끄기 (totp.go)
+//   GET  /me        {"user":"alice","expires":"2026-10-17T08:30:00Z"}, 로그인하지 않았으면 401
+//
+//   $ curl -c jar -H 'Content-Type: application/json' -d '{"username":"alice","password":"..."}' http://localhost:8080/login
+//   $ curl -b jar http://localhost:8080/me
+//
+// 사용자와 비밀번호는 users.go 의 사용자 저장소에서 확인합니다.
+// 로그인 성공과 실패는 감사 로그(audit.go)에 auth.login, auth.login_failed 로 남깁니다.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 로그인과 세션 설정
+type LoginConfig struct {
+	UserFile    string        `json:"user_file"`    // 사용자 파일. users.go
+	SessionTTL  int           `json:"session_ttl"`  // 초
+	IdleTimeout int           `json:"idle_timeout"` // 초
+	TOTPIssuer  string        `json:"totp_issuer"`  // 인증 앱에 보이는 이름. totp.go
+	Lockout     LockoutConfig `json:"lockout"`      // 로그인 실패가 많으면 잠급니다. lockout.go
+}
+
+// 로그인 폼 템플릿(templates/login.html)에 넘겨주는 값들
+type LoginPage struct {
+	Username string
+	Next     string
+	Failed   bool
+	Locked   bool // 실패가 많아 잠시 잠겼습니다. (lockout.go)
+	NeedCode bool // 비밀번호는 맞았고 2단계 인증 코드를 묻습니다. (totp.go)
+}
+
+// 로그인 요청의 JSON 본문
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Code     string `json:"code"` // 2단계 인증을 켠 사용자의 코드 (totp.go)
+}
+
+// 로그인 시도의 결과
+type loginResult int
+
+const (
+	loginOK       loginResult = iota
+	loginFailed               // 이름, 비밀번호나 코드가 틀림
+	loginLocked               // 실패가 많아 잠겨 있음 (lockout.go)
+	loginNeedCode             // 비밀번호는 맞고 2단계 인증 코드가 필요함 (totp.go)
+)
+
+// /login 에 대한 응답
+func LoginHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		RenderTemplate(response, request, "login", LoginPage{Next: request.URL.Query().Get("next")})
+	case http.MethodPost:
+		request.Body = http.MaxBytesReader(response, request.Body, 64*1024)
+		mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if mediaType == "application/json" {
+			loginJSON(response, request)
+		} else {
+			loginForm(response, request)
+		}
+	default:
+		response.Header().Set("Allow", "GET, HEAD, POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func loginJSON(response http.ResponseWriter, request *http.Request) {
+	var body loginRequest
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("login parse error %v", err))
+		return
+	}
+	switch result, locked := login(response, request, body.Username, body.Password, body.Code); result {
+	case loginLocked:
+		setRetryAfter(response, locked)
+		WriteError(response, request, http.StatusTooManyRequests, nil)
+		return
+	case loginNeedCode:
+		SetContentType(response, "application/json")
+		response.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(response).Encode(map[string]bool{"totp_required": true})
+		return
+	case loginFailed:
+		WriteError(response, request, http.StatusUnauthorized, nil)
+		return
+	}
+	SetContentType(response, "application/json")
+	json.NewEncoder(response).Encode(map[string]string{"user": body.Username})
+}
+
+func loginForm(response http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("form parse error %v", err))
+		return
+	}
+	page := LoginPage{
+		Username: strings.TrimSpace(request.PostForm.Get("username")),
+		Next:     request.FormValue("next"),
+	}
+	var result loginResult
+	var locked time.Duration
+	if code := request.PostForm.Get("code"); code != "" && page.Username == "" {
+		// 두 번째 단계: 비밀번호는 login_2fa 쿠키가 이미 확인했습니다.
+		var pending pendingLogin
+		if err := ReadSecureCookie(request, loginTOTPCookie, &pending); err != nil || time.Now().Unix() > pending.Expires {
+			page.Failed = true
+			RenderTemplateStatus(response, request, http.StatusUnauthorized, "login", page)
+			return
+		}
+		page.Username, page.Next = pending.User, pending.Next
+		result, locked = loginSecondFactor(response, request, pending.User, code)
+	} else {
+		result, locked = login(response, request, page.Username, request.PostForm.Get("password"), "")
+	}
+	switch result {
+	case loginLocked:
+		page.Locked = true
+		setRetryAfter(response, locked)
+		RenderTemplateStatus(response, request, http.StatusTooManyRequests, "login", page)
+		return
+	case loginNeedCode:
+		pending := pendingLogin{User: page.Username, Expires: time.Now().Add(loginTOTPTTL).Unix(), Next: page.Next}
+		if err := SetSecureCookie(response, loginTOTPCookie, pending); err != nil {
+			WriteError(response, request, http.StatusInternalServerError, err)
+			return
+		}
+		page.NeedCode = true
+		RenderTemplate(response, request, "login", page)
+		return
+	case loginFailed:
+		page.Failed = true
+		page.NeedCode = request.PostForm.Get("code") != ""
+		RenderTemplateStatus(response, request, http.StatusUnauthorized, "login", page)
+		return
+	}
+	if request.PostForm.Get("code") != "" {
+		expired := NewCookie(loginTOTPCookie, "")
+		expired.MaxAge = -1
+		http.SetCookie(response, expired)
+	}
+	http.Redirect(response, request, localRedirect(page.Next, "/home"), http.StatusSeeOther)
+}
+
+// 비밀번호를 확인하고 맞으면 세션을 시작합니다.
+// 2단계 인증을 켠 사용자는 code도 맞아야 하고, code가 비어 있으면 loginNeedCode 를 돌려줍니다.
+// 실패가 많아 잠겨 있으면 loginLocked 와 남은 시간을 돌려줍니다. (lockout.go)
+func login(response http.ResponseWriter, request *http.Request, name, password, code string) (loginResult, time.Duration) {
+	if ok, locked := authenticate(request, name, password); !ok {
+		if locked > 0 {
+			return loginLocked, locked
+		}
+		audit.Record(request, "auth.login_failed", name, nil)
+		return loginFailed, 0
+	}
+	if totpEnabled(name) {
+		if code == "" {
+			return loginNeedCode, 0
+		}
+		return loginSecondFactor(response, request, name, code)
+	}
+	return startLogin(response, request, name)
+}
+
+// 비밀번호를 확인한 name의 2단계 인증 코드를 확인하고 맞으면 세션을 시작합니다.
+func loginSecondFactor(response http.ResponseWriter, request *http.Request, name, code string) (loginResult, time.Duration) {
+	if locked := loginLimiter.Locked(request, name); locked > 0 {
+		audit.Record(request, "auth.locked", name, nil)
+		return loginLocked, locked
+	}
+	if !verifySecondFactor(name, code) {
+		loginLimiter.Fail(request, name)
+		audit.Record(request, "auth.2fa_failed", name, nil)
+		return loginFailed, 0
+	}
+	return startLogin(response, request, name)
+}
+
+func startLogin(response http.ResponseWriter, request *http.Request, name string) (loginResult, time.Duration) {
+	if err := StartSession(response, name); err != nil {
+		Logf(request.Context(), "ERROR session: %v", err)
+		return loginFailed, 0
+	}
+	loginLimiter.Succeed(name)
+	audit.Record(request, "auth.login", name, nil)
+	return loginOK, 0
+}
+
+// POST /logout
+// 브라우저는 /home 으로 보내고, 다른 클라이언트에게는 204
+func LogoutHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		response.Header().Set("Allow", "POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	if user := CurrentUser(request); user != "" {
+		audit.Record(request, "auth.logout", user, nil)
+	}
+	EndSession(response, request)
+	if AcceptsType(request, "text/html") {
+		http.Redirect(response, request, "/home", http.StatusSeeOther)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// GET /me
+func MeHandler(response http.ResponseWriter, request *http.Request) {
+	session, ok := SessionFromRequest(request)
+	if !ok {
+		WriteError(response, request, http.StatusUnauthorized, nil)
+		return
+	}
+	me := map[string]interface{}{"user": session.User}
+	if expires := sessions.Expires(session); !expires.IsZero() {
+		me["expires"] = expires.UTC().Format(time.RFC3339)
+	}
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(me)
+}
+
+// next가 이 사이트 안의 경로이면 그대로, 아니면 fallback.
+// "//evil.example" 같은 주소로 다른 사이트에 보내지 않습니다.
+func localRedirect(next, fallback string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	return next
+}
@@ -0,0 +1,133 @@
+//
+// logsink.go
+//
+// 서버 로그(log 패키지)를 어디에 쓸지 정합니다. 접근 로그(accesslog.go), 감사 로그(audit.go)는 따로 씁니다.
+//
+//   "log": {"output": "stderr"}                                   기본값
+//   "log": {"output": "file", "file": "/var/log/webserver/server.log"}   SIGUSR1 로 다시 엽니다.
+//   "log": {"output": "syslog", "syslog_address": "udp://logs:514", "tag": "webserver"}
+//   "log": {"output": "journald", "tag": "webserver"}
+//
+// syslog 와 journald 에는 줄의 모양을 보고 우선순위를 붙입니다.
+//
+//   "WARN ..."   warning        (slowlog.go)
+//   "ERROR ..."  err
+//   "panic: ..." crit           (recover.go)
+//   그 밖        info
+//
+// journald 로 보내는 한 줄은 소켓의 데이터그램 하나이므로 아주 큰 줄(body_capture 등)은 잘리거나 실패할 수 있습니다.
+//
+// journald 에는 요청 ID(requestid.go)를 REQUEST_ID 필드로 따로 넣으므로
+// journalctl REQUEST_ID=5f2c9a1e0b7d4c3a 로 한 요청의 로그만 볼 수 있습니다.
+//
+// syslog 와 journald 로 보내는 부분은 logsink_unix.go 에 있습니다. Windows 와 Plan 9 에서는
+// 두 출력을 쓸 수 없고, 설정하면 서버가 시작하지 않습니다. (logsink_other.go)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// 서버 로그 설정
+type LogConfig struct {
+	Output        string `json:"output"`         // "stderr", "stdout", "file", "syslog", "journald"
+	File          string `json:"file"`           // output이 "file" 일 때
+	SyslogAddress string `json:"syslog_address"` // 비어 있으면 이 컴퓨터의 syslog. 예) "udp://logs:514"
+	Tag           string `json:"tag"`            // syslog 태그, journald 의 SYSLOG_IDENTIFIER
+}
+
+// syslog 의 우선순위 (RFC 5424)
+const (
+	priorityCrit    = 2
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+)
+
+// syslog 나 journald 로 보내는 출력
+type logSink interface {
+	io.Writer
+	Close() error
+}
+
+// 이 플랫폼에서 쓸 수 없는 출력을 설정했을 때의 에러
+var errLogSinkUnsupported = errors.New("unsupported on this platform")
+
+// config대로 log 패키지의 출력을 바꿉니다. 돌려주는 close는 서버가 끝난 뒤에 부릅니다.
+func SetupLog(config LogConfig) (close func() error, err error) {
+	nothing := func() error { return nil }
+	tag := config.Tag
+	if tag == "" {
+		tag = "webserver"
+	}
+	switch config.Output {
+	case "", "stderr":
+		return nothing, nil
+	case "stdout":
+		log.SetOutput(os.Stdout)
+		return nothing, nil
+	case "file":
+		// 접근 로그와 같은 파일을 씁니다. 크기로 나누지는 않고 SIGUSR1 에 다시 엽니다.
+		f, err := OpenRotatingFile(AccessLogConfig{File: config.File})
+		if err != nil {
+			return nil, err
+		}
+		ReopenOnSignal(f)
+		log.SetOutput(f)
+		return f.Close, nil
+	case "syslog":
+		network, address := "", ""
+		if config.SyslogAddress != "" {
+			u, err := url.Parse(config.SyslogAddress)
+			if err != nil {
+				return nil, fmt.Errorf("syslog_address: %v", err)
+			}
+			network, address = u.Scheme, u.Host
+		}
+		sink, err := openSyslog(network, address, tag)
+		if err != nil {
+			return nil, err
+		}
+		// 시간은 syslog 가 붙입니다.
+		log.SetFlags(0)
+		log.SetOutput(sink)
+		return sink.Close, nil
+	case "journald":
+		sink, err := openJournald(tag)
+		if err != nil {
+			return nil, err
+		}
+		log.SetFlags(0)
+		log.SetOutput(sink)
+		return sink.Close, nil
+	}
+	return nil, fmt.Errorf("log output %q: want stderr, stdout, file, syslog or journald", config.Output)
+}
+
+// 로그 한 줄에서 요청 ID와 우선순위를 알아냅니다. message는 요청 ID를 뺀 나머지입니다.
+func parseLogLine(line string) (priority int, message, requestID string) {
+	message = strings.TrimRight(line, "\n")
+	if rest, ok := strings.CutPrefix(message, "[req="); ok {
+		if id, after, ok := strings.Cut(rest, "] "); ok {
+			requestID, message = id, after
+		}
+	}
+	switch {
+	case strings.HasPrefix(message, "panic:"):
+		priority = priorityCrit
+	case strings.HasPrefix(message, "ERROR "):
+		priority = priorityErr
+	case strings.HasPrefix(message, "WARN "):
+		priority = priorityWarning
+	default:
+		priority = priorityInfo
+	}
+	return priority, message, requestID
+}
@@ -0,0 +1,18 @@
+//go:build windows || plan9
+
+//
+// logsink_other.go
+//
+// log/syslog 패키지가 없는 플랫폼에서는 syslog 와 journald 출력을 쓸 수 없습니다. (logsink.go)
+
+package main
+
+import "fmt"
+
+func openSyslog(network, address, tag string) (logSink, error) {
+	return nil, fmt.Errorf("log output syslog: %w", errLogSinkUnsupported)
+}
+
+func openJournald(tag string) (logSink, error) {
+	return nil, fmt.Errorf("log output journald: %w", errLogSinkUnsupported)
+}
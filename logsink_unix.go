@@ -0,0 +1,100 @@
+//go:build !windows && !plan9
+
+//
+// logsink_unix.go
+//
+// 서버 로그를 syslog 와 journald 로 보냅니다. (logsink.go)
+// log/syslog 패키지와 journald 의 unix 소켓이 있는 플랫폼에서만 빌드됩니다.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// syslog 에 연결합니다. network 와 address 가 비어 있으면 이 컴퓨터의 syslog 입니다.
+func openSyslog(network, address, tag string) (logSink, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w}, nil
+}
+
+// systemd journal 의 소켓에 연결합니다.
+func openJournald(tag string) (logSink, error) {
+	conn, err := net.Dial("unixgram", "/run/systemd/journal/socket")
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn, tag: tag}, nil
+}
+
+// 줄마다 우선순위를 골라 syslog 로 보냅니다.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	priority, message, requestID := parseLogLine(string(p))
+	if requestID != "" {
+		message = "[req=" + requestID + "] " + message
+	}
+	var err error
+	switch priority {
+	case priorityCrit:
+		err = s.w.Crit(message)
+	case priorityErr:
+		err = s.w.Err(message)
+	case priorityWarning:
+		err = s.w.Warning(message)
+	default:
+		err = s.w.Info(message)
+	}
+	return len(p), err
+}
+
+func (s *syslogSink) Close() error { return s.w.Close() }
+
+// systemd journal 의 native 프로토콜로 보냅니다.
+type journaldSink struct {
+	conn io.WriteCloser
+	tag  string
+}
+
+func (s *journaldSink) Write(p []byte) (int, error) {
+	priority, message, requestID := parseLogLine(string(p))
+	var b bytes.Buffer
+	journalField(&b, "MESSAGE", message)
+	journalField(&b, "PRIORITY", strconv.Itoa(priority))
+	journalField(&b, "SYSLOG_IDENTIFIER", s.tag)
+	if requestID != "" {
+		journalField(&b, "REQUEST_ID", requestID)
+	}
+	if _, err := s.conn.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *journaldSink) Close() error { return s.conn.Close() }
+
+// KEY=value 한 줄. 값에 줄바꿈이 있으면(스택 등) 길이를 앞에 붙이는 형식으로 씁니다.
+func journalField(b *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", key, value)
+		return
+	}
+	b.WriteString(key)
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
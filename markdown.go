@@ -0,0 +1,138 @@
+//
+// markdown.go
+//
+// /docs 페이지를 위한 아주 작은 Markdown 변환기입니다.
+// 외부 라이브러리 없이 문서에 필요한 만큼만 지원합니다.
+//
+//   # 제목 ~ ###### 제목         <h1> ~ <h6>
+//   빈 줄로 나뉜 문단             <p>
+//   - 항목, * 항목               <ul>
+//   1. 항목                      <ol>
+//   > 인용                       <blockquote>
+//   ```로 감싼 코드 블록           <pre><code>
+//   ---                          <hr>
+//   `코드` **굵게** *기울임* [링크](URL)
+//
+// 모든 글자는 먼저 HTML 이스케이프하므로 Markdown 안의 HTML 태그는 그대로 글자로 보입니다.
+
+package main
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdUnordered = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	mdOrdered   = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	mdRule      = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	mdStrong    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdEmphasis  = regexp.MustCompile(`\*([^*]+)\*`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// Markdown 문서를 HTML로 바꿉니다.
+func RenderMarkdown(source string) template.HTML {
+	var out strings.Builder
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, " ")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			out.WriteString("<pre><code>")
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				out.WriteString(html.EscapeString(lines[i]) + "\n")
+			}
+			out.WriteString("</code></pre>\n")
+
+		case mdHeading.MatchString(trimmed):
+			flush()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			out.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+
+		case mdRule.MatchString(trimmed):
+			flush()
+			out.WriteString("<hr>\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			i--
+			out.WriteString("<blockquote>\n" + string(RenderMarkdown(strings.Join(quote, "\n"))) + "</blockquote>\n")
+
+		case mdUnordered.MatchString(line), mdOrdered.MatchString(line):
+			flush()
+			item, tag := mdUnordered, "ul"
+			if !mdUnordered.MatchString(line) {
+				item, tag = mdOrdered, "ol"
+			}
+			out.WriteString("<" + tag + ">\n")
+			for ; i < len(lines) && item.MatchString(lines[i]); i++ {
+				out.WriteString("<li>" + renderInline(item.FindStringSubmatch(lines[i])[1]) + "</li>\n")
+			}
+			i--
+			out.WriteString("</" + tag + ">\n")
+
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+	return template.HTML(out.String())
+}
+
+// 한 줄 안의 코드, 굵게, 기울임, 링크를 HTML로 바꿉니다.
+func renderInline(text string) string {
+	// `코드` 안의 글자는 다른 규칙을 적용하지 않도록 잠시 빼 둡니다.
+	var codes []string
+	text = mdCode.ReplaceAllStringFunc(text, func(m string) string {
+		codes = append(codes, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return "\x00" + strconv.Itoa(len(codes)-1) + "\x00"
+	})
+
+	text = html.EscapeString(text)
+	text = mdLink.ReplaceAllStringFunc(text, func(m string) string {
+		parts := mdLink.FindStringSubmatch(m)
+		return `<a href="` + safeURL(html.UnescapeString(parts[2])) + `">` + parts[1] + "</a>"
+	})
+	text = mdStrong.ReplaceAllString(text, "<strong>$1</strong>")
+	text = mdEmphasis.ReplaceAllString(text, "<em>$1</em>")
+
+	for n, code := range codes {
+		text = strings.Replace(text, "\x00"+strconv.Itoa(n)+"\x00", code, 1)
+	}
+	return text
+}
+
+// javascript: 같은 위험한 링크를 막습니다. http, https, mailto 와 상대 경로만 허용합니다.
+func safeURL(url string) string {
+	lower := strings.ToLower(url)
+	if i := strings.IndexAny(lower, ":/?#"); i >= 0 && lower[i] == ':' &&
+		!strings.HasPrefix(lower, "http:") && !strings.HasPrefix(lower, "https:") && !strings.HasPrefix(lower, "mailto:") {
+		return "#"
+	}
+	return html.EscapeString(url)
+}
@@ -0,0 +1,154 @@
+//
+// memshed.go
+//
+// 메모리가 한도에 가까워지면 OOM killer 에게 프로세스가 죽기 전에 급하지 않은 요청을 503 으로 거절합니다.
+//
+//   "memory_shed": {"limit": 536870912, "high": 0.9, "low": 0.8, "interval": 250,
+//                   "essential": ["/metrics", "/debug/", "/login"]}
+//
+//   $ curl -i localhost:8080/items
+//   HTTP/1.1 503 Service Unavailable
+//   Retry-After: 5
+//
+// Go 런타임이 쓰는 메모리(runtime/metrics 의 /memory/classes/total 에서 OS에 돌려준 것을 뺀 값)를
+// interval ms 마다 읽어서, limit 의 high 배를 넘으면 거절을 시작하고 low 배 아래로 내려오면 멈춥니다.
+// 두 값이 다르므로 경계에서 켜졌다 꺼졌다 하지 않습니다.
+//
+// limit 이 0이면 GC 의 메모리 목표(GOMEMLIMIT, container.go 가 cgroup 한도로 정한 값)를 씁니다.
+// 그것도 없으면 끕니다.
+//
+// 헬스 체크(/healthz, /readyz)와 essential 로 시작하는 주소는 거절하지 않습니다.
+// 거절을 시작하고 멈출 때 로그를 남기고, 상태는 /debug/vars 의 "memory_shed" 로 볼 수 있습니다. (debug.go)
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	runtimemetrics "runtime/metrics"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 메모리 부족 때의 요청 거절 설정
+type MemoryShedConfig struct {
+	Limit     int64    `json:"limit"`     // 바이트. 0이면 GOMEMLIMIT
+	High      float64  `json:"high"`      // limit 의 이 비율을 넘으면 거절을 시작합니다.
+	Low       float64  `json:"low"`       // limit 의 이 비율 아래로 내려오면 거절을 멈춥니다.
+	Interval  int      `json:"interval"`  // 메모리를 읽는 간격(ms)
+	Essential []string `json:"essential"` // 거절하지 않는 주소들의 앞부분
+}
+
+// /debug/vars 의 "memory_shed"
+type MemoryShedStatus struct {
+	Shedding bool  `json:"shedding"`
+	Memory   int64 `json:"memory"` // 마지막으로 읽은 메모리 (바이트)
+	Limit    int64 `json:"limit"`
+	Shed     int64 `json:"shed"` // 거절한 요청 수
+}
+
+// 메모리를 보고 거절할지 정합니다.
+type MemoryShedder struct {
+	config MemoryShedConfig
+	limit  int64
+
+	shedding atomic.Bool
+	memory   atomic.Int64
+	shed     atomic.Int64
+}
+
+// 서버 전체의 거절 상태. 꺼져 있으면 nil 입니다.
+var memoryShedder *MemoryShedder
+
+// 지금 Go 런타임이 OS에서 받아 쓰고 있는 메모리
+func runtimeMemory() int64 {
+	samples := []runtimemetrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	runtimemetrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// config로 메모리를 보기 시작합니다. 한도가 없으면 nil을 돌려줍니다.
+func StartMemoryShedder(config MemoryShedConfig) (*MemoryShedder, error) {
+	limit := config.Limit
+	if limit == 0 {
+		limit = currentMemoryLimit()
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	if config.Low <= 0 || config.High > 1 || config.Low > config.High {
+		return nil, fmt.Errorf("memory_shed: need 0 < low (%v) <= high (%v) <= 1", config.Low, config.High)
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("memory_shed: interval %d must be positive", config.Interval)
+	}
+	s := &MemoryShedder{config: config, limit: limit}
+	log.Printf("memory_shed: shedding above %s of %s", mebibytes(int64(config.High*float64(limit))), mebibytes(limit))
+	s.check()
+	go func() {
+		for range time.Tick(time.Duration(config.Interval) * time.Millisecond) {
+			s.check()
+		}
+	}()
+	return s, nil
+}
+
+// 메모리를 읽고 거절 상태를 바꿉니다.
+func (s *MemoryShedder) check() {
+	memory := runtimeMemory()
+	s.memory.Store(memory)
+	high := int64(s.config.High * float64(s.limit))
+	low := int64(s.config.Low * float64(s.limit))
+	if memory > high && s.shedding.CompareAndSwap(false, true) {
+		log.Printf("WARN memory_shed: %s of %s used, rejecting non-essential requests", mebibytes(memory), mebibytes(s.limit))
+	} else if memory < low && s.shedding.CompareAndSwap(true, false) {
+		log.Printf("memory_shed: %s of %s used, accepting requests again (%d rejected)", mebibytes(memory), mebibytes(s.limit), s.shed.Load())
+	}
+}
+
+// request를 거절하지 않는 주소인지
+func (s *MemoryShedder) essential(request *http.Request) bool {
+	if isProbe(request) {
+		return true
+	}
+	for _, prefix := range s.config.Essential {
+		if strings.HasPrefix(request.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 지금의 거절 상태. 꺼져 있으면(nil) 빈 상태입니다.
+func (s *MemoryShedder) Status() MemoryShedStatus {
+	if s == nil {
+		return MemoryShedStatus{}
+	}
+	return MemoryShedStatus{
+		Shedding: s.shedding.Load(),
+		Memory:   s.memory.Load(),
+		Limit:    s.limit,
+		Shed:     s.shed.Load(),
+	}
+}
+
+// 메모리가 한도에 가까우면 급하지 않은 요청을 503 으로 거절합니다. shedder가 nil이면 next를 그대로 돌려줍니다.
+func MemoryShedHandler(shedder *MemoryShedder, next http.Handler) http.Handler {
+	if shedder == nil {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if shedder.shedding.Load() && !shedder.essential(request) {
+			shedder.shed.Add(1)
+			response.Header().Set("Retry-After", "5")
+			WriteError(response, request, http.StatusServiceUnavailable, fmt.Errorf("memory_shed: %s of %s used", mebibytes(shedder.memory.Load()), mebibytes(shedder.limit)))
+			return
+		}
+		next.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,318 @@
+//
+// messages.go
+//
+// 에러 응답에 쓰이는 메시지 카탈로그입니다.
+// 클라이언트가 보낸 Accept-Language 헤더를 보고 한국어 또는 영어로
+// 에러의 제목(title)과 설명(detail)을 돌려줍니다.
+//
+//   Accept-Language: ko-KR,ko;q=0.9,en;q=0.8   =>  한국어
+//   Accept-Language: en-US                      =>  English
+//   (헤더 없음)                                  =>  English (기본값)
+//
+// GET /language?lang=ko&next=/home 으로 언어를 직접 고르면 서명된 쿠키(securecookie.go)에 남겨 헤더보다 먼저 씁니다.
+//
+// HTML 페이지의 글자도 여기의 uiCatalog 에서 가져옵니다. 템플릿에서 {{t "nav.home"}} 처럼 씁니다.
+//
+// 브라우저에게는 templates/404.html, 500.html (다른 상태 코드는 error.html) 을
+// 레이아웃에 넣어 보여주고, API 클라이언트에게는 JSON을 보냅니다.
+//
+// 에러 응답 본문은 RFC 7807 의 application/problem+json 형식입니다.
+//   {"type":"about:blank","title":"찾을 수 없음","status":404,"detail":"요청한 페이지를 찾을 수 없습니다."}
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 카탈로그에 메시지가 없을 때 사용할 언어
+const DefaultLanguage = "en"
+
+// 지원하는 언어들
+var Languages = []string{"en", "ko"}
+
+// 에러 하나에 대한 제목과 설명
+type ErrorMessage struct {
+	Title  string
+	Detail string
+}
+
+// 언어 -> 상태 코드 -> 메시지
+var errorCatalog = map[string]map[int]ErrorMessage{
+	"ko": {
+		http.StatusBadRequest:            {"잘못된 요청", "요청의 형식이 올바르지 않습니다."},
+		http.StatusUnauthorized:          {"인증 필요", "이 요청을 하려면 로그인이 필요합니다."},
+		http.StatusForbidden:             {"접근 거부", "이 요청을 처리할 권한이 없습니다."},
+		http.StatusNotFound:              {"찾을 수 없음", "요청한 페이지를 찾을 수 없습니다."},
+		http.StatusMethodNotAllowed:      {"허용되지 않은 메소드", "이 URL은 요청한 HTTP 메소드를 지원하지 않습니다."},
+		http.StatusRequestEntityTooLarge: {"요청이 너무 큼", "보낸 내용이 허용된 크기보다 큽니다."},
+		http.StatusUnsupportedMediaType:  {"지원하지 않는 형식", "이 형식의 파일은 받을 수 없습니다."},
+		http.StatusUnprocessableEntity:   {"처리할 수 없는 요청", "요청 내용을 처리할 수 없습니다."},
+		http.StatusTooManyRequests:       {"요청이 너무 많음", "잠시 후 다시 시도해 주세요."},
+		http.StatusInternalServerError:   {"서버 내부 오류", "서버에서 요청을 처리하는 중 오류가 발생했습니다."},
+		http.StatusInsufficientStorage:   {"저장 공간 부족", "사용할 수 있는 저장 공간을 모두 썼습니다."},
+		http.StatusBadGateway:            {"잘못된 게이트웨이", "뒤에 있는 서버에서 올바른 응답을 받지 못했습니다."},
+		http.StatusServiceUnavailable:    {"서비스를 사용할 수 없음", "서버가 지금 요청을 처리할 수 없습니다. 잠시 후 다시 시도해 주세요."},
+		http.StatusGatewayTimeout:        {"게이트웨이 시간 초과", "뒤에 있는 서버가 제때 응답하지 않았습니다."},
+	},
+	"en": {
+		http.StatusBadRequest:            {"Bad Request", "The request is malformed."},
+		http.StatusUnauthorized:          {"Unauthorized", "You need to authenticate to perform this request."},
+		http.StatusForbidden:             {"Forbidden", "You are not allowed to perform this request."},
+		http.StatusNotFound:              {"Not Found", "The requested page could not be found."},
+		http.StatusMethodNotAllowed:      {"Method Not Allowed", "This URL does not support the requested HTTP method."},
+		http.StatusRequestEntityTooLarge: {"Payload Too Large", "The request is larger than allowed."},
+		http.StatusUnsupportedMediaType:  {"Unsupported Media Type", "Files of this type are not accepted."},
+		http.StatusUnprocessableEntity:   {"Unprocessable Entity", "The request could not be processed."},
+		http.StatusTooManyRequests:       {"Too Many Requests", "Please try again later."},
+		http.StatusInternalServerError:   {"Internal Server Error", "The server encountered an error while handling the request."},
+		http.StatusInsufficientStorage:   {"Insufficient Storage", "Your storage quota has been used up."},
+		http.StatusBadGateway:            {"Bad Gateway", "The upstream server returned an invalid response."},
+		http.StatusServiceUnavailable:    {"Service Unavailable", "The server cannot handle the request right now. Please try again later."},
+		http.StatusGatewayTimeout:        {"Gateway Timeout", "The upstream server did not respond in time."},
+	},
+}
+
+// 언어 -> 키 -> HTML 페이지에 쓰이는 글자
+var uiCatalog = map[string]map[string]string{
+	"ko": {
+		"site.title":         "go 서버 예제",
+		"nav.home":           "홈",
+		"nav.items":          "item 목록",
+		"nav.docs":           "문서",
+		"nav.about":          "소개",
+		"nav.chat":           "채팅",
+		"chat.name":          "이름",
+		"chat.placeholder":   "메시지를 입력하세요",
+		"chat.send":          "보내기",
+		"form.title":         "문의하기",
+		"form.name":          "이름",
+		"form.email":         "이메일",
+		"form.message":       "내용",
+		"form.submit":        "보내기",
+		"form.success":       "다음 내용을 받았습니다.",
+		"form.again":         "다시 쓰기",
+		"form.required":      "꼭 입력해야 합니다.",
+		"form.too_long":      "너무 깁니다.",
+		"form.email_invalid": "올바른 이메일 주소가 아닙니다.",
+		"about.body":         "GoLang을 통한 웹서버의 간단한 구현 예제입니다. net/http 표준 라이브러리만으로 HTML, JSON, 정적 파일을 보내줍니다.",
+		"home.hello":         "안녕하세요, %s 님.",
+		"home.hello_guest":   "안녕하세요, 손님.",
+		"home.time":          "서버 시간은 %s 입니다.",
+		"home.count":         "저장소에 item이 %s개 있습니다.",
+		"home.ajax":          "AJAX 요청으로 받은 이름:",
+		"login.title":        "로그인",
+		"login.username":     "이름",
+		"login.password":     "비밀번호",
+		"login.submit":       "로그인",
+		"login.failed":       "이름이나 비밀번호가 맞지 않습니다.",
+		"login.logout":       "로그아웃",
+		"login.code":         "인증 앱의 코드 (또는 복구 코드)",
+		"login.locked":       "로그인 실패가 너무 많습니다. 잠시 후 다시 시도해 주세요.",
+		"challenge.title":    "잠시만 기다려 주세요",
+		"challenge.wait":     "요청이 너무 많아 브라우저를 확인하고 있습니다. 몇 초 뒤에 페이지가 다시 열립니다.",
+		"challenge.noscript": "계속하려면 JavaScript 를 켜 주세요.",
+		"item.name":          "이름",
+		"item.what":          "종류",
+		"listing.title":      "%s 의 목록",
+		"listing.name":       "이름",
+		"listing.size":       "크기",
+		"listing.mtime":      "수정 시간",
+		"footer.license":     "MIT 라이선스",
+		"error.home":         "홈으로 돌아가기",
+		"error.404.hint":     "주소를 다시 확인하거나 아래의 페이지에서 시작해 보세요.",
+		"error.500.hint":     "잠시 후 다시 시도해 주세요. 문제가 계속되면 관리자에게 알려 주세요.",
+	},
+	"en": {
+		"site.title":         "go server example",
+		"nav.home":           "home",
+		"nav.items":          "items",
+		"nav.docs":           "docs",
+		"nav.about":          "about",
+		"nav.chat":           "chat",
+		"chat.name":          "name",
+		"chat.placeholder":   "type a message",
+		"chat.send":          "send",
+		"form.title":         "Contact",
+		"form.name":          "Name",
+		"form.email":         "Email",
+		"form.message":       "Message",
+		"form.submit":        "Send",
+		"form.success":       "We received the following.",
+		"form.again":         "Write another",
+		"form.required":      "This field is required.",
+		"form.too_long":      "This is too long.",
+		"form.email_invalid": "This is not a valid email address.",
+		"about.body":         "A small example of a webserver in the Go programming language. It serves HTML, JSON and static files using only the net/http standard library.",
+		"home.hello":         "Hello, %s.",
+		"home.hello_guest":   "Hello, guest.",
+		"home.time":          "Server time is %s.",
+		"home.count":         "The store has %s items.",
+		"home.ajax":          "The ajax request says the name is",
+		"login.title":        "Log in",
+		"login.username":     "Username",
+		"login.password":     "Password",
+		"login.submit":       "Log in",
+		"login.failed":       "The username or password is incorrect.",
+		"login.logout":       "Log out",
+		"login.code":         "Code from your authenticator app (or a recovery code)",
+		"login.locked":       "Too many failed attempts. Please try again later.",
+		"challenge.title":    "Just a moment",
+		"challenge.wait":     "We received too many requests and are checking your browser. This page will reload in a few seconds.",
+		"challenge.noscript": "Please enable JavaScript to continue.",
+		"item.name":          "name",
+		"item.what":          "what",
+		"listing.title":      "Index of %s",
+		"listing.name":       "name",
+		"listing.size":       "size",
+		"listing.mtime":      "modified",
+		"footer.license":     "MIT License",
+		"error.home":         "Back to home",
+		"error.404.hint":     "Check the address, or start from one of these pages.",
+		"error.500.hint":     "Please try again in a moment. If the problem persists, let the administrator know.",
+	},
+}
+
+// lang 언어로 key의 글자를 찾고, args가 있으면 %s 자리에 채워 넣습니다.
+// 카탈로그에 없는 키는 키 자체를 돌려주므로 빠진 번역이 화면에서 바로 보입니다.
+func Translate(lang, key string, args ...interface{}) string {
+	text, ok := uiCatalog[lang][key]
+	if !ok {
+		if text, ok = uiCatalog[DefaultLanguage][key]; !ok {
+			return key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// problem+json 응답 본문
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Accept-Language 헤더에서 카탈로그가 지원하는 언어 중 가장 선호하는 것을 고릅니다.
+// q 값이 같으면 헤더에 먼저 나온 언어가 이깁니다.
+// /language 로 고른 언어(서명된 "lang" 쿠키)가 있으면 헤더보다 먼저 씁니다.
+func PreferredLanguage(request *http.Request) string {
+	var chosen string
+	if err := ReadSecureCookie(request, "lang", &chosen); err == nil {
+		if _, ok := errorCatalog[chosen]; ok {
+			return chosen
+		}
+	}
+	best, bestq := DefaultLanguage, -1.0
+	for _, part := range strings.Split(request.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		// "ko-KR" 같은 태그는 앞부분 "ko"만 봅니다.
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := errorCatalog[lang]; ok && q > 0 && q > bestq {
+			best, bestq = lang, q
+		}
+	}
+	return best
+}
+
+// GET /language?lang=ko
+// 고른 언어를 서명된 쿠키에 넣고 next(이 사이트 안의 경로) 또는 /home 으로 돌려보냅니다.
+func LanguageHandler(response http.ResponseWriter, request *http.Request) {
+	lang := request.URL.Query().Get("lang")
+	if _, ok := errorCatalog[lang]; !ok {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("unknown language %q", lang))
+		return
+	}
+	if err := SetSecureCookie(response, "lang", lang); err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	http.Redirect(response, request, localRedirect(request.URL.Query().Get("next"), "/home"), http.StatusSeeOther)
+}
+
+// 주어진 언어로 상태 코드에 맞는 메시지를 찾습니다.
+// 카탈로그에 없으면 net/http의 상태 문구를 제목으로 씁니다.
+func LookupError(lang string, status int) ErrorMessage {
+	if msg, ok := errorCatalog[lang][status]; ok {
+		return msg
+	}
+	if msg, ok := errorCatalog[DefaultLanguage][status]; ok {
+		return msg
+	}
+	return ErrorMessage{Title: http.StatusText(status)}
+}
+
+// 에러 페이지 템플릿(templates/404.html, 500.html, error.html)에 넘겨주는 값들
+type ErrorPage struct {
+	Status int
+	Title  string
+	Detail string
+}
+
+// 클라이언트의 언어로 에러 응답을 보냅니다.
+// cause는 클라이언트에게 보여주지 않고 서버 로그에만 남깁니다.
+//
+// 브라우저(Accept: text/html)에게는 레이아웃에 넣은 에러 페이지를,
+// API 클라이언트에게는 problem+json 을 보냅니다.
+func WriteError(response http.ResponseWriter, request *http.Request, status int, cause error) {
+	if cause != nil {
+		Logf(request.Context(), "%s %s : %d %v", request.Method, request.URL.Path, status, cause)
+	}
+	lang := PreferredLanguage(request)
+	msg := LookupError(lang, status)
+
+	response.Header().Add("Vary", "Accept")
+	if AcceptsType(request, "text/html") && writeErrorPage(response, request, lang, status, msg) {
+		return
+	}
+
+	SetContentType(response, "application/problem+json")
+	response.Header().Set("Content-Language", lang)
+	response.Header().Set("X-Content-Type-Options", "nosniff")
+	WriteJSON(response, status, Problem{
+		Type:   "about:blank",
+		Title:  msg.Title,
+		Status: status,
+		Detail: msg.Detail,
+	})
+}
+
+// 상태 코드의 에러 페이지 템플릿(없으면 error.html)으로 응답합니다.
+// 템플릿을 쓸 수 없으면 아무것도 쓰지 않고 false를 돌려주므로, 부른 쪽이 JSON으로 대신 응답합니다.
+// (여기서 다시 WriteError를 부르면 에러 페이지의 에러가 끝없이 이어질 수 있습니다.)
+func writeErrorPage(response http.ResponseWriter, request *http.Request, lang string, status int, msg ErrorMessage) bool {
+	r, err := currentRenderer()
+	if err != nil {
+		Logf(request.Context(), "error page %d: %v", status, err)
+		return false
+	}
+	data := ErrorPage{Status: status, Title: msg.Title, Detail: msg.Detail}
+	meta := PageMetaFor(request, data)
+	page, err := r.Execute(lang, strconv.Itoa(status), meta, data)
+	if err != nil {
+		page, err = r.Execute(lang, "error", meta, data)
+	}
+	if err != nil {
+		Logf(request.Context(), "error page %d: %v", status, err)
+		return false
+	}
+	WriteHTML(response, request, lang, status, page)
+	return true
+}
+
+// 등록되지 않은 모든 URL에 대한 응답
+func NotFoundHandler(response http.ResponseWriter, request *http.Request) {
+	WriteError(response, request, http.StatusNotFound, nil)
+}
@@ -0,0 +1,116 @@
+//
+// meta.go
+//
+// 페이지마다 <head> 에 들어가는 제목, 설명, Open Graph / Twitter card 메타 태그입니다.
+//
+// 값은 다음 순서로 덮어씁니다. (뒤의 것이 이깁니다)
+//   1. 설정 파일의 "meta": {"default": {...}}
+//   2. 페이지 데이터가 PageMeta() 메소드로 알려주는 값 (예: item, 문서)
+//   3. 설정 파일의 "meta": {"routes": {"/home": {...}}}  (주소별)
+//
+//   "meta": {
+//     "default": {"description": "Go 웹서버 예제", "image": "/static/favicon.ico", "twitter_card": "summary"},
+//     "routes": {"/home": {"title": "홈", "description": "AJAX 예제가 있는 홈 페이지"}}
+//   }
+//
+// title 이 비어 있으면 페이지 템플릿의 {{define "title"}} 을 씁니다.
+//
+// og:url 과 og:image 의 절대 주소는 설정 파일의 "site_url" 로 만듭니다. 요청의 Host 헤더는 클라이언트가 마음대로
+// 보낼 수 있으므로 쓰지 않습니다. site_url 이 없으면 og:url 을 넣지 않고 og:image 는 적힌 주소 그대로 둡니다.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 페이지 하나의 메타 정보
+type PageMeta struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Image       string `json:"image"`        // og:image. "/"로 시작하면 site_url을 앞에 붙입니다.
+	Type        string `json:"type"`         // og:type. 기본은 "website"
+	TwitterCard string `json:"twitter_card"` // summary, summary_large_image ...
+	URL         string `json:"-"`            // og:url. site_url 과 요청 주소로 채웁니다. site_url 이 없으면 비어 있습니다.
+}
+
+// 메타 정보 설정
+type MetaConfig struct {
+	Default PageMeta            `json:"default"`
+	Routes  map[string]PageMeta `json:"routes"`
+}
+
+// 자기 메타 정보를 알려주는 페이지 데이터
+type MetaProvider interface {
+	PageMeta() PageMeta
+}
+
+// 서버 전체의 메타 정보 설정과 사이트 주소. main에서 정합니다.
+var (
+	metaConfig MetaConfig
+	siteURL    string
+)
+
+// 레이아웃에 넘겨주는 값. 페이지 템플릿의 블록들은 Data를 받습니다.
+type PageContext struct {
+	Data interface{}
+	Meta PageMeta
+}
+
+// 비어 있지 않은 값만 m 위에 덮어씁니다.
+func (m PageMeta) merge(over PageMeta) PageMeta {
+	if over.Title != "" {
+		m.Title = over.Title
+	}
+	if over.Description != "" {
+		m.Description = over.Description
+	}
+	if over.Image != "" {
+		m.Image = over.Image
+	}
+	if over.Type != "" {
+		m.Type = over.Type
+	}
+	if over.TwitterCard != "" {
+		m.TwitterCard = over.TwitterCard
+	}
+	return m
+}
+
+// 요청과 페이지 데이터로 이 페이지의 메타 정보를 만듭니다.
+func PageMetaFor(request *http.Request, data interface{}) PageMeta {
+	meta := metaConfig.Default
+	if provider, ok := data.(MetaProvider); ok {
+		meta = meta.merge(provider.PageMeta())
+	}
+	if request != nil {
+		meta = meta.merge(metaConfig.Routes[request.URL.Path])
+		meta.URL = absoluteURL(request.URL.Path)
+		if strings.HasPrefix(meta.Image, "/") && siteURL != "" {
+			meta.Image = absoluteURL(meta.Image)
+		}
+	}
+	if meta.Type == "" {
+		meta.Type = "website"
+	}
+	return meta
+}
+
+// site_url 을 앞에 붙인 절대 주소. site_url 이 없으면 ""
+func absoluteURL(path string) string {
+	if siteURL == "" {
+		return ""
+	}
+	return strings.TrimRight(siteURL, "/") + path
+}
+
+// item 상세 페이지의 메타 정보
+func (item Item) PageMeta() PageMeta {
+	return PageMeta{Description: item.Name + " (" + item.What + ")"}
+}
+
+// 문서 페이지의 메타 정보
+func (page DocPage) PageMeta() PageMeta {
+	return PageMeta{Title: page.Title, Type: "article"}
+}
@@ -0,0 +1,210 @@
+//
+// metrics.go
+//
+// Prometheus가 가져갈 수 있는 /metrics 엔드포인트입니다. (text exposition format 0.0.4)
+// 외부 라이브러리 없이 필요한 것만 직접 셉니다.
+//
+//   http_requests_total{route="/item/",code="200"}          요청 수
+//   http_request_duration_seconds{route="/item/"}           처리 시간 (histogram)
+//   http_requests_in_flight                                 처리 중인 요청 수
+//   http_response_size_bytes{route="/item/"}                응답 크기 (summary: 합과 개수)
+//   http_panics_total                                       핸들러의 panic 수 (recover.go)
+//
+// route 는 URL 그대로가 아니라 요청을 받은 ServeMux 패턴입니다. (/item/yellow => "/item/")
+// URL마다 라벨이 생기면 메트릭의 수가 끝없이 늘어나기 때문입니다.
+//
+// 설정 파일의 "metrics" 로 끄거나 접근을 제한할 수 있습니다. (guard.go)
+//   "metrics": {"enabled": true, "allowed_ips": ["127.0.0.1"]}
+//
+// 같은 설정으로 /stats 도 켜집니다. route별 최근 p50/p95/p99 (stats.go)
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 메트릭 설정
+type MetricsConfig struct {
+	Enabled bool `json:"enabled"`
+	GuardConfig
+}
+
+// 처리 시간 histogram의 구간(초)
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// route 하나의 처리 시간과 응답 크기
+type routeMetrics struct {
+	buckets  []uint64 // latencyBuckets 마다 그 값 이하인 요청 수 (누적이 아님)
+	count    uint64
+	sum      float64 // 처리 시간의 합(초)
+	sizeSum  int64
+	sizeSeen uint64
+}
+
+// 요청 메트릭 모음
+type Metrics struct {
+	inFlight int64  // atomic
+	panics   uint64 // atomic
+
+	mu       sync.Mutex
+	requests map[[2]string]uint64 // {route, code} -> 요청 수
+	routes   map[string]*routeMetrics
+
+	Stats  *LatencyStats // 최근 처리 시간의 백분위수. /stats (stats.go)
+	Alerts *ErrorAlerts  // 5xx 비율 알림. nil이면 꺼져 있습니다. (alerts.go)
+	StatsD *StatsD       // StatsD 로 보내기. nil이면 꺼져 있습니다. (statsd.go)
+}
+
+// 서버 전체가 함께 쓰는 메트릭
+var metrics = NewMetrics()
+
+// 빈 메트릭 모음을 만듭니다.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: make(map[[2]string]uint64),
+		routes:   make(map[string]*routeMetrics),
+		Stats:    NewLatencyStats(),
+	}
+}
+
+// 끝난 요청 하나를 기록합니다.
+func (m *Metrics) Observe(route string, status int, duration time.Duration, size int64) {
+	m.Stats.Observe(route, duration)
+	m.Alerts.Observe(route, status)
+	m.StatsD.Observe(route, status, duration)
+	seconds := duration.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{route, strconv.Itoa(status)}]++
+	rm := m.routes[route]
+	if rm == nil {
+		rm = &routeMetrics{buckets: make([]uint64, len(latencyBuckets))}
+		m.routes[route] = rm
+	}
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			rm.buckets[i]++
+			break
+		}
+	}
+	rm.count++
+	rm.sum += seconds
+	rm.sizeSum += size
+	rm.sizeSeen++
+}
+
+// route별 요청 수 (상태 코드를 합한 값)
+func (m *Metrics) RequestCounts() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]uint64)
+	for key, n := range m.requests {
+		counts[key[0]] += n
+	}
+	return counts
+}
+
+// 지금 처리 중인 요청 수
+func (m *Metrics) InFlight() int64 {
+	return atomic.LoadInt64(&m.inFlight)
+}
+
+// 핸들러의 panic 하나를 셉니다.
+func (m *Metrics) ObservePanic() {
+	atomic.AddUint64(&m.panics, 1)
+}
+
+// 요청을 처리하는 동안 in-flight를 늘리고, 끝나면 결과를 기록합니다.
+// route 라벨은 routes에서 요청을 받을 패턴입니다.
+func (m *Metrics) Handler(routes *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+		start := time.Now()
+		recorder := newResponseRecorder(response)
+		next.ServeHTTP(recorder, request)
+		m.Observe(RoutePattern(routes, request), recorder.Status(), time.Since(start), recorder.size)
+	})
+}
+
+// routes에서 request를 받는 패턴. 맞는 패턴이 없으면 "unmatched"
+func RoutePattern(routes *http.ServeMux, request *http.Request) string {
+	if _, pattern := routes.Handler(request); pattern != "" {
+		return pattern
+	}
+	return "unmatched"
+}
+
+// Prometheus 형식으로 씁니다.
+func (m *Metrics) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	response.Header().Set("Cache-Control", "no-store")
+	var b strings.Builder
+
+	m.mu.Lock()
+	b.WriteString("# HELP http_requests_total Number of HTTP requests by route and status code.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	keys := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&b, "http_requests_total{route=%s,code=%q} %d\n", promLabel(key[0]), key[1], m.requests[key])
+	}
+
+	routes := make([]string, 0, len(m.routes))
+	for route := range m.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	b.WriteString("# HELP http_request_duration_seconds Time spent handling HTTP requests.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	for _, route := range routes {
+		rm := m.routes[route]
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += rm.buckets[i]
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%s,le=%q} %d\n", promLabel(route), strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", promLabel(route), rm.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{route=%s} %g\n", promLabel(route), rm.sum)
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{route=%s} %d\n", promLabel(route), rm.count)
+	}
+	b.WriteString("# HELP http_response_size_bytes Size of HTTP response bodies.\n")
+	b.WriteString("# TYPE http_response_size_bytes summary\n")
+	for _, route := range routes {
+		rm := m.routes[route]
+		fmt.Fprintf(&b, "http_response_size_bytes_sum{route=%s} %d\n", promLabel(route), rm.sizeSum)
+		fmt.Fprintf(&b, "http_response_size_bytes_count{route=%s} %d\n", promLabel(route), rm.sizeSeen)
+	}
+	m.mu.Unlock()
+
+	b.WriteString("# HELP http_requests_in_flight Number of HTTP requests being handled.\n")
+	b.WriteString("# TYPE http_requests_in_flight gauge\n")
+	// 지금 이 /metrics 요청은 빼고 셉니다.
+	fmt.Fprintf(&b, "http_requests_in_flight %d\n", atomic.LoadInt64(&m.inFlight)-1)
+	b.WriteString("# HELP http_panics_total Number of panics recovered from handlers.\n")
+	b.WriteString("# TYPE http_panics_total counter\n")
+	fmt.Fprintf(&b, "http_panics_total %d\n", atomic.LoadUint64(&m.panics))
+	response.Write([]byte(b.String()))
+}
+
+// Prometheus 라벨 값. 역슬래시, 따옴표, 줄바꿈을 이스케이프합니다.
+func promLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
@@ -0,0 +1,200 @@
+//
+// minify.go
+//
+// HTML, CSS, JS 의 공백과 주석을 줄여 페이지 크기를 줄입니다. (기본은 꺼져 있음)
+//
+//   "minify": {"html": true, "css": true, "js": true}
+//
+//   - html : 템플릿으로 만든 페이지의 연속된 공백을 하나로 줄입니다.
+//            <pre>, <textarea>, <script>, <style> 안은 건드리지 않습니다.
+//   - css  : /static/ 의 .css 파일에서 주석과 불필요한 공백을 뺍니다.
+//            문자열과 url() 안은 그대로 두고, : 앞뒤 공백은 선언 블록 안에서만 뺍니다. (a :hover 는 a:hover 와 다릅니다)
+//   - js   : /static/ 의 .js 파일에서 줄 앞뒤 공백과 빈 줄만 뺍니다.
+//            문자열이나 정규식을 잘못 건드리지 않도록 일부러 보수적으로 합니다.
+//
+// 정적 파일은 처음 요청될 때 한 번 줄여서 메모리에 두고, 파일이 바뀔 때만 다시 줄입니다.
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// 줄이기 설정
+type MinifyConfig struct {
+	HTML bool `json:"html"`
+	CSS  bool `json:"css"`
+	JS   bool `json:"js"`
+}
+
+// 서버 전체의 줄이기 설정. main에서 정합니다.
+var minifyConfig MinifyConfig
+
+var (
+	htmlSpace    = regexp.MustCompile(`\s+`)
+	htmlRawStart = regexp.MustCompile(`(?i)<(pre|textarea|script|style)\b`)
+)
+
+// 안에 선언 대신 규칙이 오는 at-rule 들. 예) @media (...) { a { color: red } }
+var cssGroupRules = map[string]bool{
+	"media": true, "supports": true, "document": true, "-moz-document": true,
+	"container": true, "layer": true, "scope": true, "starting-style": true,
+}
+
+// 확장자로 정적 파일을 줄일 수 있는지 확인하고, 줄인 내용을 돌려줍니다.
+// 줄이지 않는 파일이면 ok가 false입니다.
+func MinifyAsset(name string, content []byte) (minified []byte, ok bool) {
+	switch path.Ext(name) {
+	case ".css":
+		if minifyConfig.CSS {
+			return MinifyCSS(content), true
+		}
+	case ".js":
+		if minifyConfig.JS {
+			return MinifyJS(content), true
+		}
+	case ".html", ".htm":
+		if minifyConfig.HTML {
+			return MinifyHTML(content), true
+		}
+	}
+	return nil, false
+}
+
+// HTML의 연속된 공백을 하나로 줄입니다. 공백이 의미 있는 태그 안은 그대로 둡니다.
+func MinifyHTML(content []byte) []byte {
+	var out bytes.Buffer
+	rest := string(content)
+	for {
+		loc := htmlRawStart.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			out.WriteString(htmlSpace.ReplaceAllString(rest, " "))
+			break
+		}
+		out.WriteString(htmlSpace.ReplaceAllString(rest[:loc[0]], " "))
+		closing := "</" + strings.ToLower(rest[loc[2]:loc[3]])
+		end := strings.Index(strings.ToLower(rest[loc[0]:]), closing)
+		if end < 0 {
+			out.WriteString(rest[loc[0]:])
+			break
+		}
+		end += loc[0]
+		out.WriteString(rest[loc[0]:end])
+		rest = rest[end:]
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// CSS의 주석과 불필요한 공백을 뺍니다.
+// 문자열("...", '...')과 따옴표 없는 url(...) 은 그대로 옮깁니다.
+// { } ; , > 앞뒤의 공백은 언제나, : 앞뒤의 공백은 선언 블록 안에서만 뺍니다.
+func MinifyCSS(content []byte) []byte {
+	var out bytes.Buffer
+	// 열린 블록마다 선언 블록인지. 맨 바깥은 규칙들입니다.
+	var blocks []bool
+	declarations := func() bool { return len(blocks) > 0 && blocks[len(blocks)-1] }
+	tight := func(c byte) bool {
+		return c == '{' || c == '}' || c == ';' || c == ',' || c == '>' || c == ':' && declarations()
+	}
+	prelude := 0       // 지금 규칙(또는 선언)이 out 에서 시작하는 곳
+	space := false     // 쓰지 않고 미뤄 둔 공백이 있는지
+	semicolon := false // 마지막으로 쓴 것이 구분자 ; 인지
+	write := func(b []byte) {
+		if space && out.Len() > 0 && !tight(out.Bytes()[out.Len()-1]) && !tight(b[0]) {
+			out.WriteByte(' ')
+		}
+		space, semicolon = false, false
+		out.Write(b)
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += 2 + end + 1
+			}
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(content) && content[end] != c {
+				if content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			write(content[i:min(end+1, len(content))])
+			i = end
+		case (c == 'u' || c == 'U') && len(content)-i > 4 && strings.EqualFold(string(content[i:i+4]), "url(") &&
+			(i == 0 || !isCSSNameByte(content[i-1])):
+			open := i + 4
+			for open < len(content) && (content[open] == ' ' || content[open] == '\t' || content[open] == '\n') {
+				open++
+			}
+			if open < len(content) && (content[open] == '"' || content[open] == '\'') {
+				// 따옴표가 있으면 문자열로 처리합니다.
+				write(content[i : i+4])
+				i += 3
+				continue
+			}
+			end := bytes.IndexByte(content[i:], ')')
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			write(content[i : i+end+1])
+			i += end
+		case c == '{':
+			name := strings.TrimSpace(out.String()[prelude:])
+			rule := strings.TrimPrefix(name, "@")
+			if end := strings.IndexAny(rule, " (\t\n"); end >= 0 {
+				rule = rule[:end]
+			}
+			blocks = append(blocks, !(strings.HasPrefix(name, "@") && cssGroupRules[strings.ToLower(rule)]))
+			write([]byte{c})
+			prelude = out.Len()
+		case c == '}':
+			if semicolon {
+				out.Truncate(out.Len() - 1)
+			}
+			if len(blocks) > 0 {
+				blocks = blocks[:len(blocks)-1]
+			}
+			write([]byte{c})
+			prelude = out.Len()
+		case c == ';':
+			write([]byte{c})
+			semicolon = true
+			prelude = out.Len()
+		default:
+			write([]byte{c})
+		}
+	}
+	return out.Bytes()
+}
+
+// CSS 이름(식별자)에 쓰이는 글자인지
+func isCSSNameByte(c byte) bool {
+	return c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+// JS의 줄 앞뒤 공백과 빈 줄을 뺍니다.
+// 줄바꿈은 남겨 두므로 세미콜론 자동 삽입(ASI)에 기대는 코드도 그대로 동작합니다.
+func MinifyJS(content []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
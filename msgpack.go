@@ -0,0 +1,149 @@
+//
+// msgpack.go
+//
+// 외부 라이브러리 없이 구현한 MessagePack 인코더입니다. (https://msgpack.org)
+// JSON과 같은 구조를 더 작은 바이너리로 보냅니다. /items/ws 의 msgpack 하위 프로토콜이 씁니다.
+//
+// 지원하는 값: nil, bool, 정수, float64, string, []byte, []interface{},
+// map[string]interface{}, time.Time (timestamp 확장 타입 -1)
+// 서버는 보내기만 하므로 디코더는 없습니다.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// v를 MessagePack으로 인코딩해 b 뒤에 붙입니다.
+func AppendMsgPack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return appendMsgPackInt(b, int64(v)), nil
+	case int64:
+		return appendMsgPackInt(b, v), nil
+	case uint64:
+		return appendMsgPackUint(b, v), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case string:
+		return appendMsgPackString(b, v), nil
+	case []byte:
+		return appendMsgPackBytes(b, v), nil
+	case time.Time:
+		return appendMsgPackTime(b, v), nil
+	case []interface{}:
+		b = appendMsgPackLength(b, len(v), 0x90, 0xdc, 0xdd)
+		for _, e := range v {
+			var err error
+			if b, err = AppendMsgPack(b, e); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		// 같은 값이면 늘 같은 바이트가 되도록 키를 정렬합니다.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgPackLength(b, len(v), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			b = appendMsgPackString(b, k)
+			var err error
+			if b, err = AppendMsgPack(b, v[k]); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	}
+	return b, fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+// 배열/맵의 길이. fix는 15개까지 한 바이트에 넣는 형식입니다.
+func appendMsgPackLength(b []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, code16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, code32), uint32(n))
+	}
+}
+
+func appendMsgPackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgPackUint(b, uint64(n))
+	case n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+	}
+}
+
+func appendMsgPackUint(b []byte, n uint64) []byte {
+	switch {
+	case n < 128:
+		return append(b, byte(n))
+	case n <= math.MaxUint8:
+		return append(b, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), n)
+	}
+}
+
+func appendMsgPackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgPackBytes(b []byte, p []byte) []byte {
+	switch n := len(p); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, p...)
+}
+
+// timestamp 확장 타입(-1)의 timestamp 96 형식: 나노초(uint32) + 초(int64)
+func appendMsgPackTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xc7, 12, 0xff)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+	return binary.BigEndian.AppendUint64(b, uint64(t.Unix()))
+}
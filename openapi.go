@@ -0,0 +1,486 @@
+//
+// openapi.go
+//
+// item API 의 OpenAPI 3 문서를 /openapi.json 으로, 그 문서를 둘러보는 Swagger UI 를 /openapi 로 보여줍니다.
+//
+//   $ curl localhost:8080/openapi.json
+//   {"openapi":"3.0.3","info":{"title":"webserver","version":"dev"},"paths":{"/items":{"get":...
+//
+// 문서는 서버가 시작할 때 한 번 만듭니다. /v1 주소와 그 메시지 모양은 gateway.go 와 같이 items.proto 에서 읽고
+// (rpc 위의 주석이 설명이 됩니다. gRPC 를 켰을 때만 넣습니다), /items 처럼 손으로 쓴 핸들러는 itemAPIOperations 에 적힌 Go 타입의
+// json 태그를 보고 스키마를 만듭니다. proto 나 Item 같은 타입을 고치면 문서도 함께 바뀝니다.
+// 새 핸들러를 문서에 넣으려면 itemAPIOperations 에 한 줄을 더하면 됩니다.
+//
+// 에러 응답은 모두 problem+json(messages.go)입니다. site_url 을 설정하면 servers 에 넣습니다.
+//
+// Swagger UI 는 vendor-assets.sh 로 static/vendor/ 에 내려받아 둔 것을 씁니다. 다른 출처에서 받지 않으므로 CSP(csp.go)를 켜도 됩니다.
+// 파일이 없으면 서버가 시작하지 않습니다. (swaggerUIAssets)
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 손으로 쓴 핸들러 하나의 설명
+type apiOperation struct {
+	Method   string
+	Path     string // OpenAPI 형식의 주소. 예) "/item/{name}"
+	Summary  string
+	Params   []apiParam
+	Request  interface{} // 요청 본문의 Go 값. nil 이면 본문이 없습니다.
+	Status   int         // 성공 상태 코드. 0 이면 200
+	Response interface{} // 성공 응답 본문의 Go 값
+	Errors   []int       // problem+json 으로 돌려주는 상태 코드들
+}
+
+// 주소나 쿼리의 인자 하나
+type apiParam struct {
+	Name        string
+	In          string // "path" 또는 "query"
+	Type        string // JSON 스키마 타입. 예) "string", "integer"
+	Description string
+	Required    bool
+}
+
+// 문서에 넣는 손으로 쓴 item 핸들러들
+var itemAPIOperations = []apiOperation{
+	{
+		Method: http.MethodGet, Path: "/items",
+		Summary: "모든 item 을 돌려줍니다. ?format=csv 이거나 Accept: text/csv 이면 CSV 로 응답합니다.",
+		Params: []apiParam{
+			{Name: "format", In: "query", Type: "string", Description: "csv 이면 CSV"},
+			{Name: "header", In: "query", Type: "string", Description: "absent 이면 CSV 의 첫 줄(헤더)을 뺍니다."},
+		},
+		Response: []Item{},
+		Errors:   []int{http.StatusServiceUnavailable},
+	},
+	{
+		Method: http.MethodPost, Path: "/items",
+		Summary: "item 을 추가하거나 바꿉니다. what 이 비어 있으면 \"item\" 입니다.",
+		Request: Item{}, Status: http.StatusCreated, Response: Item{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	},
+	{
+		Method: http.MethodGet, Path: "/items/changes",
+		Summary: "since 이후의 변경을 long polling 으로 받습니다. since 를 빼면 기다리지 않고 지금의 last 만 돌려줍니다.",
+		Params: []apiParam{
+			{Name: "since", In: "query", Type: "integer", Description: "이 순서 번호 뒤의 변경부터"},
+			{Name: "timeout", In: "query", Type: "integer", Description: "최대 몇 초까지 기다릴지. 설정값보다 길게는 안 됩니다."},
+		},
+		Response: ChangesResponse{},
+		Errors:   []int{http.StatusBadRequest},
+	},
+}
+
+// OpenAPI 문서. 필드 순서대로 JSON 이 나오도록 map 대신 struct 를 씁니다.
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Tags       []openAPITag                            `json:"tags"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]jsonSchema `json:"schemas"`
+	SecuritySchemes map[string]jsonSchema `json:"securitySchemes,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required,omitempty"`
+	Schema      jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content,omitempty"`
+}
+
+type openAPIMedia struct {
+	Schema jsonSchema `json:"schema"`
+}
+
+// JSON 스키마 하나. 예) {"type": "string"}, {"$ref": "#/components/schemas/Item"}
+type jsonSchema map[string]interface{}
+
+// components/schemas 를 가리키는 스키마
+func schemaRef(name string) jsonSchema {
+	return jsonSchema{"$ref": "#/components/schemas/" + name}
+}
+
+// 문서를 만드는 동안 components/schemas 를 모읍니다.
+type openAPIBuilder struct {
+	doc    *openAPIDocument
+	schema *protoSchema
+}
+
+// rpc 바로 위의 // 주석들
+var protoMethodComment = regexp.MustCompile(`((?:[ \t]*//[^\n]*\n)+)[ \t]*rpc[ \t]+([A-Za-z0-9_]+)`)
+
+// operations 로 OpenAPI 문서를 만듭니다. gRPC 가 켜져 있으면 items.proto 의 REST 주소도 넣습니다.
+func BuildOpenAPI(siteURL string, grpc GRPCConfig, operations []apiOperation) (*openAPIDocument, error) {
+	schema, err := parseProtoSchema(itemsProto)
+	if err != nil {
+		return nil, err
+	}
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "webserver",
+			Description: "item 저장소의 API 입니다. 에러는 모두 application/problem+json 입니다.",
+			Version:     version,
+		},
+		Tags:       []openAPITag{{Name: "items", Description: "/items 핸들러 (item.go, changes.go)"}},
+		Paths:      map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{Schemas: map[string]jsonSchema{}},
+	}
+	if siteURL != "" {
+		doc.Servers = []openAPIServer{{URL: strings.TrimSuffix(siteURL, "/")}}
+	}
+	b := &openAPIBuilder{doc: doc, schema: schema}
+	b.goSchema(reflect.TypeOf(Problem{}))
+
+	for _, operation := range operations {
+		if err := b.addOperation(operation); err != nil {
+			return nil, err
+		}
+	}
+	if !grpc.Enabled {
+		return doc, nil
+	}
+	doc.Tags = append(doc.Tags, openAPITag{
+		Name: schema.Service, Description: "items.proto 의 REST 주소 (gateway.go). 같은 메서드를 gRPC 로도 부를 수 있습니다.",
+	})
+	if grpc.Username != "" || grpc.Users {
+		b.doc.Components.SecuritySchemes = map[string]jsonSchema{"grpc": {"type": "http", "scheme": "basic"}}
+	}
+	comments := map[string]string{}
+	for _, match := range protoMethodComment.FindAllStringSubmatch(itemsProto, -1) {
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(match[1]), "\n") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "//")))
+		}
+		comments[match[2]] = strings.Join(lines, " ")
+	}
+	for _, method := range schema.Methods {
+		if method.HTTPMethod == "" {
+			continue
+		}
+		if err := b.addProtoMethod(method, comments[method.Name]); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// 경로에 operation 을 넣습니다. 같은 주소와 메서드가 두 번 나오면 에러입니다.
+func (b *openAPIBuilder) put(method, path string, operation *openAPIOperation) error {
+	if b.doc.Paths[path] == nil {
+		b.doc.Paths[path] = map[string]*openAPIOperation{}
+	}
+	key := strings.ToLower(method)
+	if b.doc.Paths[path][key] != nil {
+		return fmt.Errorf("openapi: %s %s is described twice", method, path)
+	}
+	b.doc.Paths[path][key] = operation
+	return nil
+}
+
+// 손으로 쓴 핸들러 하나
+func (b *openAPIBuilder) addOperation(operation apiOperation) error {
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	out := &openAPIOperation{
+		OperationID: operationID(operation.Method, operation.Path),
+		Summary:     operation.Summary,
+		Tags:        []string{"items"},
+		Responses:   map[string]*openAPIResponse{},
+	}
+	for _, param := range operation.Params {
+		out.Parameters = append(out.Parameters, openAPIParameter{
+			Name:        param.Name,
+			In:          param.In,
+			Description: param.Description,
+			Required:    param.Required || param.In == "path",
+			Schema:      jsonSchema{"type": param.Type},
+		})
+	}
+	if operation.Request != nil {
+		out.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMedia{"application/json": {Schema: b.goSchema(reflect.TypeOf(operation.Request))}},
+		}
+	}
+	response := &openAPIResponse{Description: http.StatusText(status)}
+	if operation.Response != nil {
+		response.Content = map[string]openAPIMedia{"application/json": {Schema: b.goSchema(reflect.TypeOf(operation.Response))}}
+	}
+	out.Responses[strconv.Itoa(status)] = response
+	for _, code := range operation.Errors {
+		out.Responses[strconv.Itoa(code)] = problemResponse(http.StatusText(code))
+	}
+	return b.put(operation.Method, operation.Path, out)
+}
+
+// "GET /items/changes" 의 operationId 는 "getItemsChanges" 입니다.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(c rune) bool { return c == '/' || c == '{' || c == '}' || c == '_' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// problem+json 에러 응답
+func problemResponse(description string) *openAPIResponse {
+	return &openAPIResponse{
+		Description: description,
+		Content:     map[string]openAPIMedia{"application/problem+json": {Schema: schemaRef("Problem")}},
+	}
+}
+
+// Go 타입의 JSON 스키마. 이름 있는 struct 는 components 에 넣고 $ref 로 가리킵니다.
+func (b *openAPIBuilder) goSchema(t reflect.Type) jsonSchema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return jsonSchema{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.goSchema(t.Elem())
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return jsonSchema{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return jsonSchema{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return jsonSchema{"type": "array", "items": b.goSchema(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": b.goSchema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := b.doc.Components.Schemas[t.Name()]; !ok && t.Name() != "" {
+			// 자기 자신을 가리키는 타입에서 끝없이 돌지 않도록 먼저 자리를 잡아 둡니다.
+			b.doc.Components.Schemas[t.Name()] = jsonSchema{}
+			b.doc.Components.Schemas[t.Name()] = b.goStruct(t)
+		}
+		if t.Name() == "" {
+			return b.goStruct(t)
+		}
+		return schemaRef(t.Name())
+	}
+	return jsonSchema{}
+}
+
+// struct 의 필드들. json 태그의 이름을 쓰고, omitempty 가 없는 필드는 required 입니다.
+func (b *openAPIBuilder) goStruct(t reflect.Type) jsonSchema {
+	properties := map[string]jsonSchema{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.goSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// items.proto 의 rpc 하나. 요청 메시지는 gatewayHandler 처럼 본문, 주소, 쿼리에서 채웁니다.
+func (b *openAPIBuilder) addProtoMethod(method protoMethod, summary string) error {
+	input := b.schema.Messages[method.Input]
+	out := &openAPIOperation{
+		OperationID: b.schema.Service + "_" + method.Name,
+		Summary:     summary,
+		Tags:        []string{b.schema.Service},
+		Responses:   map[string]*openAPIResponse{"default": problemResponse("gRPC 상태 코드를 바꾼 HTTP 에러")},
+	}
+	if b.doc.Components.SecuritySchemes != nil {
+		out.Security = []map[string][]string{{"grpc": {}}}
+	}
+	inPath := map[string]bool{}
+	for _, match := range gatewayPathField.FindAllStringSubmatch(method.HTTPPath, -1) {
+		inPath[match[1]] = true
+		field := input.field(match[1])
+		out.Parameters = append(out.Parameters, openAPIParameter{
+			Name: match[1], In: "path", Required: true, Schema: b.protoFieldSchema(*field),
+		})
+	}
+	switch method.Body {
+	case "*":
+		out.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMedia{"application/json": {Schema: b.protoMessageSchema(method.Input)}},
+		}
+	case "":
+		for _, field := range input.Fields {
+			// 쿼리로는 스칼라만 받습니다.
+			if inPath[field.Name] || inPath[field.JSONName] || b.schema.Messages[field.Type] != nil {
+				continue
+			}
+			out.Parameters = append(out.Parameters, openAPIParameter{
+				Name: field.JSONName, In: "query", Schema: b.protoFieldSchema(field),
+			})
+		}
+	default:
+		field := input.field(method.Body)
+		out.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMedia{"application/json": {Schema: b.protoFieldSchema(*field)}},
+		}
+	}
+
+	result := b.protoMessageSchema(method.Output)
+	description := http.StatusText(http.StatusOK)
+	if method.Stream {
+		// 한 줄에 {"result": 메시지} 하나씩 옵니다. 중간에 실패하면 마지막 줄이 {"error": ...} 입니다.
+		description = "newline-delimited JSON stream. 한 줄에 메시지 하나씩 옵니다."
+		result = jsonSchema{"type": "object", "properties": map[string]jsonSchema{
+			"result": result,
+			"error": {"type": "object", "properties": map[string]jsonSchema{
+				"code":    {"type": "integer", "format": "int32"},
+				"message": {"type": "string"},
+			}},
+		}}
+	}
+	out.Responses["200"] = &openAPIResponse{
+		Description: description,
+		Content:     map[string]openAPIMedia{"application/json": {Schema: result}},
+	}
+	return b.put(method.HTTPMethod, method.HTTPPath, out)
+}
+
+// proto message 의 스키마. 손으로 쓴 핸들러의 Go 타입과 JSON 모양이 다를 수 있으므로
+// (64비트 정수가 문자열입니다) "items.Item" 처럼 패키지 이름을 붙여 따로 둡니다.
+func (b *openAPIBuilder) protoMessageSchema(name string) jsonSchema {
+	if name == protoTimestamp {
+		return jsonSchema{"type": "string", "format": "date-time"}
+	}
+	key := b.schema.Package + "." + name
+	if _, ok := b.doc.Components.Schemas[key]; !ok {
+		b.doc.Components.Schemas[key] = jsonSchema{}
+		properties := map[string]jsonSchema{}
+		for _, field := range b.schema.Messages[name].Fields {
+			properties[field.JSONName] = b.protoFieldSchema(field)
+		}
+		b.doc.Components.Schemas[key] = jsonSchema{"type": "object", "properties": properties}
+	}
+	return schemaRef(key)
+}
+
+// proto3 JSON 매핑을 따르는 필드의 스키마
+func (b *openAPIBuilder) protoFieldSchema(field protoFieldDesc) jsonSchema {
+	var schema jsonSchema
+	switch field.Type {
+	case "string":
+		schema = jsonSchema{"type": "string"}
+	case "bool":
+		schema = jsonSchema{"type": "boolean"}
+	case "int32":
+		schema = jsonSchema{"type": "integer", "format": "int32"}
+	case "uint32":
+		schema = jsonSchema{"type": "integer", "format": "int64", "minimum": 0, "maximum": uint32(1<<32 - 1)}
+	case "int64", "uint64":
+		schema = jsonSchema{"type": "string", "format": field.Type}
+	default:
+		schema = b.protoMessageSchema(field.Type)
+	}
+	if field.Repeated {
+		return jsonSchema{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// GET /openapi.json 에 대한 핸들러를 만듭니다. 문서는 여기서 한 번만 만들어 둡니다.
+func OpenAPIHandler(siteURL string, grpc GRPCConfig) (http.Handler, error) {
+	doc, err := BuildOpenAPI(siteURL, grpc, itemAPIOperations)
+	if err != nil {
+		return nil, err
+	}
+	body, err := encodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			response.Header().Set("Allow", "GET, HEAD")
+			WriteError(response, request, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		// 다른 주소의 Swagger UI 나 코드 생성기도 읽을 수 있게 합니다.
+		response.Header().Set("Access-Control-Allow-Origin", "*")
+		response.Header().Set("Cache-Control", "no-cache")
+		writeJSONBytes(response, http.StatusOK, body)
+	}), nil
+}
+
+// Swagger UI 페이지(templates/swagger.html)가 쓰는 static/ 의 파일들
+var swaggerUIAssets = []string{
+	"vendor/swagger-ui-dist@5.18.2/swagger-ui.css",
+	"vendor/swagger-ui-dist@5.18.2/swagger-ui-bundle.js",
+}
+
+// GET /openapi 의 Swagger UI 페이지
+func SwaggerUIHandler(response http.ResponseWriter, request *http.Request) {
+	RenderTemplate(response, request, "swagger", nil)
+}
@@ -0,0 +1,134 @@
+//
+// pages.go
+//
+// pages/ 디렉토리의 HTML 파일들을 자동으로 페이지로 등록합니다.
+// 파일 이름이 주소가 되고, 모든 페이지는 레이아웃 위에 그려집니다.
+//
+//   pages/about.html  =>  http://localhost:8080/about
+//
+// 페이지 파일은 templates/item.html 처럼 "title", "heading", "content" 를
+// {{define}} 으로 채우면 됩니다. {{define "menu"}}메뉴 이름{{end}} 이 있으면
+// 레이아웃의 메뉴(nav)에도 나타납니다. 메뉴 이름은 uiCatalog 의 키를 써도 됩니다.
+//
+// 이미 다른 핸들러가 쓰는 주소의 파일(예: pages/home.html => /home)은 등록하지 않고 WARN 로그를 남깁니다.
+// 그런 페이지는 메뉴와 sitemap.xml 에도 나오지 않습니다.
+//
+// 기본은 실행 파일 안의 pages/ 이고, 설정 파일의 "pages": {"dir": "./pages"}
+// 로 디스크의 디렉토리를 대신 쓸 수 있습니다.
+
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// 페이지 설정
+type PagesConfig struct {
+	Dir string `json:"dir"` // 비어 있으면 실행 파일 안의 pages/ 를 씁니다.
+}
+
+// pages/ 에서 읽은 페이지 하나
+type SitePage struct {
+	Name string // 파일 이름에서 .html을 뺀 것. 렌더러에는 "page:"+Name 으로 등록됩니다.
+	File string // pagesFS 안의 파일 이름
+	Path string // 주소. 예) /about
+	Menu string // 메뉴에 보여줄 이름. 비어 있으면 메뉴에 나오지 않습니다.
+}
+
+var (
+	pagesFS   fs.FS      // 페이지 파일을 읽을 파일 시스템
+	sitePages []SitePage // 등록된 페이지들 (파일 이름 순서)
+)
+
+// config의 디렉토리에서 페이지 목록을 읽어 둡니다. 템플릿을 읽기 전에 불러야 합니다.
+func LoadSitePages(config PagesConfig) error {
+	var fsys fs.FS
+	if config.Dir != "" {
+		fsys = os.DirFS(config.Dir)
+	} else {
+		sub, err := fs.Sub(assets, "pages")
+		if err != nil {
+			return err
+		}
+		fsys = sub
+	}
+	files, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return err
+	}
+
+	var pages []SitePage
+	for _, file := range files {
+		menu, err := pageMenu(fsys, file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(file, path.Ext(file))
+		pages = append(pages, SitePage{Name: name, File: file, Path: "/" + name, Menu: menu})
+	}
+	pagesFS, sitePages = fsys, pages
+	return nil
+}
+
+// 페이지 파일의 {{define "menu"}} 내용을 읽습니다.
+func pageMenu(fsys fs.FS, file string) (string, error) {
+	t, err := template.New("").Funcs(TemplateFuncs(DefaultLanguage)).ParseFS(fsys, file)
+	if err != nil {
+		return "", err
+	}
+	if t.Lookup("menu") == nil {
+		return "", nil
+	}
+	var buffer bytes.Buffer
+	if err := t.ExecuteTemplate(&buffer, "menu", nil); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// 렌더러에 모든 페이지를 등록합니다.
+func addSitePages(r *Renderer) error {
+	for _, page := range sitePages {
+		if err := r.AddPage(pagesFS, "page:"+page.Name, page.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 메뉴에 나오는 페이지들. 레이아웃의 nav가 씁니다.
+func MenuPages() []SitePage {
+	var menu []SitePage
+	for _, page := range sitePages {
+		if page.Menu != "" {
+			menu = append(menu, page)
+		}
+	}
+	return menu
+}
+
+// mux에 모든 페이지의 주소를 등록합니다. 다른 핸들러들을 등록한 뒤에 불러야 합니다.
+// 같은 주소가 이미 있으면 (mux.Handle 이 panic 하므로) 그 페이지는 빼고 WARN 로그를 남깁니다.
+func HandleSitePages(mux *http.ServeMux) {
+	var registered []SitePage
+	for _, page := range sitePages {
+		probe, _ := http.NewRequest(http.MethodGet, page.Path, nil)
+		if _, pattern := mux.Handler(probe); pattern == page.Path {
+			log.Printf("WARN page %s skipped: %s is already registered", page.File, page.Path)
+			continue
+		}
+		name := "page:" + page.Name
+		mux.Handle(page.Path, http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			RenderTemplate(response, request, name, page)
+		}))
+		registered = append(registered, page)
+	}
+	sitePages = registered
+}
@@ -0,0 +1,87 @@
+#!/bin/sh
+#
+# perf/run.sh
+#
+# 서버의 성능을 늘 같은 조건으로 재서 perf/results/<이름>/ 에 남깁니다.
+# 성능이 좋아졌다는 PR 은 바꾸기 전과 후의 결과를 benchcmp 로 비교해서 붙입니다.
+#
+#   $ perf/run.sh base main          git 의 main 을 빌드해서 잽니다.
+#   $ perf/run.sh mine               지금 작업 트리를 잽니다.
+#   $ go run . benchcmp perf/results/base perf/results/mine
+#
+#   name             req/s (old -> new)               p50 ms                       p99 ms
+#   item             15210.40 -> 16102.90 +5.9%       1.90 -> 1.80 -5.3%           6.10 -> 5.20 -14.8%
+#
+# 주소마다 <이름>.json (bench -json 결과)과 <이름>.cpu.pprof (그동안의 서버 CPU 프로파일)를 남기고,
+# 끝나면 heap.pprof 를 남깁니다. 서버를 띄우기 전에 잰 go test 벤치마크(perf_test.go 등)의 결과는
+# gobench.txt 에 남고, benchcmp 가 ns/op, B/op, allocs/op 를 함께 비교합니다.
+#
+#   $ go tool pprof -http=: perf/results/mine/items.cpu.pprof
+#
+# 서버는 perf/config.json 으로 8089 포트에서 띄웁니다. (pprof 켜짐, 감사 로그와 느린 요청 로그 꺼짐)
+# 부하를 주는 쪽(bench)은 두 결과가 같은 클라이언트로 재도록 언제나 지금 작업 트리의 것을 씁니다.
+# PERF_DURATION (기본 10s), PERF_CONCURRENCY (기본 32) 로 바꿀 수 있습니다.
+# 같은 컴퓨터에서 다른 일을 하지 않을 때 재야 결과를 비교할 수 있습니다.
+
+set -eu
+
+label=${1:?usage: perf/run.sh label [git-rev]}
+rev=${2:-}
+duration=${PERF_DURATION:-10s}
+concurrency=${PERF_CONCURRENCY:-32}
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+out="$root/perf/results/$label"
+tmp=$(mktemp -d)
+pid=
+cleanup() {
+	[ -n "$pid" ] && kill "$pid" 2>/dev/null && wait "$pid" 2>/dev/null
+	rm -rf "$tmp"
+}
+trap cleanup EXIT
+
+# 잴 서버의 소스
+src=$root
+if [ -n "$rev" ]; then
+	src="$tmp/src"
+	mkdir -p "$src"
+	git -C "$root" archive "$rev" | tar -x -C "$src"
+fi
+
+echo "building $label (${rev:-working tree})"
+# go.mod 가 없던 옛 커밋도 빌드할 수 있도록 GO111MODULE=auto 로 빌드합니다.
+(cd "$src" && GO111MODULE=auto go build -o "$tmp/server" .)
+(cd "$root" && go build -o "$tmp/bench" .)
+
+mkdir -p "$out"
+echo "== go test -bench"
+(cd "$src" && GO111MODULE=auto go test -run '^$' -bench . -benchmem) >"$out/gobench.txt"
+(cd "$tmp" && "$tmp/server" -config "$root/perf/config.json" >"$out/server.log" 2>&1) &
+pid=$!
+base=http://localhost:8089
+for i in 1 2 3 4 5 6 7 8 9 10; do
+	curl -sf "$base/healthz" >/dev/null 2>&1 && break
+	sleep 0.5
+done
+
+# 이름  주소  헤더
+run() {
+	name=$1
+	path=$2
+	shift 2
+	echo "== $name $path"
+	# 캐시와 연결을 데워 둡니다.
+	"$tmp/bench" bench -c "$concurrency" -n 500 "$@" "$base$path" >/dev/null
+	"$tmp/bench" bench -c "$concurrency" -d "$duration" -json "$out/$name.json" -cpuprofile "$out/$name.cpu.pprof" "$@" "$base$path"
+}
+
+run item /item/green
+run item_html /item/green -H 'Accept: text/html'
+run items /items
+run items_csv /items -H 'Accept: text/csv'
+run home /home
+run static /static/js/home.js -H 'Accept-Encoding: gzip'
+run healthz /healthz
+
+curl -sf "$base/debug/pprof/heap" -o "$out/heap.pprof"
+echo "results in $out"
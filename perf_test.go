@@ -0,0 +1,105 @@
+//
+// perf_test.go
+//
+// 핸들러, 라우터, 저장소, JSON 인코딩의 벤치마크입니다.
+// 서버를 띄우지 않고 httptest 로 재므로 네트워크와 부하를 주는 쪽의 영향을 받지 않습니다.
+// 서버 전체를 재는 것은 perf/run.sh 이고, run.sh 는 이 벤치마크의 결과도 gobench.txt 로 함께 남깁니다.
+//
+//   $ go test -run '^$' -bench . -benchmem
+//
+// package main 은 다른 패키지에서 import 할 수 없으므로 perf/ 가 아니라 여기에 둡니다.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// main 이 등록하는 주소 중 자주 불리는 것들만 등록한 mux
+func newBenchMux(tb testing.TB) *http.ServeMux {
+	tb.Helper()
+	mustLoadTemplates(tb)
+	static, err := NewStaticHandler("/static/", StaticConfig{MaxAge: 3600})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/home", http.HandlerFunc(HomeHandler))
+	mux.Handle("/static/", static)
+	mux.Handle("/item/", http.HandlerFunc(ItemHandler))
+	mux.Handle("/items", http.HandlerFunc(ItemsHandler))
+	mux.Handle("/generic/", http.HandlerFunc(GenericHandler))
+	mux.Handle("/healthz", http.HandlerFunc(HealthHandler))
+	mux.Handle("/", http.HandlerFunc(NotFoundHandler))
+	return mux
+}
+
+func BenchmarkRouter(b *testing.B) {
+	mux := newBenchMux(b)
+	paths := []string{"/home", "/static/js/home.js", "/item/green", "/items", "/healthz", "/nothing/here"}
+	requests := make([]*http.Request, len(paths))
+	for i, path := range paths {
+		requests[i] = httptest.NewRequest(http.MethodGet, path, nil)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if handler, _ := mux.Handler(requests[i%len(requests)]); handler == nil {
+			b.Fatal("no handler")
+		}
+	}
+}
+
+func BenchmarkItemsHandler(b *testing.B) {
+	benchmarkGet(b, newBenchMux(b), "/items")
+}
+
+func BenchmarkItemsHandlerCSV(b *testing.B) {
+	benchmarkGet(b, newBenchMux(b), "/items", "Accept", "text/csv")
+}
+
+func BenchmarkHomeHandler(b *testing.B) {
+	benchmarkGet(b, newBenchMux(b), "/home")
+}
+
+func BenchmarkStaticHandler(b *testing.B) {
+	benchmarkGet(b, newBenchMux(b), "/static/js/home.js")
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	s := NewItemStore(nil, defaultItems...)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := s.Get("purple"); !ok {
+			b.Fatal("purple not found")
+		}
+	}
+}
+
+func BenchmarkStoreList(b *testing.B) {
+	s := NewItemStore(nil, defaultItems...)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.List()
+	}
+}
+
+func BenchmarkStorePut(b *testing.B) {
+	s := NewItemStore(nil, defaultItems...)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Put(Item{Name: "green", What: "item"})
+	}
+}
+
+func BenchmarkEncodeItemsJSON(b *testing.B) {
+	items := NewItemStore(nil, defaultItems...).List()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeJSON(items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+//
+// precompress.go
+//
+// 정적 파일(static.go)을 요청마다 압축하지 않도록 시작할 때 한 번 gzip 으로 압축해 두고,
+// 클라이언트가 받을 수 있으면 압축한 내용을 그대로 보냅니다.
+//
+//   "static": {"precompress": true}
+//
+//   $ curl -H 'Accept-Encoding: br, gzip' -I localhost:8080/static/js/home.9add26d1.js
+//   Content-Encoding: br
+//   ETag: "5be1a3c07d2e44f1-br"
+//   Vary: Accept-Encoding
+//
+// 표준 라이브러리에는 brotli 압축기가 없으므로 .br 은 만들지 않습니다. 대신 static 디렉토리에
+// 빌드 도구가 만든 js/home.js.br 이 있으면 그것을 쓰고, js/home.js.gz 가 있으면 새로 압축하지 않고 그것을 씁니다.
+// 줄일 수 있는 파일(minify.go)은 줄인 내용을 압축합니다.
+//
+// 텍스트 파일(text/*, JavaScript, JSON, SVG, XML, wasm)만 압축하고, 압축해도 10% 넘게 줄지 않으면 두지 않습니다.
+// Range 요청에는 원본을 보냅니다. (compress.go 와 같은 이유)
+// 압축한 응답에는 Content-Encoding 이 있으므로 compress.go 가 다시 압축하지 않습니다.
+// static.dir 의 파일이 서버가 도는 중에 바뀌면 다시 시작할 때까지 그 파일은 압축하지 않고 보냅니다.
+// 개발 모드에서는 만들지 않습니다.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 파일 하나의 압축한 내용들
+type precompressedFile struct {
+	size    int64 // 압축한 원본의 크기와 수정 시간. 바뀌었으면 쓰지 않습니다.
+	modTime time.Time
+	etag    string // 원본 내용의 ETag. 압축 방식을 뒤에 붙입니다.
+
+	encodings map[string][]byte // "br", "gzip" -> 압축한 내용
+}
+
+// 보내는 순서. 앞의 것이 더 작습니다.
+var precompressedEncodings = []string{"br", "gzip"}
+
+// 미리 압축할 만한 Content-Type 인지
+func precompressibleType(contentType string) bool {
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediatype, "text/"):
+		return true
+	case mediatype == "image/svg+xml", mediatype == "application/wasm":
+		return true
+	}
+	return strings.HasSuffix(mediatype, "javascript") || strings.HasSuffix(mediatype, "json") || strings.HasSuffix(mediatype, "xml")
+}
+
+// fsys의 모든 텍스트 파일을 압축해 둡니다.
+func (h *StaticHandler) precompress() error {
+	h.precompressed = make(map[string]*precompressedFile)
+	var saved, total int64
+	start := time.Now()
+	err := fs.WalkDir(h.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		ext := path.Ext(name)
+		if ext == ".gz" || ext == ".br" || !precompressibleType(mime.TypeByExtension(ext)) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := fs.ReadFile(h.fsys, name)
+		if err != nil {
+			return err
+		}
+		if minified, ok := MinifyAsset(name, data); ok {
+			data = minified
+		}
+		file := &precompressedFile{size: info.Size(), modTime: info.ModTime(), encodings: make(map[string][]byte)}
+		// 빌드 도구가 만든 것이 있으면 그것을 씁니다. 줄이기 전의 파일을 압축한 것일 수 있지만 내용은 같습니다.
+		for _, coding := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if encoded, err := fs.ReadFile(h.fsys, name+coding.ext); err == nil {
+				file.encodings[coding.name] = encoded
+			}
+		}
+		if _, ok := file.encodings["gzip"]; !ok {
+			var buffer bytes.Buffer
+			gz, _ := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+			gz.Write(data)
+			if err := gz.Close(); err != nil {
+				return err
+			}
+			file.encodings["gzip"] = buffer.Bytes()
+		}
+		for coding, encoded := range file.encodings {
+			if len(encoded) > len(data)*9/10 {
+				delete(file.encodings, coding)
+			}
+		}
+		if len(file.encodings) == 0 {
+			return nil
+		}
+		// ETag 는 압축하지 않은 응답의 것(static.go)에 압축 방식을 붙여 만듭니다.
+		original, err := h.fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer original.Close()
+		content, ok := original.(io.ReadSeeker)
+		if !ok {
+			return nil
+		}
+		etag, err := h.etag(name, info, content)
+		if err != nil {
+			return err
+		}
+		file.etag = etag.etag
+		h.precompressed[name] = file
+		if gz, ok := file.encodings["gzip"]; ok {
+			total += int64(len(data))
+			saved += int64(len(data) - len(gz))
+		}
+		return nil
+	})
+	if err == nil && len(h.precompressed) > 0 {
+		log.Printf("static: precompressed %d files in %v, gzip saves %d of %d bytes",
+			len(h.precompressed), time.Since(start).Round(time.Millisecond), saved, total)
+	}
+	return err
+}
+
+// name을 미리 압축한 내용이 있고 클라이언트가 받을 수 있으면 그것을 보내고 true를 돌려줍니다.
+func (h *StaticHandler) servePrecompressed(response http.ResponseWriter, request *http.Request, name string, info fs.FileInfo) bool {
+	file := h.precompressed[name]
+	if file == nil || file.size != info.Size() || !file.modTime.Equal(info.ModTime()) || request.Header.Get("Range") != "" {
+		return false
+	}
+	for _, coding := range precompressedEncodings {
+		encoded, ok := file.encodings[coding]
+		if !ok || !acceptsEncoding(request, coding) {
+			continue
+		}
+		header := response.Header()
+		if !strings.Contains(strings.Join(header.Values("Vary"), ","), "Accept-Encoding") {
+			header.Add("Vary", "Accept-Encoding")
+		}
+		header.Set("Content-Encoding", coding)
+		header.Set("ETag", strings.TrimSuffix(file.etag, `"`)+"-"+coding+`"`)
+		if header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", "public, max-age="+strconv.Itoa(h.maxAge))
+		}
+		// 확장자로 정하는 Content-Type 을 압축한 내용으로 짐작하지 않게 미리 붙입니다.
+		if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+			header.Set("Content-Type", ctype)
+		}
+		http.ServeContent(response, request, name, info.ModTime(), bytes.NewReader(encoded))
+		return true
+	}
+	return false
+}
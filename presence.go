@@ -0,0 +1,123 @@
+//
+// presence.go
+//
+// 지금 연결되어 있는 실시간 클라이언트(WebSocket, SSE)를 방(room)별로 셉니다.
+//
+//   $ curl http://localhost:8080/presence
+//   {"total":3,"rooms":{"chat":{"count":2,"members":["alice","bob"]},"items.events":{"count":1}}}
+//
+// 방 이름은 연결을 받는 곳이 정합니다. 이름이 있는 연결(채팅)은 members에 나옵니다.
+//   chat, echo, items.ws, items.events
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 방별 연결 목록
+type Presence struct {
+	mu    sync.Mutex
+	rooms map[string]map[*presenceEntry]bool
+}
+
+// 연결 하나
+type presenceEntry struct {
+	name string
+}
+
+// 방 하나의 상태
+type RoomPresence struct {
+	Count   int      `json:"count"`
+	Members []string `json:"members,omitempty"`
+}
+
+// /presence 의 응답 본문
+type PresenceReport struct {
+	Total int                     `json:"total"`
+	Rooms map[string]RoomPresence `json:"rooms"`
+}
+
+// 서버 전체가 함께 쓰는 연결 목록
+var presence = NewPresence()
+
+// 빈 연결 목록을 만듭니다.
+func NewPresence() *Presence {
+	return &Presence{rooms: make(map[string]map[*presenceEntry]bool)}
+}
+
+// room에 연결 하나를 더합니다. name은 비어 있어도 됩니다.
+// 돌려주는 함수를 연결이 끊길 때 부르면 됩니다.
+//
+//	defer presence.Join("chat", name)()
+func (p *Presence) Join(room, name string) (leave func()) {
+	entry := &presenceEntry{name: name}
+	p.mu.Lock()
+	if p.rooms[room] == nil {
+		p.rooms[room] = make(map[*presenceEntry]bool)
+	}
+	p.rooms[room][entry] = true
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			delete(p.rooms[room], entry)
+			if len(p.rooms[room]) == 0 {
+				delete(p.rooms, room)
+			}
+		})
+	}
+}
+
+// 지금의 방별 연결 수와 이름들
+func (p *Presence) Report() PresenceReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	report := PresenceReport{Rooms: make(map[string]RoomPresence)}
+	for room, entries := range p.rooms {
+		rp := RoomPresence{Count: len(entries)}
+		for entry := range entries {
+			if entry.name != "" {
+				rp.Members = append(rp.Members, entry.name)
+			}
+		}
+		sort.Strings(rp.Members)
+		report.Rooms[room] = rp
+		report.Total += rp.Count
+	}
+	return report
+}
+
+// 모든 연결이 끊길 때까지 기다립니다. ctx가 먼저 끝나면 ctx의 에러를 돌려줍니다.
+func (p *Presence) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		p.mu.Lock()
+		empty := len(p.rooms) == 0
+		p.mu.Unlock()
+		if empty {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GET /presence
+func PresenceHandler(response http.ResponseWriter, request *http.Request) {
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(presence.Report())
+}
@@ -0,0 +1,163 @@
+//
+// progress.go
+//
+// 받고 있는 업로드의 진행 상황입니다. 브라우저가 서버가 실제로 받은 바이트 수로
+// 진행 막대를 그릴 수 있습니다.
+//
+//   POST /upload?progress=abc123              업로드할 때 클라이언트가 정한 ID를 붙입니다.
+//   GET  /upload/progress/abc123              진행 상황 (JSON)
+//   GET  /upload/progress/abc123  (Accept: text/event-stream)  끝날 때까지 SSE로
+//
+//   {"received":5242880,"total":10485760,"done":false}
+//
+// 이어받기 업로드(resumable.go)는 업로드 주소의 uid가 진행 상황 ID입니다.
+// 끝난 업로드의 진행 상황은 잠시 뒤에 지웁니다.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 끝난 업로드의 진행 상황을 남겨 두는 시간
+const progressKeep = time.Minute
+
+// SSE로 진행 상황을 확인하는 간격
+const progressInterval = 250 * time.Millisecond
+
+// 진행 상황 ID로 쓸 수 있는 글자
+var progressIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// 업로드 하나의 진행 상황
+type uploadProgress struct {
+	received int64 // atomic
+	total    int64 // 모르면 -1
+	done     int32 // atomic. 1이면 끝남
+}
+
+// 진행 상황의 JSON 모양
+type ProgressReport struct {
+	Received int64 `json:"received"`
+	Total    int64 `json:"total"`
+	Done     bool  `json:"done"`
+}
+
+func (p *uploadProgress) report() ProgressReport {
+	return ProgressReport{Received: atomic.LoadInt64(&p.received), Total: p.total, Done: atomic.LoadInt32(&p.done) == 1}
+}
+
+// 진행 중인 업로드들
+type ProgressTracker struct {
+	mu      sync.Mutex
+	uploads map[string]*uploadProgress
+}
+
+// 서버 전체가 함께 쓰는 진행 상황 목록
+var uploadProgresses = &ProgressTracker{uploads: make(map[string]*uploadProgress)}
+
+// id의 진행 상황을 새로 시작하고 body를 읽는 만큼 received를 늘리는 Reader를 돌려줍니다.
+// 받기가 끝나면 돌려받은 finish를 불러야 합니다.
+func (t *ProgressTracker) Track(id string, received, total int64, body io.Reader) (io.Reader, func()) {
+	p := &uploadProgress{received: received, total: total}
+	t.mu.Lock()
+	t.uploads[id] = p
+	t.mu.Unlock()
+	finish := func() {
+		atomic.StoreInt32(&p.done, 1)
+		time.AfterFunc(progressKeep, func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.uploads[id] == p {
+				delete(t.uploads, id)
+			}
+		})
+	}
+	return &progressReader{r: body, p: p}, finish
+}
+
+func (t *ProgressTracker) get(id string) (*uploadProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.uploads[id]
+	return p, ok
+}
+
+// 읽은 바이트 수를 세는 Reader
+type progressReader struct {
+	r io.Reader
+	p *uploadProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	atomic.AddInt64(&r.p.received, int64(n))
+	return n, err
+}
+
+// 요청에 ?progress=ID 가 있으면 본문을 진행 상황으로 감쌉니다. 없으면 아무것도 하지 않습니다.
+func trackUploadBody(request *http.Request) (finish func()) {
+	id := request.URL.Query().Get("progress")
+	if !progressIDPattern.MatchString(id) {
+		return func() {}
+	}
+	body, finish := uploadProgresses.Track(id, 0, request.ContentLength, request.Body)
+	request.Body = readCloser{body, request.Body}
+	return finish
+}
+
+// Reader와 원래 본문의 Close를 합칩니다.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// GET /upload/progress/{id}
+func ProgressHandler(response http.ResponseWriter, request *http.Request) {
+	id := strings.TrimPrefix(request.URL.Path, "/upload/progress/")
+	p, ok := uploadProgresses.get(id)
+	if !ok {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	response.Header().Set("Cache-Control", "no-store")
+	response.Header().Add("Vary", "Accept")
+	flusher, canFlush := response.(http.Flusher)
+	if !AcceptsType(request, "text/event-stream") || !canFlush {
+		SetContentType(response, "application/json")
+		json.NewEncoder(response).Encode(p.report())
+		return
+	}
+
+	SetContentType(response, "text/event-stream")
+	response.WriteHeader(http.StatusOK)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	last := ProgressReport{Received: -1}
+	for {
+		report := p.report()
+		if report != last {
+			data, _ := json.Marshal(report)
+			fmt.Fprintf(response, "event: progress\ndata: %s\n\n", data)
+			flusher.Flush()
+			last = report
+		}
+		if report.Done {
+			return
+		}
+		select {
+		case <-request.Context().Done():
+			return
+		case <-draining:
+			return
+		case <-ticker.C:
+		}
+	}
+}
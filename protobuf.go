@@ -0,0 +1,112 @@
+//
+// protobuf.go
+//
+// 외부 라이브러리 없이 구현한 Protocol Buffers 인코더와 디코더입니다. (https://protobuf.dev/programming-guides/encoding/)
+// gRPC 의 ItemService(grpc.go, items.proto)가 씁니다.
+//
+// 필드 번호와 wire type 만 다루는 낮은 수준의 함수들이므로 메시지마다 인코딩 함수를 따로 씁니다.
+// proto3 처럼 0 이나 빈 문자열인 필드는 보내지 않습니다.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// wire type
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// 읽은 필드 하나. wire type 이 protoBytes 면 Bytes, 아니면 Value 에 값이 있습니다.
+type protoField struct {
+	Number int
+	Type   int
+	Value  uint64
+	Bytes  []byte
+}
+
+// 필드 번호와 wire type 을 b 뒤에 붙입니다.
+func appendProtoTag(b []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// 0이 아니면 varint 필드를 붙입니다.
+func appendProtoUint(b []byte, number int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendProtoTag(b, number, protoVarint), v)
+}
+
+// 비어 있지 않으면 string 필드를 붙입니다.
+func appendProtoString(b []byte, number int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(appendProtoTag(b, number, protoBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// 인코딩한 메시지 msg 를 필드로 붙입니다. repeated 필드의 원소는 비어 있어도 붙여야 하므로 늘 붙입니다.
+func appendProtoMessage(b []byte, number int, msg []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, number, protoBytes), uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// t를 google.protobuf.Timestamp 메시지로 붙입니다.
+func appendProtoTimestamp(b []byte, number int, t time.Time) []byte {
+	var msg []byte
+	msg = appendProtoUint(msg, 1, uint64(t.Unix()))
+	msg = appendProtoUint(msg, 2, uint64(t.Nanosecond()))
+	return appendProtoMessage(b, number, msg)
+}
+
+// 메시지를 필드들로 나눕니다. 같은 번호가 여러 번 나오면 나온 순서대로 모두 돌려줍니다.
+func parseProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("protobuf: bad tag")
+		}
+		b = b[n:]
+		field := protoField{Number: int(tag >> 3), Type: int(tag & 7)}
+		if field.Number == 0 {
+			return nil, errors.New("protobuf: field number 0")
+		}
+		switch field.Type {
+		case protoVarint:
+			if field.Value, n = binary.Uvarint(b); n <= 0 {
+				return nil, errors.New("protobuf: bad varint")
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return nil, errors.New("protobuf: short fixed64")
+			}
+			field.Value, b = binary.LittleEndian.Uint64(b), b[8:]
+		case protoFixed32:
+			if len(b) < 4 {
+				return nil, errors.New("protobuf: short fixed32")
+			}
+			field.Value, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case protoBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return nil, errors.New("protobuf: bad length")
+			}
+			field.Bytes, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d", field.Type)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
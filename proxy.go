@@ -0,0 +1,187 @@
+//
+// proxy.go
+//
+// 설정한 주소 아래의 요청을 다른 서버(upstream)로 넘겨주는 리버스 프록시입니다.
+// 데모에서 이 서버 하나로 같은 컴퓨터의 다른 서비스들을 함께 보여줄 수 있습니다.
+//
+//   "proxy": [{"path": "/proxy/grafana/", "upstream": "http://localhost:3000", "strip_prefix": true,
+//              "timeout": 30, "headers": {"X-WEBAUTH-USER": "demo"}}]
+//
+//   GET /proxy/grafana/api/health   =>   GET http://localhost:3000/api/health       (strip_prefix)
+//
+//   path          "/" 로 끝나면 그 아래 모두, 아니면 그 주소 하나
+//   strip_prefix  upstream 에 보낼 때 path 를 뗍니다. upstream 이 돌려주는 Location 에는 다시 붙입니다.
+//   timeout       upstream 의 응답 헤더를 기다리는 시간(초). 넘으면 504 Gateway Timeout
+//   headers       upstream 에 보내는 요청에 붙일 헤더. 값이 비어 있으면 그 헤더를 지웁니다.
+//
+// X-Forwarded-For, X-Forwarded-Host, X-Forwarded-Proto 를 붙여서 보냅니다. 클라이언트가 보낸 것은
+// 믿는 프록시(headers.go)에서 온 것만 남아 있으므로 그대로 이어 붙입니다.
+// 로그인 세션과 API 키는 이 서버의 것이므로 Authorization, X-API-Key 와 이 서버가 서명한 쿠키
+// (session, consent, login_2fa, challenge_pass)는 넘기지 않습니다. upstream 에 인증이 필요하면 headers 로 붙입니다.
+// upstream 에 연결할 수 없으면 502 Bad Gateway 를 이 서버의 에러 형식(messages.go)으로 돌려줍니다.
+// SSE 와 WebSocket(Upgrade)도 그대로 넘깁니다.
+//
+// upstreams 로 여러 upstream 에 나눠 보낼 수 있습니다. 고르는 방법과 health check 는 balancer.go 에 있습니다.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 프록시 주소 하나
+type ProxyRoute struct {
+	Path        string            `json:"path"`
+	Upstream    string            `json:"upstream"`
+	Upstreams   []string          `json:"upstreams"` // 여러 개면 나눠 보냅니다. (balancer.go)
+	Balance     string            `json:"balance"`   // "round_robin" 또는 "least_conn"
+	HealthCheck ProxyHealthCheck  `json:"health_check"`
+	StripPrefix bool              `json:"strip_prefix"`
+	Timeout     int               `json:"timeout"` // 초. 0이면 30
+	Headers     map[string]string `json:"headers"`
+}
+
+// upstream 에 넘기지 않는 이 서버의 인증 헤더와 쿠키
+var (
+	proxyPrivateHeaders = []string{"Authorization", "X-API-Key", "X-Client-ID", "X-Signature"}
+	proxyPrivateCookies = []string{sessionCookie, consentCookie, loginTOTPCookie, challengeCookie}
+)
+
+// 요청을 보내기로 고른 upstream
+type upstreamKey struct{}
+
+// route의 요청을 upstream 들로 넘기는 핸들러를 만듭니다.
+func NewProxyHandler(route ProxyRoute, pool *UpstreamPool) (http.Handler, error) {
+	if !strings.HasPrefix(route.Path, "/") {
+		return nil, fmt.Errorf("proxy %s: path must start with /", route.Path)
+	}
+	timeout := time.Duration(route.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	prefix := strings.TrimSuffix(route.Path, "/")
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			upstream := r.In.Context().Value(upstreamKey{}).(*upstreamBackend).url
+			r.SetURL(upstream)
+			if route.StripPrefix {
+				r.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.In.URL.Path, prefix), "/")
+				r.Out.URL.RawPath = ""
+				r.Out.URL.Path = singleJoin(upstream.Path, r.Out.URL.Path)
+			}
+			// Rewrite 는 클라이언트의 X-Forwarded-* 를 지우므로 믿을 수 있는 것(headers.go 가 남긴 것)을 이어 붙입니다.
+			r.Out.Header["X-Forwarded-For"] = r.In.Header["X-Forwarded-For"]
+			r.SetXForwarded()
+			if isHTTPS(r.In) {
+				r.Out.Header.Set("X-Forwarded-Proto", "https")
+			}
+			for _, name := range proxyPrivateHeaders {
+				r.Out.Header.Del(name)
+			}
+			removeCookies(r.Out, proxyPrivateCookies)
+			for name, value := range route.Headers {
+				if value == "" {
+					r.Out.Header.Del(name)
+				} else {
+					r.Out.Header.Set(name, value)
+				}
+			}
+		},
+		Transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			ResponseHeaderTimeout: timeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   32,
+			ForceAttemptHTTP2:     true,
+		},
+		ModifyResponse: func(response *http.Response) error {
+			if !route.StripPrefix {
+				return nil
+			}
+			// upstream 안의 주소로 리다이렉트하면 프록시 주소로 바꿉니다.
+			if location := response.Header.Get("Location"); strings.HasPrefix(location, "/") {
+				response.Header.Set("Location", prefix+location)
+			} else if u, err := url.Parse(location); err == nil && pool.hasHost(u.Host) {
+				response.Header.Set("Location", prefix+u.RequestURI())
+			}
+			return nil
+		},
+		ErrorHandler: func(response http.ResponseWriter, request *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				// 클라이언트가 먼저 끊었으므로 받을 사람이 없습니다. 접근 로그에는 nginx 처럼 499 로 남깁니다.
+				Logf(request.Context(), "proxy %s: client went away", route.Path)
+				response.WriteHeader(499)
+				return
+			}
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+				status = http.StatusGatewayTimeout
+			}
+			WriteError(response, request, status, fmt.Errorf("proxy %s: %v", route.Path, err))
+		},
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		backend := pool.pick()
+		if backend == nil {
+			response.Header().Set("Retry-After", "5")
+			WriteError(response, request, http.StatusServiceUnavailable, fmt.Errorf("proxy %s: no healthy upstream", route.Path))
+			return
+		}
+		backend.requests.Add(1)
+		backend.active.Add(1)
+		defer backend.active.Add(-1)
+		proxy.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), upstreamKey{}, backend)))
+	}), nil
+}
+
+// 두 경로를 / 하나로 잇습니다.
+func singleJoin(a, b string) string {
+	if a == "" || a == "/" {
+		return b
+	}
+	return strings.TrimSuffix(a, "/") + "/" + strings.TrimPrefix(b, "/")
+}
+
+// 요청의 Cookie 헤더에서 names 쿠키들만 뺍니다.
+func removeCookies(request *http.Request, names []string) {
+	cookies := request.Cookies()
+	request.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if !slices.Contains(names, cookie.Name) {
+			request.AddCookie(cookie)
+		}
+	}
+}
+
+// config의 프록시 주소들을 mux에 등록합니다.
+func RegisterProxies(mux *http.ServeMux, routes []ProxyRoute) error {
+	for _, route := range routes {
+		upstreams := route.Upstreams
+		if route.Upstream != "" {
+			upstreams = append([]string{route.Upstream}, upstreams...)
+		}
+		pool, err := NewUpstreamPool(upstreams, route.Balance)
+		if err != nil {
+			return fmt.Errorf("proxy %s: %v", route.Path, err)
+		}
+		handler, err := NewProxyHandler(route, pool)
+		if err != nil {
+			return err
+		}
+		upstreamPools[route.Path] = pool
+		pool.StartHealthCheck(route.Path, route.HealthCheck)
+		mux.Handle(route.Path, handler)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+//
+// rbac.go
+//
+// 주소마다 필요한 역할을 정하고, 요청한 사용자의 역할(users.go)과 맞춰 봅니다.
+//
+//   "access": [
+//     {"path": "/admin/", "roles": ["admin"]},
+//     {"path": "/items", "methods": ["POST"], "roles": ["items:write"]}
+//   ]
+//
+// path 가 "/" 로 끝나면 그 아래 모든 주소, 아니면 그 주소만입니다. (http.ServeMux 와 같습니다)
+// methods 가 비어 있으면 모든 메소드에 적용합니다. 맞는 규칙이 여럿이면 모두 통과해야 합니다.
+// roles 중 하나만 있으면 되고, "admin" 역할은 모든 규칙을 통과합니다.
+//
+// 사용자는 로그인 세션(session.go)으로, 없으면 Basic 인증의 이름과 비밀번호로 알아냅니다.
+// 사용자를 알 수 없으면 401, 역할이 없으면 403 을 application/problem+json 으로 돌려주고
+// 감사 로그에 access.denied 를 남깁니다.
+//
+// 규칙이 없는 주소는 지금처럼 누구나 쓸 수 있습니다.
+//
+// HTTP 미들웨어를 거치지 않고 같은 일을 하는 곳(gRPC 의 PutItem, GraphQL 의 putItem, REST 게이트웨이의 POST /v1/items)은
+// Authorize 로 "POST /items" 의 규칙과 https 전용 주소(https.go)를 똑같이 확인합니다. (SaveItem, item.go)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 규칙이 적용되는 주소와 메소드. https 전용 주소(https.go)도 같은 형식입니다.
+type RouteRule struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"` // 비어 있으면 모든 메소드
+}
+
+// 주소 하나의 접근 규칙
+type AccessRule struct {
+	RouteRule
+	Roles []string `json:"roles"` // 이 중 하나가 있어야 합니다.
+}
+
+// 모든 규칙을 통과하는 역할
+const adminRole = "admin"
+
+// 서버 전체가 쓰는 접근 규칙과 https 전용 주소. main에서 설정으로 채웁니다. (Authorize)
+var (
+	accessRules     []AccessRule
+	httpsOnlyRoutes []RouteRule
+)
+
+// 규칙 때문에 거절한 이유. status 는 401 이나 403 입니다.
+type accessError struct {
+	status int
+	err    error
+}
+
+func (e *accessError) Error() string { return e.err.Error() }
+
+// rule이 request에 적용되는지
+func (rule RouteRule) matches(request *http.Request) bool {
+	return rule.matchesRoute(request.Method, request.URL.Path)
+}
+
+// rule이 method path 에 적용되는지
+func (rule RouteRule) matchesRoute(method, path string) bool {
+	if strings.HasSuffix(rule.Path, "/") {
+		if !strings.HasPrefix(path, rule.Path) {
+			return false
+		}
+	} else if path != rule.Path {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// roles가 rule을 통과하는지
+func (rule AccessRule) allows(roles []string) bool {
+	for _, have := range roles {
+		if have == adminRole {
+			return true
+		}
+		for _, want := range rule.Roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 요청한 사용자의 이름. 세션이 없으면 Basic 인증을 사용자 저장소로 확인합니다. 모르면 ""
+func RequestUser(request *http.Request) string {
+	if user := CurrentUser(request); user != "" {
+		return user
+	}
+	if name, password, ok := request.BasicAuth(); ok {
+		if basicAuthenticate(request, name, password) {
+			return name
+		}
+	}
+	return ""
+}
+
+// rules를 통과하는 요청만 next로 보냅니다. 규칙이 없으면 next를 그대로 돌려줍니다.
+func AccessHandler(rules []AccessRule, next http.Handler) http.Handler {
+	if len(rules) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if err := checkAccess(request, request.Method, request.URL.Path, rules); err != nil {
+			WriteError(response, request, err.status, err)
+			return
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+// request를 보낸 사용자가 method path 에 맞는 rules를 모두 통과하는지. 통과하지 못하면 감사 로그를 남깁니다.
+func checkAccess(request *http.Request, method, path string, rules []AccessRule) *accessError {
+	var matched []AccessRule
+	for _, rule := range rules {
+		if rule.matchesRoute(method, path) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	user := RequestUser(request)
+	if user == "" {
+		audit.Record(request, "access.denied", "", map[string]string{"reason": "unauthenticated"})
+		return &accessError{http.StatusUnauthorized, fmt.Errorf("%s %s needs a user", method, path)}
+	}
+	roles := users.Roles(user)
+	for _, rule := range matched {
+		if !rule.allows(roles) {
+			audit.Record(request, "access.denied", user, map[string]string{"need": strings.Join(rule.Roles, ",")})
+			return &accessError{http.StatusForbidden, fmt.Errorf("%s %s needs one of roles %v", method, path, rule.Roles)}
+		}
+	}
+	return nil
+}
+
+// ctx 에 request 를 넣어 둡니다. ctx 만 받는 곳에서 Authorize 가 요청한 사용자를 알아낼 때 씁니다.
+type accessRequestKey struct{}
+
+func withAccessRequest(request *http.Request) context.Context {
+	return context.WithValue(request.Context(), accessRequestKey{}, request)
+}
+
+// ctx 의 요청이 method path 로 온 것처럼 https 전용 주소와 접근 규칙을 확인합니다.
+// 거절하면 *accessError 를 돌려줍니다. ctx 에 요청이 없으면 누가 보냈는지 모르므로 거절합니다.
+func Authorize(ctx context.Context, method, path string) error {
+	request, _ := ctx.Value(accessRequestKey{}).(*http.Request)
+	if request == nil {
+		return &accessError{http.StatusForbidden, fmt.Errorf("%s %s: unknown request", method, path)}
+	}
+	if !isHTTPS(request) {
+		for _, route := range httpsOnlyRoutes {
+			if route.matchesRoute(method, path) {
+				return &accessError{http.StatusForbidden, fmt.Errorf("%s %s requires https", method, path)}
+			}
+		}
+	}
+	if err := checkAccess(request, method, path, accessRules); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testAccessRules = []AccessRule{
+	{RouteRule{Path: "/admin/"}, []string{"admin"}},
+	{RouteRule{Path: "/items", Methods: []string{"post"}}, []string{"items:write"}},
+	{RouteRule{Path: "/reports"}, []string{"reports:read", "reports:write"}},
+	{RouteRule{Path: "/reports", Methods: []string{"DELETE"}}, []string{"reports:write"}},
+}
+
+// user 의 세션으로 보낸 요청. user 가 비어 있으면 세션이 없습니다.
+func requestAs(user, method, target string) *http.Request {
+	request := httptest.NewRequest(method, target, nil)
+	if user == "" {
+		return request
+	}
+	return request.WithContext(context.WithValue(request.Context(), sessionKey{}, Session{User: user}))
+}
+
+func TestAccessHandler(t *testing.T) {
+	useTestUsers(t,
+		User{Name: "root", Roles: []string{"admin"}},
+		User{Name: "writer", Roles: []string{"items:write"}},
+		User{Name: "reader", Roles: []string{"reports:read"}},
+	)
+	handler := AccessHandler(testAccessRules, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	tests := []struct {
+		user, method, path string
+		status             int
+	}{
+		// 규칙이 없는 주소는 누구나 씁니다.
+		{"", http.MethodGet, "/home", http.StatusOK},
+		// "/" 로 끝나는 규칙은 그 아래 모든 주소에, 아닌 규칙은 그 주소에만 적용합니다.
+		{"", http.MethodGet, "/admin/", http.StatusUnauthorized},
+		{"", http.MethodGet, "/admin/users/1", http.StatusUnauthorized},
+		{"", http.MethodGet, "/admin", http.StatusOK},
+		{"", http.MethodPost, "/items/green", http.StatusOK},
+		{"writer", http.MethodGet, "/admin/users/1", http.StatusForbidden},
+		{"root", http.MethodGet, "/admin/users/1", http.StatusOK},
+		// methods 가 있는 규칙은 그 메소드에만 적용합니다. 대소문자는 가리지 않습니다.
+		{"", http.MethodGet, "/items", http.StatusOK},
+		{"", http.MethodPost, "/items", http.StatusUnauthorized},
+		{"reader", http.MethodPost, "/items", http.StatusForbidden},
+		{"writer", http.MethodPost, "/items", http.StatusOK},
+		// roles 중 하나면 되고, 맞는 규칙이 여럿이면 모두 통과해야 합니다.
+		{"reader", http.MethodGet, "/reports", http.StatusOK},
+		{"reader", http.MethodDelete, "/reports", http.StatusForbidden},
+		{"writer", http.MethodGet, "/reports", http.StatusForbidden},
+		{"root", http.MethodDelete, "/reports", http.StatusOK},
+		// 저장소에 없는 사용자는 역할이 없습니다.
+		{"ghost", http.MethodGet, "/reports", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, requestAs(test.user, test.method, test.path))
+		if recorder.Code != test.status {
+			t.Errorf("%s %s as %q: status %d, want %d", test.method, test.path, test.user, recorder.Code, test.status)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	useTestUsers(t, User{Name: "writer", Roles: []string{"items:write"}}, User{Name: "reader"})
+	old := accessRules
+	accessRules = testAccessRules
+	t.Cleanup(func() { accessRules = old })
+
+	status := func(err error) int {
+		var denied *accessError
+		if errors.As(err, &denied) {
+			return denied.status
+		}
+		if err != nil {
+			return -1
+		}
+		return http.StatusOK
+	}
+	// gRPC, GraphQL 처럼 다른 주소로 온 요청도 "POST /items" 의 규칙으로 확인합니다.
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+	}{
+		{"no request", context.Background(), http.StatusForbidden},
+		{"anonymous", withAccessRequest(requestAs("", http.MethodPost, "/graphql")), http.StatusUnauthorized},
+		{"no role", withAccessRequest(requestAs("reader", http.MethodPost, "/graphql")), http.StatusForbidden},
+		{"items:write", withAccessRequest(requestAs("writer", http.MethodPost, "/graphql")), http.StatusOK},
+	}
+	for _, test := range tests {
+		if got := status(Authorize(test.ctx, http.MethodPost, "/items")); got != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, got, test.status)
+		}
+	}
+}
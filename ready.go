@@ -0,0 +1,165 @@
+//
+// ready.go
+//
+// k8s readiness probe 가 부르는 /readyz 입니다.
+// 등록된 검사(저장소, 템플릿, 디스크)를 모두 돌려 보고, 하나라도 실패하면
+// 503 과 함께 검사마다의 결과를 돌려줍니다. 모두 통과하면 200 입니다.
+//
+//   $ curl localhost:8080/readyz
+//   {"status":"ready","checks":{"disk":{"status":"ok","duration_ms":0.2},"store":{...},"templates":{...}}}
+//
+// 서버가 꺼지는 중(shutdown.go)에도 503 을 돌려주어 로드밸런서가 새 요청을 보내지 않게 합니다.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 검사 하나의 제한 시간
+const readinessTimeout = 2 * time.Second
+
+// 준비되었는지 확인하는 검사. 준비되지 않았으면 에러를 돌려줍니다.
+type ReadinessCheck func(ctx context.Context) error
+
+// 검사 하나의 결과
+type CheckResult struct {
+	Status   string  `json:"status"` // "ok" 또는 "fail"
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// /readyz 의 응답 본문
+type ReadinessReport struct {
+	Status string                 `json:"status"` // "ready", "not ready", "draining"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// 등록된 검사들
+type Readiness struct {
+	mu     sync.Mutex
+	checks map[string]ReadinessCheck
+}
+
+// 서버 전체가 쓰는 readiness
+var readiness = &Readiness{checks: make(map[string]ReadinessCheck)}
+
+// name 이라는 검사를 등록합니다.
+func (r *Readiness) Register(name string, check ReadinessCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// 모든 검사를 함께 돌리고 결과를 모읍니다.
+func (r *Readiness) Check(ctx context.Context) ReadinessReport {
+	r.mu.Lock()
+	checks := make(map[string]ReadinessCheck, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.Unlock()
+
+	report := ReadinessReport{Status: "ready", Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check ReadinessCheck) {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+			mu.Lock()
+			report.Checks[name] = result
+			if result.Status != "ok" {
+				report.Status = "not ready"
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	select {
+	case <-draining:
+		report.Status = "draining"
+	default:
+	}
+	return report
+}
+
+// check를 readinessTimeout 안에 끝나지 않으면 실패로 봅니다.
+func runCheck(parent context.Context, check ReadinessCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(parent, readinessTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := CheckResult{Status: "ok", Duration: milliseconds(time.Since(start))}
+	if err != nil {
+		result.Status, result.Error = "fail", err.Error()
+	}
+	return result
+}
+
+// GET /readyz
+func (r *Readiness) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	report := r.Check(request.Context())
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ready" {
+		response.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(response).Encode(report)
+}
+
+// 저장소의 잠금을 얻을 수 있는지 (어디선가 잠금을 쥔 채 멈추지 않았는지)
+func StoreCheck(store *ItemStore) ReadinessCheck {
+	return func(ctx context.Context) error {
+		store.Len()
+		return nil
+	}
+}
+
+// home 페이지 템플릿을 읽어 두었는지
+func TemplatesCheck() ReadinessCheck {
+	return func(ctx context.Context) error {
+		r, err := currentRenderer()
+		if err != nil {
+			return err
+		}
+		if _, ok := r.pages[DefaultLanguage]["home"]; !ok {
+			return fmt.Errorf("home template not parsed")
+		}
+		return nil
+	}
+}
+
+// dir에 파일을 만들고 지울 수 있는지
+func DiskCheck(dir string) ReadinessCheck {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".readyz-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(name); err == nil {
+			err = rerr
+		}
+		return err
+	}
+}
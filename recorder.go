@@ -0,0 +1,68 @@
+//
+// recorder.go
+//
+// 미들웨어가 핸들러의 응답 상태 코드와 크기를 알 수 있게 하는 ResponseWriter 입니다.
+// 메트릭(metrics.go)과 로그가 함께 씁니다.
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// 응답의 상태 코드와 본문 크기를 기록하는 ResponseWriter
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	size     int64
+	hijacked bool
+}
+
+func newResponseRecorder(response http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: response}
+}
+
+// 핸들러가 WriteHeader를 부르지 않고 끝났으면 200
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		if r.hijacked {
+			return http.StatusSwitchingProtocols
+		}
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 && status >= 200 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,115 @@
+//
+// recover.go
+//
+// 핸들러에서 panic이 나도 서버가 죽지 않게 잡아서 500 으로 응답합니다.
+// 잡은 panic은 요청 정보(민감한 헤더는 가림)와 goroutine 스택을 담은 JSON 보고서로
+// crash_dir 에 남기고, /metrics 의 http_panics_total 을 늘립니다.
+//
+//   crashes/20261016-081809.123-a1b2c3d4.json
+//   {"time":"...","panic":"runtime error: index out of range","method":"GET","url":"/item/x",
+//    "remote_addr":"127.0.0.1:5000","headers":{"Cookie":["[REDACTED]"],...},"stack":"goroutine 7 [running]:..."}
+//
+// crash_dir 가 비어 있으면 보고서는 로그에만 씁니다.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// 보고서에서 값을 가리는 헤더들
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Proxy-Authorization"}
+
+// 가린 값 대신 쓰는 글자
+const redacted = "[REDACTED]"
+
+// panic 보고서
+type PanicReport struct {
+	Time       time.Time   `json:"time"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Panic      string      `json:"panic"`
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	RemoteAddr string      `json:"remote_addr"`
+	Headers    http.Header `json:"headers"`
+	Stack      string      `json:"stack"`
+}
+
+// 민감한 헤더의 값을 가린 복사본
+func redactHeaders(header http.Header) http.Header {
+	clean := header.Clone()
+	for _, name := range redactedHeaders {
+		if _, ok := clean[name]; ok {
+			clean[name] = []string{redacted}
+		}
+	}
+	return clean
+}
+
+// next의 panic을 잡아 보고서를 남기고 500 으로 응답합니다.
+// 응답을 이미 쓰기 시작했으면 연결을 끊어 클라이언트가 잘린 응답을 알게 합니다.
+func RecoverHandler(crashDir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		recorder := newResponseRecorder(response)
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				// 핸들러가 일부러 연결을 끊은 것입니다.
+				panic(value)
+			}
+			metrics.ObservePanic()
+			report := PanicReport{
+				Time:       time.Now(),
+				RequestID:  RequestID(request.Context()),
+				Panic:      fmt.Sprint(value),
+				Method:     request.Method,
+				URL:        request.URL.String(),
+				RemoteAddr: request.RemoteAddr,
+				Headers:    redactHeaders(request.Header),
+				Stack:      string(debug.Stack()),
+			}
+			if path, err := writePanicReport(crashDir, report); err != nil {
+				Logf(request.Context(), "panic: %v %s %s (report: %v)\n%s", value, request.Method, request.URL, err, report.Stack)
+			} else if path != "" {
+				Logf(request.Context(), "panic: %v %s %s (report: %s)", value, request.Method, request.URL, path)
+			} else {
+				Logf(request.Context(), "panic: %v %s %s\n%s", value, request.Method, request.URL, report.Stack)
+			}
+			if recorder.status != 0 || recorder.hijacked {
+				panic(http.ErrAbortHandler)
+			}
+			WriteError(recorder, request, http.StatusInternalServerError, nil)
+		}()
+		next.ServeHTTP(recorder, request)
+	})
+}
+
+// report를 dir에 JSON 파일로 씁니다. dir가 비어 있으면 아무것도 하지 않고 "" 를 돌려줍니다.
+func writePanicReport(dir string, report PanicReport) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	path := filepath.Join(dir, report.Time.Format("20060102-150405.000")+"-"+hex.EncodeToString(suffix)+".json")
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// 헤더 값이 가려져 있어도 URL 등이 남으므로 서버 계정만 읽을 수 있게 합니다.
+	return path, os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,65 @@
+//
+// requestid.go
+//
+// 요청마다 ID를 붙이고, 요청을 처리하는 동안 남기는 모든 로그 줄에 그 ID를 넣습니다.
+// 접근 로그, 에러 로그, panic 보고서, 저장소 로그를 ID 하나로 묶어 볼 수 있습니다.
+//
+//   2026/10/16 08:21:36 [req=5f2c9a1e0b7d4c3a] POST /items : 422 invalid item name "a b"
+//
+// 게이트웨이가 X-Request-ID 를 보내면 그 값을 그대로 쓰고, 없으면 새로 만듭니다.
+// 응답의 X-Request-ID 헤더로 클라이언트에게도 알려줍니다.
+//
+// 요청 안에서 로그를 남길 때는 log.Printf 대신 Logf(ctx, ...) 를 씁니다.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+// ctx의 요청 ID. 요청 밖이면 ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// 요청 ID를 정하고 context와 응답 헤더에 넣습니다.
+func RequestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		response.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), requestIDKey{}, id)))
+	})
+}
+
+// 밖에서 받은 ID는 로그를 깨뜨리지 않는 짧은 글자들만 받습니다.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// ctx에 요청 ID가 있으면 앞에 붙여 로그를 남깁니다.
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	if id := RequestID(ctx); id != "" {
+		format = "[req=" + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
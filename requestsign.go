@@ -0,0 +1,229 @@
+//
+// requestsign.go
+//
+// 사람이 아닌 클라이언트(배치 작업, 다른 서버)의 요청을 HMAC 서명으로 확인합니다.
+// API 키(apikeys.go)와 달리 비밀 키가 요청에 실리지 않으므로, 요청이 새어도 다른 요청을 만들 수 없습니다.
+//
+//   "request_signing": {"clients": {"build-bot": "k3y..."}, "window": 300, "max_body": 1048576,
+//                       "required": ["/upload", "/items"]}
+//
+// 클라이언트는 네 개의 헤더를 붙입니다.
+//
+//   X-Client-ID: build-bot
+//   X-Timestamp: 1792137600                      (유닉스 시간, 초)
+//   X-Nonce:     6f1c0b7e9a2d4c11                (요청마다 새 값)
+//   X-Signature: base64(HMAC-SHA256(키, 서명할 문자열))
+//
+//   서명할 문자열 = 메소드 \n 경로?쿼리 \n X-Timestamp \n X-Nonce \n hex(SHA-256(본문))
+//
+//   $ ts=$(date +%s); nonce=$(openssl rand -hex 8); body='{"name":"x"}'
+//   $ hash=$(printf %s "$body" | openssl dgst -sha256 -hex | cut -d' ' -f2)
+//   $ sig=$(printf 'POST\n/items\n%s\n%s\n%s' $ts $nonce $hash | openssl dgst -sha256 -hmac 'k3y...' -binary | base64)
+//   $ curl -H "X-Client-ID: build-bot" -H "X-Timestamp: $ts" -H "X-Nonce: $nonce" -H "X-Signature: $sig" \
+//          -d "$body" http://localhost:8080/items
+//
+// 시각이 window 초보다 어긋났거나, window 안에 이미 쓴 nonce 이면 다시 보낸 요청(replay)으로 보고 거절합니다.
+// 서명이 맞는 요청은 클라이언트 이름이 요청한 사람(owner)이 됩니다. (apikeys.go)
+// clients 는 비밀 키 파일(secrets.go)에 두고 SIGHUP 으로 다시 읽을 수도 있습니다.
+// X-Signature 가 있는데 맞지 않으면 401 과 감사 로그의 signature.rejected 로 거절합니다.
+// required 의 주소는 ("/" 로 끝나면 그 아래 모두) 서명이 없어도 거절합니다.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 요청 서명 설정
+type RequestSigningConfig struct {
+	Clients  map[string]string `json:"clients"`  // 클라이언트 이름 -> 비밀 키
+	Window   int               `json:"window"`   // 초. 시각이 이만큼까지 어긋난 요청을 받습니다.
+	MaxBody  int64             `json:"max_body"` // 서명을 확인하려고 읽는 본문의 최대 크기
+	Required []string          `json:"required"` // 서명이 꼭 있어야 하는 주소들
+}
+
+// 서명을 확인하고 쓴 nonce 를 기억합니다.
+type RequestVerifier struct {
+	config RequestSigningConfig
+
+	mu      sync.Mutex
+	clients map[string]string    // 비밀 키 파일(secrets.go)을 다시 읽으면 바뀝니다.
+	nonces  map[string]time.Time // 클라이언트 + nonce -> 잊어도 되는 시각
+}
+
+// 서버 전체가 쓰는 확인기. main에서 설정으로 만듭니다.
+var requestVerifier = NewRequestVerifier(DefaultConfig().RequestSigning)
+
+func NewRequestVerifier(config RequestSigningConfig) *RequestVerifier {
+	v := &RequestVerifier{config: config, clients: config.Clients, nonces: make(map[string]time.Time)}
+	// nonce 는 window 의 두 배 동안 기억하므로, window 마다 지난 것을 지우면
+	// 맵에는 최근 3 window 동안 받은 요청의 nonce 만 남습니다.
+	if config.Window > 0 {
+		go func() {
+			for now := range time.Tick(time.Duration(config.Window) * time.Second) {
+				v.prune(now)
+			}
+		}()
+	}
+	return v
+}
+
+// 클라이언트와 키를 바꿉니다. 이미 기억한 nonce 는 그대로 둡니다.
+func (v *RequestVerifier) SetClients(clients map[string]string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clients = clients
+}
+
+// 클라이언트가 하나라도 있는지. 없으면 서명을 보지 않습니다.
+func (v *RequestVerifier) enabled() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.clients) > 0
+}
+
+// name 클라이언트의 키
+func (v *RequestVerifier) clientKey(name string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.clients[name]
+	return key, ok
+}
+
+var (
+	errSignatureMissing = errors.New("request signature required")
+	errSignatureClient  = errors.New("unknown signing client")
+	errSignatureTime    = errors.New("request timestamp outside the allowed window")
+	errSignatureReplay  = errors.New("request nonce already used")
+	errSignatureInvalid = errors.New("invalid request signature")
+)
+
+type signedClientKey struct{}
+
+// 서명이 맞는 요청이면 클라이언트 이름, 아니면 ""
+func SignedClient(request *http.Request) string {
+	client, _ := request.Context().Value(signedClientKey{}).(string)
+	return client
+}
+
+// 서명할 문자열
+func signingString(method, uri, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])
+}
+
+// key로 만든 서명 (base64)
+func RequestSignature(key, method, uri, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	io.WriteString(mac, signingString(method, uri, timestamp, nonce, body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// request의 서명을 확인하고 클라이언트 이름을 돌려줍니다. body는 이미 읽어 둔 본문입니다.
+func (v *RequestVerifier) Verify(request *http.Request, body []byte) (string, error) {
+	client := request.Header.Get("X-Client-ID")
+	key, ok := v.clientKey(client)
+	if !ok {
+		return "", errSignatureClient
+	}
+	timestamp, nonce := request.Header.Get("X-Timestamp"), request.Header.Get("X-Nonce")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return client, errSignatureTime
+	}
+	now := time.Now()
+	window := time.Duration(v.config.Window) * time.Second
+	if skew := now.Sub(time.Unix(ts, 0)); skew > window || skew < -window {
+		return client, errSignatureTime
+	}
+	if nonce == "" || len(nonce) > 128 {
+		return client, errSignatureReplay
+	}
+	want := RequestSignature(key, request.Method, request.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal([]byte(want), []byte(request.Header.Get("X-Signature"))) {
+		return client, errSignatureInvalid
+	}
+	// 서명이 맞는 요청만 nonce 를 기억하므로, 서명 없이 nonce 자리를 채울 수는 없습니다.
+	if !v.remember(client+"\n"+nonce, now, 2*window) {
+		return client, errSignatureReplay
+	}
+	return client, nil
+}
+
+// 처음 보는 nonce 이면 ttl 동안 기억하고 true를 돌려줍니다.
+func (v *RequestVerifier) remember(nonce string, now time.Time, ttl time.Duration) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if until, ok := v.nonces[nonce]; ok && until.After(now) {
+		return false
+	}
+	v.nonces[nonce] = now.Add(ttl)
+	return true
+}
+
+// now 에 잊어도 되는 nonce 를 지웁니다.
+func (v *RequestVerifier) prune(now time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for nonce, until := range v.nonces {
+		if !until.After(now) {
+			delete(v.nonces, nonce)
+		}
+	}
+}
+
+// path에 서명이 꼭 있어야 하는지
+func (v *RequestVerifier) required(path string) bool {
+	for _, p := range v.config.Required {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 서명이 있는 요청을 v로 확인하고, 맞으면 클라이언트 이름을 요청에 붙여 next로 보냅니다.
+func RequestSigningHandler(v *RequestVerifier, next http.Handler) http.Handler {
+	config := v.config
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("X-Signature") == "" || !v.enabled() {
+			if v.required(request.URL.Path) {
+				audit.Record(request, "signature.rejected", "", map[string]string{"reason": errSignatureMissing.Error()})
+				WriteError(response, request, http.StatusUnauthorized, errSignatureMissing)
+				return
+			}
+			next.ServeHTTP(response, request)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(request.Body, config.MaxBody+1))
+		if err != nil {
+			WriteError(response, request, http.StatusBadRequest, fmt.Errorf("request body read error %v", err))
+			return
+		}
+		if int64(len(body)) > config.MaxBody {
+			WriteError(response, request, http.StatusRequestEntityTooLarge, nil)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		client, err := v.Verify(request, body)
+		if err != nil {
+			audit.Record(request, "signature.rejected", client, map[string]string{"reason": err.Error()})
+			WriteError(response, request, http.StatusUnauthorized, err)
+			return
+		}
+		next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), signedClientKey{}, client)))
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// key 로 서명한 POST 요청
+func signedRequest(key, path, body string, ts time.Time, nonce string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	request.Header.Set("X-Client-ID", "bot")
+	request.Header.Set("X-Timestamp", timestamp)
+	request.Header.Set("X-Nonce", nonce)
+	request.Header.Set("X-Signature", RequestSignature(key, http.MethodPost, path, timestamp, nonce, []byte(body)))
+	return request
+}
+
+func TestRequestSigningHandler(t *testing.T) {
+	v := NewRequestVerifier(RequestSigningConfig{
+		Clients:  map[string]string{"bot": "k3y"},
+		Window:   300,
+		MaxBody:  1 << 10,
+		Required: []string{"/items"},
+	})
+	handler := RequestSigningHandler(v, http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.Write([]byte(SignedClient(request)))
+	}))
+	now := time.Now()
+	tampered := signedRequest("k3y", "/items", `{"name":"x"}`, now, "n3")
+	tampered.Header.Set("X-Signature", signedRequest("k3y", "/items", `{"name":"y"}`, now, "n3").Header.Get("X-Signature"))
+	otherClient := signedRequest("k3y", "/items", "", now, "n4")
+	otherClient.Header.Set("X-Client-ID", "someone")
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"valid", signedRequest("k3y", "/items", `{"name":"x"}`, now, "n1"), http.StatusOK},
+		{"replayed nonce", signedRequest("k3y", "/items", `{"name":"x"}`, now, "n1"), http.StatusUnauthorized},
+		{"same nonce, other request", signedRequest("k3y", "/upload", "", now, "n1"), http.StatusUnauthorized},
+		{"wrong key", signedRequest("other", "/items", "", now, "n2"), http.StatusUnauthorized},
+		{"tampered body", tampered, http.StatusUnauthorized},
+		{"unknown client", otherClient, http.StatusUnauthorized},
+		{"too old", signedRequest("k3y", "/items", "", now.Add(-301*time.Second), "n5"), http.StatusUnauthorized},
+		{"too new", signedRequest("k3y", "/items", "", now.Add(301*time.Second), "n6"), http.StatusUnauthorized},
+		{"inside the window", signedRequest("k3y", "/items", "", now.Add(-290*time.Second), "n7"), http.StatusOK},
+		{"unsigned, required", httptest.NewRequest(http.MethodPost, "/items", nil), http.StatusUnauthorized},
+		{"unsigned, not required", httptest.NewRequest(http.MethodPost, "/upload", nil), http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, test.request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d (%s)", test.name, recorder.Code, test.status, strings.TrimSpace(recorder.Body.String()))
+		}
+		if recorder.Code == http.StatusOK && test.request.Header.Get("X-Signature") != "" && recorder.Body.String() != "bot" {
+			t.Errorf("%s: client %q, want %q", test.name, recorder.Body.String(), "bot")
+		}
+	}
+}
+
+func TestRequestVerifierPrune(t *testing.T) {
+	v := NewRequestVerifier(RequestSigningConfig{})
+	now := time.Now()
+	if !v.remember("bot\nold", now, time.Minute) || !v.remember("bot\nnew", now, time.Hour) {
+		t.Fatal("fresh nonce rejected")
+	}
+	v.prune(now.Add(2 * time.Minute))
+	if _, ok := v.nonces["bot\nold"]; ok {
+		t.Error("expired nonce kept")
+	}
+	if v.remember("bot\nnew", now.Add(2*time.Minute), time.Hour) {
+		t.Error("nonce forgotten before it expired")
+	}
+}
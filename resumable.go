@@ -0,0 +1,346 @@
+//
+// resumable.go
+//
+// 연결이 자주 끊기는 곳에서도 큰 파일을 올릴 수 있는 이어받기 업로드입니다.
+// tus 프로토콜(https://tus.io, core + creation)의 방식을 따릅니다.
+//
+//   1) 만들기   POST  /upload/resumable          Upload-Length: 1000000
+//                                                 Upload-Metadata: filename Y2F0LnBuZw==
+//               => 201 Created, Location: /upload/resumable/{uid}
+//   2) 보내기   PATCH /upload/resumable/{uid}     Upload-Offset: 0
+//                                                 Content-Type: application/offset+octet-stream
+//               => 204 No Content, Upload-Offset: 65536  (받은 만큼)
+//   3) 끊기면   HEAD  /upload/resumable/{uid}     => Upload-Offset: 65536  (여기서부터 다시 PATCH)
+//
+// 마지막 조각까지 받으면 /upload 와 같은 검사를 거쳐 파일 저장소로 옮기고,
+// 응답의 Upload-File-Id 헤더로 파일 ID를 알려줍니다.
+// 받는 중인 파일은 업로드 디렉토리의 .partial/ 에 있으므로 서버를 다시 시작해도 이어갈 수 있습니다.
+// 다 받은 업로드는 .partial/ 에서 지우므로 그 뒤의 HEAD 는 404 입니다.
+// resumable_ttl 초 동안 아무것도 받지 않은 업로드도 지웁니다. (0 이면 지우지 않습니다.)
+// PATCH의 진행 상황은 /upload/progress/{uid} 로 볼 수 있습니다. (progress.go)
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 서버가 따르는 tus 버전
+const tusVersion = "1.0.0"
+
+// 받는 중인 업로드 하나의 정보. .partial/{uid}.json 에 저장합니다.
+// 받은 바이트 수(offset)는 .partial/{uid} 파일의 크기입니다.
+type resumableUpload struct {
+	Length int64  `json:"length"`
+	Name   string `json:"name"`
+	Owner  string `json:"owner"`
+}
+
+// 오래된 업로드를 찾는 간격. resumable_ttl 이 더 짧으면 그 간격으로 찾습니다.
+const resumableSweepInterval = time.Hour
+
+// 이어받기 업로드 핸들러
+type ResumableHandler struct {
+	config UploadConfig
+	store  *FileStore
+	dir    string
+
+	mu   sync.Mutex
+	busy map[string]bool // PATCH를 받고 있는 업로드. 같은 업로드에 동시에 쓰지 않도록
+}
+
+// /upload/resumable 핸들러를 만듭니다.
+func NewResumableHandler(config UploadConfig, store *FileStore) (*ResumableHandler, error) {
+	dir := filepath.Join(store.dir, ".partial")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	h := &ResumableHandler{config: config, store: store, dir: dir, busy: make(map[string]bool)}
+	if config.ResumableTTL > 0 {
+		ttl := time.Duration(config.ResumableTTL) * time.Second
+		h.sweep(ttl)
+		go func() {
+			for range time.Tick(min(ttl, resumableSweepInterval)) {
+				h.sweep(ttl)
+			}
+		}()
+	}
+	return h, nil
+}
+
+func (h *ResumableHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Tus-Resumable", tusVersion)
+	uid := strings.Trim(strings.TrimPrefix(request.URL.Path, "/upload/resumable"), "/")
+
+	switch {
+	case request.Method == http.MethodOptions:
+		response.Header().Set("Tus-Version", tusVersion)
+		response.Header().Set("Tus-Extension", "creation")
+		response.Header().Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxFileSize, 10))
+		response.WriteHeader(http.StatusNoContent)
+	case uid == "" && request.Method == http.MethodPost:
+		h.create(response, request)
+	case uid != "" && validFileID(uid) && request.Method == http.MethodHead:
+		h.head(response, request, uid)
+	case uid != "" && validFileID(uid) && request.Method == http.MethodPatch:
+		h.patch(response, request, uid)
+	case uid != "" && !validFileID(uid):
+		WriteError(response, request, http.StatusNotFound, nil)
+	default:
+		response.Header().Set("Allow", "OPTIONS, POST, HEAD, PATCH")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// POST /upload/resumable : 새 업로드를 만듭니다.
+func (h *ResumableHandler) create(response http.ResponseWriter, request *http.Request) {
+	length, err := strconv.ParseInt(request.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		WriteError(response, request, http.StatusBadRequest, fmt.Errorf("resumable: bad Upload-Length"))
+		return
+	}
+	if length > h.config.MaxFileSize {
+		WriteError(response, request, http.StatusRequestEntityTooLarge, fmt.Errorf("resumable: %d bytes", length))
+		return
+	}
+	upload := resumableUpload{Length: length, Name: tusMetadata(request.Header.Get("Upload-Metadata"))["filename"], Owner: RequestOwner(request)}
+	if h.config.Quota > 0 && length > h.config.Quota {
+		WriteError(response, request, http.StatusRequestEntityTooLarge, fmt.Errorf("resumable: %d bytes is over quota", length))
+		return
+	}
+	if remaining := h.config.remaining(h.store, upload.Owner); remaining >= 0 && length > remaining {
+		WriteError(response, request, http.StatusInsufficientStorage, fmt.Errorf("resumable %s: %w", upload.Owner, ErrQuotaExceeded))
+		return
+	}
+	if upload.Name == "" {
+		upload.Name = "upload"
+	}
+	// 내용은 다 받은 뒤에 검사하지만, 확장자는 미리 알 수 있습니다.
+	if !h.config.allowedExtension(upload.Name) {
+		WriteError(response, request, http.StatusUnsupportedMediaType, fmt.Errorf("resumable: %s", upload.Name))
+		return
+	}
+
+	uid := newFileID()
+	if err := os.WriteFile(h.path(uid), nil, 0o644); err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.save(uid, upload); err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	response.Header().Set("Location", "/upload/resumable/"+uid)
+	response.Header().Set("Upload-Offset", "0")
+	response.WriteHeader(http.StatusCreated)
+}
+
+// HEAD /upload/resumable/{uid} : 지금까지 받은 바이트 수를 알려줍니다.
+func (h *ResumableHandler) head(response http.ResponseWriter, request *http.Request, uid string) {
+	upload, offset, err := h.load(uid)
+	if err != nil {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	response.Header().Set("Cache-Control", "no-store")
+	response.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	response.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	response.WriteHeader(http.StatusOK)
+}
+
+// PATCH /upload/resumable/{uid} : Upload-Offset 부터 이어서 받습니다.
+func (h *ResumableHandler) patch(response http.ResponseWriter, request *http.Request, uid string) {
+	if request.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		WriteError(response, request, http.StatusUnsupportedMediaType, nil)
+		return
+	}
+	if !h.lock(uid) {
+		WriteError(response, request, http.StatusConflict, fmt.Errorf("resumable %s: already receiving", uid))
+		return
+	}
+	defer h.unlock(uid)
+
+	upload, offset, err := h.load(uid)
+	if err != nil {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	if request.Header.Get("Upload-Offset") != strconv.FormatInt(offset, 10) {
+		// 클라이언트는 HEAD로 offset을 다시 물어봐야 합니다.
+		WriteError(response, request, http.StatusConflict, fmt.Errorf("resumable %s: offset mismatch", uid))
+		return
+	}
+
+	file, err := os.OpenFile(h.path(uid), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	// 연결이 중간에 끊겨도 받은 만큼은 남기고, 다음 PATCH가 거기서부터 이어갑니다.
+	// Length를 넘는 내용은 받지 않습니다.
+	body, finish := uploadProgresses.Track(uid, offset, upload.Length, io.LimitReader(request.Body, upload.Length-offset))
+	n, copyErr := io.Copy(file, body)
+	finish()
+	if err := file.Close(); copyErr == nil {
+		copyErr = err
+	}
+	offset += n
+	response.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if copyErr != nil {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("resumable %s: %v", uid, copyErr))
+		return
+	}
+
+	if offset == upload.Length {
+		f, err := h.finish(request.Context(), uid, upload)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var uerr *uploadError
+			if errors.As(err, &uerr) {
+				status = uerr.status
+			}
+			WriteError(response, request, status, fmt.Errorf("resumable %s: %v", uid, err))
+			return
+		}
+		response.Header().Set("Upload-File-Id", f.ID)
+		QueueThumbnails(h.store, f, h.config.ThumbnailSizes)
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// 다 받은 파일을 검사하고 저장소로 옮깁니다. 성공해도 실패해도 .partial/ 의 파일과 정보를 지웁니다.
+func (h *ResumableHandler) finish(ctx context.Context, uid string, upload resumableUpload) (StoredFile, error) {
+	file, err := os.Open(h.path(uid))
+	if err != nil {
+		return StoredFile{}, err
+	}
+	head, _ := bufio.NewReaderSize(file, 512).Peek(512)
+	file.Close()
+	contentType := http.DetectContentType(head)
+	if !h.config.allowed(upload.Name, contentType) {
+		h.remove(uid)
+		return StoredFile{}, &uploadError{http.StatusUnsupportedMediaType, fmt.Errorf("%s: type %s not allowed", upload.Name, contentType)}
+	}
+
+	f, err := h.store.Import(ctx, h.path(uid), upload.Owner, upload.Name, contentType)
+	// 옮긴 업로드와, 검사에서 격리되었거나 옮기지 못한 업로드는 이어받을 수 없습니다.
+	h.remove(uid)
+	if err != nil {
+		return f, uploadStatus(err)
+	}
+	return f, nil
+}
+
+// 받는 중인 파일의 경로
+func (h *ResumableHandler) path(uid string) string {
+	return filepath.Join(h.dir, uid)
+}
+
+func (h *ResumableHandler) save(uid string, upload resumableUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path(uid)+".json", data, 0o644)
+}
+
+// 업로드 정보와 지금까지 받은 바이트 수
+func (h *ResumableHandler) load(uid string) (resumableUpload, int64, error) {
+	var upload resumableUpload
+	data, err := os.ReadFile(h.path(uid) + ".json")
+	if err != nil {
+		return upload, 0, err
+	}
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return upload, 0, err
+	}
+	info, err := os.Stat(h.path(uid))
+	if err != nil {
+		return upload, 0, err
+	}
+	return upload, info.Size(), nil
+}
+
+func (h *ResumableHandler) remove(uid string) {
+	os.Remove(h.path(uid))
+	os.Remove(h.path(uid) + ".json")
+}
+
+// ttl 동안 아무것도 받지 않은 업로드를 지웁니다. PATCH 를 받고 있는 업로드는 건너뜁니다.
+func (h *ResumableHandler) sweep(ttl time.Duration) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		log.Printf("WARN resumable sweep: %v", err)
+		return
+	}
+	// 업로드 하나에 {uid} 와 {uid}.json 두 파일이 있습니다. 둘 중 늦게 바뀐 것을 봅니다.
+	modified := make(map[string]time.Time)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		uid := strings.TrimSuffix(entry.Name(), ".json")
+		if info.ModTime().After(modified[uid]) {
+			modified[uid] = info.ModTime()
+		}
+	}
+	removed := 0
+	for uid, t := range modified {
+		if time.Since(t) < ttl || !validFileID(uid) || !h.lock(uid) {
+			continue
+		}
+		h.remove(uid)
+		h.unlock(uid)
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("resumable: removed %d uploads idle for over %v", removed, ttl)
+	}
+}
+
+func (h *ResumableHandler) lock(uid string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.busy[uid] {
+		return false
+	}
+	h.busy[uid] = true
+	return true
+}
+
+func (h *ResumableHandler) unlock(uid string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.busy, uid)
+}
+
+// Upload-Metadata 헤더를 읽습니다. "key base64값,key2 base64값2"
+func tusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err == nil {
+			metadata[key] = string(decoded)
+		}
+	}
+	return metadata
+}
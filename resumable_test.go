@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestResumable(t *testing.T) *ResumableHandler {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewResumableHandler(UploadConfig{MaxFileSize: 1000}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// h 에 요청을 보내고 응답을 돌려줍니다. header 는 이름, 값을 번갈아 씁니다.
+func tusDo(h *ResumableHandler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		request.Header.Set(header[i], header[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// length 바이트짜리 a.txt 업로드를 만들고 주소를 돌려줍니다.
+func tusCreate(t *testing.T, h *ResumableHandler, length string) string {
+	t.Helper()
+	recorder := tusDo(h, http.MethodPost, "/upload/resumable", "",
+		"Upload-Length", length, "Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("a.txt")))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d", recorder.Code)
+	}
+	return recorder.Header().Get("Location")
+}
+
+func tusPatch(h *ResumableHandler, location, offset, body string) *httptest.ResponseRecorder {
+	return tusDo(h, http.MethodPatch, location, body, "Upload-Offset", offset, "Content-Type", "application/offset+octet-stream")
+}
+
+func TestResumableUpload(t *testing.T) {
+	h := newTestResumable(t)
+	location := tusCreate(t, h, "11")
+
+	if recorder := tusPatch(h, location, "0", "hello "); recorder.Code != http.StatusNoContent || recorder.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("first PATCH: status %d, offset %q", recorder.Code, recorder.Header().Get("Upload-Offset"))
+	}
+	// 끊긴 뒤에는 HEAD 로 받은 만큼을 묻고 거기서부터 보냅니다.
+	if recorder := tusDo(h, http.MethodHead, location, ""); recorder.Code != http.StatusOK || recorder.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("HEAD: status %d, offset %q", recorder.Code, recorder.Header().Get("Upload-Offset"))
+	}
+	for _, offset := range []string{"0", "5", "7", ""} {
+		if recorder := tusPatch(h, location, offset, "world"); recorder.Code != http.StatusConflict {
+			t.Errorf("PATCH at offset %q: status %d, want %d", offset, recorder.Code, http.StatusConflict)
+		}
+	}
+	if recorder := tusDo(h, http.MethodHead, location, ""); recorder.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("offset %q after mismatched PATCHes, want 6", recorder.Header().Get("Upload-Offset"))
+	}
+
+	recorder := tusPatch(h, location, "6", "world and more")
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Upload-Offset") != "11" {
+		t.Fatalf("last PATCH: status %d, offset %q", recorder.Code, recorder.Header().Get("Upload-Offset"))
+	}
+	file, _, err := h.store.Open(recorder.Header().Get("Upload-File-Id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "hello world" {
+		t.Errorf("stored %q, want %q", data, "hello world")
+	}
+	if recorder := tusDo(h, http.MethodHead, location, ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("HEAD after finish: status %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestResumableSweep(t *testing.T) {
+	h := newTestResumable(t)
+	idle, busy, fresh := tusCreate(t, h, "10"), tusCreate(t, h, "10"), tusCreate(t, h, "10")
+	old := time.Now().Add(-2 * time.Hour)
+	for _, location := range []string{idle, busy} {
+		path := h.path(strings.TrimPrefix(location, "/upload/resumable/"))
+		for _, name := range []string{path, path + ".json"} {
+			if err := os.Chtimes(name, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// PATCH 를 받고 있는 업로드는 오래되었어도 지우지 않습니다.
+	h.lock(strings.TrimPrefix(busy, "/upload/resumable/"))
+	h.sweep(time.Hour)
+
+	for location, status := range map[string]int{idle: http.StatusNotFound, busy: http.StatusOK, fresh: http.StatusOK} {
+		if recorder := tusDo(h, http.MethodHead, location, ""); recorder.Code != status {
+			t.Errorf("HEAD %s: status %d, want %d", location, recorder.Code, status)
+		}
+	}
+}
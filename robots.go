@@ -0,0 +1,52 @@
+//
+// robots.go
+//
+// /robots.txt 를 설정 파일로 정합니다. docroot에 파일을 두지 않아도 됩니다.
+//
+//   "robots": {"mode": "allow"}                                     모든 크롤러 허용 (기본값)
+//   "robots": {"mode": "deny"}                                      모든 크롤러 거부 (스테이징 서버 등)
+//   "robots": {"mode": "custom", "body": "User-agent: *\nDisallow: /items\n"}
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// robots.txt 설정
+type RobotsConfig struct {
+	Mode string `json:"mode"` // "allow", "deny", "custom"
+	Body string `json:"body"` // mode가 "custom"일 때의 내용
+}
+
+const (
+	robotsAllowAll = "User-agent: *\nDisallow:\n"
+	robotsDenyAll  = "User-agent: *\nDisallow: /\n"
+)
+
+// 설정에 맞는 robots.txt 내용을 만듭니다.
+func (c RobotsConfig) body() (string, error) {
+	switch c.Mode {
+	case "", "allow":
+		return robotsAllowAll, nil
+	case "deny":
+		return robotsDenyAll, nil
+	case "custom":
+		return c.Body, nil
+	}
+	return "", fmt.Errorf("robots: unknown mode %q", c.Mode)
+}
+
+// /robots.txt 에 대한 응답을 만듭니다. 내용은 시작할 때 한 번만 정합니다.
+func NewRobotsHandler(config RobotsConfig) (http.Handler, error) {
+	body, err := config.body()
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		SetContentType(response, "text/plain")
+		response.Header().Set("Cache-Control", "public, max-age=3600")
+		fmt.Fprint(response, body)
+	}), nil
+}
@@ -0,0 +1,147 @@
+//
+// runtimestats.go
+//
+// 대시보드가 자주 가져가기 좋게 Go 런타임의 상태를 /debug/runtime 에서 JSON으로 보여줍니다.
+// pprof 보다 훨씬 가볍습니다.
+//
+//   $ curl localhost:8080/debug/runtime
+//   {"goroutines":12,"heap":{"alloc":2408448,...},"gc":{"count":3,"recent_pauses_ms":[0.05,0.04,0.06]},
+//    "connections":{"new":0,"active":1,"idle":2,"realtime":1}}
+//
+// 열린 연결 수는 http.Server.ConnState 로 셉니다. (main 에서 connections.Track 을 넣습니다)
+// WebSocket 처럼 서버에서 떼어 간(hijack) 연결은 닫혀도 알 수 없으므로 presence.go 의 수를 씁니다.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// 응답에 넣는 최근 GC 멈춤 시간의 수
+const recentGCPauses = 16
+
+// 상태별 열린 연결 수
+type ConnectionCounts struct {
+	New      int `json:"new"`
+	Active   int `json:"active"`
+	Idle     int `json:"idle"`
+	Realtime int `json:"realtime"` // WebSocket, SSE (presence.go)
+}
+
+// 서버의 연결들의 상태를 셉니다.
+type ConnTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+// 서버 전체가 쓰는 연결 수. main에서 http.Server.ConnState 로 넣습니다.
+var connections = &ConnTracker{conns: make(map[net.Conn]http.ConnState)}
+
+// http.Server.ConnState 에 넣는 함수
+func (t *ConnTracker) Track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state == http.StateClosed || state == http.StateHijacked {
+		delete(t.conns, conn)
+		return
+	}
+	t.conns[conn] = state
+}
+
+// 지금의 상태별 연결 수
+func (t *ConnTracker) Counts() ConnectionCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var counts ConnectionCounts
+	for _, state := range t.conns {
+		switch state {
+		case http.StateNew:
+			counts.New++
+		case http.StateActive:
+			counts.Active++
+		case http.StateIdle:
+			counts.Idle++
+		}
+	}
+	counts.Realtime = presence.Report().Total
+	return counts
+}
+
+// /debug/runtime 의 응답 본문. 크기는 바이트입니다.
+type RuntimeStats struct {
+	Goroutines  int              `json:"goroutines"`
+	CPUs        int              `json:"cpus"`
+	GOMAXPROCS  int              `json:"gomaxprocs"`
+	MemoryLimit int64            `json:"memory_limit,omitempty"` // GC 목표 (container.go)
+	Heap        HeapStats        `json:"heap"`
+	GC          GCStats          `json:"gc"`
+	Connections ConnectionCounts `json:"connections"`
+}
+
+type HeapStats struct {
+	Alloc    uint64 `json:"alloc"`    // 쓰고 있는 heap
+	Sys      uint64 `json:"sys"`      // OS에서 받은 heap
+	Idle     uint64 `json:"idle"`     // 비어 있는 span
+	Released uint64 `json:"released"` // OS에 돌려준 것
+	Objects  uint64 `json:"objects"`
+}
+
+type GCStats struct {
+	Count        uint32     `json:"count"`
+	Last         *time.Time `json:"last,omitempty"`
+	PauseTotal   float64    `json:"pause_total_ms"`
+	RecentPauses []float64  `json:"recent_pauses_ms"` // 최근 것부터
+	NextGC       uint64     `json:"next_gc"`          // 다음 GC가 일어날 heap 크기
+	CPUFraction  float64    `json:"cpu_fraction"`     // 시작한 뒤로 GC가 쓴 CPU 비율
+	Forced       uint32     `json:"forced"`           // runtime.GC() 로 일으킨 수
+}
+
+// 지금의 런타임 상태. runtime.ReadMemStats 는 잠깐 모든 goroutine을 멈춥니다.
+func ReadRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	gc := GCStats{
+		Count:        m.NumGC,
+		PauseTotal:   milliseconds(time.Duration(m.PauseTotalNs)),
+		NextGC:       m.NextGC,
+		CPUFraction:  m.GCCPUFraction,
+		Forced:       m.NumForcedGC,
+		RecentPauses: []float64{},
+	}
+	if m.LastGC > 0 {
+		last := time.Unix(0, int64(m.LastGC))
+		gc.Last = &last
+	}
+	// PauseNs 는 256칸의 원형 버퍼이고 가장 최근 것은 (NumGC+255)%256 에 있습니다.
+	for i := uint32(0); i < m.NumGC && i < recentGCPauses; i++ {
+		pause := m.PauseNs[(m.NumGC-i+255)%256]
+		gc.RecentPauses = append(gc.RecentPauses, milliseconds(time.Duration(pause)))
+	}
+	return RuntimeStats{
+		Goroutines:  runtime.NumGoroutine(),
+		CPUs:        runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		MemoryLimit: currentMemoryLimit(),
+		Heap: HeapStats{
+			Alloc:    m.HeapAlloc,
+			Sys:      m.HeapSys,
+			Idle:     m.HeapIdle,
+			Released: m.HeapReleased,
+			Objects:  m.HeapObjects,
+		},
+		GC:          gc,
+		Connections: connections.Counts(),
+	}
+}
+
+// GET /debug/runtime
+func RuntimeStatsHandler(response http.ResponseWriter, request *http.Request) {
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(ReadRuntimeStats())
+}
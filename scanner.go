@@ -0,0 +1,84 @@
+//
+// scanner.go
+//
+// 업로드가 끝난 파일을 내려받을 수 있게 되기 전에 검사하는 훅입니다.
+// 바이러스 검사기(ClamAV 등)나 직접 만든 검사를 Scanner 로 끼워 넣습니다.
+//
+// 검사에서 거절된 파일은 지우지 않고 업로드 디렉토리의 .quarantine/ 으로 옮깁니다.
+// 저장소 목록에는 들어가지 않으므로 내려받을 수 없습니다.
+//
+// 설정 파일에서 외부 명령으로 검사할 수 있습니다. 파일 내용은 명령의 표준 입력으로 갑니다.
+// 종료 코드가 0이면 통과, 1이면 거절, 그 밖에는 검사 실패입니다. (clamdscan과 같음)
+//
+//   "upload": {"scan_command": ["clamdscan", "--no-summary", "-"]}
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 검사 명령이 끝나기를 기다리는 최대 시간
+const scanTimeout = 2 * time.Minute
+
+// 업로드된 파일 하나를 검사합니다.
+// 파일을 거절하려면 *ScanRejectedError 를, 검사 자체가 실패하면 다른 에러를 돌려줍니다.
+// 두 경우 모두 파일은 격리됩니다.
+type Scanner interface {
+	Scan(ctx context.Context, f StoredFile, content io.Reader) error
+}
+
+// 함수를 Scanner로 씁니다.
+type ScannerFunc func(ctx context.Context, f StoredFile, content io.Reader) error
+
+func (fn ScannerFunc) Scan(ctx context.Context, f StoredFile, content io.Reader) error {
+	return fn(ctx, f, content)
+}
+
+// 검사에서 거절된 파일
+type ScanRejectedError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ScanRejectedError) Error() string {
+	return fmt.Sprintf("%s rejected by scanner: %s", e.Name, e.Reason)
+}
+
+// 외부 명령으로 검사하는 Scanner
+type CommandScanner struct {
+	Command []string
+}
+
+func (s CommandScanner) Scan(ctx context.Context, f StoredFile, content io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = content
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return &ScanRejectedError{Name: f.Name, Reason: strings.TrimSpace(output.String())}
+	}
+	if err != nil {
+		return fmt.Errorf("scan %s: %v %s", f.Name, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// 설정의 scan_command 로 Scanner를 만듭니다. 비어 있으면 nil (검사하지 않음)
+func NewScanner(config UploadConfig) Scanner {
+	if len(config.ScanCommand) == 0 {
+		return nil
+	}
+	return CommandScanner{Command: config.ScanCommand}
+}
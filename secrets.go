@@ -0,0 +1,150 @@
+//
+// secrets.go
+//
+// 쿠키 키, API 키, 요청 서명 키를 설정 파일에 적지 않고 따로 둔 비밀 키 파일이나 환경 변수에서 읽습니다.
+//
+//   "secrets": {"file": "/run/secrets/webserver.json", "env": "WEBSERVER_SECRETS"}
+//
+// 비밀 키 파일과 환경 변수의 값은 같은 JSON 형식입니다.
+//
+//   {
+//     "cookie_keys": [{"hash": "new-secret", "block": "new-block"}, {"hash": "old-secret"}],
+//     "api_keys": {"s3cret": "alice", "n3w-s3cret": "alice"},
+//     "signing_clients": {"build-bot": "k3y..."}
+//   }
+//
+//   $ WEBSERVER_SECRETS='{"api_keys":{"s3cret":"alice"}}' go run .
+//
+// 설정 파일에도 같은 키가 있으면 함께 씁니다. 쿠키 키는 비밀 키의 것이 앞에 오므로 새 쿠키는 그 키로 만듭니다.
+// 모든 키는 여러 개를 동시에 쓸 수 있습니다. 키를 바꿀 때는 (securecookie.go, apikeys.go)
+//
+//   1. 새 키를 맨 앞에 넣고 SIGHUP 을 보냅니다.    $ kill -HUP $(pidof webserver)
+//   2. 옛 키로 만든 쿠키와 클라이언트가 모두 바뀐 뒤에 옛 키를 지우고 다시 SIGHUP 을 보냅니다.
+//
+// SIGHUP 을 받으면 비밀 키 파일을 다시 읽습니다. 파일이 잘못되었으면 로그를 남기고 지금 키를 그대로 씁니다.
+// 환경 변수는 실행 중에 바뀌지 않으므로 시작할 때의 값을 계속 씁니다.
+// 비밀 키 파일은 서버를 실행하는 사용자만 읽을 수 있어야 합니다. (chmod 600) 다른 사람도 읽을 수 있으면 경고합니다.
+//
+// JWT 는 아직 이 서버에 없으므로 여기서 읽는 키도 없습니다.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 비밀 키를 읽어 올 곳
+type SecretsConfig struct {
+	File string `json:"file"` // 비밀 키 파일. SIGHUP 으로 다시 읽습니다.
+	Env  string `json:"env"`  // 비밀 키 JSON 을 담은 환경 변수 이름
+}
+
+// 비밀 키 파일의 내용
+type Secrets struct {
+	CookieKeys     []CookieKey       `json:"cookie_keys"`
+	APIKeys        map[string]string `json:"api_keys"`        // API 키 -> 이름
+	SigningClients map[string]string `json:"signing_clients"` // 클라이언트 이름 -> 서명 키. requestsign.go
+}
+
+// cookieCodec 과 apiKeys 를 바꾸는 동안 요청이 읽지 못하게 합니다.
+var secretsMu sync.RWMutex
+
+// 지금 쓰는 쿠키 코덱
+func currentCookieCodec() *CookieCodec {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return cookieCodec
+}
+
+// 지금 쓰는 API 키들
+func currentAPIKeys() map[string]string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return apiKeys
+}
+
+// config의 환경 변수와 파일에서 비밀 키를 읽습니다. 둘 다 없으면 빈 Secrets
+func LoadSecrets(config SecretsConfig) (Secrets, error) {
+	var secrets Secrets
+	if config.Env != "" {
+		if value := os.Getenv(config.Env); value != "" {
+			if err := json.Unmarshal([]byte(value), &secrets); err != nil {
+				return Secrets{}, fmt.Errorf("$%s: %v", config.Env, err)
+			}
+		}
+	}
+	if config.File == "" {
+		return secrets, nil
+	}
+	if info, err := os.Stat(config.File); err == nil && info.Mode().Perm()&0077 != 0 {
+		log.Printf("WARN secrets: %s is readable by other users (%v)", config.File, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(config.File)
+	if err != nil {
+		return Secrets{}, err
+	}
+	var file Secrets
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Secrets{}, fmt.Errorf("%s: %v", config.File, err)
+	}
+	// 파일의 쿠키 키가 더 자주 바뀌므로 앞에 둡니다.
+	secrets.CookieKeys = append(file.CookieKeys, secrets.CookieKeys...)
+	secrets.APIKeys = mergeKeys(secrets.APIKeys, file.APIKeys)
+	secrets.SigningClients = mergeKeys(secrets.SigningClients, file.SigningClients)
+	return secrets, nil
+}
+
+// 두 키 목록을 합친 새 맵. 같은 키는 over의 것을 씁니다.
+func mergeKeys(base, over map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		merged[k] = v
+	}
+	return merged
+}
+
+// 설정 파일의 키와 secrets를 합쳐 서버가 쓰는 키들을 바꿉니다.
+// 쿠키 키가 잘못되었으면 아무것도 바꾸지 않고 에러를 돌려줍니다.
+func ApplySecrets(config Config, secrets Secrets) error {
+	keys := append(append([]CookieKey(nil), secrets.CookieKeys...), config.Cookies.Keys...)
+	codec, err := NewCookieCodec(keys, time.Duration(config.Cookies.MaxAge)*time.Second)
+	if err != nil {
+		return fmt.Errorf("cookie keys: %v", err)
+	}
+	secretsMu.Lock()
+	cookieCodec = codec
+	apiKeys = mergeKeys(config.APIKeys, secrets.APIKeys)
+	secretsMu.Unlock()
+	requestVerifier.SetClients(mergeKeys(config.RequestSigning.Clients, secrets.SigningClients))
+	return nil
+}
+
+// SIGHUP 을 받을 때마다 비밀 키를 다시 읽습니다.
+func ReloadSecretsOnSignal(config Config) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			secrets, err := LoadSecrets(config.Secrets)
+			if err == nil {
+				err = ApplySecrets(config, secrets)
+			}
+			if err != nil {
+				log.Printf("ERROR secrets reload: %v (keeping the current keys)", err)
+				continue
+			}
+			log.Printf("secrets reloaded: %d cookie keys, %d api keys, %d signing clients",
+				len(secrets.CookieKeys), len(secrets.APIKeys), len(secrets.SigningClients))
+		}
+	}()
+}
@@ -0,0 +1,187 @@
+//
+// securecookie.go
+//
+// 클라이언트가 값을 바꾸지 못하는 쿠키입니다. 세션과 설정(언어 등) 쿠키에 씁니다.
+//
+//   Set-Cookie: lang=AAAAAGkXk8kia28i.2nqhc2m1o1tN...; Path=/; HttpOnly; SameSite=Lax
+//
+// 값은 "본문.서명" 입니다. 본문은 만든 시각과 JSON 값이고, 서명은 쿠키 이름과 본문의 HMAC-SHA256 입니다.
+// 그래서 값을 고치거나 다른 이름의 쿠키로 옮겨 붙이면 서명이 맞지 않습니다.
+// block 키가 있으면 JSON 값을 AES-GCM 으로 암호화하므로 클라이언트가 내용을 읽을 수도 없습니다.
+//
+//   "cookies": {"keys": [{"hash": "new-secret", "block": "new-block"},
+//                        {"hash": "old-secret"}]}
+//
+// 쿠키는 첫 번째 키로 만들고, 읽을 때는 모든 키를 차례로 맞춰 봅니다. 키를 바꿀 때는 새 키를
+// 맨 앞에 넣고 옛 키를 잠시 남겨 두면 이미 나간 쿠키도 계속 읽을 수 있습니다. (키 교체)
+// block 은 아무 길이의 글자여도 되고, SHA-256 으로 AES-256 키를 만듭니다.
+//
+// 키는 설정 파일 대신 비밀 키 파일이나 환경 변수(secrets.go)에 둘 수 있습니다.
+// "keys" 를 주지 않으면 시작할 때마다 무작위 키를 만들므로 재시작하면 쿠키가 무효가 됩니다.
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 쿠키 서명과 암호화에 쓰는 키 한 쌍
+type CookieKey struct {
+	Hash  string `json:"hash"`  // 서명 키. 반드시 있어야 합니다.
+	Block string `json:"block"` // 암호화 키. 비어 있으면 암호화하지 않습니다.
+}
+
+// 쿠키 하나에 담을 수 있는 최대 크기. 브라우저는 보통 4096 바이트까지 받습니다.
+const maxCookieSize = 4096
+
+// 쿠키 값이 없거나 틀렸을 때의 에러
+var (
+	ErrCookieInvalid = errors.New("cookie: invalid value")
+	ErrCookieExpired = errors.New("cookie: expired")
+)
+
+type cookieKey struct {
+	hash  []byte
+	block cipher.AEAD // nil이면 암호화하지 않습니다.
+}
+
+// 쿠키 값을 만들고 확인합니다.
+type CookieCodec struct {
+	keys   []cookieKey // 첫 번째로 만들고, 모두로 읽습니다.
+	maxAge time.Duration
+}
+
+// 서버 전체가 쓰는 쿠키 코덱. main에서 설정으로 만들고, 비밀 키를 다시 읽으면(secrets.go) 바뀝니다.
+var cookieCodec, _ = NewCookieCodec(nil, 0)
+
+// keys로 CookieCodec을 만듭니다. keys가 비어 있으면 무작위 서명 키를 씁니다.
+// maxAge보다 오래된 쿠키는 읽지 않습니다. 0이면 시각을 보지 않습니다.
+func NewCookieCodec(keys []CookieKey, maxAge time.Duration) (*CookieCodec, error) {
+	c := &CookieCodec{maxAge: maxAge}
+	if len(keys) == 0 {
+		random := make([]byte, 32)
+		rand.Read(random)
+		c.keys = []cookieKey{{hash: random}}
+		return c, nil
+	}
+	for i, key := range keys {
+		if key.Hash == "" {
+			return nil, fmt.Errorf("keys[%d]: hash is empty", i)
+		}
+		k := cookieKey{hash: []byte(key.Hash)}
+		if key.Block != "" {
+			sum := sha256.Sum256([]byte(key.Block))
+			block, err := aes.NewCipher(sum[:])
+			if err != nil {
+				return nil, err
+			}
+			if k.block, err = cipher.NewGCM(block); err != nil {
+				return nil, err
+			}
+		}
+		c.keys = append(c.keys, k)
+	}
+	return c, nil
+}
+
+// name 쿠키의 본문에 대한 서명
+func (k cookieKey) signature(name string, body []byte) []byte {
+	mac := hmac.New(sha256.New, k.hash)
+	fmt.Fprintf(mac, "%s\n", name)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// value를 JSON으로 바꿔 name 쿠키의 값을 만듭니다.
+func (c *CookieCodec) Encode(name string, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	key := c.keys[0]
+	if key.block != nil {
+		nonce := make([]byte, key.block.NonceSize())
+		rand.Read(nonce)
+		// 이름을 추가 데이터로 넣어 다른 쿠키로 옮겨도 풀리지 않게 합니다.
+		data = key.block.Seal(nonce, nonce, data, []byte(name))
+	}
+	body := binary.BigEndian.AppendUint64(nil, uint64(time.Now().Unix()))
+	body = append(body, data...)
+	encoded := base64.RawURLEncoding.EncodeToString(body) + "." +
+		base64.RawURLEncoding.EncodeToString(key.signature(name, body))
+	if len(name)+1+len(encoded) > maxCookieSize {
+		return "", fmt.Errorf("cookie %s: value is too large", name)
+	}
+	return encoded, nil
+}
+
+// name 쿠키의 값을 확인하고 JSON을 value에 풉니다.
+func (c *CookieCodec) Decode(name, encoded string, value interface{}) error {
+	b64, b64sig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return ErrCookieInvalid
+	}
+	body, err := base64.RawURLEncoding.DecodeString(b64)
+	if err != nil || len(body) < 8 {
+		return ErrCookieInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(b64sig)
+	if err != nil {
+		return ErrCookieInvalid
+	}
+	for _, key := range c.keys {
+		if !hmac.Equal(sig, key.signature(name, body)) {
+			continue
+		}
+		created := time.Unix(int64(binary.BigEndian.Uint64(body)), 0)
+		if c.maxAge > 0 && time.Since(created) > c.maxAge {
+			return ErrCookieExpired
+		}
+		data := body[8:]
+		if key.block != nil {
+			size := key.block.NonceSize()
+			if len(data) < size {
+				return ErrCookieInvalid
+			}
+			if data, err = key.block.Open(nil, data[:size], data[size:], []byte(name)); err != nil {
+				return ErrCookieInvalid
+			}
+		}
+		if err := json.Unmarshal(data, value); err != nil {
+			return ErrCookieInvalid
+		}
+		return nil
+	}
+	return ErrCookieInvalid
+}
+
+// value를 담은 name 쿠키를 응답에 붙입니다. 속성은 cookieConfig 를 따릅니다. (cookie.go)
+func SetSecureCookie(response http.ResponseWriter, name string, value interface{}) error {
+	encoded, err := currentCookieCodec().Encode(name, value)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(response, NewCookie(name, encoded))
+	return nil
+}
+
+// 요청의 name 쿠키를 확인하고 value에 풉니다. 쿠키가 없으면 http.ErrNoCookie
+func ReadSecureCookie(request *http.Request, name string, value interface{}) error {
+	cookie, err := request.Cookie(name)
+	if err != nil {
+		return err
+	}
+	return currentCookieCodec().Decode(name, cookie.Value, value)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustCookieCodec(t *testing.T, maxAge time.Duration, keys ...CookieKey) *CookieCodec {
+	t.Helper()
+	c, err := NewCookieCodec(keys, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// created 에 만든 것처럼 서명한 쿠키 값. (암호화하지 않는 키만)
+func encodeCookieAt(c *CookieCodec, name string, value interface{}, created time.Time) string {
+	data, _ := json.Marshal(value)
+	body := binary.BigEndian.AppendUint64(nil, uint64(created.Unix()))
+	body = append(body, data...)
+	return base64.RawURLEncoding.EncodeToString(body) + "." +
+		base64.RawURLEncoding.EncodeToString(c.keys[0].signature(name, body))
+}
+
+// 본문을 fn 으로 고치고 같은 키로 다시 서명한 값. 서명은 맞지만 내용이 바뀐 쿠키입니다.
+func resignCookie(c *CookieCodec, name, encoded string, fn func(body []byte)) string {
+	b64, _, _ := strings.Cut(encoded, ".")
+	body, _ := base64.RawURLEncoding.DecodeString(b64)
+	fn(body)
+	return base64.RawURLEncoding.EncodeToString(body) + "." +
+		base64.RawURLEncoding.EncodeToString(c.keys[0].signature(name, body))
+}
+
+func TestCookieCodec(t *testing.T) {
+	oldKey := CookieKey{Hash: "old-secret", Block: "old-block"}
+	newKey := CookieKey{Hash: "new-secret", Block: "new-block"}
+	signOnly := CookieKey{Hash: "sign-only"}
+
+	old := mustCookieCodec(t, 0, oldKey)
+	rotated := mustCookieCodec(t, 0, newKey, oldKey)
+	dropped := mustCookieCodec(t, 0, newKey)
+	plain := mustCookieCodec(t, time.Hour, signOnly)
+
+	session, err := old.Encode("session", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang, err := plain.Encode("lang", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 서명의 첫 글자를 바꿉니다. (마지막 글자는 버려지는 비트만 바뀔 수 있습니다.)
+	tamperMAC := func(s string) string {
+		i := strings.Index(s, ".") + 1
+		c := byte('A')
+		if s[i] == c {
+			c = 'B'
+		}
+		return s[:i] + string(c) + s[i+1:]
+	}
+
+	tests := []struct {
+		name    string
+		codec   *CookieCodec
+		cookie  string
+		encoded string
+		want    string
+		err     error
+	}{
+		{"round trip", old, "session", session, "alice", nil},
+		{"rotated key reads old cookie", rotated, "session", session, "alice", nil},
+		{"removed key", dropped, "session", session, "", ErrCookieInvalid},
+		{"tampered mac", old, "session", tamperMAC(session), "", ErrCookieInvalid},
+		{"tampered ciphertext", old, "session", resignCookie(old, "session", session, func(body []byte) { body[len(body)-1] ^= 1 }), "", ErrCookieInvalid},
+		{"encrypted value under another name", old, "lang", session, "", ErrCookieInvalid},
+		{"resigned under another name", old, "lang", resignCookie(old, "lang", session, func([]byte) {}), "", ErrCookieInvalid},
+		{"signed value under another name", plain, "session", lang, "", ErrCookieInvalid},
+		{"tampered value", plain, "lang", strings.Replace(lang, ".", "A.", 1), "", ErrCookieInvalid},
+		{"no signature", plain, "lang", strings.Split(lang, ".")[0], "", ErrCookieInvalid},
+		{"fresh", plain, "lang", encodeCookieAt(plain, "lang", "ko", time.Now().Add(-time.Minute)), "ko", nil},
+		{"expired", plain, "lang", encodeCookieAt(plain, "lang", "ko", time.Now().Add(-2*time.Hour)), "", ErrCookieExpired},
+		{"no max age", mustCookieCodec(t, 0, signOnly), "lang", encodeCookieAt(plain, "lang", "ko", time.Now().Add(-24*time.Hour)), "ko", nil},
+	}
+	for _, test := range tests {
+		var got string
+		err := test.codec.Decode(test.cookie, test.encoded, &got)
+		if !errors.Is(err, test.err) || got != test.want {
+			t.Errorf("%s: Decode = %q, %v; want %q, %v", test.name, got, err, test.want, test.err)
+		}
+	}
+}
+
+// 새 쿠키는 첫 번째 키로 만듭니다.
+func TestCookieCodecEncodesWithFirstKey(t *testing.T) {
+	rotated := mustCookieCodec(t, 0, CookieKey{Hash: "new-secret"}, CookieKey{Hash: "old-secret"})
+	encoded, err := rotated.Encode("lang", "ko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := mustCookieCodec(t, 0, CookieKey{Hash: "new-secret"}).Decode("lang", encoded, &got); err != nil || got != "ko" {
+		t.Errorf("new key only: Decode = %q, %v", got, err)
+	}
+	if err := mustCookieCodec(t, 0, CookieKey{Hash: "old-secret"}).Decode("lang", encoded, &got); !errors.Is(err, ErrCookieInvalid) {
+		t.Errorf("old key only: Decode error %v, want %v", err, ErrCookieInvalid)
+	}
+}
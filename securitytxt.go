@@ -0,0 +1,96 @@
+//
+// securitytxt.go
+//
+// 보안 문제를 찾은 사람이 어디로 알려야 하는지 /.well-known/security.txt 로 알려줍니다. (RFC 9116)
+//
+//   "security_txt": {"contact": ["mailto:security@example.com", "https://example.com/report"],
+//                    "expires": "2027-10-16T00:00:00Z", "policy": "https://example.com/security-policy",
+//                    "encryption": "https://example.com/pgp-key.txt", "acknowledgments": "",
+//                    "preferred_languages": "ko, en"}
+//
+//   Contact: mailto:security@example.com
+//   Contact: https://example.com/report
+//   Expires: 2027-10-16T00:00:00Z
+//   Policy: https://example.com/security-policy
+//   Preferred-Languages: ko, en
+//   Canonical: https://example.com/.well-known/security.txt
+//
+// contact 가 비어 있으면 security.txt 를 내지 않습니다. (404)
+// expires 를 주지 않으면 서버가 시작한 날부터 1년 뒤입니다. 이미 지난 날짜이면 시작할 때 경고합니다.
+// Canonical 은 site_url 이 있을 때 붙입니다. 옛 위치인 /security.txt 에서도 같은 내용을 줍니다.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// security.txt 설정
+type SecurityTxtConfig struct {
+	Contact            []string `json:"contact"` // mailto:, https:, tel: 주소
+	Expires            string   `json:"expires"` // RFC 3339. 비어 있으면 1년 뒤
+	Policy             string   `json:"policy"`
+	Encryption         string   `json:"encryption"`
+	Acknowledgments    string   `json:"acknowledgments"`
+	PreferredLanguages string   `json:"preferred_languages"`
+	Hiring             string   `json:"hiring"`
+}
+
+// 설정과 site 주소로 security.txt 내용을 만듭니다. now는 expires 가 없을 때 기준 시각입니다.
+func (c SecurityTxtConfig) body(site string, now time.Time) (string, error) {
+	var b strings.Builder
+	for _, contact := range c.Contact {
+		if !strings.HasPrefix(contact, "mailto:") && !strings.HasPrefix(contact, "https://") && !strings.HasPrefix(contact, "tel:") {
+			return "", fmt.Errorf("security_txt: contact %q must start with mailto:, https:// or tel:", contact)
+		}
+		fmt.Fprintf(&b, "Contact: %s\n", SafeHeaderValue(contact))
+	}
+	expires := now.AddDate(1, 0, 0).UTC().Truncate(24 * time.Hour)
+	if c.Expires != "" {
+		t, err := time.Parse(time.RFC3339, c.Expires)
+		if err != nil {
+			return "", fmt.Errorf("security_txt: expires %v", err)
+		}
+		if t.Before(now) {
+			log.Printf("WARN security_txt: expires %s has already passed", c.Expires)
+		}
+		expires = t
+	}
+	fmt.Fprintf(&b, "Expires: %s\n", expires.Format(time.RFC3339))
+	for _, field := range []struct{ name, value string }{
+		{"Encryption", c.Encryption},
+		{"Acknowledgments", c.Acknowledgments},
+		{"Preferred-Languages", c.PreferredLanguages},
+		{"Policy", c.Policy},
+		{"Hiring", c.Hiring},
+	} {
+		if field.value != "" {
+			// 값에 줄바꿈을 넣어 다른 항목을 만들지 못하게 합니다.
+			fmt.Fprintf(&b, "%s: %s\n", field.name, SafeHeaderValue(field.value))
+		}
+	}
+	if site != "" {
+		fmt.Fprintf(&b, "Canonical: %s/.well-known/security.txt\n", strings.TrimRight(site, "/"))
+	}
+	return b.String(), nil
+}
+
+// /.well-known/security.txt 에 대한 응답을 만듭니다. contact 가 없으면 nil을 돌려줍니다.
+func NewSecurityTxtHandler(config SecurityTxtConfig, site string) (http.Handler, error) {
+	if len(config.Contact) == 0 {
+		return nil, nil
+	}
+	body, err := config.body(site, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		SetContentType(response, "text/plain")
+		response.Header().Set("Cache-Control", "public, max-age=86400")
+		fmt.Fprint(response, body)
+	}), nil
+}
@@ -0,0 +1,176 @@
+//
+// session.go
+//
+// 로그인한 사용자의 세션입니다. 세션은 서버 메모리에 두고, 클라이언트에는 세션 ID만
+// 서명된 "session" 쿠키(securecookie.go)로 줍니다. 서버를 다시 시작하면 모두 로그아웃됩니다.
+//
+//   "login": {"session_ttl": 86400, "idle_timeout": 3600}
+//
+// 세션은 로그인한 지 session_ttl 초가 지나거나, idle_timeout 초 동안 요청이 없으면 끝납니다.
+// (idle_timeout 이 0이면 요청이 없어도 끝나지 않습니다.)
+//
+// SessionHandler 가 요청마다 쿠키의 세션을 찾아 context에 넣으므로 핸들러는
+// CurrentUser(request) 로 로그인한 사용자를 알 수 있습니다. 로그인해야 하는 페이지는
+// RequireLogin 으로 감쌉니다.
+//
+// 세션 쿠키는 로그인에 꼭 필요하므로 쿠키 동의(consent.go)와 관계없이 씁니다.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// 세션 ID를 담는 쿠키
+const sessionCookie = "session"
+
+// 로그인 세션 하나
+type Session struct {
+	ID       string
+	User     string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+// 세션들을 메모리에 둡니다.
+type SessionStore struct {
+	ttl  time.Duration
+	idle time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// 서버 전체가 쓰는 세션 저장소. main에서 설정으로 만듭니다.
+var sessions = NewSessionStore(DefaultConfig().Login)
+
+// config의 session_ttl, idle_timeout 으로 세션 저장소를 만듭니다.
+func NewSessionStore(config LoginConfig) *SessionStore {
+	return &SessionStore{
+		ttl:      time.Duration(config.SessionTTL) * time.Second,
+		idle:     time.Duration(config.IdleTimeout) * time.Second,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// user의 새 세션을 만듭니다. 로그인할 때마다 새 ID를 쓰므로 미리 심어 둔 세션 ID를 쓸 수 없습니다.
+func (s *SessionStore) New(user string) *Session {
+	id := make([]byte, 32)
+	rand.Read(id)
+	now := time.Now()
+	session := &Session{ID: hex.EncodeToString(id), User: user, Created: now, LastSeen: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 끝난 세션은 새 세션을 만들 때 치웁니다.
+	for id, old := range s.sessions {
+		if s.expired(old, now) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = session
+	return session
+}
+
+// id의 세션을 찾습니다. 끝난 세션이면 지우고 false를 돌려줍니다.
+func (s *SessionStore) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	now := time.Now()
+	if s.expired(session, now) {
+		delete(s.sessions, id)
+		return Session{}, false
+	}
+	session.LastSeen = now
+	return *session, true
+}
+
+// id의 세션을 끝냅니다.
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// 세션이 끝났는지. mu를 잡은 상태에서 불러야 합니다.
+func (s *SessionStore) expired(session *Session, now time.Time) bool {
+	if s.ttl > 0 && now.Sub(session.Created) > s.ttl {
+		return true
+	}
+	return s.idle > 0 && now.Sub(session.LastSeen) > s.idle
+}
+
+// 세션 만료 시각 (session_ttl 기준)
+func (s *SessionStore) Expires(session Session) time.Time {
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+	return session.Created.Add(s.ttl)
+}
+
+type sessionKey struct{}
+
+// 요청의 세션 쿠키를 확인하고 세션을 context에 넣습니다.
+func SessionHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var id string
+		if err := ReadSecureCookie(request, sessionCookie, &id); err == nil {
+			if session, ok := sessions.Get(id); ok {
+				request = request.WithContext(context.WithValue(request.Context(), sessionKey{}, session))
+			}
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+// 요청의 세션. 로그인하지 않았으면 false
+func SessionFromRequest(request *http.Request) (Session, bool) {
+	session, ok := request.Context().Value(sessionKey{}).(Session)
+	return session, ok
+}
+
+// 로그인한 사용자의 이름. 로그인하지 않았으면 ""
+func CurrentUser(request *http.Request) string {
+	session, _ := SessionFromRequest(request)
+	return session.User
+}
+
+// 세션을 시작하고 세션 쿠키를 응답에 붙입니다.
+func StartSession(response http.ResponseWriter, user string) error {
+	session := sessions.New(user)
+	return SetSecureCookie(response, sessionCookie, session.ID)
+}
+
+// 요청의 세션을 끝내고 세션 쿠키를 지웁니다.
+func EndSession(response http.ResponseWriter, request *http.Request) {
+	if session, ok := SessionFromRequest(request); ok {
+		sessions.Delete(session.ID)
+	}
+	expired := NewCookie(sessionCookie, "")
+	expired.MaxAge = -1
+	http.SetCookie(response, expired)
+}
+
+// 로그인한 요청만 next로 보냅니다. 브라우저는 로그인 페이지로 보내고, 다른 클라이언트에게는 401
+func RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if CurrentUser(request) != "" {
+			next.ServeHTTP(response, request)
+			return
+		}
+		if AcceptsType(request, "text/html") {
+			http.Redirect(response, request, "/login?next="+url.QueryEscape(request.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		WriteError(response, request, http.StatusUnauthorized, nil)
+	})
+}
@@ -0,0 +1,154 @@
+//
+// shutdown.go
+//
+// SIGINT(Ctrl+C)이나 SIGTERM을 받으면 서버를 바로 끄지 않고 천천히 닫습니다.
+//
+//  1. 새 연결을 받지 않습니다. (http.Server.Shutdown)
+//  2. 실시간 클라이언트에게 끝난다고 알립니다. WebSocket은 1001(going away) 닫기 프레임,
+//     SSE는 "event: shutdown" 을 받고, long polling은 바로 응답을 받습니다.
+//  3. 진행 중인 요청과 실시간 연결(presence.go)이 모두 끝나기를 기다립니다.
+//     shutdown_timeout 초가 지나면(hard stop) 남은 연결은 그냥 끊습니다.
+//  4. 시그널을 한 번 더 받으면 기다리지 않고 바로 끊습니다.
+//
+// 기다리는 동안 1초마다 남은 요청과 연결 수를 로그에 남기고, 다 닫히면 걸린 시간을 남깁니다.
+// shutdown_timeout 을 얼마로 할지 이 로그를 보고 정할 수 있습니다.
+//
+//   shutdown: draining, 3 requests, 4 connections (2 active, 1 idle, 1 new), 1 realtime left, hard stop in 8s
+//   shutdown: drained in 2.41s
+//
+// 같은 값이 /debug/vars 의 "shutdown" 에도 있습니다. (debug.go)
+//
+//   "shutdown": {"draining":true,"started":"2026-10-16T08:30:00Z","deadline":"2026-10-16T08:30:10Z",
+//                "in_flight":3,"connections":{"new":1,"active":2,"idle":1,"realtime":1}}
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 남은 요청과 연결을 로그에 남기는 간격
+const drainReportInterval = time.Second
+
+// /debug/vars 의 "shutdown"
+type DrainStatus struct {
+	Draining    bool             `json:"draining"`
+	Started     time.Time        `json:"started"`  // 닫기 시작한 시각
+	Deadline    time.Time        `json:"deadline"` // 이 시각에 남은 연결을 끊습니다.
+	InFlight    int64            `json:"in_flight"`
+	Connections ConnectionCounts `json:"connections"`
+}
+
+var (
+	drainMu       sync.Mutex
+	drainStarted  time.Time
+	drainDeadline time.Time
+)
+
+// 지금의 닫기 상태
+func CurrentDrainStatus() DrainStatus {
+	drainMu.Lock()
+	status := DrainStatus{Started: drainStarted, Deadline: drainDeadline}
+	drainMu.Unlock()
+	status.Draining = !status.Started.IsZero()
+	status.InFlight = metrics.InFlight()
+	status.Connections = connections.Counts()
+	return status
+}
+
+// 남은 요청과 연결을 한 줄로
+func (s DrainStatus) String() string {
+	c := s.Connections
+	return fmt.Sprintf("%d requests, %d connections (%d active, %d idle, %d new), %d realtime left",
+		s.InFlight, c.New+c.Active+c.Idle, c.Active, c.Idle, c.New, c.Realtime)
+}
+
+// 서버가 닫히기 시작하면 닫히는 채널. 실시간 연결들이 기다립니다.
+var draining = make(chan struct{})
+
+var drainOnce sync.Once
+
+// 실시간 연결들에게 서버가 닫힌다고 알립니다. 여러 번 불러도 됩니다.
+func StartDrain() {
+	drainOnce.Do(func() { close(draining) })
+}
+
+// parent가 끝나거나 서버가 닫히기 시작하면 끝나는 context
+func drainContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-draining:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// server를 시작하고, 시그널을 받으면 timeout 안에서 천천히 닫습니다.
+// 서버가 다 닫힌 뒤에 돌아옵니다.
+func ListenAndServeGracefully(server *http.Server, timeout time.Duration) error {
+	server.RegisterOnShutdown(StartDrain)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		started := time.Now()
+		deadline := started.Add(timeout)
+		drainMu.Lock()
+		drainStarted, drainDeadline = started, deadline
+		drainMu.Unlock()
+		log.Printf("%v: shutting down (hard stop at %s)", sig, deadline.Format(time.TimeOnly))
+
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		// 기다리는 동안 남은 것을 알리고, 시그널을 한 번 더 받으면 바로 끊습니다.
+		go func() {
+			ticker := time.NewTicker(drainReportInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Printf("shutdown: draining, %v, hard stop in %v", CurrentDrainStatus(), time.Until(deadline).Round(time.Second))
+				case sig := <-signals:
+					log.Printf("%v: stopping now", sig)
+					cancel()
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("WARN shutdown: hard stop after %.2fs (%v), %v", time.Since(started).Seconds(), err, CurrentDrainStatus())
+			server.Close()
+			return
+		}
+		// Shutdown은 hijack된 WebSocket 연결을 기다리지 않으므로 따로 기다립니다.
+		if err := presence.Wait(ctx); err != nil {
+			log.Printf("WARN shutdown: %d realtime connections left", presence.Report().Total)
+			return
+		}
+		log.Printf("shutdown: drained in %.2fs", time.Since(started).Seconds())
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-done
+	log.Print("server stopped")
+	return nil
+}
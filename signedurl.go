@@ -0,0 +1,89 @@
+//
+// signedurl.go
+//
+// 로그인 없이도 정해진 시간 동안만 쓸 수 있는 서명된 주소입니다.
+//
+//   /files/{id}/download?expires=1792137600&sig=3q2-7w...
+//
+// sig는 서버만 아는 키로 만든 HMAC-SHA256 (경로 + 만료 시각) 입니다.
+// 경로나 만료 시각을 한 글자라도 바꾸면 서명이 맞지 않으므로, 다른 파일을 받거나
+// 기한을 늘릴 수 없습니다. 만료 시각이 지난 주소는 403으로 거절합니다.
+//
+// 설정 파일의 "upload": {"signed_downloads": true} 이면 /files/{id}/download 는 서명된
+// 주소로만 받을 수 있고, 업로드 응답의 "url" 에 서명된 주소가 들어갑니다.
+// "signing_key" 를 주지 않으면 시작할 때마다 무작위 키를 만들므로 재시작하면 주소가 무효가 됩니다.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 주소에 서명하고 확인합니다.
+type URLSigner struct {
+	key []byte
+}
+
+// key로 서명하는 URLSigner를 만듭니다. key가 비어 있으면 무작위 키를 씁니다.
+func NewURLSigner(key string) *URLSigner {
+	if key == "" {
+		random := make([]byte, 32)
+		rand.Read(random)
+		return &URLSigner{key: random}
+	}
+	return &URLSigner{key: []byte(key)}
+}
+
+// path와 만료 시각의 서명
+func (s *URLSigner) signature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s\n%d", path, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// path에 ttl 동안 쓸 수 있는 서명을 붙인 주소를 돌려줍니다.
+func (s *URLSigner) Sign(path string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", s.signature(path, expires))
+	return path + "?" + query.Encode()
+}
+
+// 요청의 주소가 올바르게 서명되었고 아직 만료되지 않았는지 확인합니다.
+func (s *URLSigner) Verify(request *http.Request) error {
+	query := request.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return errors.New("signed url: missing expires")
+	}
+	want := s.signature(request.URL.Path, expires)
+	if !hmac.Equal([]byte(query.Get("sig")), []byte(want)) {
+		return errors.New("signed url: bad signature")
+	}
+	if time.Now().Unix() > expires {
+		return errors.New("signed url: expired")
+	}
+	return nil
+}
+
+// 서명이 올바른 요청만 next로 보냅니다. 아니면 403
+func (s *URLSigner) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if err := s.Verify(request); err != nil {
+			audit.Record(request, "signed_url.rejected", "", map[string]string{"reason": err.Error()})
+			WriteError(response, request, http.StatusForbidden, err)
+			return
+		}
+		next.ServeHTTP(response, request)
+	})
+}
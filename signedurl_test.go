@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	s := NewURLSigner("k3y")
+	valid := s.Sign("/files/abc/download", time.Hour)
+	query, _ := url.ParseQuery(valid[strings.IndexByte(valid, '?')+1:])
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	later := strconv.FormatInt(expires+3600, 10)
+
+	tests := []struct {
+		name   string
+		target string
+		ok     bool
+	}{
+		{"valid", valid, true},
+		{"expired", s.Sign("/files/abc/download", -time.Minute), false},
+		{"other id", strings.Replace(valid, "/abc/", "/abd/", 1), false},
+		{"other path", strings.Replace(valid, "/download", "/info", 1), false},
+		{"extended expiry", strings.Replace(valid, "expires="+query.Get("expires"), "expires="+later, 1), false},
+		{"tampered sig", strings.Replace(valid, "sig=", "sig=x", 1), false},
+		{"no sig", "/files/abc/download?expires=" + query.Get("expires"), false},
+		{"no expires", "/files/abc/download?sig=" + query.Get("sig"), false},
+		{"other key", NewURLSigner("other").Sign("/files/abc/download", time.Hour), false},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.target, nil)
+		if err := s.Verify(request); (err == nil) != test.ok {
+			t.Errorf("%s: Verify(%s) = %v, want ok %v", test.name, test.target, err, test.ok)
+		}
+	}
+}
+
+func TestURLSignerRequire(t *testing.T) {
+	s := NewURLSigner("")
+	handler := s.Require(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for target, status := range map[string]int{
+		s.Sign("/files/abc/download", time.Hour):    http.StatusOK,
+		s.Sign("/files/abc/download", -time.Minute): http.StatusForbidden,
+		"/files/abc/download":                       http.StatusForbidden,
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		if recorder.Code != status {
+			t.Errorf("GET %s: status %d, want %d", target, recorder.Code, status)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+//
+// sitemap.go
+//
+// 검색 엔진을 위한 /sitemap.xml 을 만듭니다.
+// 등록된 HTML 페이지(/home, pages/ 의 페이지)와 /docs/ 의 Markdown 문서가 들어갑니다.
+// lastmod 는 파일의 수정 시간이고, 수정 시간이 없는 파일(실행 파일에 들어간 파일)은
+// 서버가 시작한 시간을 씁니다.
+//
+// 주소 앞부분은 설정 파일의 "site_url" (예: "https://example.com") 이고,
+// 비어 있으면 요청의 Host 헤더로 만듭니다.
+
+package main
+
+import (
+	"encoding/xml"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sitemap 의 <url> 하나
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemap 전체
+type Sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+// 사이트의 HTML 주소 하나와 그 내용이 마지막으로 바뀐 시간
+type SiteRoute struct {
+	Path    string
+	ModTime time.Time
+}
+
+// /sitemap.xml 핸들러
+type SitemapHandler struct {
+	siteURL string
+	docs    *DocsHandler
+}
+
+// siteURL이 비어 있으면 요청마다 Host 헤더로 주소를 만듭니다.
+func NewSitemapHandler(siteURL string, docs *DocsHandler) *SitemapHandler {
+	return &SitemapHandler{siteURL: strings.TrimRight(siteURL, "/"), docs: docs}
+}
+
+func (h *SitemapHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	base := h.siteURL
+	if base == "" {
+		scheme := "http"
+		if isHTTPS(request) {
+			scheme = "https"
+		}
+		base = scheme + "://" + request.Host
+	}
+
+	sitemap := Sitemap{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, route := range h.routes() {
+		modTime := route.ModTime
+		if modTime.IsZero() {
+			modTime = serverStarted
+		}
+		sitemap.URLs = append(sitemap.URLs, SitemapURL{
+			Loc:     base + route.Path,
+			LastMod: modTime.UTC().Format("2006-01-02"),
+		})
+	}
+
+	SetContentType(response, "application/xml")
+	response.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(response)
+	enc.Indent("", "  ")
+	enc.Encode(sitemap)
+}
+
+// sitemap에 들어갈 모든 주소
+func (h *SitemapHandler) routes() []SiteRoute {
+	routes := []SiteRoute{{Path: "/home", ModTime: modTime(assets, "home.html")}}
+	for _, page := range sitePages {
+		routes = append(routes, SiteRoute{Path: page.Path, ModTime: modTime(pagesFS, page.File)})
+	}
+	if h.docs != nil {
+		routes = append(routes, h.docs.Routes()...)
+	}
+	sort.SliceStable(routes[1:], func(i, j int) bool { return routes[i+1].Path < routes[j+1].Path })
+	return routes
+}
+
+// fsys 안의 name 파일의 수정 시간. 알 수 없으면 0입니다.
+func modTime(fsys fs.FS, name string) time.Time {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,97 @@
+//
+// slowlog.go
+//
+// 설정한 시간(slow_request_threshold, ms)보다 오래 걸린 요청을 WARN 으로 로그에 남깁니다.
+// 시간은 핸들러가 계산한 시간과 응답을 쓰는 데 든 시간으로 나누어 보여주므로
+// ItemHandler 나 저장소가 느려졌는지, 클라이언트가 느리게 받아 가는지 바로 알 수 있습니다.
+//
+//   WARN slow request: GET /items 200 total=1.204s handler=1.187s write=17ms first_byte=1.190s
+//
+// 원래 오래 걸리는 요청(WebSocket, SSE, long polling)은 기록하지 않습니다.
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 일부러 응답을 오래 붙잡는 주소들. (changes.go)
+var longPollPaths = map[string]bool{
+	"/items/changes": true,
+}
+
+// 응답을 쓰는 데 든 시간을 재는 ResponseWriter
+type timedWriter struct {
+	http.ResponseWriter
+	start     time.Time
+	firstByte time.Duration // 처음 Write까지 걸린 시간. 쓰지 않았으면 0
+	writing   time.Duration // Write와 Flush 안에서 보낸 시간의 합
+	status    int
+	hijacked  bool
+}
+
+func (w *timedWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timedWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	start := time.Now()
+	if w.firstByte == 0 {
+		w.firstByte = start.Sub(w.start)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.writing += time.Since(start)
+	return n, err
+}
+
+func (w *timedWriter) Flush() {
+	start := time.Now()
+	http.NewResponseController(w.ResponseWriter).Flush()
+	w.writing += time.Since(start)
+}
+
+func (w *timedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *timedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// threshold 보다 오래 걸린 요청을 로그에 남깁니다. threshold가 0 이하이면 next를 그대로 돌려줍니다.
+func SlowRequestHandler(threshold time.Duration, next http.Handler) http.Handler {
+	if threshold <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		w := &timedWriter{ResponseWriter: response, start: time.Now()}
+		next.ServeHTTP(w, request)
+		total := time.Since(w.start)
+		if total < threshold || w.hijacked || longPollPaths[request.URL.Path] ||
+			strings.HasPrefix(response.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		status := w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		Logf(request.Context(), "WARN slow request: %s %s %d total=%v handler=%v write=%v first_byte=%v",
+			request.Method, request.URL.Path, status, total.Round(time.Millisecond),
+			(total - w.writing).Round(time.Millisecond), w.writing.Round(time.Millisecond),
+			w.firstByte.Round(time.Millisecond))
+	})
+}
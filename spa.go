@@ -0,0 +1,69 @@
+//
+// spa.go
+//
+// SPA(single-page application) 모드입니다.
+//
+// 클라이언트에서 라우팅하는 프런트엔드(React, Vue ...)는 /users/42 같은 주소도
+// 모두 index.html 하나로 열어야 합니다. 이 모드를 켜면 등록되지 않은 주소에
+// 404 대신 정적 파일 디렉토리의 index.html 을 보내줍니다.
+//
+//   "static": {"dir": "./frontend/dist"},
+//   "spa": {"enabled": true, "index": "index.html", "api_prefixes": ["/api/", "/item/", "/items"]}
+//
+// 다음의 경우에는 예전처럼 404 에러를 보냅니다.
+//   - api_prefixes 로 시작하는 API 경로
+//   - /missing.js 처럼 확장자가 있는 경로 (없는 파일을 HTML로 받으면 디버깅이 어렵습니다)
+//   - HTML을 받지 않는 클라이언트 (Accept에 text/html이 없음)
+
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SPA 모드 설정
+type SPAConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Index       string   `json:"index"`        // 정적 파일 디렉토리 안의 index 파일
+	APIPrefixes []string `json:"api_prefixes"` // 이 경로들은 index.html 대신 404
+}
+
+// 등록되지 않은 주소에 index.html을 보내주는 핸들러
+type SPAHandler struct {
+	config SPAConfig
+	static *StaticHandler
+}
+
+// config와 정적 파일 핸들러로 SPA 핸들러를 만듭니다.
+func NewSPAHandler(config SPAConfig, static *StaticHandler) *SPAHandler {
+	return &SPAHandler{config: config, static: static}
+}
+
+func (h *SPAHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if !h.isPage(request) {
+		NotFoundHandler(response, request)
+		return
+	}
+	// index.html은 배포할 때마다 바뀌므로 브라우저가 항상 다시 확인하게 합니다.
+	// 해시가 붙은 JS/CSS는 /static/ 에서 오래 캐시됩니다.
+	response.Header().Set("Cache-Control", "no-cache")
+	h.static.ServeFile(response, request, h.config.Index)
+}
+
+// 이 요청에 index.html을 보내줘야 하는지 확인합니다.
+func (h *SPAHandler) isPage(request *http.Request) bool {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return false
+	}
+	for _, prefix := range h.config.APIPrefixes {
+		if strings.HasPrefix(request.URL.Path, prefix) {
+			return false
+		}
+	}
+	if path.Ext(request.URL.Path) != "" {
+		return false
+	}
+	return request.Header.Get("Accept") == "" || AcceptsType(request, "text/html")
+}
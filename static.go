@@ -0,0 +1,362 @@
+//
+// static.go
+//
+// /static/ 아래의 정적 파일(JS, CSS, 이미지 ...)을 보내줍니다.
+//
+//   URL: http://localhost:8080/static/js/home.js
+//
+// 기본은 실행 파일 안에 들어간 static/ 디렉토리이고, 설정 파일의
+// "static": {"dir": "/srv/www"} 로 디스크의 디렉토리를 대신 쓸 수 있습니다.
+//
+// 설정의 "listing": true 로 디렉토리의 파일 목록(이름, 크기, 수정 시간)을
+// HTML로 보여줄 수 있습니다. 어떤 파일이 있는지 드러나므로 기본은 꺼져 있습니다.
+//
+// 응답에는 Cache-Control과 내용의 해시로 만든 ETag를 붙입니다.
+// 브라우저가 If-None-Match로 다시 물어보면 304로 답하므로 같은 파일을 또 받지 않습니다.
+// MIME type은 파일 확장자로 정합니다.
+//
+// 시작할 때 모든 파일의 내용 해시로 지문(fingerprint)이 붙은 주소를 만들어 둡니다.
+// 템플릿에서 {{asset "js/home.js"}} 라고 쓰면 /static/js/home.9add26d1.js 가 되고,
+// 이 주소는 내용이 바뀌면 주소도 바뀌므로 1년 동안 캐시(immutable)하게 합니다.
+// static.dir 의 파일이 서버가 도는 중에 바뀌면 크기나 수정 시간이 달라진 것을 보고 지문을 다시 계산합니다.
+// 예전 지문의 주소로 오는 요청에는 지금 내용을 immutable 없이 보냅니다.
+// 개발 모드에서는 파일이 계속 바뀌므로 지문 없는 주소를 씁니다.
+//
+// Range / If-Range 헤더도 지원합니다. (http.ServeContent)
+// 큰 파일의 일부만 206 Partial Content로 보내므로 동영상 탐색과 다운로드 이어받기가 됩니다.
+//
+//   $ curl -H 'Range: bytes=0-99' http://localhost:8080/static/js/home.js    =>  206, 100 bytes
+//
+// "precompress": true 이면 텍스트 파일을 시작할 때 gzip 으로 압축해 두고 그대로 보냅니다. (precompress.go)
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 정적 파일 설정
+type StaticConfig struct {
+	Dir     string `json:"dir"`     // 비어 있으면 실행 파일 안의 static/ 을 씁니다.
+	MaxAge  int    `json:"max_age"` // Cache-Control max-age (초)
+	Listing bool   `json:"listing"` // 디렉토리의 파일 목록을 보여줄지
+
+	Precompress bool `json:"precompress"` // 시작할 때 텍스트 파일을 gzip 으로 압축해 둘지 (precompress.go)
+}
+
+// fsys의 파일을 보내주는 핸들러. prefix("/static/")를 뗀 경로로 파일을 찾습니다.
+type StaticHandler struct {
+	fsys    fs.FS
+	prefix  string
+	maxAge  int
+	listing bool
+
+	mu    sync.Mutex
+	etags map[string]etagEntry // 경로 -> 해시로 만든 ETag
+
+	fingerprinted map[string]fingerprintEntry // "js/home.js" -> "js/home.9add26d1.js"
+	original      map[string]string           // "js/home.9add26d1.js" -> "js/home.js". 예전 지문도 남겨 둡니다.
+
+	precompressed map[string]*precompressedFile // 시작할 때 압축해 둔 내용 (precompress.go)
+}
+
+// 지문이 붙은 주소의 캐시 설정 (1년)
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// 파일이 바뀌면 ETag를 다시 계산하도록 크기와 수정 시간을 함께 기억합니다.
+type etagEntry struct {
+	size     int64
+	modTime  time.Time
+	etag     string
+	minified []byte // 줄인 내용 (minify.go). 줄이지 않는 파일이면 nil
+}
+
+// 파일이 바뀌면 지문을 다시 계산하도록 크기와 수정 시간을 함께 기억합니다.
+type fingerprintEntry struct {
+	size    int64
+	modTime time.Time
+	hashed  string
+}
+
+// config에 따라 정적 파일 핸들러를 만듭니다.
+func NewStaticHandler(prefix string, config StaticConfig) (*StaticHandler, error) {
+	var fsys fs.FS
+	if config.Dir != "" {
+		fsys = os.DirFS(config.Dir)
+	} else {
+		sub, err := fs.Sub(assets, "static")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+	h := &StaticHandler{
+		fsys:          fsys,
+		prefix:        prefix,
+		maxAge:        config.MaxAge,
+		listing:       config.Listing,
+		etags:         make(map[string]etagEntry),
+		fingerprinted: make(map[string]fingerprintEntry),
+		original:      make(map[string]string),
+	}
+	if err := h.fingerprint(); err != nil {
+		return nil, err
+	}
+	if config.Precompress && !reloadTemplates {
+		if err := h.precompress(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// 모든 파일의 내용 해시를 계산하여 지문이 붙은 이름을 만들어 둡니다.
+func (h *StaticHandler) fingerprint() error {
+	return fs.WalkDir(h.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		_, err = h.hashFile(name, info)
+		return err
+	})
+}
+
+// name 파일의 내용 해시로 지문이 붙은 이름을 만들어 기억합니다.
+func (h *StaticHandler) hashFile(name string, info fs.FileInfo) (string, error) {
+	content, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	ext := path.Ext(name)
+	hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+
+	h.mu.Lock()
+	h.fingerprinted[name] = fingerprintEntry{size: info.Size(), modTime: info.ModTime(), hashed: hashed}
+	h.original[hashed] = name
+	h.mu.Unlock()
+	return hashed, nil
+}
+
+// name 파일의 지금 지문이 붙은 이름. 파일이 바뀌었으면 다시 계산합니다.
+func (h *StaticHandler) currentFingerprint(name string) (string, bool) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	h.mu.Lock()
+	entry, ok := h.fingerprinted[name]
+	h.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.hashed, true
+	}
+	hashed, err := h.hashFile(name, info)
+	return hashed, err == nil
+}
+
+// names 파일이 모두 있는지 봅니다. 템플릿이 {{asset}} 으로 쓰는 파일이 빠지면 페이지가 깨지므로
+// 시작할 때 확인해서 없는 파일들을 에러로 알려줍니다.
+func (h *StaticHandler) Require(names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if _, err := fs.Stat(h.fsys, name); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing static files: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// 템플릿에서 쓸 name 파일의 주소. 지문이 있으면 지문이 붙은 주소를 돌려줍니다.
+func (h *StaticHandler) AssetURL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if reloadTemplates || !fs.ValidPath(name) {
+		return h.prefix + name
+	}
+	if hashed, ok := h.currentFingerprint(name); ok {
+		return h.prefix + hashed
+	}
+	return h.prefix + name
+}
+
+func (h *StaticHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		response.Header().Set("Allow", "GET, HEAD")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(request.URL.Path), strings.TrimSuffix(h.prefix, "/"))
+	name = strings.TrimPrefix(name, "/")
+	if name == "" && h.listing {
+		name = "."
+	}
+	if name == "" || !fs.ValidPath(name) {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	h.mu.Lock()
+	original, ok := h.original[name]
+	h.mu.Unlock()
+	if ok {
+		// 지문이 붙은 주소는 내용이 바뀌지 않으므로 오래 캐시해도 됩니다.
+		// 파일이 바뀌어 예전 지문이 된 주소는 보통의 Cache-Control 로 지금 내용을 보냅니다.
+		if current, ok := h.currentFingerprint(original); ok && current == name {
+			response.Header().Set("Cache-Control", immutableCacheControl)
+		}
+		name = original
+	}
+
+	h.ServeFile(response, request, name)
+}
+
+// fsys 안의 name 파일을 캐시 헤더와 함께 보냅니다.
+func (h *StaticHandler) ServeFile(response http.ResponseWriter, request *http.Request, name string) {
+	file, err := h.fsys.Open(name)
+	if err != nil {
+		h.openError(response, request, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		h.openError(response, request, err)
+		return
+	}
+	if info.IsDir() {
+		if !h.listing {
+			WriteError(response, request, http.StatusNotFound, nil)
+			return
+		}
+		h.serveListing(response, request, name)
+		return
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("static %s is not seekable", name))
+		return
+	}
+
+	entry, err := h.etag(name, info, content)
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("static %s etag error %v", name, err))
+		return
+	}
+	if h.servePrecompressed(response, request, name, info) {
+		return
+	}
+	response.Header().Set("ETag", entry.etag)
+	if response.Header().Get("Cache-Control") == "" {
+		response.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(h.maxAge))
+	}
+	if entry.minified != nil {
+		content = bytes.NewReader(entry.minified)
+	}
+	// ServeContent가 확장자로 Content-Type을 정하고 If-None-Match, Range 등을 처리합니다.
+	http.ServeContent(response, request, name, info.ModTime(), content)
+}
+
+// 디렉토리 목록 페이지에 넘겨주는 값들
+type ListingPage struct {
+	Path    string
+	Root    bool // 맨 위 디렉토리이면 "../" 링크를 보여주지 않습니다.
+	Entries []ListingEntry
+}
+
+// 디렉토리 목록의 한 줄
+type ListingEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// name 디렉토리의 파일 목록을 HTML로 보여줍니다. 디렉토리가 먼저, 그 다음은 이름 순서입니다.
+func (h *StaticHandler) serveListing(response http.ResponseWriter, request *http.Request, name string) {
+	// 상대 링크가 맞도록 디렉토리 주소는 항상 /로 끝나게 합니다.
+	if !strings.HasSuffix(request.URL.Path, "/") {
+		http.Redirect(response, request, request.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		h.openError(response, request, err)
+		return
+	}
+	page := ListingPage{Path: request.URL.Path, Root: name == "."}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		page.Entries = append(page.Entries, ListingEntry{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   entry.IsDir(),
+		})
+	}
+	sort.SliceStable(page.Entries, func(i, j int) bool {
+		return page.Entries[i].IsDir && !page.Entries[j].IsDir
+	})
+	response.Header().Set("Cache-Control", "no-cache")
+	RenderTemplate(response, request, "listing", page)
+}
+
+// 파일을 열 수 없을 때의 응답
+func (h *StaticHandler) openError(response http.ResponseWriter, request *http.Request, err error) {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	WriteError(response, request, http.StatusInternalServerError, err)
+}
+
+// 보낼 내용의 sha256으로 ETag를 만들고, 줄일 수 있는 파일이면 줄인 내용도 만듭니다.
+// 한 번 계산한 값은 파일이 바뀔 때까지 기억합니다.
+func (h *StaticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (etagEntry, error) {
+	h.mu.Lock()
+	entry, ok := h.etags[name]
+	h.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry, nil
+	}
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return entry, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return entry, err
+	}
+	entry = etagEntry{size: info.Size(), modTime: info.ModTime()}
+	if minified, ok := MinifyAsset(name, data); ok {
+		entry.minified, data = minified, minified
+	}
+	sum := sha256.Sum256(data)
+	entry.etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	h.mu.Lock()
+	h.etags[name] = entry
+	h.mu.Unlock()
+	return entry, nil
+}
@@ -0,0 +1,50 @@
+/* /chat 페이지: 채팅방 WebSocket 클라이언트 (chat.go) */
+(function () {
+  var log = document.getElementById("chat-log");
+  var form = document.getElementById("chat-form");
+  var nameInput = document.getElementById("chat-name");
+  var textInput = document.getElementById("chat-text");
+  var ws = null;
+
+  function show(line) {
+    var p = document.createElement("p");
+    p.textContent = line;
+    log.appendChild(p);
+    log.scrollTop = log.scrollHeight;
+  }
+
+  function connect() {
+    var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    var url = scheme + location.host + "/chat/ws?name=" + encodeURIComponent(nameInput.value);
+    ws = new WebSocket(url);
+    ws.onmessage = function (event) {
+      var msg = JSON.parse(event.data);
+      var time = new Date(msg.time).toLocaleTimeString();
+      if (msg.type === "message") {
+        show("[" + time + "] " + msg.name + ": " + msg.text);
+      } else if (msg.type === "item") {
+        show("[" + time + "] * item " + msg.name + " (" + msg.text + ")");
+      } else {
+        show("[" + time + "] * " + msg.name + " " + msg.type);
+      }
+    };
+    ws.onclose = function () { show("* disconnected"); ws = null; };
+  }
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    if (ws === null) {
+      connect();
+      ws.onopen = function () { send(); };
+      return;
+    }
+    send();
+  });
+
+  function send() {
+    if (textInput.value !== "") {
+      ws.send(textInput.value);
+      textInput.value = "";
+    }
+  }
+})();
@@ -0,0 +1,11 @@
+/* /graphql 의 GraphiQL 페이지 (graphql.go). subscription 은 같은 주소의 WebSocket 으로 보냅니다. */
+(function () {
+  var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+  var fetcher = GraphiQL.createFetcher({
+    url: "/graphql",
+    wsClient: graphqlWs.createClient({ url: scheme + location.host + "/graphql" })
+  });
+  ReactDOM.createRoot(document.getElementById("graphiql")).render(
+    React.createElement(GraphiQL, { fetcher: fetcher })
+  );
+})();
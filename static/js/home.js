@@ -0,0 +1,11 @@
+$(function(){ ajax_request() });
+var ajax_handler = function(json){
+  /* debugging : */
+  /* alert(" typeof(json) = " + typeof(json) + "; json = " + json); */
+  /* the json data for /item/foo should be {"name":"foo","what":"item"} */
+  $("#the_span").html(json.name);
+}
+var ajax_request = function(){
+  /* see https://api.jquery.com/jQuery.get */
+  $.get("/item/foo", ajax_handler, "json");
+}
@@ -0,0 +1,8 @@
+/* /openapi 의 Swagger UI 페이지 (openapi.go). 문서는 /openapi.json 에서 읽습니다. */
+(function () {
+  SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true
+  });
+})();
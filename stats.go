@@ -0,0 +1,143 @@
+//
+// stats.go
+//
+// route별 최근 처리 시간의 백분위수(p50, p95, p99)를 /stats 에서 JSON으로 보여줍니다.
+// Prometheus 없이 curl 로 바로 볼 때 씁니다. (/metrics 의 histogram 은 metrics.go)
+//
+//   $ curl localhost:8080/stats
+//   {"window_seconds":300,"routes":{"/item/":{"count":42,"p50_ms":0.3,"p95_ms":1.2,"p99_ms":4.8,"max_ms":5.1}}}
+//
+// route마다 최근 statsSamples 개의 요청 중 statsWindow 안에 끝난 것만 셉니다.
+// /metrics 와 같은 접근 제한("metrics" 설정)을 따릅니다.
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// route마다 기억하는 최근 요청의 수와 기간
+const (
+	statsSamples = 1024
+	statsWindow  = 5 * time.Minute
+)
+
+// 최근 요청들의 처리 시간. 꽉 차면 가장 오래된 것부터 덮어씁니다.
+type latencyWindow struct {
+	samples [statsSamples]latencySample
+	next    int
+	full    bool
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+func (w *latencyWindow) add(at time.Time, duration time.Duration) {
+	w.samples[w.next] = latencySample{at, duration}
+	w.next = (w.next + 1) % statsSamples
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// since 이후에 끝난 요청들의 처리 시간 (정렬됨)
+func (w *latencyWindow) since(since time.Time) []time.Duration {
+	n := w.next
+	if w.full {
+		n = statsSamples
+	}
+	durations := make([]time.Duration, 0, n)
+	for _, s := range w.samples[:n] {
+		if s.at.After(since) {
+			durations = append(durations, s.duration)
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations
+}
+
+// route 하나의 백분위수. 단위는 밀리초
+type RouteStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// /stats 의 응답 본문
+type StatsReport struct {
+	WindowSeconds int                   `json:"window_seconds"`
+	Routes        map[string]RouteStats `json:"routes"`
+}
+
+// route별 최근 처리 시간 모음
+type LatencyStats struct {
+	mu     sync.Mutex
+	routes map[string]*latencyWindow
+}
+
+func NewLatencyStats() *LatencyStats {
+	return &LatencyStats{routes: make(map[string]*latencyWindow)}
+}
+
+// 끝난 요청 하나를 기록합니다.
+func (s *LatencyStats) Observe(route string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.routes[route]
+	if w == nil {
+		w = new(latencyWindow)
+		s.routes[route] = w
+	}
+	w.add(time.Now(), duration)
+}
+
+// 지금부터 statsWindow 전까지의 백분위수
+func (s *LatencyStats) Report() StatsReport {
+	report := StatsReport{WindowSeconds: int(statsWindow / time.Second), Routes: make(map[string]RouteStats)}
+	since := time.Now().Add(-statsWindow)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for route, w := range s.routes {
+		durations := w.since(since)
+		if len(durations) == 0 {
+			continue
+		}
+		report.Routes[route] = RouteStats{
+			Count: len(durations),
+			P50:   percentile(durations, 0.50),
+			P95:   percentile(durations, 0.95),
+			P99:   percentile(durations, 0.99),
+			Max:   milliseconds(durations[len(durations)-1]),
+		}
+	}
+	return report
+}
+
+// 정렬된 durations의 p 백분위수 (nearest-rank)
+func percentile(durations []time.Duration, p float64) float64 {
+	i := int(math.Ceil(p*float64(len(durations)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return milliseconds(durations[i])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// GET /stats
+func (s *LatencyStats) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(s.Report())
+}
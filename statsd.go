@@ -0,0 +1,229 @@
+//
+// statsd.go
+//
+// Prometheus 가 가져가지 않는 환경에서 메트릭을 StatsD (또는 DogStatsD) 서버로 보냅니다.
+// 요청마다 보내지 않고 flush_interval 초 동안 모았다가 UDP 로 한꺼번에 보냅니다.
+//
+//   "statsd": {"enabled": true, "address": "127.0.0.1:8125", "prefix": "webserver.",
+//              "tags": ["env:prod"], "dogstatsd": true, "flush_interval": 10}
+//
+// 보내는 메트릭 (dogstatsd 이면 route, code 는 태그로, 아니면 이름에 들어갑니다)
+//
+//   webserver.requests:12|c|#route:/items,code:200,env:prod      요청 수
+//   webserver.errors:1|c|#route:/upload,env:prod                 5xx 응답 수
+//   webserver.latency:3.2|ms|@0.5|#route:/items,env:prod         처리 시간(ms)
+//
+//   webserver.requests.items.200:12|c                            dogstatsd 가 아닐 때
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsD 설정
+type StatsDConfig struct {
+	Enabled       bool     `json:"enabled"`
+	Address       string   `json:"address"`        // host:port (UDP)
+	Prefix        string   `json:"prefix"`         // 메트릭 이름 앞에 붙입니다.
+	Tags          []string `json:"tags"`           // 모든 메트릭에 붙이는 태그. dogstatsd 일 때만 씁니다.
+	DogStatsD     bool     `json:"dogstatsd"`      // |#tag 형식을 씁니다.
+	FlushInterval int      `json:"flush_interval"` // 초
+}
+
+// 한 번 보낼 때 route마다 보내는 처리 시간의 최대 개수. 넘으면 일부만 보내고 @rate 를 붙입니다.
+const statsdMaxTimings = 512
+
+// UDP 패킷 하나의 최대 크기. 흔한 MTU 안에 들어가도록 합니다.
+const statsdPacketSize = 1432
+
+// route 하나의 모은 값
+type statsdRoute struct {
+	codes   map[int]int64
+	errors  int64
+	timings []float64 // ms
+	seen    int64     // 처리 시간을 받은 수 (timings 보다 많을 수 있습니다)
+}
+
+// 메트릭을 모았다가 StatsD 로 보냅니다.
+type StatsD struct {
+	config StatsDConfig
+	conn   net.Conn
+
+	mu     sync.Mutex
+	routes map[string]*statsdRoute
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// config로 StatsD 클라이언트를 만들고 보내는 goroutine을 시작합니다. 꺼져 있으면 nil
+func NewStatsD(config StatsDConfig) (*StatsD, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 10
+	}
+	s := &StatsD{
+		config: config,
+		conn:   conn,
+		routes: make(map[string]*statsdRoute),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// 끝난 요청 하나를 모읍니다. (metrics.go 의 Observe 가 부릅니다)
+func (s *StatsD) Observe(route string, status int, duration time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.routes[route]
+	if r == nil {
+		r = &statsdRoute{codes: make(map[int]int64)}
+		s.routes[route] = r
+	}
+	r.codes[status]++
+	if status >= 500 {
+		r.errors++
+	}
+	r.seen++
+	if len(r.timings) < statsdMaxTimings {
+		r.timings = append(r.timings, float64(duration)/float64(time.Millisecond))
+	}
+}
+
+// 남은 메트릭을 보내고 멈춥니다.
+func (s *StatsD) Close() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.conn.Close()
+}
+
+func (s *StatsD) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(time.Duration(s.config.FlushInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// 모은 값을 줄로 만들어 보내고 비웁니다.
+func (s *StatsD) flush() {
+	s.mu.Lock()
+	routes := s.routes
+	s.routes = make(map[string]*statsdRoute)
+	s.mu.Unlock()
+
+	names := make([]string, 0, len(routes))
+	for route := range routes {
+		names = append(names, route)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, route := range names {
+		r := routes[route]
+		for code, n := range r.codes {
+			lines = append(lines, s.line("requests", strconv.FormatInt(n, 10)+"|c", route, strconv.Itoa(code)))
+		}
+		if r.errors > 0 {
+			lines = append(lines, s.line("errors", strconv.FormatInt(r.errors, 10)+"|c", route, ""))
+		}
+		rate := ""
+		if r.seen > int64(len(r.timings)) {
+			rate = "|@" + strconv.FormatFloat(float64(len(r.timings))/float64(r.seen), 'f', 4, 64)
+		}
+		for _, ms := range r.timings {
+			lines = append(lines, s.line("latency", strconv.FormatFloat(ms, 'f', 3, 64)+"|ms"+rate, route, ""))
+		}
+	}
+	if err := s.send(lines); err != nil {
+		log.Printf("statsd: %v", err)
+	}
+}
+
+// 메트릭 한 줄. dogstatsd 이면 route, code 를 태그로, 아니면 이름에 넣습니다.
+func (s *StatsD) line(name, value, route, code string) string {
+	if s.config.DogStatsD {
+		tags := []string{"route:" + route}
+		if code != "" {
+			tags = append(tags, "code:"+code)
+		}
+		tags = append(tags, s.config.Tags...)
+		return s.config.Prefix + name + ":" + value + "|#" + strings.Join(tags, ",")
+	}
+	name = s.config.Prefix + name + "." + statsdName(route)
+	if code != "" {
+		name += "." + code
+	}
+	return name + ":" + value
+}
+
+// route를 StatsD 이름에 쓸 수 있게 바꿉니다. "/items/changes" => "items_changes", "/" => "root"
+func statsdName(route string) string {
+	name := strings.Trim(route, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// 줄들을 statsdPacketSize 를 넘지 않는 패킷으로 나누어 보냅니다.
+func (s *StatsD) send(lines []string) error {
+	var packet bytes.Buffer
+	write := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := s.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdPacketSize {
+			if err := write(); err != nil {
+				return fmt.Errorf("send: %v", err)
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if err := write(); err != nil {
+		return fmt.Errorf("send: %v", err)
+	}
+	return nil
+}
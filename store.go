@@ -0,0 +1,148 @@
+//
+// store.go
+//
+// item을 메모리에 보관하는 간단한 저장소입니다.
+// 서버를 재시작하면 처음의 기본 item들로 돌아갑니다.
+//
+// 저장소는 바뀐 내용(change feed)을 순서 번호(seq)와 함께 최근 maxChanges 개까지 기억합니다.
+// 실시간 기능(long polling 등)은 저장소를 계속 조회하지 않고 WaitChanges 로 기다립니다.
+// 변경은 이벤트 버스(events.go)의 TopicItems 로도 보내집니다.
+
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 기억해 두는 최근 변경의 개수
+const maxChanges = 1000
+
+// 저장소의 변경 하나
+type ItemChange struct {
+	Seq  uint64    `json:"seq"`
+	Item Item      `json:"item"`
+	Time time.Time `json:"time"`
+}
+
+// 서버가 시작할 때 들어있는 item들
+var defaultItems = []Item{
+	{Name: "yellow", What: "item"},
+	{Name: "purple", What: "item"},
+	{Name: "foo", What: "item"},
+}
+
+// 여러 요청이 동시에 접근해도 안전한 item 저장소
+type ItemStore struct {
+	mu    sync.RWMutex
+	items map[string]Item
+	bus   *EventBus // nil이 아니면 변경을 TopicItems로 보냅니다.
+
+	seq     uint64        // 마지막 변경의 순서 번호
+	changes []ItemChange  // 최근 변경들 (오래된 것부터)
+	changed chan struct{} // 다음 변경이 생기면 닫히는 채널
+}
+
+// 서버 전체가 함께 쓰는 저장소
+var store = NewItemStore(events, defaultItems...)
+
+// 주어진 item들을 담은 저장소를 만듭니다. bus는 nil이어도 됩니다.
+func NewItemStore(bus *EventBus, items ...Item) *ItemStore {
+	s := &ItemStore{items: make(map[string]Item), bus: bus, changed: make(chan struct{})}
+	for _, item := range items {
+		s.items[item.Name] = item
+	}
+	return s
+}
+
+// 이름으로 item을 찾습니다.
+func (s *ItemStore) Get(name string) (Item, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[name]
+	return item, ok
+}
+
+// item을 추가하거나 같은 이름의 item을 바꾸고, 기다리는 쪽에 알립니다.
+func (s *ItemStore) Put(item Item) ItemChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.Name] = item
+
+	s.seq++
+	change := ItemChange{Seq: s.seq, Item: item, Time: time.Now()}
+	s.changes = append(s.changes, change)
+	if len(s.changes) > maxChanges {
+		s.changes = s.changes[len(s.changes)-maxChanges:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+	// 잠금 안에서 보내야 구독자가 변경을 순서대로 받습니다. Publish는 기다리지 않습니다.
+	if s.bus != nil {
+		s.bus.Publish(TopicItems, change)
+	}
+	return change
+}
+
+// 마지막으로 item이 바뀐 시각. 바뀐 적이 없으면 0
+func (s *ItemStore) LastModified() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.changes) == 0 {
+		return time.Time{}
+	}
+	return s.changes[len(s.changes)-1].Time
+}
+
+// since 이후의 변경들과 마지막 순서 번호를 돌려줍니다.
+// since가 너무 오래되어 잊어버린 변경은 빠집니다. (최근 maxChanges 개만 기억)
+func (s *ItemStore) ChangesSince(since uint64) ([]ItemChange, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changesSince(since), s.seq
+}
+
+// mu를 잡은 상태에서 불러야 합니다.
+func (s *ItemStore) changesSince(since uint64) []ItemChange {
+	i := sort.Search(len(s.changes), func(i int) bool { return s.changes[i].Seq > since })
+	return append([]ItemChange(nil), s.changes[i:]...)
+}
+
+// since 이후의 변경이 생길 때까지 기다립니다.
+// 이미 있으면 바로 돌려주고, ctx가 끝나면 빈 목록을 돌려줍니다.
+func (s *ItemStore) WaitChanges(ctx context.Context, since uint64) ([]ItemChange, uint64) {
+	for {
+		s.mu.RLock()
+		changes, last, changed := s.changesSince(since), s.seq, s.changed
+		s.mu.RUnlock()
+		if len(changes) > 0 {
+			return changes, last
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, last
+		}
+	}
+}
+
+// 모든 item을 이름 순서로 돌려줍니다.
+func (s *ItemStore) List() []Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Item, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// 저장된 item의 개수
+func (s *ItemStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
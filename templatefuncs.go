@@ -0,0 +1,122 @@
+//
+// templatefuncs.go
+//
+// 모든 HTML 템플릿에서 쓸 수 있는 함수들입니다.
+// 템플릿에 글자나 주소를 직접 적지 않고 이 함수들로 만듭니다.
+//
+//   {{t "nav.home"}}                 =>  home / 홈           (messages.go 의 uiCatalog)
+//   {{t "home.count" 3}}             =>  The store has 3 items.
+//   {{date .ServerTime}}             =>  Oct 16, 2026 07:45 / 2026년 10월 16일 07:45
+//   {{number 1234567}}               =>  1,234,567
+//   {{url "item" "yellow"}}          =>  /item/yellow
+//   {{asset "js/home.js"}}           =>  /static/js/home.9add26d1.js  (static.go)
+//   {{lang}}                         =>  en / ko
+//   {{range menu}}...{{end}}         =>  pages/ 의 메뉴 페이지들  (pages.go)
+//   <script nonce="{{nonce}}">       =>  요청마다 바뀌는 CSP nonce  (csp.go)
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 이름 -> 주소. {} 자리에는 url 함수의 인자가 경로에 맞게 이스케이프되어 들어갑니다.
+var routeURLs = map[string]string{
+	"home":   "/home",
+	"items":  "/items",
+	"item":   "/item/{}",
+	"docs":   "/docs/{}",
+	"chat":   "/chat",
+	"form":   "/form",
+	"login":  "/login",
+	"logout": "/logout",
+}
+
+// 언어별 날짜 형식
+var dateFormats = map[string]string{
+	"ko": "2006년 1월 2일 15:04",
+	"en": "Jan 2, 2006 15:04",
+}
+
+// lang 언어의 템플릿에서 쓸 함수들
+func TemplateFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string, args ...interface{}) string {
+			return Translate(lang, key, args...)
+		},
+		"date": func(t time.Time) string {
+			return FormatDate(lang, t)
+		},
+		"number": templateNumber,
+		"url":    RouteURL,
+		"asset":  AssetURL,
+		"lang":   func() string { return lang },
+		"menu":   MenuPages,
+		"nonce":  func() string { return cspNoncePlaceholder },
+	}
+}
+
+// lang 언어의 형식으로 날짜를 씁니다.
+func FormatDate(lang string, t time.Time) string {
+	format, ok := dateFormats[lang]
+	if !ok {
+		format = dateFormats[DefaultLanguage]
+	}
+	return t.Format(format)
+}
+
+// 템플릿의 number 함수. int와 int64를 모두 받습니다.
+func templateNumber(n interface{}) (string, error) {
+	switch v := n.(type) {
+	case int:
+		return FormatNumber(int64(v)), nil
+	case int64:
+		return FormatNumber(v), nil
+	}
+	return "", fmt.Errorf("number: %T is not an integer", n)
+}
+
+// 정수를 세 자리마다 쉼표를 넣어 씁니다. 1234567 => "1,234,567"
+func FormatNumber(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var out strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(d)
+	}
+	return sign + out.String()
+}
+
+// 이름이 name인 주소를 만듭니다. 인자는 순서대로 {} 자리에 들어갑니다.
+func RouteURL(name string, args ...string) (string, error) {
+	pattern, ok := routeURLs[name]
+	if !ok {
+		return "", fmt.Errorf("url: unknown route %q", name)
+	}
+	for _, arg := range args {
+		pattern = strings.Replace(pattern, "{}", url.PathEscape(arg), 1)
+	}
+	return strings.ReplaceAll(pattern, "{}", ""), nil
+}
+
+// 정적 파일 핸들러. main에서 만들고, 템플릿의 asset 함수가 씁니다.
+var staticFiles *StaticHandler
+
+// 정적 파일 name의 주소. 지문이 붙은 주소가 있으면 그것을 씁니다.
+func AssetURL(name string) string {
+	if staticFiles == nil {
+		return "/static/" + strings.TrimPrefix(name, "/")
+	}
+	return staticFiles.AssetURL(name)
+}
@@ -0,0 +1,376 @@
+//
+// templates.go
+//
+// html/template 으로 만드는 HTML 페이지들입니다.
+// 템플릿은 서버가 시작할 때 LoadTemplates 로 한 번만 읽어 둡니다.
+//
+// home.html 과 templates/ 는 //go:embed 로 실행 파일 안에 들어가므로
+// 어느 디렉토리에서 실행해도 됩니다. 개발 중에는 설정 파일의 "dev_dir" 로
+// 디스크의 파일을 대신 읽게 할 수 있습니다. (재컴파일 없이 HTML 수정)
+//
+// -dev 플래그로 실행하면 요청마다 템플릿 파일의 수정 시간을 확인하고, 바뀐 파일이
+// 있을 때만 다시 읽습니다. HTML을 고치고 브라우저를 새로고침하기만 하면 됩니다.
+// 운영 모드에서는 시작할 때 한 번만 읽습니다.
+//
+// html/template 은 {{.User}} 같은 값을 문맥에 맞게 이스케이프해주므로
+// 사용자가 넣은 값이 그대로 HTML/JavaScript가 되는 일이 없습니다.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 실행 파일에 들어가는 HTML 파일과 정적 파일들
+//
+//go:embed home.html templates static docs pages
+var embeddedAssets embed.FS
+
+// 템플릿을 읽어올 파일 시스템. 기본은 실행 파일 안의 embeddedAssets 입니다.
+var assets fs.FS = embeddedAssets
+
+// dir가 비어 있지 않으면 실행 파일 안의 파일 대신 디스크의 dir에서 읽습니다.
+func UseAssetDir(dir string) {
+	if dir != "" {
+		assets = os.DirFS(dir)
+	}
+}
+
+// 개발 모드를 켭니다. 템플릿을 디스크에서 (dir가 비어 있으면 현재 디렉토리에서)
+// 요청마다 다시 읽습니다.
+func EnableDevMode(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	UseAssetDir(dir)
+	reloadTemplates = true
+}
+
+// 레이아웃과 partial(header, nav, footer)을 모든 페이지가 함께 쓰는 HTML 렌더러
+//
+// 페이지 템플릿은 레이아웃의 빈칸("title", "head", "heading", "content")만
+// {{define}} 으로 채우면 됩니다. 예) templates/item.html
+// <head>의 메타 태그는 레이아웃이 PageMeta로 채웁니다. (meta.go)
+//
+//	templates/layout.html           <html> 뼈대. "layout" 템플릿
+//	templates/partials/*.html       header, nav, footer
+//	home.html, templates/item.html  페이지
+//
+// 템플릿 함수(templatefuncs.go)의 {{t "key"}} 가 언어마다 다른 글자를 내야 하므로
+// 지원하는 언어마다 템플릿을 따로 읽어 둡니다. 요청마다 Accept-Language로 고릅니다.
+type Renderer struct {
+	base  map[string]*template.Template            // 언어 -> 레이아웃 + partial
+	pages map[string]map[string]*template.Template // 언어 -> 페이지 이름 -> 레이아웃에 페이지를 합친 템플릿
+}
+
+// fsys에서 레이아웃과 partial을 읽어 렌더러를 만듭니다.
+func NewRenderer(fsys fs.FS) (*Renderer, error) {
+	r := &Renderer{
+		base:  make(map[string]*template.Template),
+		pages: make(map[string]map[string]*template.Template),
+	}
+	for _, lang := range Languages {
+		base, err := template.New("").Funcs(TemplateFuncs(lang)).ParseFS(fsys, "templates/layout.html", "templates/partials/*.html")
+		if err != nil {
+			return nil, err
+		}
+		r.base[lang] = base
+		r.pages[lang] = make(map[string]*template.Template)
+	}
+	return r, nil
+}
+
+// fsys의 path 파일을 name 이라는 페이지로 등록합니다.
+// 레이아웃을 복사한 위에 페이지를 읽으므로 페이지끼리 서로 영향을 주지 않습니다.
+func (r *Renderer) AddPage(fsys fs.FS, name, path string) error {
+	for lang, base := range r.base {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := t.ParseFS(fsys, path); err != nil {
+			return err
+		}
+		r.pages[lang][name] = t
+	}
+	return nil
+}
+
+// name 페이지를 레이아웃에 넣어 클라이언트의 언어로 status와 함께 응답합니다.
+// 템플릿을 먼저 버퍼에 실행해 보고, 성공하면 응답합니다.
+// 도중에 실패해도 반쯤 쓰인 페이지 대신 500 에러를 보낼 수 있습니다.
+func (r *Renderer) Render(response http.ResponseWriter, request *http.Request, status int, name string, data interface{}) {
+	lang := PreferredLanguage(request)
+	// 버퍼는 풀에서 빌려 쓰고 응답을 다 쓴 뒤에 돌려줍니다. (bufpool.go)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	page, err := r.executeTo(buffer, lang, name, PageMetaFor(request, data), data)
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	WriteHTML(response, request, lang, status, page)
+}
+
+// name 페이지를 lang 언어로 만들어 돌려줍니다.
+// 레이아웃은 meta로 <head>의 메타 태그를 채우고, 페이지의 블록들은 data를 받습니다.
+func (r *Renderer) Execute(lang, name string, meta PageMeta, data interface{}) ([]byte, error) {
+	return r.executeTo(new(bytes.Buffer), lang, name, meta, data)
+}
+
+// Execute 와 같지만 buffer에 만듭니다. 돌려주는 페이지는 buffer의 내용일 수 있습니다.
+func (r *Renderer) executeTo(buffer *bytes.Buffer, lang, name string, meta PageMeta, data interface{}) ([]byte, error) {
+	t, ok := r.pages[lang][name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	if err := t.ExecuteTemplate(buffer, "layout", PageContext{Data: data, Meta: meta}); err != nil {
+		return nil, fmt.Errorf("template %s error %v", name, err)
+	}
+	if minifyConfig.HTML {
+		return MinifyHTML(buffer.Bytes()), nil
+	}
+	return buffer.Bytes(), nil
+}
+
+// 다 만들어진 HTML 페이지를 status로 응답합니다.
+// 페이지의 {{nonce}} 는 여기서 요청의 CSP nonce 로 바뀝니다. (csp.go)
+func WriteHTML(response http.ResponseWriter, request *http.Request, lang string, status int, page []byte) {
+	SetContentType(response, "text/html")
+	response.Header().Set("Content-Language", lang)
+	response.Header().Add("Vary", "Accept-Language")
+	if liveReload != nil {
+		page = InjectLiveReload(page)
+	}
+	page = applyCSPNonce(request, page)
+	response.WriteHeader(status)
+	response.Write(page)
+}
+
+// 서버 전체가 쓰는 렌더러. main에서 LoadTemplates로 만듭니다.
+var renderer *Renderer
+
+// true이면 템플릿 파일이 바뀔 때마다 다시 읽습니다. (-dev 플래그)
+var reloadTemplates bool
+
+// 개발 모드에서 마지막으로 읽은 템플릿과 그때의 파일 수정 시간들
+var devTemplates struct {
+	mu       sync.Mutex
+	renderer *Renderer
+	stamp    string
+}
+
+// 서버가 보여주는 페이지들. 페이지 이름 -> 파일
+var pageFiles = map[string]string{
+	"home":      "home.html",
+	"item":      "templates/item.html",
+	"listing":   "templates/listing.html",
+	"doc":       "templates/doc.html",
+	"chat":      "templates/chat.html",
+	"form":      "templates/form.html",
+	"login":     "templates/login.html",
+	"challenge": "templates/challenge.html",
+	"graphiql":  "templates/graphiql.html",
+	"swagger":   "templates/swagger.html",
+	"404":       "templates/404.html",
+	"500":       "templates/500.html",
+	"error":     "templates/error.html",
+}
+
+// home.html 에 넘겨주는 값들
+type HomePage struct {
+	ServerTime time.Time
+	ItemCount  int
+	User       string // 로그인한 사용자 이름. 로그인하지 않았으면 비어 있습니다.
+	Form       FormPage
+}
+
+// 레이아웃, partial, 페이지 템플릿을 모두 읽어 둡니다.
+func LoadTemplates() error {
+	r, err := parseTemplates()
+	if err != nil {
+		return err
+	}
+	renderer = r
+	return nil
+}
+
+// assets에서 레이아웃, partial, 페이지 템플릿을 읽어 새 렌더러를 만듭니다.
+func parseTemplates() (*Renderer, error) {
+	r, err := NewRenderer(assets)
+	if err != nil {
+		return nil, err
+	}
+	for name, path := range pageFiles {
+		if err := r.AddPage(assets, name, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := addSitePages(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// name 페이지를 서버의 렌더러로 응답합니다.
+// 개발 모드에서는 템플릿 파일이 바뀌었으면 새로 읽습니다.
+func RenderTemplate(response http.ResponseWriter, request *http.Request, name string, data interface{}) {
+	RenderTemplateStatus(response, request, http.StatusOK, name, data)
+}
+
+// RenderTemplate과 같지만 200 대신 status로 응답합니다. 예) 폼 검사 실패는 422
+func RenderTemplateStatus(response http.ResponseWriter, request *http.Request, status int, name string, data interface{}) {
+	r, err := currentRenderer()
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	r.Render(response, request, status, name, data)
+}
+
+// RenderTemplate 과 같지만 ETag 와 Last-Modified 를 붙이고, 클라이언트가 가진 페이지와 같으면
+// 본문 없이 304 Not Modified 로 응답합니다. modified는 페이지의 내용이 마지막으로 바뀐 시각입니다.
+//
+// ETag 는 만든 페이지의 해시이므로 modified가 맞지 않아도 다른 페이지를 같다고 하지 않습니다.
+// 해시는 CSP nonce(csp.go)를 넣기 전에 만들므로 요청마다 바뀌지 않습니다.
+//
+// key가 있으면 동시에 온 요청 중 key, 언어, 주소가 같은 것들은 템플릿을 한 번만 실행하고 페이지를 나눠 받습니다. (coalesce.go)
+// key에는 data 중 요청마다 다른 것(사용자 이름 같은)을 넣습니다. 비어 있으면 나눠 받지 않습니다.
+func RenderTemplateConditional(response http.ResponseWriter, request *http.Request, name, key string, data interface{}, modified time.Time) {
+	r, err := currentRenderer()
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	lang := PreferredLanguage(request)
+	var page []byte
+	if key == "" {
+		buffer := getBuffer()
+		defer putBuffer(buffer)
+		page, err = r.executeTo(buffer, lang, name, PageMetaFor(request, data), data)
+	} else {
+		// 메타 태그의 주소(meta.go)는 site_url 과 요청 주소로 만들므로 Host 는 key 에 넣지 않습니다.
+		flight := strings.Join([]string{"page", name, lang, request.URL.Path, key}, "\x00")
+		page, err, _ = flights.Do(flight, func() ([]byte, error) {
+			return r.Execute(lang, name, PageMetaFor(request, data), data)
+		})
+	}
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, err)
+		return
+	}
+	sum := sha256.Sum256(page)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	modified = modified.UTC().Truncate(time.Second)
+	header := response.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", modified.Format(http.TimeFormat))
+	// 브라우저가 저장해 두되 쓸 때마다 다시 물어보게 합니다.
+	header.Set("Cache-Control", "private, no-cache")
+	if notModified(request, etag, modified) {
+		// 304 의 헤더는 브라우저가 가진 페이지의 헤더를 덮어씁니다.
+		// 새 nonce 의 CSP 를 보내면 저장된 페이지의 스크립트가 막히므로 보내지 않습니다.
+		header.Del("Content-Security-Policy")
+		header.Del("Content-Security-Policy-Report-Only")
+		header.Set("Content-Language", lang)
+		header.Add("Vary", "Accept-Language")
+		response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	WriteHTML(response, request, lang, http.StatusOK, page)
+}
+
+// 조건부 GET 의 If-None-Match (없으면 If-Modified-Since) 가 지금 페이지와 맞는지
+func notModified(request *http.Request, etag string, modified time.Time) bool {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return false
+	}
+	if match := request.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			// 압축(compress.go)한 응답의 ETag 는 W/ 가 붙은 약한 ETag 입니다.
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// 지금 쓸 렌더러. 개발 모드에서는 템플릿 파일이 바뀌었으면 새로 읽습니다.
+func currentRenderer() (*Renderer, error) {
+	if !reloadTemplates {
+		if renderer == nil {
+			return nil, fmt.Errorf("templates are not loaded")
+		}
+		return renderer, nil
+	}
+	r, err := currentDevTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("template reload error %v", err)
+	}
+	return r, nil
+}
+
+// 템플릿 파일의 수정 시간이 마지막으로 읽었을 때와 다르면 다시 읽고,
+// 같으면 메모리에 있는 렌더러를 그대로 씁니다.
+func currentDevTemplates() (*Renderer, error) {
+	stamp, err := templateStamp()
+	if err != nil {
+		return nil, err
+	}
+	devTemplates.mu.Lock()
+	defer devTemplates.mu.Unlock()
+	if devTemplates.renderer != nil && devTemplates.stamp == stamp {
+		return devTemplates.renderer, nil
+	}
+	r, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+	devTemplates.renderer, devTemplates.stamp = r, stamp
+	return r, nil
+}
+
+// 템플릿 파일들의 이름, 크기, 수정 시간을 하나의 문자열로 만듭니다.
+// 어느 파일이든 바뀌거나 추가, 삭제되면 값이 달라집니다.
+func templateStamp() (string, error) {
+	var stamp strings.Builder
+	add := func(fsys fs.FS, root string) error {
+		return fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&stamp, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	for _, root := range []string{"home.html", "templates"} {
+		if err := add(assets, root); err != nil {
+			return "", err
+		}
+	}
+	if pagesFS != nil {
+		if err := add(pagesFS, "."); err != nil {
+			return "", err
+		}
+	}
+	return stamp.String(), nil
+}
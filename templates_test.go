@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// 개발 모드(-dev)로 바꾸고, 벤치마크가 끝나면 되돌립니다.
+func useDevTemplates(tb testing.TB) {
+	tb.Helper()
+	oldAssets, oldReload := assets, reloadTemplates
+	assets, reloadTemplates = os.DirFS("."), true
+	tb.Cleanup(func() {
+		assets, reloadTemplates = oldAssets, oldReload
+		devTemplates.mu.Lock()
+		devTemplates.renderer, devTemplates.stamp = nil, ""
+		devTemplates.mu.Unlock()
+	})
+}
+
+// 개발 모드에서 템플릿 파일이 바뀌지 않았으면 다시 읽지 않고 수정 시간만 확인합니다.
+func BenchmarkRenderTemplateDev(b *testing.B) {
+	useDevTemplates(b)
+	request := httptest.NewRequest(http.MethodGet, "/item/green", nil)
+	item := Item{Name: "green", What: "item"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder := httptest.NewRecorder()
+		RenderTemplate(recorder, request, "item", item)
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("status %d", recorder.Code)
+		}
+	}
+}
+
+// 파일이 그대로면 같은 렌더러를 다시 씁니다.
+func TestDevTemplatesReused(t *testing.T) {
+	useDevTemplates(t)
+	first, err := currentDevTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := currentDevTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("templates were parsed again although no file changed")
+	}
+}
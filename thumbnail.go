@@ -0,0 +1,135 @@
+//
+// thumbnail.go
+//
+// 업로드된 파일이 이미지(PNG, JPEG, GIF)이면 워커 풀(workpool.go)에서 작은 썸네일들을 만듭니다.
+//
+//   GET /files/{id}/thumb/{size}   가로세로 중 긴 쪽이 size 픽셀인 PNG
+//
+// 만들 크기는 설정 파일의 "upload": {"thumbnail_sizes": [64, 256]} 입니다.
+// 썸네일은 업로드 응답을 보낸 뒤에 만들어지므로 잠시 동안은 404가 날 수 있습니다.
+// 워커 풀의 큐가 가득 차 있으면 그 파일의 썸네일은 만들지 않고 경고만 남깁니다.
+// 외부 라이브러리 없이 표준 라이브러리의 image 패키지와 간단한 평균 필터로 줄입니다.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// 이보다 픽셀이 많은 이미지는 메모리를 너무 많이 쓰므로 썸네일을 만들지 않습니다.
+const maxThumbnailSourcePixels = 50 << 20
+
+// id의 size 썸네일 경로
+func (s *FileStore) thumbPath(id string, size int) string {
+	return fmt.Sprintf("%s.thumb-%d.png", s.path(id), size)
+}
+
+// 썸네일을 만들 수 있는 파일인지
+func isThumbnailable(f StoredFile) bool {
+	return f.Type == "image/png" || f.Type == "image/jpeg" || f.Type == "image/gif"
+}
+
+// f의 썸네일 만들기를 워커 풀에 맡깁니다. 업로드 핸들러가 부릅니다.
+func QueueThumbnails(store *FileStore, f StoredFile, sizes []int) {
+	if !isThumbnailable(f) || len(sizes) == 0 {
+		return
+	}
+	if err := workers.Submit(func() { GenerateThumbnails(store, f, sizes) }); err != nil {
+		log.Printf("WARN thumbnail %s skipped: %v", f.ID, err)
+	}
+}
+
+// f의 썸네일들을 만듭니다.
+func GenerateThumbnails(store *FileStore, f StoredFile, sizes []int) {
+	if !isThumbnailable(f) || len(sizes) == 0 {
+		return
+	}
+	if err := generateThumbnails(store, f, sizes); err != nil {
+		log.Printf("thumbnail %s: %v", f.ID, err)
+	}
+}
+
+func generateThumbnails(store *FileStore, f StoredFile, sizes []int) error {
+	file, _, err := store.Open(f.ID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > maxThumbnailSourcePixels {
+		return fmt.Errorf("image too large (%dx%d)", config.Width, config.Height)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+	for _, size := range sizes {
+		if err := writeThumbnail(store.thumbPath(f.ID, size), Thumbnail(src, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 다 쓴 다음에 이름을 바꾸므로 반쯤 쓰인 썸네일이 보이지 않습니다.
+func writeThumbnail(path string, img image.Image) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 긴 쪽이 size 픽셀이 되도록 줄인 이미지를 만듭니다. 이미 작으면 크기를 그대로 둡니다.
+// 새 픽셀 하나는 원본에서 그 픽셀이 덮는 영역의 평균입니다.
+func Thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > size {
+		tw, th = size, max(1, h*size/w)
+	} else if h > w && h > size {
+		tw, th = max(1, w*size/h), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,248 @@
+//
+// totp.go
+//
+// 2단계 인증(TOTP, RFC 6238)입니다. 켠 사용자는 비밀번호 다음에 인증 앱의 6자리 코드를 넣어야 로그인됩니다.
+//
+// 켜기 (로그인한 상태에서)
+//
+//   POST /2fa/enroll     {"secret":"JBSWY3DPEHPK3PXP","uri":"otpauth://totp/go-webserver:alice?secret=...&issuer=go-webserver"}
+//                        uri 를 QR 코드로 만들어 인증 앱(Google Authenticator 등)으로 찍거나 secret 을 직접 넣습니다.
+//   POST /2fa/confirm    {"code":"123456"}  앱의 코드로 확인하면 켜지고, 복구 코드 10개를 한 번만 보여줍니다.
+//                        {"recovery_codes":["k3m9-x2pq-7h", ...]}
+//   POST /2fa/disable    {"code":"123456"}  끄기
+//
+// 로그인 (login.go)
+//
+//   JSON 은 {"username":"alice","password":"...","code":"123456"} 로 한 번에 보냅니다.
+//   코드가 없으면 401 {"totp_required":true}
+//   폼은 비밀번호가 맞으면 코드를 묻는 폼을 다시 보여줍니다. 그 사이의 상태는 5분짜리
+//   서명된 "login_2fa" 쿠키(securecookie.go)에 둡니다.
+//
+// 휴대폰을 잃어버렸으면 코드 대신 복구 코드를 넣습니다. 복구 코드는 한 번씩만 쓸 수 있습니다.
+// 틀린 코드도 로그인 실패로 세어 잠급니다. (lockout.go)
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP 코드 하나가 쓰이는 시간
+const totpPeriod = 30
+
+// 시계가 조금 어긋난 휴대폰도 받도록 앞뒤로 받는 시간 단계 수
+const totpSkew = 1
+
+// 복구 코드의 수
+const recoveryCodeCount = 10
+
+// 인증 앱에 보이는 발급자 이름. main에서 설정으로 채웁니다.
+var totpIssuer = DefaultConfig().Login.TOTPIssuer
+
+// 비밀번호를 확인하고 코드를 기다리는 동안의 쿠키와 그 유효 시간
+const (
+	loginTOTPCookie = "login_2fa"
+	loginTOTPTTL    = 5 * time.Minute
+)
+
+var errBadTOTP = errors.New("invalid code")
+
+// 비밀 키는 base32 (패딩 없이) 로 주고받습니다.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 새 비밀 키 (160비트)
+func newTOTPSecret() string {
+	key := make([]byte, 20)
+	rand.Read(key)
+	return totpEncoding.EncodeToString(key)
+}
+
+// 시간 단계 step의 6자리 코드 (RFC 4226)
+func totpCode(key []byte, step int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// code가 secret의 지금 코드이고 lastStep 다음 것인지. 맞으면 그 시간 단계를 돌려줍니다.
+func checkTOTP(secret, code string, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 || len(code) != 6 {
+		return 0, false
+	}
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		// 이미 쓴 코드(와 그 이전 코드)는 받지 않습니다.
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// 인증 앱에 넣는 otpauth:// 주소
+func totpURI(user, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + user)
+	query := url.Values{"secret": {secret}, "issuer": {totpIssuer}, "period": {"30"}, "digits": {"6"}}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// 새 복구 코드들과 그 해시들
+func newRecoveryCodes() (codes, hashes []string) {
+	const letters = "abcdefghjkmnpqrstuvwxyz23456789"
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		rand.Read(b)
+		for j := range b {
+			b[j] = letters[int(b[j])%len(letters)]
+		}
+		code := string(b[:4]) + "-" + string(b[4:8]) + "-" + string(b[8:])
+		codes = append(codes, code)
+		hashes = append(hashes, recoveryCodeHash(code))
+	}
+	return codes, hashes
+}
+
+func recoveryCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// 2단계 인증을 켠 사용자인지
+func totpEnabled(name string) bool {
+	user, ok := users.Get(name)
+	return ok && user.TOTPSecret != ""
+}
+
+// name의 TOTP 코드나 복구 코드를 확인합니다. 맞으면 다시 쓰지 못하게 기록합니다.
+func verifySecondFactor(name, code string) bool {
+	code = strings.TrimSpace(code)
+	err := users.Update(name, func(user *User) error {
+		if step, ok := checkTOTP(user.TOTPSecret, code, user.TOTPLastStep); ok {
+			user.TOTPLastStep = step
+			return nil
+		}
+		hash := recoveryCodeHash(code)
+		for i, h := range user.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+				user.RecoveryCodes = append(user.RecoveryCodes[:i], user.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+		return errBadTOTP
+	})
+	return err == nil
+}
+
+// 요청 본문의 {"code":"..."}
+func readTOTPCode(response http.ResponseWriter, request *http.Request) (string, error) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 4096)).Decode(&body); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(body.Code), nil
+}
+
+// /2fa/enroll, /2fa/confirm, /2fa/disable 에 대한 응답. RequireLogin 으로 감싸서 씁니다.
+func TOTPHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		response.Header().Set("Allow", "POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	name := CurrentUser(request)
+	var result interface{}
+	switch strings.TrimPrefix(request.URL.Path, "/2fa/") {
+	case "enroll":
+		secret := newTOTPSecret()
+		err := users.Update(name, func(user *User) error {
+			if user.TOTPSecret != "" {
+				return fmt.Errorf("two-factor authentication is already enabled")
+			}
+			user.TOTPPending = secret
+			return nil
+		})
+		if err != nil {
+			WriteError(response, request, http.StatusConflict, err)
+			return
+		}
+		result = map[string]string{"secret": secret, "uri": totpURI(name, secret)}
+	case "confirm":
+		code, err := readTOTPCode(response, request)
+		if err != nil {
+			WriteError(response, request, http.StatusBadRequest, err)
+			return
+		}
+		codes, hashes := newRecoveryCodes()
+		err = users.Update(name, func(user *User) error {
+			step, ok := checkTOTP(user.TOTPPending, code, 0)
+			if !ok {
+				return errBadTOTP
+			}
+			user.TOTPSecret, user.TOTPPending, user.TOTPLastStep = user.TOTPPending, "", step
+			user.RecoveryCodes = hashes
+			return nil
+		})
+		if err != nil {
+			WriteError(response, request, http.StatusUnprocessableEntity, err)
+			return
+		}
+		audit.Record(request, "auth.2fa_enabled", name, nil)
+		result = map[string][]string{"recovery_codes": codes}
+	case "disable":
+		code, err := readTOTPCode(response, request)
+		if err != nil {
+			WriteError(response, request, http.StatusBadRequest, err)
+			return
+		}
+		if !totpEnabled(name) || !verifySecondFactor(name, code) {
+			WriteError(response, request, http.StatusUnprocessableEntity, errBadTOTP)
+			return
+		}
+		err = users.Update(name, func(user *User) error {
+			user.TOTPSecret, user.TOTPLastStep, user.RecoveryCodes = "", 0, nil
+			return nil
+		})
+		if err != nil {
+			WriteError(response, request, http.StatusInternalServerError, err)
+			return
+		}
+		audit.Record(request, "auth.2fa_disabled", name, nil)
+		result = map[string]bool{"enabled": false}
+	default:
+		WriteError(response, request, http.StatusNotFound, nil)
+		return
+	}
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(result)
+}
+
+// 비밀번호를 확인하고 코드를 기다리는 로그인. login_2fa 쿠키의 값
+type pendingLogin struct {
+	User    string `json:"user"`
+	Expires int64  `json:"expires"`
+	Next    string `json:"next"`
+}
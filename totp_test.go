@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 부록 B 의 SHA-1 값들. 8자리 코드의 마지막 6자리입니다.
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		if got := totpCode(key, test.time/totpPeriod); got != test.code {
+			t.Errorf("T=%d: code %s, want %s", test.time, got, test.code)
+		}
+	}
+}
+
+// 사용자 저장소를 메모리의 것으로 바꾸고, 테스트가 끝나면 되돌립니다.
+func useTestUsers(t *testing.T, list ...User) {
+	t.Helper()
+	store, err := OpenUserStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range list {
+		store.users[user.Name] = user
+	}
+	old := users
+	users = store
+	t.Cleanup(func() { users = old })
+}
+
+func TestCheckTOTPRejectsUsedStep(t *testing.T) {
+	secret := newTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now().Unix() / totpPeriod
+	code := totpCode(key, now)
+
+	step, ok := checkTOTP(secret, code, now-1)
+	if !ok || step != now {
+		t.Fatalf("fresh code: step %d, %v; want %d, true", step, ok, now)
+	}
+	if _, ok := checkTOTP(secret, code, now); ok {
+		t.Error("code of the last used step accepted again")
+	}
+	if _, ok := checkTOTP(secret, totpCode(key, now-1), now); ok {
+		t.Error("code older than the last used step accepted")
+	}
+	if _, ok := checkTOTP(secret, totpCode(key, now-5), 0); ok {
+		t.Error("code outside the skew accepted")
+	}
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	secret := newTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	codes, hashes := newRecoveryCodes()
+	useTestUsers(t, User{Name: "alice", TOTPSecret: secret, RecoveryCodes: hashes})
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+
+	if !verifySecondFactor("alice", code) {
+		t.Fatal("valid code rejected")
+	}
+	if verifySecondFactor("alice", code) {
+		t.Error("same code accepted twice")
+	}
+	if !verifySecondFactor("alice", strings.ToUpper(codes[0])) {
+		t.Fatal("recovery code rejected")
+	}
+	if verifySecondFactor("alice", codes[0]) {
+		t.Error("recovery code accepted twice")
+	}
+	if user, _ := users.Get("alice"); len(user.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes left, want %d", len(user.RecoveryCodes), recoveryCodeCount-1)
+	}
+}
+
+func TestTOTPDisableNeedsCode(t *testing.T) {
+	secret := newTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now().Unix() / totpPeriod
+	useTestUsers(t, User{Name: "alice", TOTPSecret: secret, TOTPLastStep: now - 2})
+	disable := func(code string) int {
+		request := httptest.NewRequest(http.MethodPost, "/2fa/disable", strings.NewReader(`{"code":"`+code+`"}`))
+		request = request.WithContext(context.WithValue(request.Context(), sessionKey{}, Session{User: "alice"}))
+		recorder := httptest.NewRecorder()
+		TOTPHandler(recorder, request)
+		return recorder.Code
+	}
+
+	wrong := "000000"
+	if totpCode(key, now) == wrong {
+		wrong = "111111"
+	}
+	if status := disable(wrong); status != http.StatusUnprocessableEntity {
+		t.Errorf("wrong code: status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	if !totpEnabled("alice") {
+		t.Fatal("disabled with a wrong code")
+	}
+	if status := disable(totpCode(key, now-2)); status != http.StatusUnprocessableEntity {
+		t.Errorf("used code: status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	if status := disable(totpCode(key, now)); status != http.StatusOK {
+		t.Errorf("valid code: status %d, want %d", status, http.StatusOK)
+	}
+	if totpEnabled("alice") {
+		t.Error("still enabled after disable")
+	}
+}
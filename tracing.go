@@ -0,0 +1,399 @@
+//
+// tracing.go
+//
+// 요청을 추적(tracing)합니다. OpenTelemetry 의 W3C Trace Context 와 OTLP/HTTP (JSON) 를
+// 외부 라이브러리 없이 필요한 만큼만 구현합니다.
+//
+// 요청마다 서버 span 하나를 만들고, 핸들러는 StartSpan 으로 그 아래에 span을 더합니다.
+// (저장소를 부르는 곳 등. item.go, upload.go, files.go)
+//
+//	_, span := StartSpan(request.Context(), "ItemStore.List")
+//	items := store.List()
+//	span.End()
+//
+// 게이트웨이가 traceparent 헤더를 보내면 같은 trace 안에 이어 붙이므로
+// Jaeger, Tempo 같은 곳에서 게이트웨이부터 저장소까지 한 번에 볼 수 있습니다.
+//
+//	traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//
+// 끝난 span은 모아 두었다가 OTLP collector의 /v1/traces 로 보냅니다. 설정 파일의 "tracing" :
+//
+//	"tracing": {"enabled": true, "endpoint": "http://localhost:4318/v1/traces",
+//	            "service_name": "go-webserver", "sample_ratio": 1, "headers": {"Authorization": "..."}}
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracing 설정
+type TracingConfig struct {
+	Enabled     bool              `json:"enabled"`
+	Endpoint    string            `json:"endpoint"`     // OTLP/HTTP traces 주소
+	ServiceName string            `json:"service_name"` // resource의 service.name
+	SampleRatio float64           `json:"sample_ratio"` // 새로 시작하는 trace 중 기록할 비율 (0~1)
+	Headers     map[string]string `json:"headers"`      // collector에 보낼 때 더할 헤더
+}
+
+// OTLP의 span 종류
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// 한 번에 보내는 span의 최대 개수와 보내는 간격
+const (
+	traceBatchSize     = 512
+	traceFlushInterval = 5 * time.Second
+)
+
+// 작업 하나의 시작과 끝. nil이어도 메소드를 부를 수 있습니다. (tracing이 꺼져 있을 때)
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+
+	name  string
+	kind  int
+	start time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+// 끝난 span을 모아 OTLP collector로 보냅니다.
+type Tracer struct {
+	config TracingConfig
+	client *http.Client
+	spans  chan *Span
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// 서버 전체가 쓰는 tracer. nil이면 tracing이 꺼져 있습니다. main에서 만듭니다.
+var tracer *Tracer
+
+// config로 tracer를 만들고 보내는 goroutine을 시작합니다. 꺼져 있으면 nil
+func NewTracer(config TracingConfig) *Tracer {
+	if !config.Enabled {
+		return nil
+	}
+	t := &Tracer{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan *Span, 4*traceBatchSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// 남은 span을 모두 보내고 멈춥니다.
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			log.Printf("tracing: %d spans dropped: %v", len(batch), err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-t.spans:
+			batch = append(batch, span)
+			if len(batch) >= traceBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case span := <-t.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// span들을 OTLP/HTTP JSON 으로 보냅니다.
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.otlp(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, t.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range t.config.Headers {
+		request.Header.Set(name, value)
+	}
+	response, err := t.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", response.Status)
+	}
+	return nil
+}
+
+// ExportTraceServiceRequest 의 JSON 형태. id는 hex, 시간과 int 값은 문자열입니다.
+func (t *Tracer) otlp(spans []*Span) map[string]interface{} {
+	list := make([]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parentID != ([8]byte{}) {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.err}
+		}
+		s.mu.Unlock()
+		list = append(list, span)
+	}
+	service := t.config.ServiceName
+	if service == "" {
+		service = "go-webserver"
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "webserver"},
+				"spans": list,
+			}},
+		}},
+	}
+}
+
+// OTLP의 KeyValue 목록
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	list := make([]interface{}, 0, len(attributes))
+	for key, value := range attributes {
+		var v map[string]interface{}
+		switch value := value.(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": value}
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		list = append(list, map[string]interface{}{"key": key, "value": v})
+	}
+	return list
+}
+
+type spanContextKey struct{}
+
+// ctx에 들어 있는 span. 없으면 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// ctx의 span 아래에 name 이라는 span을 시작합니다.
+// ctx에 span이 없으면 (tracing이 꺼져 있거나 요청 밖이면) nil span을 돌려줍니다.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := parent.tracer.newSpan(name, SpanKindInternal, parent.traceID, parent.spanID, parent.sampled)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+func (t *Tracer) newSpan(name string, kind int, traceID [16]byte, parentID [8]byte, sampled bool) *Span {
+	span := &Span{
+		tracer:     t,
+		traceID:    traceID,
+		parentID:   parentID,
+		sampled:    sampled,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// span에 속성을 더합니다. 예) span.SetAttribute("item.count", len(items))
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// 작업이 실패했다고 표시합니다.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// span을 끝냅니다. 기록할 span이면 보낼 목록에 넣습니다.
+// 보내는 쪽이 밀려 있으면 기다리지 않고 버립니다.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	if !s.sampled {
+		return
+	}
+	select {
+	case s.tracer.spans <- s:
+	default:
+	}
+}
+
+// W3C traceparent 헤더 값
+func (s *Span) Traceparent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-" + flags
+}
+
+// 다른 서버로 보내는 요청에 ctx의 traceparent를 붙입니다.
+func InjectTraceparent(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set("Traceparent", span.Traceparent())
+	}
+}
+
+// traceparent 헤더를 읽습니다. 형식이 틀리면 ok가 false
+func parseTraceparent(value string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == ([16]byte{}) {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == ([8]byte{}) {
+		return traceID, parentID, false, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return traceID, parentID, false, false
+	}
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// 새 trace를 기록할지 정합니다. trace id의 뒷부분으로 정하므로 같은 trace는 늘 같은 결과입니다.
+func (t *Tracer) sample(traceID [16]byte) bool {
+	ratio := t.config.SampleRatio
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(traceID[8:])>>11)/(1<<53) < ratio
+}
+
+// 요청마다 서버 span을 만드는 미들웨어. t가 nil이면 next를 그대로 돌려줍니다.
+// span 이름은 "GET /item/" 처럼 메소드와 routes의 패턴입니다. (metrics.go 의 RoutePattern)
+func TracingHandler(t *Tracer, routes *http.ServeMux, next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if isProbe(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		traceID, parentID, sampled, ok := parseTraceparent(request.Header.Get("Traceparent"))
+		if !ok {
+			rand.Read(traceID[:])
+			parentID, sampled = [8]byte{}, t.sample(traceID)
+		}
+		route := RoutePattern(routes, request)
+		span := t.newSpan(request.Method+" "+route, SpanKindServer, traceID, parentID, sampled)
+		span.SetAttribute("http.request.method", request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", request.URL.Path)
+		span.SetAttribute("user_agent.original", request.UserAgent())
+		span.SetAttribute("client.address", request.RemoteAddr)
+
+		recorder := newResponseRecorder(response)
+		next.ServeHTTP(recorder, request.WithContext(context.WithValue(request.Context(), spanContextKey{}, span)))
+
+		status := recorder.Status()
+		span.SetAttribute("http.response.status_code", status)
+		span.SetAttribute("http.response.body.size", recorder.size)
+		if status >= 500 {
+			span.SetError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+		span.End()
+	})
+}
@@ -0,0 +1,270 @@
+//
+// upload.go
+//
+// POST /upload 는 multipart/form-data 로 보낸 파일들을 저장하고,
+// 저장한 파일들의 정보를 JSON으로 돌려줍니다. (저장은 filestore.go)
+//
+//   $ curl -F file=@cat.png -F file=@notes.txt http://localhost:8080/upload
+//   {"files":[{"id":"3f2a...","name":"cat.png","size":1234,"type":"image/png","uploaded":"..."}, ...]}
+//
+// 받은 파일은 /files/{id}/download 로 내려받을 수 있습니다. (files.go)
+// ?progress=ID 를 붙이면 /upload/progress/ID 로 진행 상황을 볼 수 있습니다. (progress.go)
+//
+// 설정 파일의 "upload" 로 제한을 바꿀 수 있습니다.
+//   - max_file_size  : 파일 하나의 최대 크기 (넘으면 413)
+//   - max_total_size : 요청 전체의 최대 크기 (넘으면 413)
+//   - allowed_extensions, allowed_types : 허용할 확장자와 MIME type (아니면 415)
+//     MIME type은 클라이언트가 보낸 값을 믿지 않고 파일의 앞부분을 보고 알아냅니다.
+//
+// 요청 안의 파일 하나라도 거절되면 그 요청으로 저장한 파일들도 모두 지웁니다.
+// 검사기(scanner.go)가 거절한 파일은 격리되고 422로 응답합니다.
+//
+// "quota" 를 정하면 한 사람(X-API-Key 의 이름, 없으면 IP 주소)이 올린 파일들의 크기 합을
+// 제한합니다. 요청 하나가 quota 보다 크면 413, 남은 공간이 모자라면 507로 거절합니다.
+// 남은 공간은 GET /upload/quota 로 볼 수 있습니다.
+// 파일은 메모리에 모으지 않고 받는 대로 디스크에 씁니다. (saveUploads)
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// 업로드 설정
+type UploadConfig struct {
+	Dir               string   `json:"dir"`                // 파일을 저장할 디렉토리
+	MaxFileSize       int64    `json:"max_file_size"`      // 바이트
+	MaxTotalSize      int64    `json:"max_total_size"`     // 바이트
+	AllowedExtensions []string `json:"allowed_extensions"` // 예) ".png". 비어 있으면 모두 허용
+	AllowedTypes      []string `json:"allowed_types"`      // 예) "image/*". 비어 있으면 모두 허용
+	ThumbnailSizes    []int    `json:"thumbnail_sizes"`    // 이미지에 만들 썸네일의 크기들(픽셀). thumbnail.go
+	ScanCommand       []string `json:"scan_command"`       // 업로드를 검사할 명령. scanner.go
+	SignedDownloads   bool     `json:"signed_downloads"`   // 내려받기와 썸네일에 서명된 주소가 필요한지. signedurl.go
+	SigningKey        string   `json:"signing_key"`        // 주소 서명 키. 비어 있으면 시작할 때 무작위로 만듭니다.
+	ShareTTL          int      `json:"share_ttl"`          // 서명된 주소를 쓸 수 있는 시간(초)
+	Quota             int64    `json:"quota"`              // 한 사람(API 키 또는 IP)이 쓸 수 있는 바이트. 0이면 제한 없음
+	ResumableTTL      int      `json:"resumable_ttl"`      // 이 시간(초) 동안 받지 않은 이어받기 업로드를 지웁니다. 0이면 지우지 않음. resumable.go
+}
+
+// owner가 더 올릴 수 있는 바이트. 제한이 없으면 -1
+// 받기 전에 미리 거절할 때만 씁니다. 동시에 올리는 파일들은 저장소(FileStore.Quota)가 넣을 때 다시 확인합니다.
+func (c UploadConfig) remaining(store *FileStore, owner string) int64 {
+	if c.Quota <= 0 {
+		return -1
+	}
+	used, _ := store.Usage(owner)
+	return max(0, c.Quota-used)
+}
+
+// 확장자와 MIME type이 허용된 것인지 확인합니다.
+func (c UploadConfig) allowed(name, contentType string) bool {
+	return c.allowedExtension(name) && c.allowedType(contentType)
+}
+
+// 파일 이름의 확장자가 허용된 것인지
+func (c UploadConfig) allowedExtension(name string) bool {
+	if len(c.AllowedExtensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range c.AllowedExtensions {
+		if strings.ToLower(allowed) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// MIME type이 허용된 것인지. "image/*" 처럼 끝을 *로 쓸 수 있습니다.
+func (c UploadConfig) allowedType(contentType string) bool {
+	if len(c.AllowedTypes) == 0 {
+		return true
+	}
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range c.AllowedTypes {
+		if allowed == mediatype {
+			return true
+		}
+		if prefix, wildcard := strings.CutSuffix(allowed, "/*"); wildcard && strings.HasPrefix(mediatype, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// /upload 의 응답 본문
+type UploadResponse struct {
+	Files []UploadedFile `json:"files"`
+}
+
+// 저장한 파일의 정보와 내려받을 주소
+type UploadedFile struct {
+	StoredFile
+	URL string `json:"url"`
+}
+
+// 파일 업로드 요청을 거절하는 이유
+type uploadError struct {
+	status int
+	err    error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+
+// POST /upload 핸들러를 만듭니다. 응답의 내려받기 주소는 filesHandler가 만듭니다.
+func UploadHandler(config UploadConfig, store *FileStore, filesHandler *FilesHandler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			response.Header().Set("Allow", "POST")
+			WriteError(response, request, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		owner := RequestOwner(request)
+		if config.Quota > 0 && request.ContentLength > config.Quota {
+			WriteError(response, request, http.StatusRequestEntityTooLarge, fmt.Errorf("upload: %d bytes is over quota", request.ContentLength))
+			return
+		}
+		if remaining := config.remaining(store, owner); remaining >= 0 && request.ContentLength > remaining {
+			WriteError(response, request, http.StatusInsufficientStorage, fmt.Errorf("upload %s: %w", owner, ErrQuotaExceeded))
+			return
+		}
+		request.Body = http.MaxBytesReader(response, request.Body, config.MaxTotalSize)
+		finish := trackUploadBody(request)
+		saved, err := saveUploads(config, store, owner, request)
+		finish()
+		if err != nil {
+			for _, f := range saved {
+				store.Delete(f.ID)
+			}
+			status := http.StatusBadRequest
+			var uerr *uploadError
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &uerr) {
+				status = uerr.status
+			} else if errors.As(err, &maxErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			WriteError(response, request, status, fmt.Errorf("upload: %v", err))
+			return
+		}
+		if len(saved) == 0 {
+			WriteError(response, request, http.StatusBadRequest, errors.New("upload: no files"))
+			return
+		}
+
+		var uploaded []UploadedFile
+		for _, f := range saved {
+			QueueThumbnails(store, f, config.ThumbnailSizes)
+			uploaded = append(uploaded, UploadedFile{StoredFile: f, URL: filesHandler.DownloadURL(f.ID)})
+		}
+
+		SetContentType(response, "application/json")
+		response.WriteHeader(http.StatusCreated)
+		json.NewEncoder(response).Encode(UploadResponse{Files: uploaded})
+	})
+}
+
+// 요청의 파일들을 검사하고 저장합니다. 에러가 나도 그때까지 저장한 파일들을 돌려줍니다.
+//
+// multipart.Reader 로 파트를 하나씩 읽으면서 바로 디스크에 씁니다.
+// 메모리에는 버퍼 몇 개만 있으므로 몇 GB짜리 파일도 서버의 메모리를 늘리지 않습니다.
+func saveUploads(config UploadConfig, store *FileStore, owner string, request *http.Request) ([]StoredFile, error) {
+	reader, err := request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	var saved []StoredFile
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return saved, nil
+		}
+		if err != nil {
+			return saved, err
+		}
+		if part.FileName() == "" {
+			// 파일이 아닌 폼 필드는 쓰지 않습니다.
+			part.Close()
+			continue
+		}
+		_, span := StartSpan(request.Context(), "FileStore.Save")
+		f, err := saveUpload(request.Context(), config, store, owner, part.FileName(), part)
+		part.Close()
+		span.SetAttribute("file.size", f.Size)
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, f)
+	}
+}
+
+// 파일 하나의 앞부분으로 MIME type을 알아내 검사한 뒤 저장합니다.
+func saveUpload(ctx context.Context, config UploadConfig, store *FileStore, owner, name string, r io.Reader) (StoredFile, error) {
+	reader := bufio.NewReaderSize(r, 512)
+	head, _ := reader.Peek(512)
+	contentType := http.DetectContentType(head)
+	if !config.allowed(name, contentType) {
+		return StoredFile{}, &uploadError{http.StatusUnsupportedMediaType, fmt.Errorf("%s: type %s not allowed", name, contentType)}
+	}
+	// 남은 공간이 파일 크기 제한보다 작으면 남은 공간까지만 받습니다.
+	limit, quotaLimited := config.MaxFileSize, false
+	if remaining := config.remaining(store, owner); remaining >= 0 && remaining < limit {
+		limit, quotaLimited = remaining, true
+	}
+	f, err := store.Save(ctx, owner, name, contentType, reader, limit)
+	if quotaLimited && errors.Is(err, ErrFileTooLarge) {
+		err = fmt.Errorf("%s: %w", name, ErrQuotaExceeded)
+	}
+	return f, uploadStatus(err)
+}
+
+// /upload/quota 의 응답 본문
+type QuotaStatus struct {
+	Owner     string `json:"owner"`
+	Used      int64  `json:"used"`
+	Files     int    `json:"files"`
+	Quota     int64  `json:"quota"`     // 0이면 제한 없음
+	Remaining int64  `json:"remaining"` // 제한이 없으면 -1
+}
+
+// GET /upload/quota : 요청한 사람이 쓴 공간과 남은 공간
+func QuotaHandler(config UploadConfig, store *FileStore) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		owner := RequestOwner(request)
+		used, count := store.Usage(owner)
+		SetContentType(response, "application/json")
+		response.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(response).Encode(QuotaStatus{
+			Owner:     owner,
+			Used:      used,
+			Files:     count,
+			Quota:     config.Quota,
+			Remaining: config.remaining(store, owner),
+		})
+	})
+}
+
+// 저장소의 에러에 응답할 상태 코드를 붙입니다.
+func uploadStatus(err error) error {
+	var rejected *ScanRejectedError
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return &uploadError{http.StatusInsufficientStorage, err}
+	case errors.Is(err, ErrFileTooLarge):
+		return &uploadError{http.StatusRequestEntityTooLarge, err}
+	case errors.As(err, &rejected):
+		return &uploadError{http.StatusUnprocessableEntity, err}
+	}
+	return err
+}
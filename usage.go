@@ -0,0 +1,149 @@
+//
+// usage.go
+//
+// 누가 서버를 얼마나 쓰는지 API 키(apikeys.go)마다 셉니다. 키가 없는 요청은 클라이언트 IP 로 셉니다.
+// 하루(UTC) 단위로 모아 최근 usageDays 일 치를 보관합니다. item 저장소(store.go)처럼 메모리에 두므로
+// 서버를 다시 시작하면 사라집니다.
+//
+//   GET /admin/usage              최근 7일
+//   GET /admin/usage?days=30&owner=alice
+//
+//   {"days":[{"date":"2026-10-16","owners":{"alice":{"requests":120,"bytes_in":5120,"bytes_out":88000}}}]}
+//
+// /admin/ 주소는 설정 파일의 "admin" 으로 접근을 제한합니다. (guard.go)
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 보관하는 날 수
+const usageDays = 30
+
+// 하루에 따로 세는 사용자의 최대 수. 넘으면 "other" 로 합쳐 셉니다. (IP가 끝없이 늘어나는 것을 막습니다)
+const usageMaxOwners = 10000
+
+// 사용자 하나의 하루 사용량
+type Usage struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// 하루의 사용량
+type DailyUsage struct {
+	Date   string            `json:"date"` // 2006-01-02 (UTC)
+	Owners map[string]*Usage `json:"owners"`
+}
+
+// 날짜별 사용량 저장소
+type UsageStore struct {
+	mu   sync.Mutex
+	days map[string]*DailyUsage
+}
+
+// 서버 전체가 쓰는 사용량 저장소
+var usage = &UsageStore{days: make(map[string]*DailyUsage)}
+
+// owner의 요청 하나를 더합니다.
+func (s *UsageStore) Add(owner string, at time.Time, bytesIn, bytesOut int64) {
+	date := at.UTC().Format("2006-01-02")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := s.days[date]
+	if day == nil {
+		day = &DailyUsage{Date: date, Owners: make(map[string]*Usage)}
+		s.days[date] = day
+		s.prune(at)
+	}
+	u := day.Owners[owner]
+	if u == nil {
+		if len(day.Owners) >= usageMaxOwners {
+			owner = "other"
+			u = day.Owners[owner]
+		}
+		if u == nil {
+			u = new(Usage)
+			day.Owners[owner] = u
+		}
+	}
+	u.Requests++
+	u.BytesIn += bytesIn
+	u.BytesOut += bytesOut
+}
+
+// usageDays 보다 오래된 날을 지웁니다. mu를 잡은 상태에서 불러야 합니다.
+func (s *UsageStore) prune(now time.Time) {
+	oldest := now.UTC().AddDate(0, 0, -usageDays+1).Format("2006-01-02")
+	for date := range s.days {
+		if date < oldest {
+			delete(s.days, date)
+		}
+	}
+}
+
+// 최근 days 일의 사용량을 최근 날부터 돌려줍니다. owner가 비어 있지 않으면 그 사용자만 넣습니다.
+func (s *UsageStore) Report(now time.Time, days int, owner string) []DailyUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := []DailyUsage{}
+	for i := 0; i < days; i++ {
+		date := now.UTC().AddDate(0, 0, -i).Format("2006-01-02")
+		day := s.days[date]
+		if day == nil {
+			continue
+		}
+		copied := DailyUsage{Date: date, Owners: make(map[string]*Usage)}
+		for name, u := range day.Owners {
+			if owner == "" || name == owner {
+				v := *u
+				copied.Owners[name] = &v
+			}
+		}
+		report = append(report, copied)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Date > report[j].Date })
+	return report
+}
+
+// 요청마다 요청한 사람의 사용량을 더하는 미들웨어
+func UsageHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if isProbe(request) {
+			next.ServeHTTP(response, request)
+			return
+		}
+		recorder := newResponseRecorder(response)
+		next.ServeHTTP(recorder, request)
+		bytesIn := request.ContentLength
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+		owner, _ := requestOwner(request)
+		usage.Add(owner, time.Now(), bytesIn, recorder.size)
+	})
+}
+
+// GET /admin/usage
+func UsageReportHandler(response http.ResponseWriter, request *http.Request) {
+	days := 7
+	if s := request.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > usageDays {
+			WriteError(response, request, http.StatusBadRequest, err)
+			return
+		}
+		days = n
+	}
+	SetContentType(response, "application/json")
+	response.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(response).Encode(map[string]interface{}{
+		"days": usage.Report(time.Now(), days, request.URL.Query().Get("owner")),
+	})
+}
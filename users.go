@@ -0,0 +1,384 @@
+//
+// users.go
+//
+// 로그인(login.go)과 Basic 인증(guard.go)이 확인하는 사용자들입니다.
+// 비밀번호는 그대로 두지 않고 PBKDF2-HMAC-SHA256 으로 해시해서 JSON 파일에 저장합니다.
+//
+//   "login": {"user_file": "users.json"}
+//
+//   [{"name":"alice","password_hash":"pbkdf2-sha256$600000$Yk3...$q8P...","roles":["admin"],"created":"2026-10-16T08:30:00Z"}]
+//
+// 사용자 만들기
+//
+//   $ echo 'correct horse' | webserver adduser -config server.json -roles admin alice    명령으로
+//   $ curl -d '{"username":"bob","password":"...","roles":["items:write"]}' http://localhost:8080/admin/users
+//                                                                                 서버에서 (admin 접근 제한)
+//   $ curl http://localhost:8080/admin/users                                     이름 목록
+//
+// roles 는 주소마다 필요한 역할(rbac.go)과 맞춰 봅니다.
+//
+// bcrypt 가 아니라 PBKDF2 인 까닭: bcrypt 는 표준 라이브러리가 아닌 golang.org/x/crypto 에 있고,
+// 이 서버는 표준 라이브러리만 씁니다. 비용은 반복 횟수 passwordIterations 로 정합니다.
+// (600000 회, OWASP 가 PBKDF2-HMAC-SHA256 에 권하는 값)
+//
+// 해시 앞에 방식("pbkdf2-sha256")과 반복 횟수가 들어 있으므로 다른 비용이나 방식으로 옮겨 갈 수 있습니다.
+//
+//   - 반복 횟수를 늘리면, 로그인에 성공할 때 옛 횟수의 해시를 새 횟수로 다시 만들어 저장합니다. (Verify)
+//   - bcrypt 같은 다른 방식으로 바꾸려면 checkPasswordHash 가 그 방식의 해시("$2b$...")도 확인하게 하고
+//     HashPassword 와 needsRehash 를 새 방식으로 바꿉니다. 옛 해시는 로그인할 때 새 방식이 됩니다.
+//     오래 로그인하지 않은 사용자는 관리자가 비밀번호를 다시 정합니다.
+// user_file 이 비어 있으면 파일에 저장하지 않고 메모리에만 둡니다.
+
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 새 해시를 만들 때의 PBKDF2 반복 횟수 (OWASP 권장값)
+const passwordIterations = 600000
+
+// 비밀번호의 최소 글자 수
+const minPasswordLength = 8
+
+// 사용자 이름으로 쓸 수 있는 글자
+var userNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+var (
+	ErrUserExists   = errors.New("user already exists")
+	ErrNoUser       = errors.New("no such user")
+	ErrUserName     = errors.New("user name must be 1-64 letters, digits, '.', '_' or '-'")
+	ErrWeakPassword = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+// 사용자 한 명
+type User struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Roles        []string  `json:"roles,omitempty"`
+	Created      time.Time `json:"created"`
+
+	// 2단계 인증 (totp.go)
+	TOTPSecret    string   `json:"totp_secret,omitempty"`    // 확인을 마친 비밀 키 (base32)
+	TOTPPending   string   `json:"totp_pending,omitempty"`   // 등록 중이고 아직 확인하지 않은 비밀 키
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"` // 마지막으로 받은 코드의 시간 단계. 같은 코드를 다시 쓰지 못하게 합니다.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // 복구 코드의 SHA-256 (hex)
+}
+
+// 사용자들을 메모리에 두고 바뀔 때마다 파일에 씁니다.
+type UserStore struct {
+	path string // 비어 있으면 파일에 쓰지 않습니다.
+
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// 서버 전체가 쓰는 사용자 저장소. main에서 설정으로 엽니다.
+var users = &UserStore{users: make(map[string]User)}
+
+// 없는 사용자를 확인할 때 대신 비교하는 해시. 응답 시간으로 사용자가 있는지 알 수 없게 합니다.
+var dummyPasswordHash, _ = HashPassword("dummy password")
+
+// path의 사용자 파일을 읽습니다. 파일이 없으면 빈 저장소로 시작합니다.
+func OpenUserStore(path string) (*UserStore, error) {
+	s := &UserStore{path: path, users: make(map[string]User)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []User
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, user := range list {
+		s.users[user.Name] = user
+	}
+	return s, nil
+}
+
+// roles 역할을 가진 새 사용자를 만들고 파일에 씁니다.
+func (s *UserStore) Add(name, password string, roles []string) error {
+	if !userNamePattern.MatchString(name) {
+		return ErrUserName
+	}
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[name]; ok {
+		return ErrUserExists
+	}
+	s.users[name] = User{Name: name, PasswordHash: hash, Roles: roles, Created: time.Now().UTC()}
+	if err := s.save(); err != nil {
+		delete(s.users, name)
+		return err
+	}
+	return nil
+}
+
+// name의 비밀번호가 password인지 확인합니다.
+func (s *UserStore) Verify(name, password string) bool {
+	s.mu.RLock()
+	user, ok := s.users[name]
+	s.mu.RUnlock()
+	if !ok {
+		checkPasswordHash(dummyPasswordHash, password)
+		return false
+	}
+	if !checkPasswordHash(user.PasswordHash, password) {
+		return false
+	}
+	if needsRehash(user.PasswordHash) {
+		if hash, err := HashPassword(password); err == nil {
+			err = s.Update(name, func(current *User) error {
+				// 그 사이에 비밀번호가 바뀌었으면 그대로 둡니다.
+				if current.PasswordHash == user.PasswordHash {
+					current.PasswordHash = hash
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("WARN users: rehash %s: %v", name, err)
+			}
+		}
+	}
+	return true
+}
+
+// name 사용자를 찾아 fn으로 고치고 파일에 씁니다. fn이 에러를 돌려주면 고치지 않습니다.
+func (s *UserStore) Update(name string, fn func(user *User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.users[name]
+	if !ok {
+		return ErrNoUser
+	}
+	user := old
+	user.Roles = append([]string(nil), old.Roles...)
+	user.RecoveryCodes = append([]string(nil), old.RecoveryCodes...)
+	if err := fn(&user); err != nil {
+		return err
+	}
+	s.users[name] = user
+	if err := s.save(); err != nil {
+		s.users[name] = old
+		return err
+	}
+	return nil
+}
+
+// name 사용자. 없으면 false
+func (s *UserStore) Get(name string) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[name]
+	return user, ok
+}
+
+// name의 역할들. 없는 사용자이면 nil
+func (s *UserStore) Roles(name string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.users[name].Roles
+}
+
+// 사용자 이름들 (정렬된)
+func (s *UserStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.users))
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 사용자들을 파일에 씁니다. 도중에 멈춰도 옛 파일이 남도록 임시 파일을 쓴 뒤 이름을 바꿉니다.
+// mu를 잡은 상태에서 불러야 합니다.
+func (s *UserStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		list = append(list, user)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// password의 해시. "pbkdf2-sha256$반복횟수$salt$해시" (base64)
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// hash가 지금의 방식과 반복 횟수보다 약한지. 맞는 비밀번호를 받았을 때 다시 만듭니다.
+func needsRehash(hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return true
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	return err != nil || iterations < passwordIterations
+}
+
+// password가 hash와 맞는지
+func checkPasswordHash(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// POST /admin/users 의 본문
+type userRequest struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+}
+
+// GET /admin/users 는 이름 목록, POST /admin/users 는 {"username":"...","password":"...","roles":[...]} 로 사용자를 만듭니다.
+func UsersHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		SetContentType(response, "application/json")
+		response.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(response).Encode(map[string][]string{"users": users.Names()})
+	case http.MethodPost:
+		var body userRequest
+		if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 64*1024)).Decode(&body); err != nil {
+			WriteError(response, request, http.StatusBadRequest, fmt.Errorf("user parse error %v", err))
+			return
+		}
+		switch err := users.Add(body.Username, body.Password, body.Roles); {
+		case errors.Is(err, ErrUserExists):
+			WriteError(response, request, http.StatusConflict, err)
+			return
+		case errors.Is(err, ErrUserName), errors.Is(err, ErrWeakPassword):
+			WriteError(response, request, http.StatusUnprocessableEntity, err)
+			return
+		case err != nil:
+			WriteError(response, request, http.StatusInternalServerError, err)
+			return
+		}
+		audit.Record(request, "user.created", body.Username, map[string]string{"roles": strings.Join(body.Roles, ",")})
+		SetContentType(response, "application/json")
+		response.WriteHeader(http.StatusCreated)
+		json.NewEncoder(response).Encode(map[string]string{"user": body.Username})
+	default:
+		response.Header().Set("Allow", "GET, HEAD, POST")
+		WriteError(response, request, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// webserver adduser [-config 파일] [-roles 역할,역할] 이름
+// 표준 입력의 첫 줄을 비밀번호로 읽어 설정의 user_file 에 사용자를 만듭니다.
+func AddUserCommand(args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("adduser", flag.ContinueOnError)
+	configPath := flags.String("config", "", "JSON 설정 파일 경로 (config.go 참고)")
+	roleList := flags.String("roles", "", "쉼표로 나눈 역할들. 예) admin,items:write")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: webserver adduser [-config file] [-roles a,b] name < password")
+	}
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.Login.UserFile == "" {
+		return errors.New("login.user_file is not set in the config")
+	}
+	store, err := OpenUserStore(config.Login.UserFile)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(stdin, 4096))
+	if err != nil {
+		return err
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	var roles []string
+	for _, role := range strings.Split(*roleList, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return store.Add(flags.Arg(0), strings.TrimSuffix(password, "\r"), roles)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 반복 횟수가 적은 옛 해시는 로그인에 성공할 때 지금의 횟수로 바뀝니다.
+func TestVerifyRehashesOldHash(t *testing.T) {
+	store, err := OpenUserStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+	key, err := pbkdf2.Key(sha256.New, "correct horse", salt, 1000, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := fmt.Sprintf("pbkdf2-sha256$1000$%s$%s", base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	store.users["alice"] = User{Name: "alice", PasswordHash: old}
+
+	if store.Verify("alice", "wrong horse") {
+		t.Fatal("wrong password accepted")
+	}
+	if user, _ := store.Get("alice"); user.PasswordHash != old {
+		t.Error("hash changed after a failed login")
+	}
+	if !store.Verify("alice", "correct horse") {
+		t.Fatal("old hash not accepted")
+	}
+	user, _ := store.Get("alice")
+	if want := fmt.Sprintf("pbkdf2-sha256$%d$", passwordIterations); !strings.HasPrefix(user.PasswordHash, want) {
+		t.Errorf("hash after login %q, want prefix %q", user.PasswordHash, want)
+	}
+	if !store.Verify("alice", "correct horse") {
+		t.Error("new hash not accepted")
+	}
+}
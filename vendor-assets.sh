@@ -0,0 +1,39 @@
+#!/bin/sh
+#
+# vendor-assets.sh
+#
+# Swagger UI (openapi.go) 와 개발 모드의 GraphiQL (graphql.go) 이 쓰는 라이브러리를
+# 정해진 버전으로 static/vendor/ 에 내려받습니다. 다른 출처(CDN)에서 스크립트를 받지 않으므로
+# CSP(csp.go)를 켠 채로 쓸 수 있고, CDN 의 파일이 바뀌어도 영향을 받지 않습니다.
+#
+#   $ ./vendor-assets.sh
+#   $ git add static/vendor
+#
+# 내려받은 파일은 다른 정적 파일처럼 실행 파일 안에 들어가고 지문이 붙은 주소로 나갑니다. (static.go)
+# 버전을 올리면 templates/swagger.html 과 templates/graphiql.html 의 주소도 함께 바꿉니다.
+
+set -eu
+
+root=$(cd "$(dirname "$0")" && pwd)
+out="$root/static/vendor"
+
+# 패키지@버전  파일들
+fetch() {
+	pkg=$1
+	shift
+	mkdir -p "$out/$pkg"
+	for file in "$@"; do
+		echo "$pkg/$file"
+		curl -sSfL "https://unpkg.com/$pkg/$file" -o "$out/$pkg/$(basename "$file")"
+	done
+}
+
+fetch swagger-ui-dist@5.18.2 swagger-ui.css swagger-ui-bundle.js
+fetch react@18.3.1 umd/react.production.min.js
+fetch react-dom@18.3.1 umd/react-dom.production.min.js
+fetch graphql-ws@5.16.0 umd/graphql-ws.min.js
+fetch graphiql@3.7.1 graphiql.min.css graphiql.min.js
+
+# 리뷰할 때 CDN 의 파일과 같은지 확인할 수 있도록 해시를 남깁니다.
+(cd "$out" && find . -type f ! -name SHA256SUMS | sort | xargs sha256sum >SHA256SUMS)
+echo "wrote $out"
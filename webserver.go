@@ -6,7 +6,7 @@
 // 사용 예:
 //
 //   # 백그라운드에서 사용하기
-//   $ go run webserver &
+//   $ go run . &
 //
 //   실행중에 브라우저로 페이지를 방문하세요.
 //   It responds in one of several ways : 몇 가지 방법으로 응답합니다.
@@ -30,13 +30,78 @@
 //       browser (application/json) :
 //           {"name":"yellow", "what":"item"}
 //
+//       브라우저로 직접 방문하면 (Accept: text/html) JSON 대신 HTML 페이지를 보여줍니다.
+//       Accept: application/x-gob 으로 요청하면 Go 클라이언트용 gob으로 응답합니다. (item.go)
+//
+//   (2-1) /items 는 저장된 모든 item을 JSON 배열로 보내줍니다.
+//       Accept: text/csv 또는 ?format=csv 이면 엑셀에서 열 수 있는 CSV로 보내줍니다.
+//
+//       URL: http://localhost:8097/items?format=csv&header=absent
+//       browser (text/csv) :
+//           foo,item
+//           purple,item
+//           yellow,item
+//
+//       POST /items 로 JSON item을 보내면 저장소에 추가합니다.
+//       /items/changes?since=N 은 N번 이후의 변경을 long polling으로 기다립니다. (changes.go)
+//       /items/events 는 같은 변경을 SSE로, /items/ws 는 WebSocket으로 보내줍니다. (events.go)
+//
+//   (2-2) /static/ 아래의 정적 파일(JS, CSS)을 캐시 헤더와 함께 보내줍니다. (static.go)
+//
+//       URL: http://localhost:8097/static/js/home.js
+//
+//   (2-3) /docs/ 는 docs/ 디렉토리의 Markdown 문서를 페이지로 보여줍니다. (docs.go)
+//
+//       URL: http://localhost:8097/docs/rest
+//
+//   (2-4) pages/ 디렉토리의 HTML 파일은 파일 이름의 주소로 보여줍니다. (pages.go)
+//
+//       URL: http://localhost:8097/about
+//
+//   (2-5) /ws 는 받은 메시지를 그대로 돌려주는 WebSocket echo 서비스입니다. (websocket.go)
+//
+//       URL: ws://localhost:8097/ws
+//
+//       /chat 은 WebSocket 채팅방이고, /presence 는 지금 연결된 실시간 클라이언트 수를 보여줍니다. (presence.go)
+//
+//   (2-6) /form 은 POST로 받은 폼 값을 서버에서 검사하고 결과를 보여줍니다. (form.go)
+//
+//       URL: http://localhost:8097/form
+//
+//   (2-7) POST /upload 는 multipart/form-data 로 보낸 파일을 저장합니다. (upload.go)
+//
+//       $ curl -F file=@cat.png http://localhost:8097/upload
+//
+//       저장한 파일은 /files/{id}/download 로 내려받습니다. (files.go)
+//       이미지이면 썸네일을 만들어 /files/{id}/thumb/{size} 로 보여줍니다.
+//       큰 파일은 /upload/resumable 로 끊긴 곳부터 이어서 올릴 수 있습니다. (resumable.go)
+//
+//   (2-8) /login 으로 로그인하면 세션 쿠키를 받습니다. /logout 은 로그아웃, /me 는 지금 사용자입니다. (login.go)
+//
+//       $ curl -c jar -d 'username=alice&password=...' http://localhost:8097/login
+//
+//       사용자는 webserver adduser 로 만듭니다. (users.go) /2fa/ 로 2단계 인증을 켤 수 있습니다. (totp.go)
+//
+//   (2-9) 운영용 주소들
+//
+//       /healthz        살아 있는지 (health.go)
+//       /readyz         요청을 받을 준비가 되었는지 (ready.go)
+//       /metrics        Prometheus 메트릭, /stats 는 route별 p50/p95/p99 (metrics.go, stats.go)
+//       /debug/vars     expvar, /debug/pprof/ 는 프로파일 (debug.go)
+//       /debug/runtime  goroutine, heap, GC, 연결 수 (runtimestats.go)
+//       /debug/buildinfo  버전, 커밋, 켜진 기능들 (buildinfo.go)
+//       /admin/usage    API 키(사용자)별 요청 수와 바이트, 하루 단위 (usage.go)
+//       /admin/users    로그인 사용자 목록과 만들기 (users.go)
+//
 //   (3) 다른 페이지는 에러페이지를 출력해줍니다.
+//       에러는 Accept-Language에 따라 한국어 또는 영어로 나옵니다. (messages.go 참고)
 //
 //       URL: http://localhost:8097/other/path
-//       browser :
-//           404 page not found
+//       browser (application/problem+json) :
+//           {"type":"about:blank","title":"Not Found","status":404,"detail":"The requested page could not be found."}
 //
 // 매 방문은 간단한 쿠키를 설정해줍니다. 첫번 째 방문 이후로는 요청을 할 수 있습니다.
+// 설정에서 쿠키 동의 모드를 켜면 /consent 로 동의한 클라이언트에게만 줍니다. (consent.go)
 //
 // AJAX 설정을 하려면, 너는 정보와 submission의
 // 데이터를 URL에 넣기 위한 AJAX 인코드 요청을 결정해야할 것이다.
@@ -71,31 +136,59 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"regexp"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func SetMyCookie(response http.ResponseWriter) {
-	// 응답에 간단한 쿠키를 추가합니다.
-	cookie := http.Cookie{Name: "testcookiename", Value: "testcookievalue"}
-	http.SetCookie(response, &cookie)
+func SetMyCookie(response http.ResponseWriter, request *http.Request) {
+	// 응답에 간단한 쿠키를 추가합니다. 속성은 설정 파일의 "cookies" 를 따릅니다. (cookie.go)
+	// 꼭 필요한 쿠키가 아니므로 동의 모드에서는 동의한 클라이언트에게만 줍니다. (consent.go)
+	SetOptionalCookie(response, request, NewCookie("testcookiename", "testcookievalue"))
+}
+
+// 응답의 Content-Type 헤더를 설정합니다.
+// 모든 핸들러가 이 함수를 사용하므로 항상 "; charset=utf-8"이 붙습니다.
+func SetContentType(response http.ResponseWriter, mimetype string) {
+	response.Header().Set("Content-Type", mimetype+"; charset=utf-8")
+}
+
+// 요청의 Accept 헤더가 mimetype을 직접 명시했는지 확인합니다.
+// "*/*" 같은 와일드카드는 세지 않으므로, 명시하지 않은 클라이언트는 기본 형식(JSON)을 받습니다.
+func AcceptsType(request *http.Request, mimetype string) bool {
+	for _, part := range strings.Split(request.Header.Get("Accept"), ",") {
+		mt, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(mt), mimetype) {
+			continue
+		}
+		// q=0은 "이 형식은 받지 않겠다"는 뜻입니다.
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		f, err := strconv.ParseFloat(q, 64)
+		return err != nil || f > 0
+	}
+	return false
 }
 
 // /generic URL 형식에 대한 응답
 func GenericHandler(response http.ResponseWriter, request *http.Request) {
 
 	// 쿠키를 설정하고 MIME type을 http 헤더에 설정
-	SetMyCookie(response)
-	response.Header().Set("Content-type", "text/plain")
+	SetMyCookie(response, request)
+	SetContentType(response, "text/plain")
 
 	//URL을 Parse하고 POST 데이터를 요청에 포함합니다.
 	err := request.ParseForm()
 	if err != nil {
-		http.Error(response, fmt.Sprintf("error parsing url %v", err), 500)
+		WriteError(response, request, http.StatusInternalServerError, fmt.Errorf("error parsing url %v", err))
+		return
 	}
 
 	//text 진단 결과를 클라이언트에게 전달
@@ -108,36 +201,65 @@ func GenericHandler(response http.ResponseWriter, request *http.Request) {
 }
 
 // /home에 대한 응답으로 html home page를 응답해줌
+// home.html은 html/template으로 서버 시간, item 개수 등을 채워 넣습니다. (templates.go)
+//
+// 같은 페이지를 다시 받지 않도록 ETag 와 Last-Modified 를 붙이고 조건부 GET 에 304 로 답합니다.
+// 페이지는 분 단위의 서버 시간을 보여주므로, 그 분의 시작, item이 바뀐 시각,
+// 서버가 시작한 시각(실행 파일에 들어간 템플릿이 바뀌는 때) 중 늦은 것이 Last-Modified 입니다.
 func HomeHandler(response http.ResponseWriter, request *http.Request) {
-	response.Header().Set("Content-type", "text/html; charset=utf-8") //imdhson 수정함
-	webpage, err := ioutil.ReadFile("home.html")
-	if err != nil {
-		http.Error(response, fmt.Sprintf("home.html file error %v", err), 500)
+	now := time.Now()
+	modified := now.Truncate(time.Minute)
+	for _, t := range []time.Time{store.LastModified(), serverStarted} {
+		if t.After(modified) {
+			modified = t
+		}
+	}
+	// 페이지를 만드는 동안 브라우저가 먼저 받기 시작하도록 home.html 과 layout.html 이 쓰는 파일들을 알려줍니다. (earlyhints.go)
+	SendEarlyHints(response, request, homeHints()...)
+	// 같은 사용자의 요청이 몰리면 페이지를 한 번만 만듭니다. 페이지의 시간은 분 단위이므로 나눠 받아도 같습니다.
+	user := CurrentUser(request)
+	RenderTemplateConditional(response, request, "home", "user="+user, HomePage{
+		ServerTime: now,
+		ItemCount:  store.Len(),
+		User:       user,
+	}, modified)
+}
+
+// /home 이 103 Early Hints 로 알려주는 파일들. 지문이 붙은 주소는 main에서 정해지므로 요청마다 만듭니다.
+func homeHints() []EarlyHint {
+	return []EarlyHint{
+		Preload("css/site.css", "style"),
+		Preload("js/home.js", "script"),
+		Preconnect("http://ajax.googleapis.com"),
 	}
-	fmt.Fprint(response, string(webpage))
 }
 
 // /item/...에 대한 응답
 func ItemHandler(response http.ResponseWriter, request *http.Request) {
 
 	// 쿠키를 설정하고 MIME type을 http 헤더에 설정
-	SetMyCookie(response)
-	response.Header().Set("Content-type", "application/json")
-
-	// URL 형식이 /item/name이 맞는가?
-	var itemURL = regexp.MustCompile(`^/item/(\w+)$`)
-	var itemMatches = itemURL.FindStringSubmatch(request.URL.Path)
-	// itemMatches는 regex 매치로 다음과 같이 작동  ["/item/which", "which"]
-	if len(itemMatches) > 0 {
+	SetMyCookie(response, request)
+	SetContentType(response, "application/json")
+
+	// URL 형식이 /item/name이 맞는가? (item.go 의 itemPathName. 메모리를 할당하지 않습니다.)
+	name, ok := itemPathName(request.URL.Path)
+	response.Header().Add("Vary", "Accept")
+	if ok && AcceptsType(request, GobContentType) {
+		// Go 클라이언트가 gob을 원하면 JSON 대신 gob으로 전송 (item.go 참고)
+		WriteGob(response, request, Item{Name: name, What: "item"})
+	} else if ok && AcceptsType(request, "text/html") {
+		// 브라우저가 직접 방문하면 JSON 대신 HTML 페이지를 보여줌
+		WriteItemHTML(response, request, Item{Name: name, What: "item"})
+	} else if ok {
 		// 참일 경우 JSON을 클라이언트에게 전송
 		data := "This is long JSON data for calculation for bytes."
-		path_j, _ := json.Marshal(itemMatches[1])
+		path_j, _ := json.Marshal(name)
 		data_j, _ := json.Marshal(data)
-		fmt.Fprintf(response, "your request is : %s and link capacity is %d. len is %d\n%s", path_j, json_size(path_j), link_len(itemMatches[1]), data_j)
+		fmt.Fprintf(response, "your request is : %s and link capacity is %d. len is %d\n%s", path_j, json_size(path_j), link_len(name), data_j)
 		fmt.Fprintf(response, "%d\n", json_size((data_j))) //json marshal로 pack한 데이터가 얼마의 크기를 갖는지?
 	} else {
-		// 거짓일 경우 오류 전달
-		http.Error(response, "404 page not found", 404)
+		// 거짓일 경우 클라이언트의 언어로 오류 전달
+		WriteError(response, request, http.StatusNotFound, nil)
 	}
 }
 
@@ -154,22 +276,309 @@ func link_len(in string) int {
 	return len(r)
 }
 
+// 서버가 시작한 시간
+var serverStarted = time.Now()
+
 func main() {
-	port := 8080
-	portstring := strconv.Itoa(port)
+	// webserver adduser 이름 : 사용자를 만들고 끝냅니다. (users.go)
+	if len(os.Args) > 1 && os.Args[1] == "adduser" {
+		if err := AddUserCommand(os.Args[2:], os.Stdin); err != nil {
+			log.Fatal("adduser: ", err)
+		}
+		return
+	}
+	// webserver bench 주소 : 주소에 부하를 주고 처리량과 응답 시간을 보여줍니다. (bench.go)
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := BenchCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("bench: ", err)
+		}
+		return
+	}
+	// webserver benchcmp 옛결과 새결과 : bench -json 결과들을 비교합니다. (bench.go, perf/)
+	if len(os.Args) > 1 && os.Args[1] == "benchcmp" {
+		if err := BenchCompareCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("benchcmp: ", err)
+		}
+		return
+	}
+	configPath := flag.String("config", "", "JSON 설정 파일 경로 (config.go 참고)")
+	dev := flag.Bool("dev", false, "개발 모드: 템플릿을 디스크에서 요청마다 다시 읽음")
+	flag.Parse()
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal("config error: ", err)
+	}
+	if *dev {
+		config.Dev = true
+	}
+	// 서버 로그를 쓸 곳 (logsink.go)
+	closeLog, err := SetupLog(config.Log)
+	if err != nil {
+		log.Fatal("log error: ", err)
+	}
+	defer closeLog()
+	earlyHintsEnabled = config.EarlyHints.Enabled
+	// 컨테이너의 CPU, 메모리 한도에 런타임을 맞춥니다. (container.go)
+	TuneForContainer(config.Container)
+	portstring := strconv.Itoa(config.Port)
 
 	// 요청 핸들러를 두가지의 URL 패턴에 대응하게 생성함
 	//  문서는 pattern 에 대해 확정성이 부족해보임. 그럼 gorilla/mux를 쓰는것이 좋다
 	mux := http.NewServeMux()
 
+	// HTML 템플릿은 시작할 때 한 번만 읽어 둡니다. (개발 모드에서는 요청마다)
+	if config.Dev {
+		log.Print("development mode: templates are reloaded on every request")
+		EnableDevMode(config.DevDir)
+	} else {
+		UseAssetDir(config.DevDir)
+	}
+	minifyConfig = config.Minify
+	if err := config.Cookies.Validate(); err != nil {
+		log.Fatal("cookies error: ", err)
+	}
+	cookieConfig = config.Cookies
+	// gRPC, GraphQL 처럼 미들웨어를 거치지 않고 item 을 쓰는 곳도 같은 규칙을 씁니다. (rbac.go 의 Authorize)
+	accessRules, httpsOnlyRoutes = config.Access, config.HTTPSOnly.Routes
+	// 로그인과 세션 (login.go, session.go)
+	if users, err = OpenUserStore(config.Login.UserFile); err != nil {
+		log.Fatal("users error: ", err)
+	}
+	sessions = NewSessionStore(config.Login)
+	loginLimiter = NewLoginLimiter(config.Login.Lockout)
+	totpIssuer = config.Login.TOTPIssuer
+	// 서명, 암호화된 쿠키 (securecookie.go), API 키 (apikeys.go), 요청 서명 (requestsign.go)의 키.
+	// 비밀 키 파일은 SIGHUP 을 받으면 다시 읽습니다. (secrets.go)
+	requestVerifier = NewRequestVerifier(config.RequestSigning)
+	workers.Close()
+	workers = NewWorkerPool(config.Workers)
+	secrets, err := LoadSecrets(config.Secrets)
+	if err != nil {
+		log.Fatal("secrets error: ", err)
+	}
+	if err := ApplySecrets(config, secrets); err != nil {
+		log.Fatal("secrets error: ", err)
+	}
+	ReloadSecretsOnSignal(config)
+	if config.AuditLog != "" {
+		// 보안 관련 일을 따로 남기는 감사 로그 (audit.go)
+		if audit, err = OpenAuditLog(config.AuditLog); err != nil {
+			log.Fatal("audit log error: ", err)
+		}
+		defer audit.Close()
+	}
+	metaConfig, siteURL = config.Meta, config.SiteURL
+	if err := LoadSitePages(config.Pages); err != nil {
+		log.Fatal("pages error: ", err)
+	}
+	if err := LoadTemplates(); err != nil {
+		log.Fatal("template error: ", err)
+	}
+
+	static, err := NewStaticHandler("/static/", config.Static)
+	if err != nil {
+		log.Fatal("static error: ", err)
+	}
+	staticFiles = static
+
+	favicon, err := NewFaviconHandler(config.Favicon)
+	if err != nil {
+		log.Fatal("favicon error: ", err)
+	}
+
+	robots, err := NewRobotsHandler(config.Robots)
+	if err != nil {
+		log.Fatal("config error: ", err)
+	}
+	securityTxt, err := NewSecurityTxtHandler(config.SecurityTxt, config.SiteURL)
+	if err != nil {
+		log.Fatal("config error: ", err)
+	}
+
+	docs, err := NewDocsHandler("/docs/", config.Docs)
+	if err != nil {
+		log.Fatal("docs error: ", err)
+	}
+
+	files, err = NewFileStore(config.Upload.Dir)
+	if err != nil {
+		log.Fatal("upload error: ", err)
+	}
+	files.Scanner = NewScanner(config.Upload)
+	files.Quota = config.Upload.Quota
+	resumable, err := NewResumableHandler(config.Upload, files)
+	if err != nil {
+		log.Fatal("upload error: ", err)
+	}
+
+	if config.Dev {
+		// 파일을 고치면 브라우저가 새로고침합니다. (livereload.go)
+		liveReload = NewLiveReload()
+		mux.Handle("/__livereload", liveReload)
+		mux.Handle("/__livereload.js", http.HandlerFunc(LiveReloadScriptHandler))
+	}
+
 	mux.Handle("/home", http.HandlerFunc(HomeHandler))
+	mux.Handle("/docs/", docs)
+	mux.Handle("/sitemap.xml", NewSitemapHandler(config.SiteURL, docs))
+	mux.Handle("/robots.txt", robots)
+	if securityTxt != nil {
+		mux.Handle("/.well-known/security.txt", securityTxt)
+		mux.Handle("/security.txt", securityTxt)
+	}
+	mux.Handle("/favicon.ico", favicon)
+	mux.Handle("/static/", static)
+	// 다른 서비스로 넘기는 주소들 (proxy.go)
+	if err := RegisterProxies(mux, config.Proxy); err != nil {
+		log.Fatal("proxy error: ", err)
+	}
 	mux.Handle("/item/", http.HandlerFunc(ItemHandler))
+	mux.Handle("/items", http.HandlerFunc(ItemsHandler))
+	mux.Handle("/items/changes", ItemChangesHandler(config.LongPoll))
+	// items.proto 의 REST 주소들. gRPC 를 켰을 때만 (gateway.go)
+	if err := RegisterGateway(mux, config.GRPC, itemServiceMethods); err != nil {
+		log.Fatal("gateway error: ", err)
+	}
+	mux.Handle("/items/events", http.HandlerFunc(ItemEventsHandler))
+	mux.Handle("/items/ws", ItemSocketHandler(config.WebSocket))
 	mux.Handle("/generic/", http.HandlerFunc(GenericHandler))
+	mux.Handle("/ws", EchoHandler(config.WebSocket))
+	// GraphQL (graphql.go). 개발 모드에서는 GraphiQL 도 보여줍니다.
+	if config.Dev {
+		if err := static.Require(graphiQLAssets...); err != nil {
+			log.Fatal("graphiql error: ", err, " (run ./vendor-assets.sh)")
+		}
+	}
+	mux.Handle("/graphql", GraphQLHandler(config.WebSocket, config.Dev))
+	// API 문서 (openapi.go)
+	openAPI, err := OpenAPIHandler(config.SiteURL, config.GRPC)
+	if err != nil {
+		log.Fatal("openapi error: ", err)
+	}
+	mux.Handle("/openapi.json", openAPI)
+	if err := static.Require(swaggerUIAssets...); err != nil {
+		log.Fatal("openapi error: ", err, " (run ./vendor-assets.sh)")
+	}
+	mux.Handle("/openapi", http.HandlerFunc(SwaggerUIHandler))
+
+	// 채팅방 (chat.go)
+	hub := NewHub()
+	go hub.Run()
+	mux.Handle("/chat", http.HandlerFunc(ChatPageHandler))
+	mux.Handle("/chat/ws", ChatHandler(hub, config.WebSocket))
+	mux.Handle("/presence", http.HandlerFunc(PresenceHandler))
+	mux.Handle("/language", http.HandlerFunc(LanguageHandler))
+	mux.Handle("/consent", http.HandlerFunc(ConsentHandler))
+	mux.Handle("/login", http.HandlerFunc(LoginHandler))
+	mux.Handle("/logout", http.HandlerFunc(LogoutHandler))
+	mux.Handle("/me", http.HandlerFunc(MeHandler))
+	mux.Handle("/2fa/", RequireLogin(http.HandlerFunc(TOTPHandler)))
+	mux.Handle("/healthz", http.HandlerFunc(HealthHandler))
+	readiness.Register("store", StoreCheck(store))
+	readiness.Register("templates", TemplatesCheck())
+	readiness.Register("disk", DiskCheck(config.Upload.Dir))
+	mux.Handle("/readyz", readiness)
+	mux.Handle("/form", http.HandlerFunc(FormHandler))
+	filesHandler := NewFilesHandler(files, config.Upload, NewURLSigner(config.Upload.SigningKey))
+	mux.Handle("/upload", UploadHandler(config.Upload, files, filesHandler))
+	mux.Handle("/files/", filesHandler)
+	mux.Handle("/upload/resumable", resumable)
+	mux.Handle("/upload/resumable/", resumable)
+	mux.Handle("/upload/progress/", http.HandlerFunc(ProgressHandler))
+	mux.Handle("/upload/quota", QuotaHandler(config.Upload, files))
+	if config.Metrics.Enabled {
+		guarded, err := GuardHandler(config.Metrics.GuardConfig, "metrics", metrics)
+		if err != nil {
+			log.Fatal("metrics error: ", err)
+		}
+		mux.Handle("/metrics", guarded)
+		// 같은 설정이므로 에러는 위에서 이미 확인했습니다.
+		stats, _ := GuardHandler(config.Metrics.GuardConfig, "metrics", metrics.Stats)
+		mux.Handle("/stats", stats)
+	}
+	if err := HandleDebug(mux, config.Debug); err != nil {
+		log.Fatal("debug error: ", err)
+	}
+	buildInfo, err := GuardHandler(config.Debug.GuardConfig, "debug", AuditHandler("admin.access", BuildInfoHandler(config)))
+	if err != nil {
+		log.Fatal("debug error: ", err)
+	}
+	mux.Handle("/debug/buildinfo", buildInfo)
+	// API 키별 사용량 (usage.go)
+	usageReport, err := GuardHandler(config.Admin, "admin", AuditHandler("admin.access", http.HandlerFunc(UsageReportHandler)))
+	if err != nil {
+		log.Fatal("admin error: ", err)
+	}
+	mux.Handle("/admin/usage", usageReport)
+	// 사용자 만들기 (users.go)
+	usersAdmin, _ := GuardHandler(config.Admin, "admin", AuditHandler("admin.access", http.HandlerFunc(UsersHandler)))
+	mux.Handle("/admin/users", usersAdmin)
+	HandleSitePages(mux)
+	if config.SPA.Enabled {
+		// 등록되지 않은 주소는 프런트엔드의 index.html로 (spa.go)
+		mux.Handle("/", NewSPAHandler(config.SPA, static))
+	} else {
+		mux.Handle("/", http.HandlerFunc(NotFoundHandler))
+	}
 
 	//  지정된 포트로 서버를 가동하여 listen 시작
 	// (개인적으로 생각하길 서버 이름도 여기서 설정가능 할 것이다.)
+	// Ctrl+C (SIGINT) 나 SIGTERM 을 받으면 연결들을 정리하고 끝납니다. (shutdown.go)
 	log.Print("Listening on port " + portstring + " ... ")
-	err := http.ListenAndServe(":"+portstring, mux)
+	// 5xx 비율 알림 (alerts.go)
+	metrics.Alerts = NewErrorAlerts(config.Alerts)
+	// StatsD 로 보내기 (statsd.go)
+	if metrics.StatsD, err = NewStatsD(config.StatsD); err != nil {
+		log.Fatal("statsd error: ", err)
+	}
+	// 요청 추적 (tracing.go). 꺼져 있으면 tracer는 nil 입니다.
+	tracer = NewTracer(config.Tracing)
+	// 메모리가 한도에 가까우면 급하지 않은 요청을 거절합니다. (memshed.go) GOMEMLIMIT 을 정한 뒤에 시작합니다.
+	if memoryShedder, err = StartMemoryShedder(config.MemoryShed); err != nil {
+		log.Fatal("memory_shed error: ", err)
+	}
+	// 응답 시간을 보고 동시에 처리할 요청 수를 정합니다. (adaptive.go)
+	if concurrencyLimiter, err = NewAdaptiveLimiter(config.Concurrency); err != nil {
+		log.Fatal("concurrency error: ", err)
+	}
+	// 접근 로그 (accesslog.go). 압축한 뒤의 크기를 기록하도록 압축보다 바깥에 둡니다.
+	// panic은 접근 로그와 메트릭이 500 으로 기록하도록 그 안쪽에서 잡습니다. (recover.go)
+	// 메모리나 동시성 제한 때문에 거절한 요청도 접근 로그에 남도록 그 안쪽에서 거절합니다.
+	handler, closeAccessLog, err := AccessLog(config.AccessLog, RecoverHandler(config.CrashDir, MemoryShedHandler(memoryShedder, ConcurrencyLimitHandler(concurrencyLimiter, CompressHandler(config.Compression, BodyCaptureHandler(config.BodyCapture, CSPHandler(config.CSP, ChallengeHandler(config.Challenge, SessionHandler(RequestSigningHandler(requestVerifier, AccessHandler(config.Access, mux)))))))))))
+	if err != nil {
+		log.Fatal("access log error: ", err)
+	}
+	// 사용량은 압축한 뒤 실제로 보낸 크기로 셉니다.
+	handler = UsageHandler(handler)
+	handler = SlowRequestHandler(time.Duration(config.SlowRequestThreshold)*time.Millisecond, handler)
+	handler = TracingHandler(tracer, mux, handler)
+	// https 전용 주소 (https.go). X-Forwarded-Proto 는 헤더를 정리한 뒤에 읽습니다.
+	handler = HTTPSOnlyHandler(config.HTTPSOnly, handler)
+	// 잘못된 헤더는 다른 미들웨어가 읽기 전에 거절하고 정리합니다. (headers.go)
+	if handler, err = HeaderSanitizeHandler(config.Headers, handler); err != nil {
+		log.Fatal("headers error: ", err)
+	}
+	handler = metrics.Handler(mux, handler)
+	// gRPC 요청은 HTTP 미들웨어를 거치지 않고 메서드 이름으로 메트릭을 남깁니다. (grpc.go)
+	if handler, err = GRPCHandler(config.GRPC, handler); err != nil {
+		log.Fatal("grpc error: ", err)
+	}
+	// 요청 ID는 다른 모든 미들웨어의 로그에 들어가도록 가장 바깥에서 붙입니다. (requestid.go)
+	handler = RequestIDHandler(handler)
+	server := &http.Server{Addr: ":" + portstring, Handler: handler, ConnState: connections.Track}
+	if config.GRPC.Enabled {
+		// gRPC 클라이언트는 TLS 없이 HTTP/2 로 바로 연결합니다. (h2c)
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	err = ListenAndServeGracefully(server, time.Duration(config.ShutdownTimeout)*time.Second)
+	// 큐에 남은 썸네일까지 만들고 끝냅니다.
+	workers.Close()
+	tracer.Close()
+	metrics.StatsD.Close()
+	closeAccessLog()
 	if err != nil {
 		log.Fatal("ListenAndServe error: ", err)
 	}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+var (
+	loadTemplatesOnce sync.Once
+	loadTemplatesErr  error
+)
+
+// 템플릿을 한 번만 읽어 둡니다.
+func mustLoadTemplates(tb testing.TB) {
+	tb.Helper()
+	loadTemplatesOnce.Do(func() { loadTemplatesErr = LoadTemplates() })
+	if loadTemplatesErr != nil {
+		tb.Fatal(loadTemplatesErr)
+	}
+}
+
+func TestContentTypeCharset(t *testing.T) {
+	mustLoadTemplates(t)
+	tests := []struct {
+		handler     http.HandlerFunc
+		path        string
+		contentType string
+	}{
+		{GenericHandler, "/generic/", "text/plain; charset=utf-8"},
+		{HomeHandler, "/home", "text/html; charset=utf-8"},
+		{ItemHandler, "/item/green", "application/json; charset=utf-8"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		recorder := httptest.NewRecorder()
+		test.handler.ServeHTTP(recorder, request)
+		if got := recorder.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("GET %s: Content-Type %q, want %q", test.path, got, test.contentType)
+		}
+	}
+}
+
+// handler 로 path 를 GET 하는 것을 b.N 번 잽니다.
+func benchmarkGet(b *testing.B, handler http.Handler, path string, header ...string) {
+	b.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		request.Header.Set(header[i], header[i+1])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		// /home 은 103 Early Hints 를 먼저 쓰므로 recorder 에는 103 이 남습니다.
+		if recorder.Code >= http.StatusBadRequest {
+			b.Fatalf("GET %s: status %d", path, recorder.Code)
+		}
+	}
+}
+
+func BenchmarkItemHandler(b *testing.B) {
+	benchmarkGet(b, http.HandlerFunc(ItemHandler), "/item/green")
+}
@@ -0,0 +1,537 @@
+//
+// websocket.go
+//
+// 외부 라이브러리 없이 구현한 WebSocket(RFC 6455) 서버 쪽입니다.
+//
+// /ws 는 받은 메시지를 그대로 돌려주는 echo 서비스입니다.
+//
+//   브라우저 콘솔에서:
+//     var ws = new WebSocket("ws://localhost:8080/ws");
+//     ws.onmessage = function (e) { console.log(e.data); };
+//     ws.send("안녕");          // => 안녕
+//
+// 다른 사이트의 페이지가 사용자의 쿠키로 연결하지 못하도록 Origin 헤더를 확인합니다.
+// 기본은 같은 호스트에서 온 페이지만 허용하고, 설정 파일의
+// "websocket": {"allowed_origins": ["https://example.com"]} 로 더 허용할 수 있습니다.
+// Origin 헤더가 없는 연결(브라우저가 아닌 클라이언트)은 허용합니다.
+//
+// 서버는 ping_interval 초마다 ping을 보냅니다. 그 뒤 pong_timeout 초 안에 아무 프레임도
+// 오지 않으면 (상대가 사라진 반쯤 열린 연결) ReadMessage가 에러를 돌려주고,
+// 연결을 쓰던 고루틴들이 끝납니다. ping_interval이 0이면 ping을 보내지 않습니다.
+//
+// 연결마다 받는 메시지의 크기(max_message_size 바이트)와 빈도(초당 message_rate 개,
+// 한꺼번에 message_burst 개까지)를 제한합니다. 넘으면 1009(too big) 또는
+// 1008(policy violation) 닫기 프레임을 보내고 연결을 끊습니다.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket 설정
+type WebSocketConfig struct {
+	AllowedOrigins []string `json:"allowed_origins"` // 같은 호스트 말고 더 허용할 Origin들
+	MaxMessageSize int64    `json:"max_message_size"`
+	PingInterval   int      `json:"ping_interval"` // ping을 보내는 간격(초). 0이면 보내지 않습니다.
+	PongTimeout    int      `json:"pong_timeout"`  // ping 뒤에 답을 기다리는 시간(초)
+	MessageRate    float64  `json:"message_rate"`  // 연결 하나가 초당 보낼 수 있는 메시지 수. 0이면 제한하지 않습니다.
+	MessageBurst   int      `json:"message_burst"` // 한꺼번에 보낼 수 있는 메시지 수
+}
+
+// 핸드셰이크에서 쓰는 고정 GUID (RFC 6455 1.3)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// 프레임 종류 (opcode)
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// 닫기 코드 (RFC 6455 7.4.1)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// 상대가 연결을 닫았을 때 ReadMessage가 돌려주는 에러
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// WebSocket 연결 하나
+type WebSocketConn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	maxMessageSize int64
+	Subprotocol    string // 핸드셰이크에서 고른 하위 프로토콜
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	limiter      *messageLimiter // nil이면 빈도를 제한하지 않습니다.
+	done         chan struct{}   // Close 하면 닫혀서 keepalive 고루틴이 끝납니다.
+	closeOnce    sync.Once
+
+	writeMu sync.Mutex // 여러 고루틴이 동시에 프레임을 쓰지 않도록
+	closed  bool       // 닫기 프레임을 보냈는지
+}
+
+// 기본 최대 메시지 크기 (1MB)
+const defaultMaxMessageSize = 1 << 20
+
+// 요청의 Origin이 허용된 것인지 확인합니다.
+func CheckOrigin(request *http.Request, allowed []string) bool {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, request.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimRight(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTP 요청을 WebSocket 연결로 바꿉니다.
+// 실패하면 이미 에러 응답을 보냈으므로 부른 쪽은 그냥 돌아가면 됩니다.
+// subprotocols는 서버가 지원하는 하위 프로토콜이고, 클라이언트가 요청한 것 중 처음 맞는 것을 고릅니다.
+func UpgradeWebSocket(response http.ResponseWriter, request *http.Request, config WebSocketConfig, subprotocols ...string) (*WebSocketConn, error) {
+	if request.Method != "GET" ||
+		!headerContains(request.Header, "Connection", "upgrade") ||
+		!headerContains(request.Header, "Upgrade", "websocket") {
+		response.Header().Set("Upgrade", "websocket")
+		WriteError(response, request, http.StatusUpgradeRequired, nil)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if request.Header.Get("Sec-WebSocket-Version") != "13" {
+		response.Header().Set("Sec-WebSocket-Version", "13")
+		WriteError(response, request, http.StatusBadRequest, nil)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := request.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		WriteError(response, request, http.StatusBadRequest, nil)
+		return nil, errors.New("websocket: bad key")
+	}
+	if !CheckOrigin(request, config.AllowedOrigins) {
+		WriteError(response, request, http.StatusForbidden, nil)
+		return nil, fmt.Errorf("websocket: origin %q not allowed", request.Header.Get("Origin"))
+	}
+
+	var chosen string
+	for _, offered := range headerValues(request.Header, "Sec-WebSocket-Protocol") {
+		for _, supported := range subprotocols {
+			if chosen == "" && offered == supported {
+				chosen = offered
+			}
+		}
+	}
+
+	conn, rw, err := http.NewResponseController(response).Hijack()
+	if err != nil {
+		WriteError(response, request, http.StatusInternalServerError, nil)
+		return nil, err
+	}
+	// 핸드셰이크 동안 서버의 읽기/쓰기 기한이 남아 있지 않도록 풉니다.
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	if chosen != "" {
+		handshake += "Sec-WebSocket-Protocol: " + chosen + "\r\n"
+	}
+	if _, err := rw.WriteString(handshake + "\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	maxSize := config.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxMessageSize
+	}
+	ws := &WebSocketConn{
+		conn:           conn,
+		reader:         rw.Reader,
+		maxMessageSize: maxSize,
+		Subprotocol:    chosen,
+		pingInterval:   time.Duration(config.PingInterval) * time.Second,
+		pongTimeout:    time.Duration(config.PongTimeout) * time.Second,
+		done:           make(chan struct{}),
+	}
+	if config.MessageRate > 0 {
+		ws.limiter = newMessageLimiter(config.MessageRate, config.MessageBurst)
+	}
+	if ws.pingInterval > 0 {
+		if ws.pongTimeout <= 0 {
+			ws.pongTimeout = ws.pingInterval
+		}
+		go ws.keepalive()
+	}
+	go ws.closeOnDrain()
+	return ws, nil
+}
+
+// 서버가 닫히기 시작하면 1001(going away) 닫기 프레임을 보냅니다. (shutdown.go)
+// 클라이언트가 닫기 프레임으로 답하면 ReadMessage가 *CloseError 를 돌려주므로
+// 연결을 쓰던 쪽이 평소처럼 정리합니다.
+func (c *WebSocketConn) closeOnDrain() {
+	select {
+	case <-c.done:
+	case <-draining:
+		c.WriteClose(CloseGoingAway, "server shutting down")
+	}
+}
+
+// 받는 메시지의 빈도를 제한하는 토큰 버킷
+// 초당 rate 개씩 토큰이 차고 (최대 burst 개), 메시지 하나에 토큰 하나를 씁니다.
+type messageLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newMessageLimiter(rate float64, burst int) *messageLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &messageLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// 메시지 하나를 받아도 되면 토큰을 쓰고 true를 돌려줍니다.
+// ReadMessage 한 곳에서만 부르므로 잠금이 필요 없습니다.
+func (l *messageLimiter) allow() bool {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// pingInterval마다 ping을 보냅니다. 보내지 못하면 연결을 끊습니다.
+// 답(pong)은 ReadMessage가 받으면서 읽기 기한을 늘려 줍니다.
+func (c *WebSocketConn) keepalive() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.WriteMessage(OpPing, nil); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// 헤더의 쉼표로 나뉜 값들 중에 value가 있는지 (대소문자 무시)
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range headerValues(header, name) {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// 헤더의 쉼표로 나뉜 값들
+func headerValues(header http.Header, name string) []string {
+	var values []string
+	for _, line := range header.Values(name) {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// 프레임 하나
+type wsFrame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+// 프레임 하나를 읽습니다. 클라이언트가 보낸 프레임은 반드시 마스킹되어 있어야 합니다.
+func (c *WebSocketConn) readFrame() (wsFrame, error) {
+	var frame wsFrame
+	if c.pingInterval > 0 {
+		// 다음 ping의 답이 올 때까지만 기다립니다. 프레임이 올 때마다 늘어납니다.
+		c.conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+	}
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return frame, err
+	}
+	frame.fin = head[0]&0x80 != 0
+	frame.opcode = int(head[0] & 0x0F)
+	if head[0]&0x70 != 0 {
+		return frame, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	}
+	masked := head[1]&0x80 != 0
+	if !masked {
+		return frame, &CloseError{Code: CloseProtocolError, Reason: "client frame not masked"}
+	}
+
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return frame, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return frame, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if frame.opcode >= OpClose && (length > 125 || !frame.fin) {
+		return frame, &CloseError{Code: CloseProtocolError, Reason: "bad control frame"}
+	}
+	if length > c.maxMessageSize {
+		return frame, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return frame, err
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, frame.payload); err != nil {
+		return frame, err
+	}
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+	return frame, nil
+}
+
+// 메시지 하나를 읽습니다. (OpText 또는 OpBinary)
+// 중간에 오는 ping에는 pong으로 답하고, 나뉘어 온 프레임은 하나로 합칩니다.
+// 상대가 닫기 프레임을 보내면 같은 코드로 답한 뒤 *CloseError 를 돌려줍니다.
+// 프로토콜 위반이나 너무 큰 메시지, 올바른 UTF-8 이 아닌 텍스트 메시지(1007)는
+// 닫기 프레임을 보내고 *CloseError 를 돌려줍니다.
+func (c *WebSocketConn) ReadMessage() (opcode int, message []byte, err error) {
+	for {
+		frame, err := c.readFrame()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				c.WriteClose(closeErr.Code, closeErr.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch frame.opcode {
+		case OpPing:
+			if err := c.WriteMessage(OpPong, frame.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			code, reason := CloseNoStatus, ""
+			if len(frame.payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(frame.payload))
+				reason = string(frame.payload[2:])
+			}
+			reply := code
+			if reply == CloseNoStatus {
+				reply = CloseNormal
+			}
+			c.WriteClose(reply, "")
+			return 0, nil, &CloseError{Code: code, Reason: reason}
+		case OpText, OpBinary:
+			if opcode != 0 {
+				c.WriteClose(CloseProtocolError, "expected continuation frame")
+				return 0, nil, &CloseError{Code: CloseProtocolError}
+			}
+			opcode = frame.opcode
+		case OpContinuation:
+			if opcode == 0 {
+				c.WriteClose(CloseProtocolError, "unexpected continuation frame")
+				return 0, nil, &CloseError{Code: CloseProtocolError}
+			}
+		default:
+			c.WriteClose(CloseProtocolError, "unknown opcode")
+			return 0, nil, &CloseError{Code: CloseProtocolError}
+		}
+
+		message = append(message, frame.payload...)
+		if int64(len(message)) > c.maxMessageSize {
+			c.WriteClose(CloseMessageTooBig, "message too big")
+			return 0, nil, &CloseError{Code: CloseMessageTooBig}
+		}
+		if frame.fin {
+			// 나뉘어 온 텍스트는 한 글자가 두 프레임에 걸칠 수 있으므로 다 합친 뒤에 확인합니다.
+			if opcode == OpText && !utf8.Valid(message) {
+				c.WriteClose(CloseInvalidPayload, "invalid utf-8")
+				return 0, nil, &CloseError{Code: CloseInvalidPayload, Reason: "invalid utf-8"}
+			}
+			if c.limiter != nil && !c.limiter.allow() {
+				c.WriteClose(ClosePolicyViolation, "rate limit exceeded")
+				return 0, nil, &CloseError{Code: ClosePolicyViolation, Reason: "rate limit exceeded"}
+			}
+			return opcode, message, nil
+		}
+	}
+}
+
+// 메시지 하나를 프레임 하나로 보냅니다. 서버가 보내는 프레임은 마스킹하지 않습니다.
+func (c *WebSocketConn) WriteMessage(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errors.New("websocket: write after close")
+	}
+	return c.writeFrame(opcode, payload)
+}
+
+// writeMu를 잡은 상태에서 불러야 합니다.
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	header := []byte{0x80 | byte(opcode)}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if c.pongTimeout > 0 {
+		// 받지 않는 상대에게 쓰다가 고루틴이 영원히 멈추지 않도록
+		c.conn.SetWriteDeadline(time.Now().Add(c.pongTimeout))
+	}
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 닫기 프레임을 보냅니다. 두 번째부터는 아무것도 하지 않습니다.
+func (c *WebSocketConn) WriteClose(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.writeFrame(OpClose, append(payload, reason...))
+}
+
+// 닫기 프레임을 보내고 (아직 안 보냈다면) 연결을 끊습니다.
+func (c *WebSocketConn) Close() error {
+	c.WriteClose(CloseNormal, "")
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
+
+// 상대 주소
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage의 에러 중 서버 로그에 남길 만한 것만 남깁니다.
+// 상대가 정상적으로 닫은 경우는 남기지 않습니다. 로그에는 ctx의 요청 ID가 붙습니다. (requestid.go)
+func logReadError(ctx context.Context, prefix string, ws *WebSocketConn, err error) {
+	var closeErr *CloseError
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		Logf(ctx, "%s %s: no pong, closing", prefix, ws.RemoteAddr())
+	case errors.As(err, &closeErr):
+		if closeErr.Code == ClosePolicyViolation || closeErr.Code == CloseMessageTooBig {
+			Logf(ctx, "%s %s: %v", prefix, ws.RemoteAddr(), err)
+		}
+	case !errors.Is(err, io.EOF):
+		Logf(ctx, "%s %s: %v", prefix, ws.RemoteAddr(), err)
+	}
+}
+
+// /ws : 받은 메시지를 그대로 돌려줍니다.
+func EchoHandler(config WebSocketConfig) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ws, err := UpgradeWebSocket(response, request, config)
+		if err != nil {
+			Logf(request.Context(), "ws %s: %v", request.RemoteAddr, err)
+			return
+		}
+		defer ws.Close()
+		defer presence.Join("echo", "")()
+		for {
+			opcode, message, err := ws.ReadMessage()
+			if err != nil {
+				logReadError(request.Context(), "ws", ws, err)
+				return
+			}
+			if err := ws.WriteMessage(opcode, message); err != nil {
+				return
+			}
+		}
+	})
+}
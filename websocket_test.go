@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 클라이언트가 보내는 (마스킹한) 프레임
+func clientFrame(fin bool, opcode int, payload string) []byte {
+	head := byte(opcode)
+	if fin {
+		head |= 0x80
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame := append([]byte{head, 0x80 | byte(len(payload))}, mask[:]...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	return frame
+}
+
+func TestReadMessageUTF8(t *testing.T) {
+	hangul := "안녕" // "안" 은 3바이트입니다.
+	tests := []struct {
+		name   string
+		frames [][]byte
+		opcode int
+		close  int // 서버가 보낸 닫기 코드
+	}{
+		{"text", [][]byte{clientFrame(true, OpText, hangul)}, OpText, CloseNormal},
+		{"character split across fragments", [][]byte{
+			clientFrame(false, OpText, hangul[:2]),
+			clientFrame(true, OpContinuation, hangul[2:]),
+		}, OpText, CloseNormal},
+		{"binary is not checked", [][]byte{clientFrame(true, OpBinary, "\xff")}, OpBinary, CloseNormal},
+		{"invalid text", [][]byte{clientFrame(true, OpText, "a\xffb")}, 0, CloseInvalidPayload},
+		{"truncated character", [][]byte{
+			clientFrame(false, OpText, hangul[:2]),
+			clientFrame(true, OpContinuation, ""),
+		}, 0, CloseInvalidPayload},
+		{"invalid continuation", [][]byte{
+			clientFrame(false, OpText, hangul[:2]),
+			clientFrame(true, OpContinuation, "\xff"),
+		}, 0, CloseInvalidPayload},
+	}
+	for _, test := range tests {
+		server, client := net.Pipe()
+		ws := &WebSocketConn{conn: server, reader: bufio.NewReader(server), maxMessageSize: defaultMaxMessageSize, done: make(chan struct{})}
+		received := make(chan []byte, 1)
+		go func() {
+			for _, frame := range test.frames {
+				client.Write(frame)
+			}
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			data, _ := io.ReadAll(client)
+			received <- data
+		}()
+
+		opcode, _, err := ws.ReadMessage()
+		var closeErr *CloseError
+		switch {
+		case test.close == CloseNormal && (err != nil || opcode != test.opcode):
+			t.Errorf("%s: opcode %d, err %v; want opcode %d", test.name, opcode, err, test.opcode)
+		case test.close != CloseNormal && (!errors.As(err, &closeErr) || closeErr.Code != test.close):
+			t.Errorf("%s: err %v, want close %d", test.name, err, test.close)
+		}
+		ws.Close()
+		if data := <-received; len(data) < 4 || data[0] != 0x80|OpClose || int(binary.BigEndian.Uint16(data[2:4])) != test.close {
+			t.Errorf("%s: server sent % x, want close %d", test.name, data, test.close)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+//
+// workpool.go
+//
+// CPU를 많이 쓰거나 오래 막히는 일(썸네일 만들기, CSV 내보내기)을 정해진 수의 워커에게 맡깁니다.
+// 요청마다 고루틴을 새로 띄우면 업로드가 몰릴 때 고루틴과 메모리가 끝없이 늘어나므로,
+// 일은 큐에 넣고 큐가 가득 차면 더 받지 않습니다. (backpressure)
+//
+//   "workers": {"size": 4, "queue": 100}      size 가 0이면 CPU 수
+//
+//   workers.Submit(func() { ... })            기다리지 않고 맡깁니다. 큐가 가득 차면 ErrPoolFull
+//   err := workers.Do(ctx, func() { ... })    워커가 일을 끝낼 때까지 기다립니다.
+//                                             큐가 가득 찼거나 ctx가 끝나면 기다리지 않고 에러를 돌려줍니다.
+//
+// 지금 일하는 워커 수와 큐의 길이는 /debug/vars 의 "workers" 로 볼 수 있습니다. (debug.go)
+// 서버가 끝날 때 Close 로 큐에 남은 일까지 마칩니다.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// 워커 풀 설정
+type WorkerPoolConfig struct {
+	Size  int `json:"size"`  // 워커 수. 0이면 CPU 수
+	Queue int `json:"queue"` // 기다릴 수 있는 일의 수
+}
+
+// 큐가 가득 차서 일을 받지 못했습니다.
+var ErrPoolFull = errors.New("worker pool: queue is full")
+
+// 정해진 수의 워커가 큐의 일을 차례로 합니다.
+type WorkerPool struct {
+	tasks   chan func()
+	wg      sync.WaitGroup
+	running atomic.Int64
+	dropped atomic.Int64
+
+	closeOnce sync.Once
+}
+
+// 서버 전체가 쓰는 워커 풀. main에서 설정으로 만듭니다.
+var workers = NewWorkerPool(DefaultConfig().Workers)
+
+func NewWorkerPool(config WorkerPoolConfig) *WorkerPool {
+	size := config.Size
+	if size <= 0 {
+		size = runtime.NumCPU()
+	}
+	p := &WorkerPool{tasks: make(chan func(), config.Queue)}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.running.Add(1)
+		task()
+		p.running.Add(-1)
+	}
+}
+
+// task를 큐에 넣고 바로 돌아옵니다. 큐가 가득 차면 ErrPoolFull
+func (p *WorkerPool) Submit(task func()) error {
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		p.dropped.Add(1)
+		return ErrPoolFull
+	}
+}
+
+// task를 워커에게 맡기고 끝날 때까지 기다립니다.
+// 큐가 가득 차면 ErrPoolFull, 일이 시작되기 전에 ctx가 끝나면 ctx의 에러를 돌려줍니다.
+// 일이 시작된 뒤에는 ctx가 끝나도 일이 끝나기를 기다립니다. (task가 응답에 쓰고 있을 수 있습니다.)
+func (p *WorkerPool) Do(ctx context.Context, task func()) error {
+	started := make(chan struct{})
+	done := make(chan struct{})
+	err := p.Submit(func() {
+		close(started)
+		if ctx.Err() == nil {
+			task()
+		}
+		close(done)
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case <-started:
+	case <-ctx.Done():
+		// 아직 큐에 있는 일은 워커가 꺼내도 task를 부르지 않습니다.
+		select {
+		case <-started:
+		default:
+			return ctx.Err()
+		}
+	}
+	<-done
+	return ctx.Err()
+}
+
+// /debug/vars 에 보이는 값들
+func (p *WorkerPool) Stats() map[string]int64 {
+	return map[string]int64{
+		"running": p.running.Load(),
+		"queued":  int64(len(p.tasks)),
+		"dropped": p.dropped.Load(),
+	}
+}
+
+// 새 일을 받지 않고, 큐에 남은 일까지 모두 끝나기를 기다립니다.
+func (p *WorkerPool) Close() {
+	p.closeOnce.Do(func() { close(p.tasks) })
+	p.wg.Wait()
+}